@@ -2,11 +2,21 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"net/netip"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	awsec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/spf13/cobra"
 
@@ -17,10 +27,19 @@ import (
 
 var (
 	// Cache-related flags.
-	cachePath string
-	eniIDs    []string
-	allENIs   bool
-	verbose   bool
+	cachePath     string
+	eniIDs        []string
+	eniFile       string
+	allENIs       bool
+	verbose       bool
+	repair        bool
+	mergeAdjacent bool
+	maxAge        time.Duration
+	exportPath    string
+	importPath    string
+	warmColumn    int
+	warmFile      string
+	warmTop       int
 
 	// Cache-related commands.
 	cacheCmd = &cobra.Command{
@@ -49,6 +68,7 @@ func initCacheCommands() {
 		RunE:  runCacheRefresh,
 	}
 	refreshCmd.Flags().StringSliceVar(&eniIDs, "eni", nil, "ENI IDs to refresh")
+	refreshCmd.Flags().StringVar(&eniFile, "eni-file", "", "File of newline-separated ENI IDs to refresh (- reads stdin)")
 	refreshCmd.Flags().BoolVar(&allENIs, "all", false, "Refresh all ENIs in cache")
 	cacheCmd.AddCommand(refreshCmd)
 
@@ -58,6 +78,7 @@ func initCacheCommands() {
 		Short: "List cached items",
 		RunE:  runCacheList,
 	}
+	listCmd.Flags().DurationVar(&maxAge, "max-age", 0, "Prune prefixes and IP tags older than this before listing (e.g., 720h; 0 keeps everything)")
 	cacheCmd.AddCommand(listCmd)
 
 	// Cache prefixes command
@@ -66,8 +87,76 @@ func initCacheCommands() {
 		Short: "Update cloud provider IP ranges",
 		RunE:  runCachePrefixes,
 	}
+	prefixesCmd.Flags().DurationVar(&maxAge, "max-age", 0, "After updating, prune prefixes and IP tags older than this (e.g., 720h; 0 keeps everything)")
+	prefixesCmd.Flags().BoolVar(&mergeAdjacent, "merge-adjacent", false, "Merge adjacent prefixes with the same cloud and service to shrink the cache")
 	cacheCmd.AddCommand(prefixesCmd)
 
+	// Cache check command
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Verify the cache database, optionally removing corrupt records",
+		Long: `Verify that every cache bucket exists and every record can be decoded.
+
+By default the check is a dry run and never modifies the cache. Pass --repair
+to delete the corrupt records it reports.`,
+		RunE: runCacheCheck,
+	}
+	checkCmd.Flags().BoolVar(&repair, "repair", false, "Delete corrupt records instead of only reporting them")
+	cacheCmd.AddCommand(checkCmd)
+
+	// Cache warm command
+	warmCmd := &cobra.Command{
+		Use:   "warm",
+		Short: "Annotate the IPs and ENIs listed in a column of CSV input",
+		Long: `Read CSV, such as the output of a query run with --format csv, and annotate
+the IPs and ENI IDs found in one of its columns: public IPs are looked up with
+whois and ENIs are refreshed from AWS. Values that are neither, such as the
+header row, are skipped. Only the --top most frequent public IPs are looked up,
+as each whois lookup is slow and rate limited.
+
+  fli raw srcaddr --format csv | fli cache warm --column 1`,
+		RunE: runCacheWarm,
+	}
+	warmCmd.Flags().IntVar(&warmColumn, "column", 1, "Column holding the IPs or ENI IDs, counting from 1")
+	warmCmd.Flags().StringVar(&warmFile, "file", "-", "CSV file to read (- reads stdin)")
+	warmCmd.Flags().IntVar(&warmTop, "top", defaultWarmTop, "Look up at most this many public IPs, the most frequent first (0 for no limit)")
+	cacheCmd.AddCommand(warmCmd)
+
+	// Cache export command
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write all cached annotations to a JSON snapshot",
+		RunE:  runCacheExport,
+	}
+	exportCmd.Flags().StringVar(&exportPath, "out", "", "Snapshot file to write (default: stdout)")
+	cacheCmd.AddCommand(exportCmd)
+
+	// Cache graph command
+	graphCmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Write cached ENIs and their security groups as a Graphviz DOT graph",
+		Long: `Write a Graphviz DOT graph linking each cached ENI to its security groups,
+so ENIs that share a group are connected through it. Render it with Graphviz:
+
+  fli cache graph | dot -Tsvg > enis.svg`,
+		RunE: runCacheGraph,
+	}
+	cacheCmd.AddCommand(graphCmd)
+
+	// Cache import command
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Merge annotations from a JSON snapshot into the cache",
+		Long: `Merge annotations from a snapshot written by "fli cache export".
+
+Entries in the snapshot replace cached entries with the same ENI, IP, or CIDR;
+all other cached entries are kept.`,
+		RunE: runCacheImport,
+	}
+	importCmd.Flags().StringVar(&importPath, "in", "", "Snapshot file to read")
+	_ = importCmd.MarkFlagRequired("in")
+	cacheCmd.AddCommand(importCmd)
+
 	// Cache clean command
 	cleanCmd := &cobra.Command{
 		Use:   "clean",
@@ -97,14 +186,50 @@ func initCachePath() error {
 	return nil
 }
 
+// openWhoisCache opens the cache at path for a command that runs whois lookups,
+// routing them by the whois_servers map of the config file.
+func openWhoisCache(path string) (*cache.Cache, error) {
+	cfg := cache.DefaultConfig().WithCachePath(path)
+	servers, err := configuredWhoisServers()
+	if err != nil {
+		return nil, err
+	}
+	for cidr, server := range servers {
+		cfg.WithWhoisServer(cidr, server)
+	}
+	return cache.OpenWithConfig(cfg)
+}
+
+// configuredWhoisServers returns the whois_servers map of the config file, which
+// is empty when there is no config file.
+func configuredWhoisServers() (map[string]string, error) {
+	cfgPath, err := fliconfig.ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := fliconfig.LoadConfig(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.WhoisServers, nil
+}
+
 // runCacheRefresh implements the cache refresh command.
 func runCacheRefresh(cmd *cobra.Command, _ []string) error {
 	if err := initCachePath(); err != nil {
 		return fmt.Errorf("failed to initialize cache path: %w", err)
 	}
 
+	if eniFile != "" {
+		fileIDs, err := readENIFile(eniFile, os.Stdin)
+		if err != nil {
+			return err
+		}
+		eniIDs = append(eniIDs, fileIDs...)
+	}
+
 	if len(eniIDs) == 0 && !allENIs {
-		return fmt.Errorf("at least one --eni must be provided, or use --all to refresh all cached ENIs")
+		return fmt.Errorf("at least one --eni or --eni-file must be provided, or use --all to refresh all cached ENIs")
 	}
 
 	if verbose {
@@ -112,7 +237,7 @@ func runCacheRefresh(cmd *cobra.Command, _ []string) error {
 			return fmt.Errorf("failed to write to stdout: %w", err)
 		}
 	}
-	cacheObj, err := cache.Open(cachePath)
+	cacheObj, err := openWhoisCache(cachePath)
 	if err != nil {
 		return fmt.Errorf("failed to open cache: %w", err)
 	}
@@ -124,27 +249,259 @@ func runCacheRefresh(cmd *cobra.Command, _ []string) error {
 
 	ctx := cmd.Context()
 	// Load AWS config
-	awsCfg, err := config.LoadDefaultConfig(ctx)
+	awsCfg, err := loadAWSConfig(ctx, flags.awsConfigOptions()...)
 	if err != nil {
-		return fmt.Errorf("failed to load AWS config: %w", err)
+		return err
 	}
 	ec2Svc := awsec2.NewFromConfig(awsCfg)
 	ec2Client := aws.NewEC2Client(ec2Svc)
 
+	var refreshStats cache.RefreshStats
 	if allENIs {
-		if err := cacheObj.RefreshAllENIs(ctx, ec2Client); err != nil {
+		if refreshStats, err = cacheObj.RefreshAllENIs(ctx, ec2Client); err != nil {
 			return fmt.Errorf("failed to refresh all ENIs: %w", err)
 		}
 	} else {
-		if err := cacheObj.RefreshENIs(ctx, ec2Client, eniIDs); err != nil {
+		if refreshStats, err = cacheObj.RefreshENIs(ctx, ec2Client, eniIDs); err != nil {
 			return fmt.Errorf("failed to refresh ENIs: %w", err)
 		}
 	}
 
 	// Whois enrichment for public IPs
-	if err := cacheObj.EnrichIPs(); err != nil {
+	enrichStats, err := cacheObj.EnrichIPs()
+	if err != nil {
 		return fmt.Errorf("failed to enrich IPs: %w", err)
 	}
+
+	if _, err := fmt.Fprintln(os.Stdout, refreshSummary(refreshStats, enrichStats)); err != nil {
+		return fmt.Errorf("failed to write to stdout: %w", err)
+	}
+	return nil
+}
+
+// refreshSummary describes what a cache refresh changed, for example
+// "Refreshed 3 ENIs, removed 1 not found, enriched 2 IPs (1 ENI failed)".
+func refreshSummary(refresh cache.RefreshStats, enrich cache.EnrichStats) string {
+	summary := fmt.Sprintf("Refreshed %s, removed %d not found, enriched %s",
+		plural(refresh.Updated, "ENI"), refresh.Removed, plural(enrich.Enriched, "IP"))
+
+	var problems []string
+	if refresh.Skipped > 0 {
+		problems = append(problems, fmt.Sprintf("%s skipped", plural(refresh.Skipped, "ENI")))
+	}
+	if refresh.Failed > 0 {
+		problems = append(problems, fmt.Sprintf("%s failed", plural(refresh.Failed, "ENI")))
+	}
+	if enrich.Failed > 0 {
+		problems = append(problems, fmt.Sprintf("%s failed", plural(enrich.Failed, "IP")))
+	}
+	if len(problems) > 0 {
+		summary += " (" + strings.Join(problems, ", ") + ")"
+	}
+	return summary
+}
+
+// plural formats n with the noun, adding an "s" unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// eniIDPattern matches an ENI ID such as eni-0123456789abcdef0.
+var eniIDPattern = regexp.MustCompile(`^eni-[0-9a-f]+$`)
+
+// readENIFile reads newline-separated ENI IDs from path, or from stdin when path
+// is "-". Blank lines are skipped; any other line must be an ENI ID.
+func readENIFile(path string, stdin io.Reader) ([]string, error) {
+	r := stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ENI file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		id := strings.TrimSpace(scanner.Text())
+		if id == "" {
+			continue
+		}
+		if !eniIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid ENI ID %q on line %d of %s", id, line, path)
+		}
+		ids = append(ids, id)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ENI file: %w", err)
+	}
+	return ids, nil
+}
+
+// defaultWarmTop is how many public IPs cache warm looks up by default.
+const defaultWarmTop = 100
+
+// warmValues holds the values cache warm read from its input, de-duplicated in
+// the order they first appeared.
+type warmValues struct {
+	IPs      []string
+	ENIs     []string
+	Skipped  int            // values that are neither an IP nor an ENI ID, e.g. the header
+	IPCounts map[string]int // how many times each IP appeared
+}
+
+// topPublicIPs returns up to n of the public IPs, the most frequent first and
+// ties in input order, and how many public IPs there were. A limit of 0 or less
+// returns them all.
+func (v warmValues) topPublicIPs(n int) ([]string, int) {
+	var public []string
+	for _, ip := range v.IPs {
+		if addr, err := netip.ParseAddr(ip); err == nil && !addr.IsPrivate() {
+			public = append(public, ip)
+		}
+	}
+	total := len(public)
+	if n <= 0 || total <= n {
+		return public, total
+	}
+	slices.SortStableFunc(public, func(a, b string) int {
+		return cmp.Compare(v.IPCounts[b], v.IPCounts[a])
+	})
+	return public[:n], total
+}
+
+// readWarmValues reads CSV from r and sorts the values of the given column,
+// counting from 1, into IPs and ENI IDs. Rows too short to have the column are
+// skipped, so a trailing "No results found." line does no harm.
+func readWarmValues(r io.Reader, column int) (warmValues, error) {
+	if column < 1 {
+		return warmValues{}, fmt.Errorf("invalid --column %d: columns count from 1", column)
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	values := warmValues{IPCounts: make(map[string]int)}
+	seen := make(map[string]bool)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return warmValues{}, fmt.Errorf("failed to read CSV input: %w", err)
+		}
+		if len(record) < column {
+			values.Skipped++
+			continue
+		}
+
+		value := strings.TrimSpace(record[column-1])
+		switch {
+		case seen[value]:
+			if _, ok := values.IPCounts[value]; ok {
+				values.IPCounts[value]++
+			}
+			continue
+		case eniIDPattern.MatchString(value):
+			values.ENIs = append(values.ENIs, value)
+		case isIPAddr(value):
+			values.IPs = append(values.IPs, value)
+			values.IPCounts[value] = 1
+		default:
+			values.Skipped++
+			continue
+		}
+		seen[value] = true
+	}
+	return values, nil
+}
+
+// isIPAddr reports whether s is an IPv4 or IPv6 address.
+func isIPAddr(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// cacheWarmer is the part of the cache that cache warm fills.
+type cacheWarmer interface {
+	RefreshENIs(ctx context.Context, eniProvider cache.ENITagProvider, enis []string) (cache.RefreshStats, error)
+	EnrichAddrs(ips []string) cache.EnrichStats
+}
+
+// warmCache refreshes the ENIs and enriches the IPs in values. eniProvider is
+// only used, and so only needs to be non-nil, when there are ENIs to refresh.
+func warmCache(ctx context.Context, warmer cacheWarmer, values warmValues, eniProvider cache.ENITagProvider) (cache.RefreshStats, cache.EnrichStats, error) {
+	var refreshStats cache.RefreshStats
+	if len(values.ENIs) > 0 {
+		var err error
+		if refreshStats, err = warmer.RefreshENIs(ctx, eniProvider, values.ENIs); err != nil {
+			return refreshStats, cache.EnrichStats{}, fmt.Errorf("failed to refresh ENIs: %w", err)
+		}
+	}
+	return refreshStats, warmer.EnrichAddrs(values.IPs), nil
+}
+
+// runCacheWarm implements the cache warm command.
+func runCacheWarm(cmd *cobra.Command, _ []string) error {
+	if err := initCachePath(); err != nil {
+		return fmt.Errorf("failed to initialize cache path: %w", err)
+	}
+
+	r := io.Reader(os.Stdin)
+	if warmFile != "-" {
+		f, err := os.Open(warmFile)
+		if err != nil {
+			return fmt.Errorf("failed to open input: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+	values, err := readWarmValues(r, warmColumn)
+	if err != nil {
+		return err
+	}
+	if len(values.IPs) == 0 && len(values.ENIs) == 0 {
+		return fmt.Errorf("no IPs or ENI IDs found in column %d of the input", warmColumn)
+	}
+	var total int
+	if values.IPs, total = values.topPublicIPs(warmTop); len(values.IPs) < total {
+		fmt.Fprintf(os.Stderr, "Looking up the %d most frequent of %d public IPs; raise --top to look up more\n", len(values.IPs), total)
+	}
+
+	cacheObj, err := openWhoisCache(cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open cache: %w", err)
+	}
+	defer func() {
+		if closeErr := cacheObj.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close cache: %v\n", closeErr)
+		}
+	}()
+
+	// Only ENIs need AWS, so warming IPs works without credentials
+	var eniProvider cache.ENITagProvider
+	if len(values.ENIs) > 0 {
+		awsCfg, err := loadAWSConfig(cmd.Context(), flags.awsConfigOptions()...)
+		if err != nil {
+			return err
+		}
+		eniProvider = aws.NewEC2Client(awsec2.NewFromConfig(awsCfg))
+	}
+
+	refreshStats, enrichStats, err := warmCache(cmd.Context(), cacheObj, values, eniProvider)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(os.Stdout, refreshSummary(refreshStats, enrichStats)); err != nil {
+		return fmt.Errorf("failed to write to stdout: %w", err)
+	}
 	return nil
 }
 
@@ -169,6 +526,10 @@ func runCacheList(cmd *cobra.Command, _ []string) error {
 		}
 	}()
 
+	if err := pruneStaleEntries(cacheObj, maxAge); err != nil {
+		return err
+	}
+
 	output, err := cacheObj.List(cmd.Context())
 	if err != nil {
 		return fmt.Errorf("failed to list cache contents: %w", err)
@@ -180,7 +541,7 @@ func runCacheList(cmd *cobra.Command, _ []string) error {
 }
 
 // runCachePrefixes implements the cache prefixes command.
-func runCachePrefixes(_ *cobra.Command, _ []string) error {
+func runCachePrefixes(cmd *cobra.Command, _ []string) error {
 	if err := initCachePath(); err != nil {
 		return fmt.Errorf("failed to initialize cache path: %w", err)
 	}
@@ -190,7 +551,8 @@ func runCachePrefixes(_ *cobra.Command, _ []string) error {
 			return fmt.Errorf("failed to write to stdout: %w", err)
 		}
 	}
-	cacheObj, err := cache.Open(cachePath)
+	cfg := cache.DefaultConfig().WithCachePath(cachePath).WithMergeAdjacentPrefixes(mergeAdjacent)
+	cacheObj, err := cache.OpenWithConfig(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to open cache at %s: %w", cachePath, err)
 	}
@@ -200,7 +562,148 @@ func runCachePrefixes(_ *cobra.Command, _ []string) error {
 		}
 	}()
 
-	return fmt.Errorf("failed to update prefixes: %w", cacheObj.UpdatePrefixes())
+	if err := cacheObj.UpdatePrefixes(cmd.Context()); err != nil {
+		return fmt.Errorf("failed to update prefixes: %w", err)
+	}
+	return pruneStaleEntries(cacheObj, maxAge)
+}
+
+// pruneStaleEntries deletes cache entries older than maxAge and reports how many
+// were removed. A zero maxAge disables pruning.
+func pruneStaleEntries(cacheObj *cache.Cache, maxAge time.Duration) error {
+	if maxAge <= 0 {
+		return nil
+	}
+	result, err := cacheObj.PruneStale(maxAge)
+	if err != nil {
+		return fmt.Errorf("failed to prune stale entries: %w", err)
+	}
+	if _, err := fmt.Fprintf(os.Stderr, "Pruned %d stale prefixes and %d stale IP tags older than %s\n",
+		result.Prefixes, result.IPs, maxAge); err != nil {
+		return fmt.Errorf("failed to write to stderr: %w", err)
+	}
+	return nil
+}
+
+// runCacheCheck implements the cache check command.
+func runCacheCheck(_ *cobra.Command, _ []string) error {
+	if err := initCachePath(); err != nil {
+		return fmt.Errorf("failed to initialize cache path: %w", err)
+	}
+
+	check := cache.Check
+	if repair {
+		check = func(path string) (*cache.CheckReport, error) {
+			return cache.Repair(path, cache.NewDefaultLogger(true))
+		}
+	}
+
+	report, err := check(cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to check cache: %w", err)
+	}
+	if _, err := fmt.Fprint(os.Stdout, report.String()); err != nil {
+		return fmt.Errorf("failed to write to stdout: %w", err)
+	}
+	if repair {
+		if removed := report.RemovedRecords(); removed > 0 {
+			if _, err := fmt.Fprintf(os.Stdout, "Removed %d corrupt records\n", removed); err != nil {
+				return fmt.Errorf("failed to write to stdout: %w", err)
+			}
+		}
+		if len(report.MissingBuckets) > 0 {
+			return fmt.Errorf("cache check found %d missing buckets", len(report.MissingBuckets))
+		}
+		return nil
+	}
+	if !report.OK() {
+		return fmt.Errorf("cache check found %d corrupt records and %d missing buckets",
+			report.CorruptRecords(), len(report.MissingBuckets))
+	}
+	return nil
+}
+
+// runCacheExport implements the cache export command.
+func runCacheExport(_ *cobra.Command, _ []string) error {
+	if err := initCachePath(); err != nil {
+		return fmt.Errorf("failed to initialize cache path: %w", err)
+	}
+
+	cacheObj, err := cache.Open(cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open cache: %w", err)
+	}
+	defer func() {
+		if closeErr := cacheObj.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close cache: %v\n", closeErr)
+		}
+	}()
+
+	if exportPath == "" {
+		return cacheObj.Export(os.Stdout)
+	}
+
+	var buf bytes.Buffer
+	if err := cacheObj.Export(&buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(exportPath, buf.Bytes(), fliconfig.FilePermissions); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if _, err := fmt.Fprintf(os.Stdout, "Exported cache to %s\n", exportPath); err != nil {
+		return fmt.Errorf("failed to write to stdout: %w", err)
+	}
+	return nil
+}
+
+// runCacheGraph implements the cache graph command.
+func runCacheGraph(_ *cobra.Command, _ []string) error {
+	if err := initCachePath(); err != nil {
+		return fmt.Errorf("failed to initialize cache path: %w", err)
+	}
+
+	cacheObj, err := cache.Open(cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open cache: %w", err)
+	}
+	defer func() {
+		if closeErr := cacheObj.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close cache: %v\n", closeErr)
+		}
+	}()
+
+	return cacheObj.WriteENIGraph(os.Stdout)
+}
+
+// runCacheImport implements the cache import command.
+func runCacheImport(_ *cobra.Command, _ []string) error {
+	if err := initCachePath(); err != nil {
+		return fmt.Errorf("failed to initialize cache path: %w", err)
+	}
+
+	f, err := os.Open(importPath)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	cacheObj, err := cache.Open(cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open cache: %w", err)
+	}
+	defer func() {
+		if closeErr := cacheObj.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close cache: %v\n", closeErr)
+		}
+	}()
+
+	if err := cacheObj.Import(f); err != nil {
+		return fmt.Errorf("failed to import %s: %w", importPath, err)
+	}
+	if _, err := fmt.Fprintf(os.Stdout, "Imported %s into %s\n", importPath, cachePath); err != nil {
+		return fmt.Errorf("failed to write to stdout: %w", err)
+	}
+	return nil
 }
 
 // runCacheClean implements the cache clean command.
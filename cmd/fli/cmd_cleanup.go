@@ -144,9 +144,14 @@ func cleanupSingleProfile(ctx context.Context, cfg *config.Config, state *config
 		}
 	}
 
-	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	// The recorded region wins over --region, since that is where the resources are
+	opts := flags.awsConfigOptions()
+	if region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	awsCfg, err := loadAWSConfig(ctx, opts...)
 	if err != nil {
-		return fmt.Errorf("failed to load AWS config: %w", err)
+		return err
 	}
 
 	ec2Client := ec2.NewFromConfig(awsCfg)
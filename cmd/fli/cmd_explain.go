@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <verb> [fields] [flags]",
+	Short: "Print the Insights query a command would run, without AWS",
+	Long: `Print the CloudWatch Logs Insights query a query command would run, ready
+to paste into the console, without AWS credentials or a log group. The time
+range the query would cover is printed to stderr.
+
+Fields, filters, and flags are validated exactly as they are for a real run.`,
+	Example: `  # Check a filter offline
+  fli explain count --by srcaddr --filter "action=REJECT and dstport=22" --since 1h`,
+	// Flags belong to the explained command, which parses them itself
+	DisableFlagParsing: true,
+	Annotations:        map[string]string{"forward": "true"},
+	RunE:               runExplain,
+}
+
+// runExplain implements the explain command by running the query command in
+// explain mode, which stops once the query is built.
+func runExplain(cmd *cobra.Command, args []string) error {
+	if helpRequested(args) {
+		return cmd.Help()
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("requires a query command, e.g. fli explain count --by srcaddr")
+	}
+	verbCmd, rest, err := findQueryCommand(rootCmd, args)
+	if err != nil {
+		return err
+	}
+	if err := verbCmd.ParseFlags(rest); err != nil {
+		return err
+	}
+	verbArgs := verbCmd.Flags().Args()
+	if err := verbCmd.ValidateArgs(verbArgs); err != nil {
+		return err
+	}
+	if flags.Watch > 0 {
+		return fmt.Errorf("explain cannot be combined with --watch")
+	}
+
+	flags.explain = true
+	verbCmd.SetContext(cmd.Context())
+	if err := rootCmd.PersistentPreRunE(verbCmd, verbArgs); err != nil {
+		return err
+	}
+	return verbCmd.RunE(verbCmd, verbArgs)
+}
+
+// writeExplanation writes the query to w and the time range it covers to errW,
+// so that w holds nothing but the query.
+func writeExplanation(w, errW io.Writer, query string, start, end time.Time) error {
+	if _, err := fmt.Fprintln(w, query); err != nil {
+		return fmt.Errorf("failed to write query: %w", err)
+	}
+	fmt.Fprintf(errW, "Time range: %s to %s\n", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	return nil
+}
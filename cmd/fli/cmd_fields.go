@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+
+	"fli/internal/querybuilder"
+)
+
+var listFieldsCmd = &cobra.Command{
+	Use:   "list-fields",
+	Short: "List the fields of a flow log version",
+	Long: `List the fields available for a flow log version, with the kind of value
+each holds (ip, port, numeric, or categorical) and whether it is computed
+from other fields rather than parsed from the log.
+
+Use --version to pick the version and --schema-file for a custom schema.`,
+	Example: `  # Fields of version 5 flow logs
+  fli list-fields --version 5`,
+	Args: cobra.NoArgs,
+	RunE: runListFields,
+}
+
+// runListFields implements the list-fields command.
+func runListFields(cmd *cobra.Command, _ []string) error {
+	schema, err := querySchema(flags)
+	if err != nil {
+		return err
+	}
+	version := flags.Version
+	if flags.SchemaFile != "" && !cmd.Flags().Changed("version") {
+		version = schema.GetDefaultVersion()
+	}
+	return writeFieldList(os.Stdout, schema, version)
+}
+
+// writeFieldList writes a table of the schema's fields for version, followed
+// by its computed fields. Unsupported versions use the closest older version.
+func writeFieldList(w io.Writer, schema querybuilder.Schema, version int) error {
+	closest := schema.ClosestSupportedVersion(version)
+	if closest == 0 {
+		return fmt.Errorf("invalid version %d: no supported version at or below it", version)
+	}
+	if closest != version {
+		fmt.Fprintf(os.Stderr, "Version %d is not supported, listing version %d\n", version, closest)
+	}
+
+	var rows [][]string
+	for _, field := range schema.Fields(closest) {
+		rows = append(rows, []string{field, querybuilder.KindOf(schema, field).String(), "no"})
+	}
+	for _, field := range schema.ComputedFields() {
+		rows = append(rows, []string{field, querybuilder.KindOf(schema, field).String(), "yes"})
+	}
+
+	headerStyle := lipgloss.NewStyle().Bold(true)
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		Headers("Field", "Kind", "Computed").
+		Rows(rows...).
+		StyleFunc(func(row, _ int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return headerStyle
+			}
+			return lipgloss.NewStyle()
+		})
+
+	if _, err := fmt.Fprintln(w, t); err != nil {
+		return fmt.Errorf("failed to write field list: %w", err)
+	}
+	return nil
+}
@@ -93,9 +93,9 @@ func runInit(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	awsCfg, err := loadAWSConfig(ctx, append(flags.awsConfigOptions(), awsconfig.WithRegion(region))...)
 	if err != nil {
-		return fmt.Errorf("failed to load AWS config: %w", err)
+		return err
 	}
 
 	ec2Client := ec2.NewFromConfig(awsCfg)
@@ -277,7 +277,7 @@ func resolveRegion(ctx context.Context, explicit string) (string, error) {
 		}
 	}
 
-	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, flags.awsConfigOptions()...)
 	if err != nil {
 		return "", fmt.Errorf("no AWS region configured.\n\nSet a region using one of:\n  fli init --region us-east-1\n  export AWS_REGION=us-east-1\n  aws configure set region us-east-1")
 	}
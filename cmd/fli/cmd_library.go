@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+
+	"fli/internal/config"
+)
+
+// Saved query flags.
+var (
+	saveDescription string
+	saveTags        []string
+	saveForce       bool
+	listTag         string
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Manage the saved query library",
+	Long: `Save query command lines under a name and run them again later.
+
+Saved queries are kept as YAML files in ~/.fli/queries, one per query.`,
+}
+
+// initQueryCommands sets up the saved query subcommands.
+func initQueryCommands() {
+	saveCmd := &cobra.Command{
+		Use:   "save <name> -- <verb> [args...]",
+		Short: "Save a query command line under a name",
+		Example: `  # Save a query for rejected SSH attempts
+  fli query save rejected-ssh --tag security -- count --by srcaddr --filter "action=REJECT and dstport=22" --since 1h`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: runQuerySave,
+	}
+	saveCmd.Flags().StringVar(&saveDescription, "description", "", "What the query is for")
+	saveCmd.Flags().StringArrayVar(&saveTags, "tag", nil, "Tag to file the query under (repeatable)")
+	saveCmd.Flags().BoolVar(&saveForce, "force", false, "Replace a saved query with the same name")
+	queryCmd.AddCommand(saveCmd)
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List saved queries",
+		Args:  cobra.NoArgs,
+		RunE:  runQueryList,
+	}
+	listCmd.Flags().StringVar(&listTag, "tag", "", "Only list queries with this tag")
+	queryCmd.AddCommand(listCmd)
+
+	runCmd := &cobra.Command{
+		Use:   "run <name>",
+		Short: "Run a saved query",
+		Long: `Run a saved query. Flags after the name are added to the saved command
+line, so they override the saved values, e.g. "fli query run rejected-ssh --since 6h".`,
+		// Flags belong to the saved query's command, which parses them itself
+		DisableFlagParsing: true,
+		Annotations:        map[string]string{"forward": "true"},
+		RunE:               runQueryRun,
+	}
+	queryCmd.AddCommand(runCmd)
+}
+
+// findQueryCommand resolves a saved command line to its query command and the
+// remaining arguments, rejecting anything that is not a query verb.
+func findQueryCommand(root *cobra.Command, args []string) (*cobra.Command, []string, error) {
+	cmd, rest, err := root.Find(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cmd.Annotations["query"] != "true" {
+		return nil, nil, fmt.Errorf("%q is not a query command", strings.Join(args, " "))
+	}
+	return cmd, rest, nil
+}
+
+// helpRequested reports whether args hold -h or --help before any "--". Commands
+// that forward their arguments disable flag parsing, so cobra does not see them.
+func helpRequested(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--":
+			return false
+		case "-h", "--help":
+			return true
+		}
+	}
+	return false
+}
+
+// runQuerySave implements the query save command.
+func runQuerySave(cmd *cobra.Command, args []string) error {
+	if cmd.ArgsLenAtDash() != 1 {
+		return fmt.Errorf("separate the query from the name with --, e.g. fli query save <name> -- count --by srcaddr")
+	}
+	name, queryArgs := args[0], args[1:]
+
+	verbCmd, rest, err := findQueryCommand(rootCmd, queryArgs)
+	if err != nil {
+		return err
+	}
+	if err := verbCmd.ParseFlags(rest); err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+
+	dir, err := config.QueriesDir()
+	if err != nil {
+		return err
+	}
+	q := config.SavedQuery{Name: name, Description: saveDescription, Tags: saveTags, Args: queryArgs}
+	if err := config.SaveQuery(dir, q, saveForce); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved query %s\n", name)
+	return nil
+}
+
+// runQueryList implements the query list command.
+func runQueryList(_ *cobra.Command, _ []string) error {
+	dir, err := config.QueriesDir()
+	if err != nil {
+		return err
+	}
+	queries, err := config.ListQueries(dir)
+	if err != nil {
+		return err
+	}
+	queries = filterQueriesByTag(queries, listTag)
+	if len(queries) == 0 {
+		fmt.Fprintln(os.Stderr, "No saved queries. Save one with \"fli query save\".")
+		return nil
+	}
+	return writeQueryList(os.Stdout, queries)
+}
+
+// filterQueriesByTag returns the queries tagged with tag, or all of them when
+// tag is empty.
+func filterQueriesByTag(queries []config.SavedQuery, tag string) []config.SavedQuery {
+	if tag == "" {
+		return queries
+	}
+	return slices.DeleteFunc(slices.Clone(queries), func(q config.SavedQuery) bool {
+		return !q.HasTag(tag)
+	})
+}
+
+// writeQueryList writes a table of saved queries with their tags, description,
+// and command line.
+func writeQueryList(w io.Writer, queries []config.SavedQuery) error {
+	rows := make([][]string, 0, len(queries))
+	for _, q := range queries {
+		rows = append(rows, []string{q.Name, strings.Join(q.Tags, ", "), q.Description, commandLine(q.Args)})
+	}
+
+	headerStyle := lipgloss.NewStyle().Bold(true)
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		Headers("Name", "Tags", "Description", "Command").
+		Rows(rows...).
+		StyleFunc(func(row, _ int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return headerStyle
+			}
+			return lipgloss.NewStyle()
+		})
+
+	if _, err := fmt.Fprintln(w, t); err != nil {
+		return fmt.Errorf("failed to write query list: %w", err)
+	}
+	return nil
+}
+
+// commandLine renders saved arguments as a fli command line, quoting any
+// argument that would not survive the shell as is.
+func commandLine(args []string) string {
+	quoted := make([]string, 0, len(args)+1)
+	quoted = append(quoted, "fli")
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t'\"$*?|&;<>()") {
+			arg = strconv.Quote(arg)
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// runQueryRun implements the query run command. It runs the saved command line
+// through the query command it names, as if it had been typed.
+func runQueryRun(cmd *cobra.Command, args []string) error {
+	if helpRequested(args) {
+		return cmd.Help()
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("requires the name of a saved query")
+	}
+	dir, err := config.QueriesDir()
+	if err != nil {
+		return err
+	}
+	q, err := config.LoadQuery(dir, args[0])
+	if err != nil {
+		return err
+	}
+
+	verbCmd, rest, err := findQueryCommand(rootCmd, append(slices.Clone(q.Args), args[1:]...))
+	if err != nil {
+		return fmt.Errorf("invalid saved query %s: %w", q.Name, err)
+	}
+	if err := verbCmd.ParseFlags(rest); err != nil {
+		return fmt.Errorf("invalid saved query %s: %w", q.Name, err)
+	}
+	verbArgs := verbCmd.Flags().Args()
+	if err := verbCmd.ValidateArgs(verbArgs); err != nil {
+		return fmt.Errorf("invalid saved query %s: %w", q.Name, err)
+	}
+
+	verbCmd.SetContext(cmd.Context())
+	if err := rootCmd.PersistentPreRunE(verbCmd, verbArgs); err != nil {
+		return err
+	}
+	return verbCmd.RunE(verbCmd, verbArgs)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"fli/internal/querybuilder"
+)
+
+// parseFilterTree selects the tree view of the parse-filter command.
+var parseFilterTree bool
+
+var parseFilterCmd = &cobra.Command{
+	Use:   "parse-filter <filter>",
+	Short: "Parse a filter and print the expression it becomes",
+	Long: `Parse a --filter expression exactly as a query would and print the
+Insights filter it becomes, without AWS credentials or a log group. Use it to
+check how and/or, parentheses, and field-specific values such as CIDRs and
+protocol names are interpreted.
+
+Fields are validated against --version (or the --schema-file schema), and
+--tree prints the expression as a tree of its and/or/not nodes.`,
+	Example: `  # Check operator precedence
+  fli parse-filter "srcaddr like 10 and (dstport = 80 or dstport = 443)" --tree`,
+	Args: cobra.ExactArgs(1),
+	RunE: runParseFilter,
+}
+
+func initParseFilterCommand() {
+	parseFilterCmd.Flags().BoolVar(&parseFilterTree, "tree", false, "Print the expression as a tree")
+}
+
+// runParseFilter implements the parse-filter command.
+func runParseFilter(cmd *cobra.Command, args []string) error {
+	schema, err := querySchema(flags)
+	if err != nil {
+		return err
+	}
+	version := flags.Version
+	if flags.SchemaFile != "" && !cmd.Flags().Changed("version") {
+		version = schema.GetDefaultVersion()
+	}
+	return writeParsedFilter(os.Stdout, args[0], schema, version, parseFilterTree)
+}
+
+// writeParsedFilter parses filter for version and writes the Insights expression
+// it becomes to w, or its tree when tree is set.
+func writeParsedFilter(w io.Writer, filter string, schema querybuilder.Schema, version int, tree bool) error {
+	expr, err := querybuilder.ParseFilterForVersion(filter, schema, version)
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+	if expr == nil && strings.TrimSpace(filter) == "" {
+		return fmt.Errorf("invalid filter: the filter is empty")
+	}
+
+	var out strings.Builder
+	if expr == nil {
+		// Every clause was dropped, e.g. protocol = all
+		out.WriteString("(no filter)\n")
+	} else if tree {
+		writeExprTree(&out, expr, 0)
+	} else {
+		out.WriteString(expr.String() + "\n")
+	}
+	if _, err := fmt.Fprint(w, out.String()); err != nil {
+		return fmt.Errorf("failed to write filter: %w", err)
+	}
+	return nil
+}
+
+// writeExprTree writes one line per node of expr, children indented under the
+// and, or, and not nodes that combine them.
+func writeExprTree(out *strings.Builder, expr querybuilder.Expr, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch x := expr.(type) {
+	case *querybuilder.And:
+		out.WriteString(indent + "and\n")
+		for _, sub := range *x {
+			writeExprTree(out, sub, depth+1)
+		}
+	case *querybuilder.Or:
+		out.WriteString(indent + "or\n")
+		for _, sub := range *x {
+			writeExprTree(out, sub, depth+1)
+		}
+	case *querybuilder.NotExpr:
+		out.WriteString(indent + "not\n")
+		writeExprTree(out, x.Expr, depth+1)
+	default:
+		out.WriteString(indent + expr.String() + "\n")
+	}
+}
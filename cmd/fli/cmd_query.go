@@ -93,3 +93,52 @@ Examples:
   fli max packets --filter "dstport = 443" --since 1h`,
 	RunE: runVerb(querybuilder.VerbMax),
 }
+
+var pctCmd = &cobra.Command{
+	Use:     "pct <field...>",
+	Aliases: []string{"p50", "p75", "p90", "p95", "p99", "p99.9", "pct50", "pct75", "pct90", "pct95", "pct99"},
+	Short:   "Compute a percentile of numeric fields, grouped by optional fields",
+	Long: `Compute a percentile of numeric fields (e.g., bytes, packets), optionally grouped by specified fields.
+The percentile is taken from the command name: "pct" alone computes the 95th percentile,
+while aliases such as p99 or pct90 select another one.
+
+Examples:
+  # 95th percentile of bytes by source address
+  fli pct bytes --by srcaddr --since 1h
+
+  # 99th percentile of packets for HTTPS traffic
+  fli p99 packets --filter "dstport = 443" --since 1h`,
+	RunE: runVerb(querybuilder.VerbPct),
+}
+
+var distinctCmd = &cobra.Command{
+	Use:     "distinct <field...>",
+	Aliases: []string{"count_distinct"},
+	Short:   "Count distinct values of fields, grouped by optional fields",
+	Long: `Count the distinct values of fields (e.g., srcaddr, dstport), optionally grouped by specified fields.
+
+Examples:
+  # Number of unique talkers
+  fli distinct srcaddr --since 1h
+
+  # Unique destination ports per source address
+  fli distinct dstport --by srcaddr --since 1h`,
+	RunE: runVerb(querybuilder.VerbCountDistinct),
+}
+
+var fanoutCmd = &cobra.Command{
+	Use:   fanoutCommand + " <field>",
+	Short: "Ratio of distinct values of a field to flows, grouped by optional fields",
+	Long: `Count the distinct values of a field and the flows in each group, and add a
+fanout column holding their ratio. A source that reaches many distinct ports
+with few flows each, such as a port scanner, has a fanout close to 1.
+
+Examples:
+  # Distinct destination ports per flow for each source address
+  fli fanout dstport --by srcaddr --since 1h
+
+  # Sources that spread flows across the most destination addresses
+  fli fanout dstaddr --by srcaddr --sort-output fanout:desc`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVerb(querybuilder.VerbCountDistinct),
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	fliaws "fli/internal/aws"
 	"fli/internal/config"
 )
 
@@ -28,6 +32,7 @@ var defaultTimeouts = config.DefaultTimeouts()
 // Valid format values.
 var validFormats = map[string]bool{
 	"table": true,
+	"wide":  true,
 	"csv":   true,
 	"json":  true,
 }
@@ -38,8 +43,11 @@ var (
 
 	// queryVerbs are the commands that execute a query.
 	queryVerbs = []*cobra.Command{
-		rawCmd, countCmd, sumCmd, avgCmd, minCmd, maxCmd,
+		rawCmd, countCmd, sumCmd, avgCmd, minCmd, maxCmd, pctCmd, distinctCmd, fanoutCmd,
 	}
+
+	// cancelTimeout releases the deadline set by --timeout once the command returns.
+	cancelTimeout context.CancelFunc = func() {}
 )
 
 // rootCmd represents the base command when called without any subcommands.
@@ -59,9 +67,15 @@ Examples:
   # Raw query with filter
   fli raw srcaddr,dstaddr,bytes --filter "bytes > 1000"`,
 	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		// Commands that forward their arguments to a query command run this hook for
+		// that command once its flags, such as --profile, are parsed
+		if cmd.Annotations["forward"] == "true" {
+			return nil
+		}
+
 		// Check for environment variables
-		if envLogGroup := os.Getenv("FLI_LOG_GROUP"); envLogGroup != "" && flags.LogGroup == "" {
-			flags.LogGroup = envLogGroup
+		if envLogGroup := os.Getenv("FLI_LOG_GROUP"); envLogGroup != "" && len(flags.LogGroups) == 0 {
+			flags.LogGroups = []string{envLogGroup}
 		}
 
 		// Track if --version was explicitly passed before profile resolution
@@ -70,23 +84,36 @@ Examples:
 		}
 
 		// Profile-based resolution (only when log group not already set)
-		if flags.LogGroup == "" {
+		if len(flags.LogGroups) == 0 {
 			resolveProfileFlags()
 		}
 
+		// Bound the whole command by the --timeout deadline
+		flags.Timeout = commandTimeout(cmd, flags.Timeout)
+		cancelTimeout = applyTimeout(cmd, flags.Timeout)
+
 		// Only validate format and version for query commands. We identify query
 		// commands by checking for a "query" annotation.
 		if cmd.Annotations["query"] == "true" {
-			// Ensure log group is available for query commands
-			if flags.LogGroup == "" {
+			// Ensure log group is available for query commands; explain never
+			// reaches AWS, so it does not need one
+			if len(flags.LogGroups) == 0 && !flags.explain {
 				return fmt.Errorf("log group is required. Set it with --log-group, --profile, FLI_LOG_GROUP env, or run \"fli init\"")
 			}
 
 			if format := cmd.Flag("format").Value.String(); !validFormats[format] {
-				return fmt.Errorf("invalid format %q: must be one of: table, csv, json", format)
+				return fmt.Errorf("invalid format %q: must be one of: table, wide, csv, json", format)
 			}
-			if version := cmd.Flag("version").Value.String(); version != "2" && version != "5" {
-				return fmt.Errorf("invalid version %q: must be 2 or 5", version)
+			// Unsupported versions newer than the oldest one are downgraded with a warning
+			schema, err := querySchema(flags)
+			if err != nil {
+				return err
+			}
+			if flags.SchemaFile != "" && !flags.versionExplicitlySet {
+				flags.Version = schema.GetDefaultVersion()
+			}
+			if schema.ClosestSupportedVersion(flags.Version) == 0 {
+				return fmt.Errorf("invalid version %d: no supported version at or below it", flags.Version)
 			}
 		}
 
@@ -94,7 +121,39 @@ Examples:
 	},
 }
 
-// resolveProfileFlags loads the profile config and sets flags.LogGroup and flags.Version
+// commandTimeout returns the deadline for cmd: the --timeout value when set, the
+// default query timeout for query commands other than --watch, and no deadline
+// (0) otherwise.
+func commandTimeout(cmd *cobra.Command, timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	if cmd.Annotations["query"] == "true" {
+		// A watch runs until interrupted, and bounds each run by the query timeout instead
+		if watch, err := cmd.Flags().GetDuration("watch"); err == nil && watch > 0 {
+			return 0
+		}
+		return defaultTimeouts.Query
+	}
+	return 0
+}
+
+// applyTimeout replaces the command's context with one that expires after timeout
+// and returns its cancel function. A non-positive timeout leaves the context as is.
+func applyTimeout(cmd *cobra.Command, timeout time.Duration) context.CancelFunc {
+	if timeout <= 0 {
+		return func() {}
+	}
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	cmd.SetContext(ctx)
+	return cancel
+}
+
+// resolveProfileFlags loads the profile config and sets flags.LogGroups and flags.Version
 // from the resolved profile. This is called when --log-group and FLI_LOG_GROUP are not set.
 func resolveProfileFlags() {
 	cfgPath, err := config.ConfigPath()
@@ -113,7 +172,7 @@ func resolveProfileFlags() {
 		return
 	}
 
-	flags.LogGroup = profile.LogGroup
+	flags.LogGroups = []string{profile.LogGroup}
 
 	// Only override version if it wasn't explicitly set on the command line
 	if !flags.versionExplicitlySet {
@@ -130,11 +189,31 @@ func Execute() {
 	// Add all commands to the root command
 	AddCommands()
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	err := rootCmd.Execute()
+	cancelTimeout()
+	if err != nil {
+		os.Exit(exitCode(err))
 	}
 }
 
+// Exit statuses other than 0 for success.
+const (
+	// exitFailure is the status for any error without a more specific status.
+	exitFailure = 1
+
+	// exitNoCredentials is the status when no AWS credentials could be found,
+	// so scripts can tell a configuration problem from a failed query.
+	exitNoCredentials = 3
+)
+
+// exitCode returns the exit status for an error returned by a command.
+func exitCode(err error) int {
+	if errors.Is(err, fliaws.ErrNoCredentials) {
+		return exitNoCredentials
+	}
+	return exitFailure
+}
+
 // AddCommands adds all the commands to the root command.
 func AddCommands() {
 	// Add query verbs
@@ -158,6 +237,15 @@ func AddCommands() {
 	initProfileCommands()
 	rootCmd.AddCommand(profileCmd)
 
+	rootCmd.AddCommand(listFieldsCmd)
+	rootCmd.AddCommand(explainCmd)
+
+	initParseFilterCommand()
+	rootCmd.AddCommand(parseFilterCmd)
+
+	initQueryCommands()
+	rootCmd.AddCommand(queryCmd)
+
 	// Add completion command
 	rootCmd.AddCommand(completionCmd)
 
@@ -1,14 +1,34 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/spf13/cobra"
+
+	fliaws "fli/internal/aws"
+	"fli/internal/querybuilder"
 )
 
+// logGroupCompletionTimeout bounds the AWS calls made to complete --log-group, so
+// that a slow network or credential lookup never blocks the shell for long.
+const logGroupCompletionTimeout = 2 * time.Second
+
+// newLogGroupClient creates the client used to complete --log-group, honoring
+// --region and --aws-profile. It is a variable so tests can replace it.
+var newLogGroupClient = func(ctx context.Context) (fliaws.CloudWatchLogsManagementAPI, error) {
+	cfg, err := loadAWSConfig(ctx, flags.awsConfigOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	return cloudwatchlogs.NewFromConfig(cfg), nil
+}
+
 // completionCmd represents the completion command.
 var completionCmd = &cobra.Command{
 	Use:   "completion [bash|zsh|fish|powershell]",
@@ -103,39 +123,21 @@ func fieldCompletion(cmd *cobra.Command, _ []string, toComplete string) ([]strin
 	return matches, cobra.ShellCompDirectiveNoFileComp
 }
 
-// getFieldsForVersion returns the list of valid fields for a given VPC Flow Logs version.
+// getFieldsForVersion returns the list of valid fields for a given VPC Flow Logs version,
+// followed by the schema's computed fields. Unsupported versions use the closest
+// older version, or the default version if there is none.
 func getFieldsForVersion(version int) []string {
-	// Get fields based on version
-	switch version {
-	case 2:
-		return []string{
-			"version", "account_id", "interface_id", "srcaddr", "dstaddr",
-			"srcport", "dstport", "protocol", "packets", "bytes",
-			"start", "end", "action", "log_status", "duration",
-		}
-	case 3, 5:
-		return []string{
-			"version", "account_id", "interface_id", "srcaddr", "dstaddr",
-			"srcport", "dstport", "protocol", "packets", "bytes",
-			"start", "end", "action", "log_status", "vpc_id", "subnet_id",
-			"instance_id", "tcp_flags", "type", "pkt_srcaddr", "pkt_dstaddr",
-			"region", "az_id", "sublocation_type", "sublocation_id",
-			"pkt_src_aws_service", "pkt_dst_aws_service", "flow_direction",
-			"traffic_path", "duration",
-		}
-	default:
-		// Return v2 fields as fallback
-		return []string{
-			"version", "account_id", "interface_id", "srcaddr", "dstaddr",
-			"srcport", "dstport", "protocol", "packets", "bytes",
-			"start", "end", "action", "log_status", "duration",
-		}
+	schema := &querybuilder.VPCFlowLogsSchema{}
+	closest := schema.ClosestSupportedVersion(version)
+	if closest == 0 {
+		closest = schema.GetDefaultVersion()
 	}
+	return append(schema.Fields(closest), schema.ComputedFields()...)
 }
 
 // formatCompletion provides completion for output format options.
 func formatCompletion(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	formats := []string{"table", "csv", "json"}
+	formats := []string{"table", "wide", "csv", "json"}
 	var matches []string
 	for _, format := range formats {
 		if strings.HasPrefix(format, toComplete) {
@@ -173,6 +175,28 @@ func versionCompletion(_ *cobra.Command, _ []string, toComplete string) ([]strin
 	return matches, cobra.ShellCompDirectiveNoFileComp
 }
 
+// logGroupCompletion provides completion for log group names by listing the
+// groups that start with the typed prefix. Any failure, such as missing
+// credentials, silently gives no completions.
+func logGroupCompletion(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, logGroupCompletionTimeout)
+	defer cancel()
+
+	client, err := newLogGroupClient(ctx)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names, err := fliaws.ListLogGroupNames(ctx, client, toComplete, 50)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
 // setupQueryCommandCompletion configures completion for query commands.
 func setupQueryCommandCompletion(cmd *cobra.Command) {
 	// Set up field completion for positional arguments
@@ -202,10 +226,16 @@ func setupQueryCommandCompletion(cmd *cobra.Command) {
 func setupRootCommandCompletion(cmd *cobra.Command) {
 	// Set up flag completion for persistent flags
 	// Only register completion for flags that exist
-	if cmd.Flags().Lookup("version") != nil {
+	if cmd.PersistentFlags().Lookup("version") != nil {
 		err := cmd.RegisterFlagCompletionFunc("version", versionCompletion)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error setting up version completion: %v\n", err)
 		}
 	}
+	if cmd.PersistentFlags().Lookup("log-group") != nil {
+		err := cmd.RegisterFlagCompletionFunc("log-group", logGroupCompletion)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting up log group completion: %v\n", err)
+		}
+	}
 }
@@ -1,11 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwltypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/spf13/cobra"
+
+	"fli/internal/aws"
+	"fli/internal/cache"
+	"fli/internal/config"
+	"fli/internal/formatter"
 	"fli/internal/querybuilder"
+	"fli/internal/runner"
 )
 
 // mockSchema wraps the real schema to provide a simplified parse pattern for tests.
@@ -18,6 +36,11 @@ func (m *mockSchema) GetParsePattern(version int) (string, error) {
 	return "parse @message 'mock_pattern'", nil
 }
 
+// GetFieldsParsePattern returns the same pattern for queries that read only some fields.
+func (m *mockSchema) GetFieldsParsePattern(version int, _ []string) (string, error) {
+	return m.GetParsePattern(version)
+}
+
 func TestBuildCommandOptions(t *testing.T) {
 	schema := &mockSchema{}
 
@@ -111,6 +134,76 @@ func TestBuildCommandOptions(t *testing.T) {
 			expectErr:      true,
 			expectedErrStr: "invalid verb 'delete'",
 		},
+		{
+			name:       "percentile token",
+			args:       []string{"p99", "bytes"},
+			setupFlags: resetFlags,
+			expectedQuery: "parse @message 'mock_pattern'" +
+				" | stats pct(bytes, 99) as bytes_p99" +
+				" | sort bytes_p99 desc" +
+				" | limit 100",
+			expectErr: false,
+		},
+		{
+			name:       "distinct on non-numeric field",
+			args:       []string{"distinct", "srcaddr"},
+			setupFlags: resetFlags,
+			expectedQuery: "parse @message 'mock_pattern'" +
+				" | stats count_distinct(srcaddr) as srcaddr_distinct" +
+				" | sort srcaddr_distinct desc" +
+				" | limit 100",
+			expectErr: false,
+		},
+		{
+			name:           "distinct without a field",
+			args:           []string{"count_distinct"},
+			setupFlags:     resetFlags,
+			expectErr:      true,
+			expectedErrStr: `verb "distinct" requires at least one field`,
+		},
+		{
+			name:           "percentile with non-numeric field",
+			args:           []string{"pct95", "srcaddr"},
+			setupFlags:     resetFlags,
+			expectErr:      true,
+			expectedErrStr: `field "srcaddr" must be numeric for verb "pct"`,
+		},
+		{
+			name: "sum ascending",
+			args: []string{"sum", "bytes"},
+			setupFlags: func() {
+				resetFlags()
+				flags.Sort = "asc"
+			},
+			expectedQuery: "parse @message 'mock_pattern'" +
+				" | stats sum(bytes) as bytes_sum" +
+				" | sort bytes_sum asc" +
+				" | limit 100",
+			expectErr: false,
+		},
+		{
+			name: "sum sorted by secondary aggregation",
+			args: []string{"sum", "bytes,packets"},
+			setupFlags: func() {
+				resetFlags()
+				flags.SortBy = "packets_sum"
+			},
+			expectedQuery: "parse @message 'mock_pattern'" +
+				" | stats sum(bytes) as bytes_sum, sum(packets) as packets_sum" +
+				" | sort packets_sum desc" +
+				" | limit 100",
+			expectErr: false,
+		},
+		{
+			name: "invalid sort direction",
+			args: []string{"count"},
+			setupFlags: func() {
+				resetFlags()
+				flags.Sort = "sideways"
+			},
+			expectErr:      true,
+			expectedErrStr: "invalid --sort",
+		},
 		// Multi-field aggregation tests
 		{
 			name:       "count with multiple fields",
@@ -278,6 +371,50 @@ func TestBuildCommandOptions(t *testing.T) {
 				" | limit 100",
 			expectErr: false,
 		},
+		{
+			name: "group by computed field",
+			args: []string{"sum", "bytes"},
+			setupFlags: func() {
+				resetFlags()
+				flags.By = "srcaddr, duration"
+			},
+			expectedQuery: "parse @message 'mock_pattern'" +
+				" | stats sum(bytes) as bytes_sum by srcaddr, end - start as duration" +
+				" | sort bytes_sum desc" +
+				" | limit 100",
+			expectErr: false,
+		},
+		{
+			name: "group by unknown field",
+			args: []string{"count"},
+			setupFlags: func() {
+				resetFlags()
+				flags.By = "srcaddr,bogus"
+			},
+			expectErr:      true,
+			expectedErrStr: "invalid --by: invalid field 'bogus' for version 2",
+		},
+		{
+			name: "group by field missing from version",
+			args: []string{"count"},
+			setupFlags: func() {
+				resetFlags()
+				flags.By = "vpc_id"
+			},
+			expectErr:      true,
+			expectedErrStr: "invalid --by: invalid field 'vpc_id' for version 2",
+		},
+		{
+			name: "filter on field missing from version",
+			args: []string{"count"},
+			setupFlags: func() {
+				resetFlags()
+				flags.Version = 2
+				flags.Filter = "vpc_id = 'vpc-123'"
+			},
+			expectErr:      true,
+			expectedErrStr: "invalid field 'vpc_id' for version 2",
+		},
 		// Debug test to understand the issue
 		{
 			name:       "debug: count with invalid field should fail",
@@ -382,3 +519,1086 @@ func TestDebugFieldValidation(t *testing.T) {
 		t.Logf("Builder creation with invalid field failed as expected: %v", err)
 	}
 }
+
+func TestRawQueryColumnsFollowRequestedOrder(t *testing.T) {
+	flags = NewCommandFlags()
+	schema := &querybuilder.VPCFlowLogsSchema{}
+
+	opts, err := buildCommandOptions(schema, []string{"raw", "dstaddr,srcaddr,action"}, flags)
+	if err != nil {
+		t.Fatalf("buildCommandOptions() error = %v", err)
+	}
+
+	columns := queryColumns(schema, opts)
+	if got := strings.Join(columns, ","); got != "dstaddr,srcaddr,action" {
+		t.Fatalf("queryColumns() = %q, want dstaddr,srcaddr,action", got)
+	}
+
+	// CloudWatch returns fields in its own order, plus @ptr.
+	results := [][]runner.Field{
+		{
+			{Name: "action", Value: "ACCEPT"},
+			{Name: "srcaddr", Value: "10.0.0.1"},
+			{Name: "@ptr", Value: "abc"},
+			{Name: "dstaddr", Value: "10.0.0.2"},
+		},
+	}
+	output, err := formatter.Format(formatter.SelectColumns(results, columns), nil, formatter.FormatOptions{Format: "csv"})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	want := "dstaddr,srcaddr,action\n10.0.0.2,10.0.0.1,ACCEPT\n"
+	if output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+}
+
+func TestRunVerbRawFieldsKeepPTRColumns(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cachePath, err := expandPath(DefaultCachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cache.OpenWithConfig(cache.DefaultConfig().WithCachePath(cachePath).WithLogging(false))
+	if err != nil {
+		t.Fatalf("OpenWithConfig() error = %v", err)
+	}
+	// A cached hostname means no reverse DNS lookup is made
+	if err := c.UpsertIP(cache.IPTag{Addr: "203.0.113.7", Name: "EXAMPLE", PTR: "host.example.net"}); err != nil {
+		t.Fatalf("UpsertIP() error = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	savedExecute := executeQuery
+	t.Cleanup(func() { executeQuery = savedExecute })
+	executeQuery = func(context.Context, *cobra.Command, []querybuilder.Option, *CommandFlags) ([][]interface{}, runner.QueryStatistics, error) {
+		return [][]interface{}{{
+			runner.Field{Name: "dstaddr", Value: "10.0.0.5"},
+			runner.Field{Name: "srcaddr", Value: "203.0.113.7"},
+			runner.Field{Name: "@ptr", Value: "abc"},
+		}}, runner.QueryStatistics{}, nil
+	}
+
+	saved := flags
+	t.Cleanup(func() { flags = saved })
+	flags = NewCommandFlags()
+	flags.Format = "csv"
+	flags.ResolvePTR = 10
+	flags.Output = filepath.Join(t.TempDir(), "flows.csv")
+
+	// fli raw srcaddr,dstaddr --resolve-ptr 10 --format csv
+	cmd := &cobra.Command{Use: "raw"}
+	cmd.SetContext(context.Background())
+	if err := runVerb(querybuilder.VerbRaw)(cmd, []string{"srcaddr,dstaddr"}); err != nil {
+		t.Fatalf("runVerb() error = %v", err)
+	}
+
+	data, err := os.ReadFile(flags.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "srcaddr,srcaddr_annotation,srcaddr_ptr,dstaddr\n203.0.113.7,EXAMPLE,host.example.net,10.0.0.5\n"
+	if string(data) != want {
+		t.Errorf("output = %q, want %q", data, want)
+	}
+}
+
+func TestQueryColumnsForAggregation(t *testing.T) {
+	flags = NewCommandFlags()
+	schema := &querybuilder.VPCFlowLogsSchema{}
+
+	opts, err := buildCommandOptions(schema, []string{"sum", "bytes"}, flags)
+	if err != nil {
+		t.Fatalf("buildCommandOptions() error = %v", err)
+	}
+	if columns := queryColumns(schema, opts); columns != nil {
+		t.Errorf("queryColumns() = %v, want nil for aggregation queries", columns)
+	}
+}
+
+func TestApplyTimeoutSetsDeadline(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	before := time.Now()
+	cancel := applyTimeout(cmd, 2*time.Minute)
+	defer cancel()
+
+	deadline, ok := cmd.Context().Deadline()
+	if !ok {
+		t.Fatal("expected the command context to carry a deadline")
+	}
+	if deadline.Before(before.Add(2*time.Minute)) || deadline.After(time.Now().Add(2*time.Minute)) {
+		t.Errorf("deadline = %v, want about 2m after %v", deadline, before)
+	}
+
+	cancel()
+	if err := cmd.Context().Err(); err != context.Canceled {
+		t.Errorf("context error after cancel = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCommandTimeoutDefaults(t *testing.T) {
+	query := &cobra.Command{Annotations: map[string]string{"query": "true"}}
+	other := &cobra.Command{}
+
+	if got := commandTimeout(query, 0); got != defaultTimeouts.Query {
+		t.Errorf("query default = %v, want %v", got, defaultTimeouts.Query)
+	}
+	if got := commandTimeout(other, 0); got != 0 {
+		t.Errorf("non-query default = %v, want no deadline", got)
+	}
+	if got := commandTimeout(other, 30*time.Second); got != 30*time.Second {
+		t.Errorf("explicit timeout = %v, want 30s", got)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	applyTimeout(cmd, 0)()
+	if _, ok := cmd.Context().Deadline(); ok {
+		t.Error("expected no deadline for a zero timeout")
+	}
+}
+
+func TestQuerySchemaFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.yaml")
+	definition := `versions:
+  1:
+    parse_pattern: parse @message "* * *" as srcaddr, kb, team
+    fields: [srcaddr, kb, team]
+numeric_fields: [kb]
+`
+	if err := os.WriteFile(path, []byte(definition), 0o600); err != nil {
+		t.Fatalf("failed to write schema: %v", err)
+	}
+
+	cmdFlags := NewCommandFlags()
+	cmdFlags.InitDefaults(100, "table", 5*time.Minute)
+	cmdFlags.SchemaFile = path
+	cmdFlags.Version = 1
+	cmdFlags.By = "team"
+
+	schema, err := querySchema(cmdFlags)
+	if err != nil {
+		t.Fatalf("querySchema() error = %v", err)
+	}
+	opts, err := buildCommandOptions(schema, []string{"sum", "kb"}, cmdFlags)
+	if err != nil {
+		t.Fatalf("buildCommandOptions() error = %v", err)
+	}
+	b, err := querybuilder.New(schema, opts...)
+	if err != nil {
+		t.Fatalf("querybuilder.New() error = %v", err)
+	}
+	want := `parse @message "* * *" as srcaddr, kb, team | stats sum(kb) as kb_sum by team | sort kb_sum desc | limit 100`
+	if got := b.String(); got != want {
+		t.Errorf("query = %q, want %q", got, want)
+	}
+
+	if _, err := buildCommandOptions(schema, []string{"sum", "team"}, cmdFlags); err == nil {
+		t.Error("expected an error summing a field the schema does not list as numeric")
+	}
+
+	cmdFlags.SchemaFile = filepath.Join(t.TempDir(), "missing.yaml")
+	if _, err := querySchema(cmdFlags); err == nil {
+		t.Error("expected an error for a missing schema file")
+	}
+}
+
+func TestParseOutputTargets(t *testing.T) {
+	targets, err := parseOutputTargets([]string{"csv:flows.csv", "json: out/flows.json"})
+	if err != nil {
+		t.Fatalf("parseOutputTargets() error = %v", err)
+	}
+	want := []outputTarget{{Format: "csv", Path: "flows.csv"}, {Format: "json", Path: "out/flows.json"}}
+	if len(targets) != len(want) || targets[0] != want[0] || targets[1] != want[1] {
+		t.Errorf("parseOutputTargets() = %+v, want %+v", targets, want)
+	}
+
+	for _, value := range []string{"flows.csv", "csv:", ":flows.csv", "xml:flows.xml"} {
+		if _, err := parseOutputTargets([]string{value}); err == nil {
+			t.Errorf("parseOutputTargets(%q): expected an error", value)
+		}
+	}
+}
+
+func TestWriteResultsAlsoWrite(t *testing.T) {
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "count", Value: "42"}},
+		{{Name: "srcaddr", Value: "10.0.0.2"}, {Name: "count", Value: "17"}},
+	}
+	headers := []string{"srcaddr", "count"}
+	options := formatter.FormatOptions{Format: "table", Colorize: true}
+	csvPath := filepath.Join(t.TempDir(), "reports", "flows.csv")
+
+	var stdout strings.Builder
+	err := writeResults(&stdout, results, headers, options, runner.QueryStatistics{RecordsMatched: 59},
+		[]outputTarget{{Format: "csv", Path: csvPath}})
+	if err != nil {
+		t.Fatalf("writeResults() error = %v", err)
+	}
+
+	if !strings.Contains(stdout.String(), "10.0.0.1") || !strings.Contains(stdout.String(), "Records Matched: 59") {
+		t.Errorf("stdout is missing the table and its statistics:\n%s", stdout.String())
+	}
+
+	data, err := os.ReadFile(csvPath)
+	if err != nil {
+		t.Fatalf("failed to read the CSV output: %v", err)
+	}
+	if want := "srcaddr,count\n10.0.0.1,42\n10.0.0.2,17\n"; string(data) != want {
+		t.Errorf("CSV output = %q, want %q", data, want)
+	}
+}
+
+func TestFieldCompletionIncludesComputedFields(t *testing.T) {
+	for _, version := range []int{2, 3, 5} {
+		fields := getFieldsForVersion(version)
+		if !slices.Contains(fields, "duration") {
+			t.Errorf("getFieldsForVersion(%d) = %v, want the computed field duration", version, fields)
+		}
+	}
+}
+
+// logGroupLister answers DescribeLogGroups with the groups matching the prefix.
+type logGroupLister struct {
+	aws.CloudWatchLogsManagementAPI
+	names []string
+}
+
+func (l *logGroupLister) DescribeLogGroups(_ context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	out := &cloudwatchlogs.DescribeLogGroupsOutput{}
+	for _, name := range l.names {
+		if strings.HasPrefix(name, awssdk.ToString(params.LogGroupNamePrefix)) {
+			out.LogGroups = append(out.LogGroups, cwltypes.LogGroup{LogGroupName: awssdk.String(name)})
+		}
+	}
+	return out, nil
+}
+
+func TestLogGroupCompletion(t *testing.T) {
+	original := newLogGroupClient
+	t.Cleanup(func() { newLogGroupClient = original })
+
+	newLogGroupClient = func(context.Context) (aws.CloudWatchLogsManagementAPI, error) {
+		return &logGroupLister{names: []string{"/aws/vpc/prod", "/aws/vpc/staging", "/fli/flow-logs/vpc-1"}}, nil
+	}
+	got, directive := logGroupCompletion(&cobra.Command{}, nil, "/aws/vpc/")
+	if want := []string{"/aws/vpc/prod", "/aws/vpc/staging"}; !slices.Equal(got, want) || directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Errorf("logGroupCompletion() = %v, %v, want %v without file completion", got, directive, want)
+	}
+
+	// Without credentials, completion gives nothing rather than an error
+	newLogGroupClient = func(context.Context) (aws.CloudWatchLogsManagementAPI, error) {
+		return nil, errors.New("no AWS credentials found")
+	}
+	if got, directive := logGroupCompletion(&cobra.Command{}, nil, "/aws"); got != nil || directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Errorf("logGroupCompletion() without credentials = %v, %v, want no completions", got, directive)
+	}
+}
+
+// recordingENIProvider records every ENI it is asked for.
+type recordingENIProvider struct {
+	requested []string
+}
+
+func (p *recordingENIProvider) GetENITag(_ context.Context, eniID string) (aws.ENITag, error) {
+	p.requested = append(p.requested, eniID)
+	return aws.ENITag{ENI: eniID, Label: "label-" + eniID}, nil
+}
+
+func TestReadENIFileRefreshesEveryENI(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "enis.txt")
+	want := []string{"eni-0123456789abcdef0", "eni-01234567", "eni-89abcdef"}
+	if err := os.WriteFile(path, []byte(" eni-0123456789abcdef0\n\neni-01234567\r\neni-89abcdef\n"), 0o600); err != nil {
+		t.Fatalf("failed to write ENI file: %v", err)
+	}
+
+	ids, err := readENIFile(path, nil)
+	if err != nil {
+		t.Fatalf("readENIFile() error = %v", err)
+	}
+	if !slices.Equal(ids, want) {
+		t.Fatalf("readENIFile() = %v, want %v", ids, want)
+	}
+
+	c, err := cache.Open(filepath.Join(dir, "cache.db"))
+	if err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	provider := &recordingENIProvider{}
+	if _, err := c.RefreshENIs(context.Background(), provider, ids); err != nil {
+		t.Fatalf("RefreshENIs() error = %v", err)
+	}
+	if !slices.Equal(provider.requested, want) {
+		t.Errorf("provider was asked for %v, want %v", provider.requested, want)
+	}
+
+	// "-" reads stdin, and anything that is not an ENI ID is rejected
+	ids, err = readENIFile("-", strings.NewReader("eni-01234567\n"))
+	if err != nil || !slices.Equal(ids, want[1:2]) {
+		t.Errorf("readENIFile(-) = %v, %v, want [eni-01234567]", ids, err)
+	}
+	if _, err := readENIFile("-", strings.NewReader("eni-01234567\ni-0abc\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("readENIFile() error = %v, want an invalid ENI ID error for line 2", err)
+	}
+}
+
+func TestRefreshSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		refresh cache.RefreshStats
+		enrich  cache.EnrichStats
+		want    string
+	}{
+		{
+			name:    "clean refresh",
+			refresh: cache.RefreshStats{Updated: 1},
+			enrich:  cache.EnrichStats{Enriched: 2},
+			want:    "Refreshed 1 ENI, removed 0 not found, enriched 2 IPs",
+		},
+		{
+			name:    "mixed refresh",
+			refresh: cache.RefreshStats{Updated: 3, Removed: 1, Skipped: 1, Failed: 2},
+			enrich:  cache.EnrichStats{Enriched: 4, Failed: 1},
+			want:    "Refreshed 3 ENIs, removed 1 not found, enriched 4 IPs (1 ENI skipped, 2 ENIs failed, 1 IP failed)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refreshSummary(tt.refresh, tt.enrich); got != tt.want {
+				t.Errorf("refreshSummary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumericResultField(t *testing.T) {
+	numeric := numericResultField(&querybuilder.VPCFlowLogsSchema{}, 5)
+	for field, want := range map[string]bool{
+		"bytes":        true,
+		"protocol":     true,
+		"count":        true, // aggregation aliases are not schema fields
+		"sum_bytes":    true,
+		"account_id":   false,
+		"interface_id": false,
+		"srcaddr":      false,
+	} {
+		if got := numeric(field); got != want {
+			t.Errorf("numericResultField()(%q) = %v, want %v", field, got, want)
+		}
+	}
+}
+
+func TestFanoutQuery(t *testing.T) {
+	schema := &querybuilder.VPCFlowLogsSchema{}
+	cmdFlags := NewCommandFlags()
+	cmdFlags.By = "srcaddr"
+
+	fanout, err := fanoutAggregations([]string{"dstport"})
+	if err != nil {
+		t.Fatalf("fanoutAggregations() error = %v", err)
+	}
+	opts, err := buildCommandOptions(schema, []string{"distinct", "dstport"}, cmdFlags)
+	if err != nil {
+		t.Fatalf("buildCommandOptions() error = %v", err)
+	}
+	opts = append(opts, querybuilder.WithAggregations(fanout...))
+
+	query := queryString(schema, opts)
+	if !strings.Contains(query, "stats count_distinct(dstport) as dstport_distinct, count(*) as flows by srcaddr") {
+		t.Errorf("fanout query = %q, want both aggregations by srcaddr", query)
+	}
+	if verb := extractVerbFromQuery(query); verb != fanoutCommand {
+		t.Errorf("extractVerbFromQuery() = %q, want %q", verb, fanoutCommand)
+	}
+	if fanout[0].Alias() != "dstport_distinct" || fanout[1].Alias() != "flows" {
+		t.Errorf("fanout aliases = %s, %s", fanout[0].Alias(), fanout[1].Alias())
+	}
+
+	if _, err := fanoutAggregations([]string{"dstport,dstaddr"}); err == nil {
+		t.Error("fanoutAggregations() accepted two fields")
+	}
+}
+
+func TestCheckFailConditions(t *testing.T) {
+	aggregate := [][]runner.Field{{{Name: "flows", Value: "1500"}}}
+	threshold, err := formatter.ParseCondition("flows > 1000")
+	if err != nil {
+		t.Fatalf("ParseCondition() error = %v", err)
+	}
+	tests := []struct {
+		name        string
+		results     [][]runner.Field
+		failIfEmpty bool
+		failIf      formatter.Condition
+		wantErr     bool
+	}{
+		{name: "empty results pass by default", results: nil},
+		{name: "empty results fail with --fail-if-empty", results: nil, failIfEmpty: true, wantErr: true},
+		{name: "rows pass --fail-if-empty", results: aggregate, failIfEmpty: true},
+		{name: "threshold breached", results: aggregate, failIf: threshold, wantErr: true},
+		{name: "threshold not breached", results: [][]runner.Field{{{Name: "flows", Value: "999"}}}, failIf: threshold},
+		{name: "threshold on empty results", results: nil, failIf: threshold},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			err := checkFailConditions(cmd, tt.results, tt.failIfEmpty, tt.failIf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkFailConditions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !cmd.SilenceUsage {
+				t.Error("checkFailConditions() failed without silencing usage")
+			}
+		})
+	}
+
+	missing, _ := formatter.ParseCondition("bytes_sum > 0")
+	if err := checkFailConditions(&cobra.Command{}, aggregate, false, missing); err == nil || !strings.Contains(err.Error(), "bytes_sum") {
+		t.Errorf("checkFailConditions() on a missing column = %v, want an error naming it", err)
+	}
+}
+
+func TestLoadAWSConfigWithoutCredentials(t *testing.T) {
+	original := checkCredentials
+	t.Cleanup(func() { checkCredentials = original })
+	checkCredentials = func(context.Context, awssdk.CredentialsProvider) error {
+		return fmt.Errorf("%w: no providers in chain", aws.ErrNoCredentials)
+	}
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	_, err := loadAWSConfig(context.Background())
+	if !errors.Is(err, aws.ErrNoCredentials) {
+		t.Fatalf("loadAWSConfig() error = %v, want ErrNoCredentials", err)
+	}
+	if got := exitCode(err); got != exitNoCredentials {
+		t.Errorf("exitCode() = %d, want %d", got, exitNoCredentials)
+	}
+	if got := exitCode(errors.New("failed to execute query")); got != exitFailure {
+		t.Errorf("exitCode() = %d, want %d for other errors", got, exitFailure)
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	data := []byte("srcaddr,flows\n10.0.0.1,42\n")
+
+	for _, path := range []string{"", "-"} {
+		var stdout, stderr strings.Builder
+		if err := writeOutput(&stdout, &stderr, path, data); err != nil {
+			t.Fatalf("writeOutput(%q) error = %v", path, err)
+		}
+		if stdout.String() != string(data) || stderr.Len() != 0 {
+			t.Errorf("writeOutput(%q) wrote stdout %q, stderr %q; want the data on stdout only", path, stdout.String(), stderr.String())
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "reports", "daily", "flows.csv")
+	var stdout, stderr strings.Builder
+	if err := writeOutput(&stdout, &stderr, path, data); err != nil {
+		t.Fatalf("writeOutput() error = %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the output file: %v", err)
+	}
+	if string(got) != string(data) || stdout.Len() != 0 {
+		t.Errorf("file = %q, stdout = %q; want the data in the file only", got, stdout.String())
+	}
+	if !strings.Contains(stderr.String(), path) {
+		t.Errorf("stderr = %q, want a confirmation naming %s", stderr.String(), path)
+	}
+}
+
+// recordingWarmer records what cache warm asks the cache to fill.
+type recordingWarmer struct {
+	enis []string
+	ips  []string
+}
+
+func (w *recordingWarmer) RefreshENIs(_ context.Context, _ cache.ENITagProvider, enis []string) (cache.RefreshStats, error) {
+	w.enis = append(w.enis, enis...)
+	return cache.RefreshStats{Updated: len(enis)}, nil
+}
+
+func (w *recordingWarmer) EnrichAddrs(ips []string) cache.EnrichStats {
+	w.ips = append(w.ips, ips...)
+	return cache.EnrichStats{Enriched: len(ips)}
+}
+
+func TestCacheWarmReadsFormatterOutput(t *testing.T) {
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "203.0.113.15"}, {Name: "interface_id", Value: "eni-0123456789abcdef0"}, {Name: "srcaddr_annotation", Value: "EXAMPLE-NET, US"}},
+		{{Name: "srcaddr", Value: "2001:db8::1"}, {Name: "interface_id", Value: "eni-0123456789abcdef0"}},
+		{{Name: "srcaddr", Value: "203.0.113.15"}, {Name: "interface_id", Value: "-"}},
+	}
+	headers := []string{"srcaddr", "interface_id", "srcaddr_annotation"}
+	output := formatter.CSVFormatter{}.Format(results, headers)
+
+	// fli raw srcaddr,interface_id --format csv | fli cache warm --column 1
+	values, err := readWarmValues(strings.NewReader(output), 1)
+	if err != nil {
+		t.Fatalf("readWarmValues() error = %v", err)
+	}
+	if want := []string{"203.0.113.15", "2001:db8::1"}; !slices.Equal(values.IPs, want) || len(values.ENIs) != 0 || values.Skipped != 1 {
+		t.Errorf("readWarmValues(column 1) = %+v, want IPs %v and the header skipped", values, want)
+	}
+
+	warmer := &recordingWarmer{}
+	refresh, enrich, err := warmCache(context.Background(), warmer, values, nil)
+	if err != nil {
+		t.Fatalf("warmCache() error = %v", err)
+	}
+	if !slices.Equal(warmer.ips, values.IPs) || warmer.enis != nil {
+		t.Errorf("warmCache() enriched %v and refreshed %v, want only the IPs", warmer.ips, warmer.enis)
+	}
+	if got := refreshSummary(refresh, enrich); got != "Refreshed 0 ENIs, removed 0 not found, enriched 2 IPs" {
+		t.Errorf("summary = %q", got)
+	}
+
+	// --column 2 picks up the ENI, once
+	values, err = readWarmValues(strings.NewReader(output+"No results found.\n"), 2)
+	if err != nil {
+		t.Fatalf("readWarmValues() error = %v", err)
+	}
+	warmer = &recordingWarmer{}
+	if _, _, err := warmCache(context.Background(), warmer, values, &recordingENIProvider{}); err != nil {
+		t.Fatalf("warmCache() error = %v", err)
+	}
+	if want := []string{"eni-0123456789abcdef0"}; !slices.Equal(warmer.enis, want) || len(warmer.ips) != 0 {
+		t.Errorf("warmCache() refreshed %v and enriched %v, want %v only", warmer.enis, warmer.ips, want)
+	}
+
+	if _, err := readWarmValues(strings.NewReader(output), 0); err == nil {
+		t.Error("readWarmValues() accepted column 0")
+	}
+}
+
+func TestWarmValuesTopPublicIPs(t *testing.T) {
+	input := "srcaddr\n198.51.100.1\n10.0.0.1\n203.0.113.7\n203.0.113.7\n192.0.2.9\n10.0.0.1\n10.0.0.1\n"
+	values, err := readWarmValues(strings.NewReader(input), 1)
+	if err != nil {
+		t.Fatalf("readWarmValues() error = %v", err)
+	}
+
+	// Private addresses never use up the limit; ties keep their input order
+	top, total := values.topPublicIPs(2)
+	if want := []string{"203.0.113.7", "198.51.100.1"}; !slices.Equal(top, want) || total != 3 {
+		t.Errorf("topPublicIPs(2) = %v, %d, want %v, 3", top, total, want)
+	}
+	if all, _ := values.topPublicIPs(0); len(all) != 3 {
+		t.Errorf("topPublicIPs(0) = %v, want every public IP", all)
+	}
+}
+
+func TestExecuteQueryRejectsNonPositivePollInterval(t *testing.T) {
+	for _, flag := range []string{"--poll-interval", "--max-poll-interval"} {
+		cmdFlags := NewCommandFlags()
+		cmdFlags.LogGroups = []string{"/aws/vpc/flowlogs"}
+		if flag == "--poll-interval" {
+			cmdFlags.PollInterval = 0
+		} else {
+			cmdFlags.MaxPollInterval = -time.Second
+		}
+
+		_, _, err := (&QueryExecutor{}).ExecuteQuery(context.Background(), nil, nil, cmdFlags)
+		if err == nil || !strings.Contains(err.Error(), flag) {
+			t.Errorf("ExecuteQuery() error = %v, want an invalid %s error", err, flag)
+		}
+	}
+}
+
+func TestAWSConfigOptions(t *testing.T) {
+	cmdFlags := NewCommandFlags()
+	if opts := cmdFlags.awsConfigOptions(); len(opts) != 0 {
+		t.Errorf("awsConfigOptions() without --region or --aws-profile = %d options, want none", len(opts))
+	}
+
+	cmdFlags.Region = "eu-west-1"
+	cmdFlags.AWSProfile = "prod"
+	var loadOptions awsconfig.LoadOptions
+	for _, opt := range cmdFlags.awsConfigOptions() {
+		if err := opt(&loadOptions); err != nil {
+			t.Fatalf("awsConfigOptions() option error = %v", err)
+		}
+	}
+	if loadOptions.Region != "eu-west-1" || loadOptions.SharedConfigProfile != "prod" {
+		t.Errorf("awsConfigOptions() set region %q and profile %q, want eu-west-1 and prod", loadOptions.Region, loadOptions.SharedConfigProfile)
+	}
+}
+
+func TestWriteFieldList(t *testing.T) {
+	schema := &querybuilder.VPCFlowLogsSchema{}
+
+	var v5 strings.Builder
+	if err := writeFieldList(&v5, schema, 5); err != nil {
+		t.Fatalf("writeFieldList(5) error = %v", err)
+	}
+	for _, want := range []string{"vpc_id", "pkt_srcaddr", "duration"} {
+		if !strings.Contains(v5.String(), want) {
+			t.Errorf("writeFieldList(5) output is missing %q:\n%s", want, v5.String())
+		}
+	}
+
+	var v2 strings.Builder
+	if err := writeFieldList(&v2, schema, 2); err != nil {
+		t.Fatalf("writeFieldList(2) error = %v", err)
+	}
+	if strings.Contains(v2.String(), "vpc_id") {
+		t.Errorf("writeFieldList(2) lists the version 5 field vpc_id:\n%s", v2.String())
+	}
+	if !strings.Contains(v2.String(), "srcport") || !strings.Contains(v2.String(), "port") {
+		t.Errorf("writeFieldList(2) output is missing srcport and its kind:\n%s", v2.String())
+	}
+
+	if err := writeFieldList(io.Discard, schema, 1); err == nil {
+		t.Error("writeFieldList(1) error = nil, want an unsupported version error")
+	}
+}
+
+func TestParseTimeFlag(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	tests := map[string]time.Time{
+		"2024-05-01T14:30:00Z":      time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC),
+		"2024-05-01T14:30:00+02:00": time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		"2024-05-01 14:30":          time.Date(2024, 5, 1, 14, 30, 0, 0, loc),
+	}
+	for input, want := range tests {
+		got, err := parseTimeFlag(input, loc)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseTimeFlag(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{"", "yesterday", "2024-05-01", "14:30", "2024-13-01 10:00"} {
+		if _, err := parseTimeFlag(bad, loc); err == nil {
+			t.Errorf("parseTimeFlag(%q) error = nil, want an error", bad)
+		}
+	}
+}
+
+func TestQueryTimeRange(t *testing.T) {
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name               string
+		since              time.Duration
+		start, end         string
+		wantStart, wantEnd time.Time
+		wantErr            string
+	}{
+		{name: "since only", since: time.Hour, wantStart: now.Add(-time.Hour), wantEnd: now},
+		{name: "start and end", since: time.Hour, start: "2024-05-01 10:00", end: "2024-05-01 10:30",
+			wantStart: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), wantEnd: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{name: "start runs until now", since: time.Hour, start: "2024-05-01T10:00:00Z",
+			wantStart: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), wantEnd: now},
+		{name: "end with since", since: 15 * time.Minute, end: "2024-05-01 10:30",
+			wantStart: time.Date(2024, 5, 1, 10, 15, 0, 0, time.UTC), wantEnd: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{name: "start after end", since: time.Hour, start: "2024-05-01 11:00", end: "2024-05-01 10:00", wantErr: "is not before end"},
+		{name: "end in the future", since: time.Hour, end: "2024-05-03 10:00", wantErr: "in the future"},
+		{name: "start past retention", since: time.Hour, start: "2010-01-01 00:00", wantErr: "older than CloudWatch Logs retains"},
+		{name: "malformed start", since: time.Hour, start: "last tuesday", wantErr: "invalid --start"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmdFlags := NewCommandFlags()
+			cmdFlags.Since, cmdFlags.Start, cmdFlags.End = tt.since, tt.start, tt.end
+
+			start, end, err := queryTimeRange(cmdFlags, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("queryTimeRange() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("queryTimeRange() error = %v", err)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("queryTimeRange() = %v .. %v, want %v .. %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestFindQueryCommand(t *testing.T) {
+	root := &cobra.Command{Use: "fli"}
+	count := &cobra.Command{Use: "count", Annotations: map[string]string{"query": "true"}, RunE: func(*cobra.Command, []string) error { return nil }}
+	profile := &cobra.Command{Use: "profile", RunE: func(*cobra.Command, []string) error { return nil }}
+	root.AddCommand(count, profile)
+
+	cmd, rest, err := findQueryCommand(root, []string{"count", "--by", "srcaddr"})
+	if err != nil || cmd != count || !slices.Equal(rest, []string{"--by", "srcaddr"}) {
+		t.Errorf("findQueryCommand(count) = %v, %v, %v, want count with its flags", cmd.Name(), rest, err)
+	}
+	if _, _, err := findQueryCommand(root, []string{"profile"}); err == nil {
+		t.Error("findQueryCommand(profile) error = nil, want a not a query command error")
+	}
+}
+
+func TestForwardingCommandsShowHelp(t *testing.T) {
+	for _, tt := range []struct {
+		cmd  *cobra.Command
+		run  func(*cobra.Command, []string) error
+		args []string
+	}{
+		{cmd: explainCmd, run: runExplain, args: []string{"--help"}},
+		{cmd: explainCmd, run: runExplain, args: []string{"count", "-h"}},
+		{cmd: &cobra.Command{Use: "run <name>", DisableFlagParsing: true, RunE: runQueryRun}, run: runQueryRun, args: []string{"rejected-ssh", "--help"}},
+	} {
+		var out strings.Builder
+		tt.cmd.SetOut(&out)
+		if err := tt.run(tt.cmd, tt.args); err != nil {
+			t.Errorf("%s %v error = %v, want help", tt.cmd.Name(), tt.args, err)
+		}
+		if !strings.Contains(out.String(), "Usage:") {
+			t.Errorf("%s %v printed %q, want its usage", tt.cmd.Name(), tt.args, out.String())
+		}
+		tt.cmd.SetOut(nil)
+	}
+
+	if helpRequested([]string{"rejected", "--", "--help"}) {
+		t.Error("helpRequested() = true for --help after --")
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	got := commandLine([]string{"count", "--by", "srcaddr", "--filter", "action=REJECT and dstport=22"})
+	want := `fli count --by srcaddr --filter "action=REJECT and dstport=22"`
+	if got != want {
+		t.Errorf("commandLine() = %s, want %s", got, want)
+	}
+}
+
+// windowRecordingClient is a CloudWatch Logs client that completes every query
+// at once and records the time window of each StartQuery call.
+type windowRecordingClient struct {
+	starts, ends []int64
+}
+
+func (c *windowRecordingClient) StartQuery(_ context.Context, params *cloudwatchlogs.StartQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+	c.starts = append(c.starts, *params.StartTime)
+	c.ends = append(c.ends, *params.EndTime)
+	return &cloudwatchlogs.StartQueryOutput{QueryId: awssdk.String("query-1")}, nil
+}
+
+func (c *windowRecordingClient) GetQueryResults(context.Context, *cloudwatchlogs.GetQueryResultsInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	return &cloudwatchlogs.GetQueryResultsOutput{Status: cwltypes.QueryStatusComplete}, nil
+}
+
+func (c *windowRecordingClient) StopQuery(context.Context, *cloudwatchlogs.StopQueryInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error) {
+	return &cloudwatchlogs.StopQueryOutput{}, nil
+}
+
+func TestExecuteQuerySinceLast(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client := &windowRecordingClient{}
+	executor := &QueryExecutor{runner: runner.New(client, runner.WithPollInterval(time.Millisecond))}
+
+	cmdFlags := NewCommandFlags()
+	cmdFlags.LogGroups = []string{"/aws/vpc/flowlogs"}
+	cmdFlags.Since = time.Hour
+	cmdFlags.SinceLast = true
+	opts := []querybuilder.Option{querybuilder.WithVerb(querybuilder.VerbCount)}
+	wantFirst := (time.Hour - sinceLastIngestionLag).Milliseconds()
+
+	// The first run has no bookmark and falls back to --since, leaving the events
+	// still arriving to the next run
+	_, stats, err := executor.ExecuteQuery(context.Background(), nil, opts, cmdFlags)
+	if err != nil {
+		t.Fatalf("ExecuteQuery() first run error = %v", err)
+	}
+	if window := client.ends[0] - client.starts[0]; window != wantFirst {
+		t.Errorf("first run queried %dms, want the --since window less the ingestion lag, %dms", window, wantFirst)
+	}
+	if lag := time.Now().UnixMilli() - client.ends[0]; lag < sinceLastIngestionLag.Milliseconds() {
+		t.Errorf("first run ended %dms before now, want at least the ingestion lag", lag)
+	}
+
+	// Until the results are written the bookmark stays put
+	if _, _, err := executor.ExecuteQuery(context.Background(), nil, opts, cmdFlags); err != nil {
+		t.Fatalf("ExecuteQuery() unwritten run error = %v", err)
+	}
+	if window := client.ends[1] - client.starts[1]; window != wantFirst {
+		t.Errorf("run after an unwritten run queried %dms, want the --since window again", window)
+	}
+	advanceSinceLast(cmdFlags, stats)
+
+	// Later runs start where the previous written one ended
+	time.Sleep(1100 * time.Millisecond)
+	if _, _, err := executor.ExecuteQuery(context.Background(), nil, opts, cmdFlags); err != nil {
+		t.Fatalf("ExecuteQuery() second run error = %v", err)
+	}
+	if client.starts[2] != client.ends[0] {
+		t.Errorf("second run started at %d, want the first run's end %d", client.starts[2], client.ends[0])
+	}
+
+	// A different query keeps its own bookmark
+	sumOpts := []querybuilder.Option{querybuilder.WithVerb(querybuilder.VerbSum), querybuilder.WithAggregations(querybuilder.AggregationField{Field: "bytes", Verb: querybuilder.VerbSum})}
+	if _, _, err := executor.ExecuteQuery(context.Background(), nil, sumOpts, cmdFlags); err != nil {
+		t.Fatalf("ExecuteQuery() other query error = %v", err)
+	}
+	if window := client.ends[3] - client.starts[3]; window != wantFirst {
+		t.Errorf("other query queried %dms, want %dms", window, wantFirst)
+	}
+}
+
+func TestExecuteQueryResultCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client := &windowRecordingClient{}
+	executor := &QueryExecutor{runner: runner.New(client, runner.WithPollInterval(time.Millisecond))}
+
+	cmdFlags := NewCommandFlags()
+	cmdFlags.LogGroups = []string{"/aws/vpc/flowlogs"}
+	cmdFlags.Since = time.Hour
+	cmdFlags.CacheTTL = 5 * time.Minute
+	opts := []querybuilder.Option{querybuilder.WithVerb(querybuilder.VerbCount)}
+
+	_, first, err := executor.ExecuteQuery(context.Background(), nil, opts, cmdFlags)
+	if err != nil {
+		t.Fatalf("ExecuteQuery() first run error = %v", err)
+	}
+
+	// Repeating the query reuses the stored result and its statistics
+	_, cached, err := executor.ExecuteQuery(context.Background(), nil, opts, cmdFlags)
+	if err != nil || len(client.starts) != 1 || cached != first {
+		t.Errorf("repeated run = %+v, %v after %d queries, want the first run's %+v from the cache", cached, err, len(client.starts), first)
+	}
+
+	// Another region is another set of log groups
+	cmdFlags.Region = "eu-west-1"
+	if _, _, err := executor.ExecuteQuery(context.Background(), nil, opts, cmdFlags); err != nil || len(client.starts) != 2 {
+		t.Errorf("run in another region = %v after %d queries, want a second query", err, len(client.starts))
+	}
+	cmdFlags.Region = ""
+
+	// --refresh and --no-cache both run the query again
+	cmdFlags.Refresh = true
+	if _, _, err := executor.ExecuteQuery(context.Background(), nil, opts, cmdFlags); err != nil || len(client.starts) != 3 {
+		t.Errorf("run with --refresh = %v after %d queries, want a third query", err, len(client.starts))
+	}
+	cmdFlags.Refresh, cmdFlags.NoCache = false, true
+	if _, _, err := executor.ExecuteQuery(context.Background(), nil, opts, cmdFlags); err != nil || len(client.starts) != 4 {
+		t.Errorf("run with --no-cache = %v after %d queries, want a fourth query", err, len(client.starts))
+	}
+}
+
+func TestResultWindow(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	cmdFlags := NewCommandFlags()
+	cmdFlags.Since = time.Hour
+	if s, e := resultWindow(cmdFlags, start, end); s != "now-1h0m0s" || e != "now" {
+		t.Errorf("resultWindow() for --since = %q, %q, want a window relative to now", s, e)
+	}
+	cmdFlags.End = "2026-03-01T11:00:00Z"
+	if s, e := resultWindow(cmdFlags, start, end); s != "2026-03-01T10:00:00Z" || e != "2026-03-01T11:00:00Z" {
+		t.Errorf("resultWindow() with --end = %q, %q, want fixed times", s, e)
+	}
+}
+
+func TestWatchQuery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var out strings.Builder
+	runs := 0
+	err := watchQuery(ctx, &out, time.Millisecond, func(runCtx context.Context) error {
+		if _, ok := runCtx.Deadline(); !ok {
+			t.Error("watch run has no deadline, want the query timeout")
+		}
+		runs++
+		if runs == 3 {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("watchQuery() after cancellation error = %v, want nil", err)
+	}
+	if runs != 3 || strings.Count(out.String(), "Every 1ms: ") != 3 || !strings.Contains(out.String(), clearScreen) {
+		t.Errorf("watchQuery() ran %d times with output %q, want 3 runs each with a header", runs, out.String())
+	}
+
+	failure := errors.New("query failed")
+	runs = 0
+	err = watchQuery(context.Background(), io.Discard, time.Millisecond, func(context.Context) error {
+		runs++
+		return failure
+	})
+	if !errors.Is(err, failure) || runs != 1 {
+		t.Errorf("watchQuery() with a failing run = %v after %d runs, want the run error after 1", err, runs)
+	}
+}
+
+func TestCommandTimeoutWatch(t *testing.T) {
+	query := &cobra.Command{Annotations: map[string]string{"query": "true"}}
+	query.Flags().Duration("watch", 0, "")
+	if got := commandTimeout(query, 0); got != defaultTimeouts.Query {
+		t.Errorf("query without --watch = %v, want %v", got, defaultTimeouts.Query)
+	}
+	if err := query.Flags().Set("watch", "30s"); err != nil {
+		t.Fatal(err)
+	}
+	if got := commandTimeout(query, 0); got != 0 {
+		t.Errorf("query with --watch = %v, want no deadline", got)
+	}
+	if got := commandTimeout(query, time.Hour); got != time.Hour {
+		t.Errorf("query with --watch and --timeout = %v, want 1h", got)
+	}
+}
+
+func TestWriteExplanation(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	var out, errOut strings.Builder
+	if err := writeExplanation(&out, &errOut, "stats count(*) as flows", start, start.Add(time.Hour)); err != nil {
+		t.Fatalf("writeExplanation() error = %v", err)
+	}
+	if out.String() != "stats count(*) as flows\n" {
+		t.Errorf("query output = %q, want only the query", out.String())
+	}
+	if want := "Time range: 2026-03-01T10:00:00Z to 2026-03-01T11:00:00Z\n"; errOut.String() != want {
+		t.Errorf("time range output = %q, want %q", errOut.String(), want)
+	}
+}
+
+// runningClient is a CloudWatch Logs client whose queries never complete. It
+// records whether StopQuery was called.
+type runningClient struct {
+	stopped bool
+}
+
+func (c *runningClient) StartQuery(context.Context, *cloudwatchlogs.StartQueryInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+	return &cloudwatchlogs.StartQueryOutput{QueryId: awssdk.String("query-1")}, nil
+}
+
+func (c *runningClient) GetQueryResults(context.Context, *cloudwatchlogs.GetQueryResultsInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	return &cloudwatchlogs.GetQueryResultsOutput{Status: cwltypes.QueryStatusRunning}, nil
+}
+
+func (c *runningClient) StopQuery(context.Context, *cloudwatchlogs.StopQueryInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error) {
+	c.stopped = true
+	return &cloudwatchlogs.StopQueryOutput{}, nil
+}
+
+func TestExecuteQueryTimeout(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client := &runningClient{}
+	executor := &QueryExecutor{runner: runner.New(client, runner.WithPollInterval(time.Millisecond))}
+
+	cmdFlags := NewCommandFlags()
+	cmdFlags.LogGroups = []string{"/aws/vpc/flowlogs"}
+	cmdFlags.Since = time.Hour
+	cmdFlags.NoCache = true
+	cmdFlags.Timeout = 50 * time.Millisecond
+	opts := []querybuilder.Option{querybuilder.WithVerb(querybuilder.VerbCount)}
+
+	_, _, err := executor.ExecuteQuery(context.Background(), nil, opts, cmdFlags)
+	if err == nil || !strings.HasPrefix(err.Error(), "query exceeded timeout of 50ms") {
+		t.Errorf("ExecuteQuery() error = %v, want a query timeout error", err)
+	}
+	if !client.stopped {
+		t.Error("ExecuteQuery() did not stop the query after the timeout")
+	}
+}
+
+func TestExecuteQueryExplainSkipsAWS(t *testing.T) {
+	// No runner, client, or log group: explain must stop before any of them is needed
+	cmdFlags := NewCommandFlags()
+	cmdFlags.Since = time.Hour
+	cmdFlags.explain = true
+	opts := []querybuilder.Option{querybuilder.WithVerb(querybuilder.VerbCount)}
+	if _, _, err := (&QueryExecutor{}).ExecuteQuery(context.Background(), nil, opts, cmdFlags); err != nil {
+		t.Errorf("ExecuteQuery() in explain mode error = %v", err)
+	}
+
+	cmdFlags.Start = "not-a-time"
+	if _, _, err := (&QueryExecutor{}).ExecuteQuery(context.Background(), nil, opts, cmdFlags); err == nil {
+		t.Error("ExecuteQuery() in explain mode with an invalid --start: error = nil, want an error")
+	}
+}
+
+func TestWriteParsedFilter(t *testing.T) {
+	schema := &querybuilder.VPCFlowLogsSchema{}
+	filter := "srcaddr like 10 and (dstport = 80 or dstport = 443)"
+
+	var out strings.Builder
+	if err := writeParsedFilter(&out, filter, schema, 2, false); err != nil {
+		t.Fatalf("writeParsedFilter() error = %v", err)
+	}
+	if want := "srcaddr like '10' and (dstport = 80 or dstport = 443)\n"; out.String() != want {
+		t.Errorf("writeParsedFilter() = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := writeParsedFilter(&out, filter, schema, 2, true); err != nil {
+		t.Fatalf("writeParsedFilter(tree) error = %v", err)
+	}
+	wantTree := "and\n  srcaddr like '10'\n  or\n    dstport = 80\n    dstport = 443\n"
+	if out.String() != wantTree {
+		t.Errorf("writeParsedFilter(tree) =\n%s\nwant\n%s", out.String(), wantTree)
+	}
+
+	out.Reset()
+	err := writeParsedFilter(&out, "dstport = https", schema, 2, false)
+	if err == nil || !strings.Contains(err.Error(), "invalid filter: invalid port value: https") {
+		t.Errorf("writeParsedFilter(invalid) error = %v, want an invalid port error", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("writeParsedFilter(invalid) wrote %q, want nothing", out.String())
+	}
+}
+
+func TestForwardingCommandResolvesForwardedProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("FLI_LOG_GROUP", "")
+	cfgPath, err := config.ConfigPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfgPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.NewConfig()
+	cfg.ActiveProfile = "default"
+	cfg.Profiles["default"] = config.ProfileConfig{LogGroup: "/default/lg", Version: 2}
+	cfg.Profiles["prod"] = config.ProfileConfig{LogGroup: "/prod/lg", Version: 5}
+	if err := cfg.Save(cfgPath); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := flags
+	t.Cleanup(func() { flags = saved })
+	flags = NewCommandFlags()
+
+	// The hook runs first for query run itself, before the saved --profile is parsed
+	forwarding := &cobra.Command{Use: "run", Annotations: map[string]string{"forward": "true"}}
+	if err := rootCmd.PersistentPreRunE(forwarding, nil); err != nil {
+		t.Fatalf("PersistentPreRunE(query run) error = %v", err)
+	}
+	if len(flags.LogGroups) != 0 {
+		t.Fatalf("query run resolved log groups %v before the saved query was parsed", flags.LogGroups)
+	}
+
+	// It then runs for the saved query's command with its flags parsed
+	flags.Profile = "prod"
+	verb := &cobra.Command{Use: "count"}
+	verb.Flags().Int("version", 0, "")
+	if err := rootCmd.PersistentPreRunE(verb, nil); err != nil {
+		t.Fatalf("PersistentPreRunE(count) error = %v", err)
+	}
+	if !slices.Equal(flags.LogGroups, []string{"/prod/lg"}) || flags.Version != 5 {
+		t.Errorf("forwarded --profile prod resolved %v version %d, want [/prod/lg] version 5", flags.LogGroups, flags.Version)
+	}
+}
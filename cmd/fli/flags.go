@@ -1,12 +1,16 @@
 package main
 
 import (
+	"cmp"
 	"os"
+	"strings"
 	"time"
 
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/spf13/cobra"
 
 	"fli/internal/config"
+	"fli/internal/runner"
 )
 
 // CommandFlags holds all the flags for the CLI commands.
@@ -17,26 +21,76 @@ type CommandFlags struct {
 	UseColor   bool
 	NoPtr      bool
 	ProtoNames bool
+	Timeout    time.Duration // Deadline for the whole command; 0 uses commandTimeout's default
 
 	// Profile flag
 	Profile string
 
 	// Query-specific flags
-	Limit    int
-	Format   string
-	Since    time.Duration // Time window to look back
-	Filter   string        // Filter expression
-	By       string        // Group by field(s)
-	SaveENIs bool          // Save ENIs found in results to the cache
-	SaveIPs  bool          // Save public IPs found in results to the cache
+	Limit      int
+	Format     string
+	Since      time.Duration // Time window to look back
+	Start      string        // Absolute start of the time window, overriding --since
+	End        string        // Absolute end of the time window; defaults to now
+	Watch      time.Duration // Re-run the query at this interval until interrupted; 0 runs it once
+	CacheTTL   time.Duration // How long query results are reused from the local cache; 0 disables it
+	NoCache    bool          // Neither read nor store query results in the local cache
+	Refresh    bool          // Re-run the query instead of using a cached result, and cache the new one
+	Filter     string        // Filter expression
+	By         string        // Group by field(s)
+	SaveENIs   bool          // Save ENIs found in results to the cache
+	SaveIPs    bool          // Save public IPs found in results to the cache
+	WhoisTop   int           // Whois-enrich only the N most frequent unannotated public IPs
+	ResolvePTR int           // Resolve reverse DNS for only the N most frequent uncached public IPs
+	Sort       string        // Sort direction of aggregation results (asc or desc)
+	SortBy     string        // Aggregation alias or group-by field to sort by; any field or @timestamp for raw
+	SortOut    string        // Client-side sort of the output rows, as column[:asc|desc]
+	Rename     string        // Display names for output columns, as column=name pairs
+	Legend     bool          // Print annotations once in a legend instead of inline per row
+	Envelope   bool          // Wrap JSON output with the query, window, and statistics
+	MaxWidth   int           // Truncate table values longer than this; 0 disables
+
+	ColumnsFromQuery bool   // Order and select output columns by the fields requested in the query
+	Transform        string // Value transformers for output columns, as column=transformer pairs
+	SchemaFile       string // YAML schema definition for flow logs with a custom format
+	Rollup           string // Client-side rollup of an IP group-by field, as field:prefix[,prefix...]
+	FixedPoll        bool   // Poll query status at a fixed interval instead of backing off
+	TypedJSON        bool   // Emit numeric fields as JSON numbers instead of strings
+	StableKeys       bool   // Order JSON object keys by column instead of alphabetically
+	NoAnnotate       bool   // Skip cache annotations entirely
+	AnnotateSources  string // Annotation sources to apply, comma-separated (eni, prefix, whois, ptr)
+	ShowRegion       bool   // Include the provider region in prefix annotations
+	Humanize         bool   // Render byte and duration columns in human-readable units
+	DecodeFlags      bool   // Render tcp_flags bitmasks as flag names (SYN,ACK)
+	Totals           bool   // Append a TOTAL row summing the sum and count columns of table output
+	HighlightPrivate bool   // With --color, color private IPs and public IPs differently in table output
+	Lint             bool   // Warn about filter clauses that match almost every log event
+	Sessionize       bool   // Group raw flows into bidirectional sessions by 5-tuple
+	StableSort       bool   // Break sort ties on the first --by field for deterministic output
+	SinceLast        bool   // Start where the last successful run of the same query ended
+	FailIfEmpty      bool   // Exit non-zero when the query returns no rows
+	FailIf           string // Exit non-zero when a result row meets this condition, e.g. flows > 1000
+	Output           string // File to write the formatted results to instead of stdout; "-" is stdout
+	ColumnWidths     string // Per-column table width limits overriding --max-width, as column=width pairs
+
+	// Additional outputs written to files alongside stdout, as format:path
+	AlsoWrite []string
 
 	// AWS-specific flags
-	LogGroup     string
-	Version      int
-	QueryTimeout time.Duration
+	LogGroups     []string // Log groups to query; repeat --log-group to query several at once
+	LogGroupField string   // StartQuery field the log group is sent in (identifier or name)
+	Region        string   // AWS region overriding AWS_REGION and the shared config
+	AWSProfile    string   // Shared config profile overriding AWS_PROFILE
+	Version       int
+
+	// Query status polling
+	PollInterval    time.Duration // Initial time between query status checks
+	MaxPollInterval time.Duration // Cap on the back-off between query status checks
 
 	// Internal tracking
 	versionExplicitlySet bool
+	explain              bool   // Set by fli explain to print the query instead of running it
+	sinceLastKey         string // --since-last bookmark to advance once the results are written
 }
 
 // NewCommandFlags creates a new CommandFlags instance with default values.
@@ -44,26 +98,30 @@ func NewCommandFlags() *CommandFlags {
 	timeouts := config.DefaultTimeouts()
 
 	flags := &CommandFlags{
-		DryRun:       false,
-		Debug:        false,
-		UseColor:     true,
-		NoPtr:        true,
-		ProtoNames:   true,
-		Limit:        20,
-		Format:       "table",
-		Since:        timeouts.DefaultSince,
-		Filter:       "",
-		By:           "",
-		SaveENIs:     false,
-		SaveIPs:      false,
-		LogGroup:     "",
-		Version:      2,
-		QueryTimeout: timeouts.Query,
+		DryRun:           false,
+		Debug:            false,
+		UseColor:         true,
+		NoPtr:            true,
+		ProtoNames:       true,
+		Limit:            20,
+		Format:           "table",
+		MaxWidth:         0,
+		Since:            timeouts.DefaultSince,
+		Filter:           "",
+		By:               "",
+		SaveENIs:         false,
+		SaveIPs:          false,
+		ColumnsFromQuery: true,
+		LogGroups:        nil,
+		LogGroupField:    string(runner.LogGroupFieldIdentifier),
+		PollInterval:     runner.DefaultPollInterval,
+		MaxPollInterval:  timeouts.MaxPoll,
+		Version:          2,
 	}
 
 	// Load default log group from environment variable
 	if envLogGroup := os.Getenv("FLI_LOG_GROUP"); envLogGroup != "" {
-		flags.LogGroup = envLogGroup
+		flags.LogGroups = []string{envLogGroup}
 	}
 
 	return flags
@@ -79,23 +137,95 @@ func (f *CommandFlags) InitDefaults(limit int, format string, since time.Duratio
 // AddCommonFlags adds common flags to a command.
 func (f *CommandFlags) AddCommonFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().BoolVar(&f.DryRun, "dry-run", false, "Show the query that would be executed without running it")
-	cmd.PersistentFlags().StringVarP(&f.LogGroup, "log-group", "l", f.LogGroup, "CloudWatch Logs group containing flow logs")
-	cmd.PersistentFlags().IntVarP(&f.Version, "version", "v", f.Version, "VPC Flow Logs format version (2 or 5)")
+	cmd.PersistentFlags().StringArrayVarP(&f.LogGroups, "log-group", "l", f.LogGroups, "CloudWatch Logs group containing flow logs (repeatable to query several groups in one query)")
+	cmd.PersistentFlags().StringVar(&f.LogGroupField, "log-group-field", f.LogGroupField, "StartQuery field to send the log group in: identifier (names or ARNs) or name (names only)")
+	cmd.PersistentFlags().StringVar(&f.Region, "region", f.Region, "AWS region to use (overrides AWS_REGION and the shared config)")
+	cmd.PersistentFlags().StringVar(&f.AWSProfile, "aws-profile", f.AWSProfile, "AWS shared config profile to use (overrides AWS_PROFILE)")
+	cmd.PersistentFlags().IntVarP(&f.Version, "version", "v", f.Version, "VPC Flow Logs format version (2, 3 or 5; others use the closest older version)")
 	cmd.PersistentFlags().BoolVar(&f.UseColor, "color", f.UseColor, "Colorize output (ACCEPT as green, REJECT as red)")
 	cmd.PersistentFlags().BoolVar(&f.NoPtr, "no-ptr", f.NoPtr, "Remove @ptr fields from output")
 	cmd.PersistentFlags().BoolVar(&f.ProtoNames, "proto-names", f.ProtoNames, "Use protocol names instead of numbers")
 	cmd.PersistentFlags().BoolVar(&f.Debug, "debug", f.Debug, "Enable debug output")
 	cmd.PersistentFlags().StringVar(&f.Profile, "profile", "", "Named profile to use (see \"fli profile list\")")
+	cmd.PersistentFlags().DurationVarP(&f.Timeout, "timeout", "t", f.Timeout, "Deadline for the whole command (e.g., 30s, 5m, 1h; default 5m for queries, none otherwise)")
 }
 
 // AddQueryFlags adds common query flags to a command.
 func (f *CommandFlags) AddQueryFlags(cmd *cobra.Command) {
 	cmd.Flags().IntVar(&f.Limit, "limit", f.Limit, "Maximum number of results to return")
-	cmd.Flags().StringVarP(&f.Format, "format", "o", f.Format, "Output format (table, csv, json)")
+	cmd.Flags().StringVarP(&f.Format, "format", "o", f.Format, "Output format (table, wide, csv, json); wide is a table that never truncates values")
+	cmd.Flags().IntVar(&f.MaxWidth, "max-width", f.MaxWidth, "Truncate table values longer than this many characters (0 disables)")
+	cmd.Flags().StringVar(&f.ColumnWidths, "column-widths", f.ColumnWidths, "Per-column table width limits overriding --max-width, comma-separated column=width pairs (0 shows a column in full, e.g., 'srcaddr=0,log_status=6')")
 	cmd.Flags().DurationVarP(&f.Since, "since", "s", f.Since, "Time window to look back (e.g., 5m, 1h, 30s)")
+	cmd.Flags().StringVar(&f.Start, "start", f.Start, "Absolute start of the time window, overriding --since (RFC3339 or '2006-01-02 15:04' local time)")
+	cmd.Flags().StringVar(&f.End, "end", f.End, "Absolute end of the time window (default: now; with --since alone, the window ends here)")
+	cmd.Flags().BoolVar(&f.SinceLast, "since-last", f.SinceLast, "Start where the last successful run of this query on these log groups ended (falls back to --since on the first run)")
+	cmd.Flags().DurationVar(&f.CacheTTL, "cache-ttl", f.CacheTTL, "Reuse the result of an identical query run within this long from the local cache (0 disables)")
+	cmd.Flags().BoolVar(&f.NoCache, "no-cache", f.NoCache, "Run the query without reading or storing a cached result")
+	cmd.Flags().BoolVar(&f.Refresh, "refresh", f.Refresh, "Run the query even if a cached result exists, and cache the new result")
+	cmd.Flags().DurationVar(&f.Watch, "watch", f.Watch, "Re-run the query at this interval, redrawing the results, until interrupted (e.g., 30s)")
 	cmd.Flags().StringVarP(&f.Filter, "filter", "f", f.Filter, "Filter expression (e.g., 'srcaddr=10.0.0.1 and dstport=443')")
+	cmd.Flags().BoolVar(&f.Lint, "lint", f.Lint, "Warn before running when the filter has clauses that match almost everything (e.g., srcaddr like '1')")
 	cmd.Flags().StringVar(&f.By, "by", f.By, "Group by field(s), comma-separated if multiple")
 	cmd.Flags().BoolVar(&f.SaveENIs, "save-enis", false, "Save ENIs found in results to the cache")
 	cmd.Flags().BoolVar(&f.SaveIPs, "save-ips", false, "Save public IPs found in results to the cache")
-	cmd.Flags().DurationVarP(&f.QueryTimeout, "timeout", "t", f.QueryTimeout, "Query timeout (e.g., 30s, 5m, 1h)")
+	cmd.Flags().StringVar(&f.Sort, "sort", f.Sort, "Sort direction of aggregation results (asc or desc)")
+	cmd.Flags().StringVar(&f.SortBy, "sort-by", f.SortBy, "Sort by an aggregation alias or group-by field (default: first aggregation); raw queries sort by any field or @timestamp")
+	cmd.Flags().BoolVar(&f.FailIfEmpty, "fail-if-empty", f.FailIfEmpty, "Exit with a non-zero status when the query returns no results")
+	cmd.Flags().StringVar(&f.FailIf, "fail-if", f.FailIf, "Exit with a non-zero status when a result row meets a condition, as column op number (e.g., 'flows > 1000')")
+	cmd.Flags().BoolVar(&f.Totals, "totals", f.Totals, "Append a TOTAL row to table output summing the sum and count columns (e.g., bytes_sum, flows)")
+	cmd.Flags().BoolVar(&f.HighlightPrivate, "highlight-private", f.HighlightPrivate, "With --color, show private IPs in cyan and public IPs in yellow in table output")
+	cmd.Flags().BoolVar(&f.DecodeFlags, "decode-flags", f.DecodeFlags, "Show tcp_flags bitmasks as flag names, e.g. 18 as SYN,ACK (v3 and later)")
+	cmd.Flags().BoolVar(&f.Humanize, "humanize", f.Humanize, "Show byte columns as sizes (e.g., 1.5 MB) and duration columns as durations (e.g., 2m3s)")
+	cmd.Flags().BoolVar(&f.TypedJSON, "typed-json", f.TypedJSON, "With --format json, emit numeric fields such as bytes and packets as numbers instead of strings")
+	cmd.Flags().BoolVar(&f.StableKeys, "stable-keys", f.StableKeys, "With --format json, order each object's keys as the columns are displayed instead of alphabetically")
+	cmd.Flags().BoolVar(&f.Envelope, "envelope", f.Envelope, "With --format json, emit one document holding the query, time range, statistics, and results")
+	cmd.Flags().BoolVar(&f.Legend, "legend", f.Legend, "Print each distinct annotation once in a legend after the results instead of inline")
+	cmd.Flags().BoolVar(&f.StableSort, "stable-sort", f.StableSort, "Order rows that tie on the sort column by the first --by field, so output is deterministic")
+	cmd.Flags().StringVar(&f.SortOut, "sort-output", f.SortOut, "Sort output rows client-side by any column, as column[:asc|desc]")
+	cmd.Flags().StringVar(&f.Rename, "rename", f.Rename, "Rename output columns, comma-separated column=name pairs (e.g., 'srcaddr=Source')")
+	cmd.Flags().StringVar(&f.Transform, "transform", f.Transform, "Transform output values, comma-separated column=mask|truncate[:n] pairs (e.g., 'account_id=mask')")
+	cmd.Flags().StringVar(&f.SchemaFile, "schema-file", f.SchemaFile, "YAML file defining a custom flow log schema (fields, numeric and computed fields, parse patterns)")
+	cmd.Flags().BoolVar(&f.Sessionize, "sessionize", f.Sessionize, "Group raw flows and their replies into sessions by 5-tuple, with bytes and packets per direction")
+	cmd.Flags().StringVar(&f.Rollup, "rollup", f.Rollup, "Roll up count or sum results by IP prefix, as field:prefix[,prefix...] (e.g., 'srcaddr:24,16')")
+	cmd.Flags().DurationVar(&f.PollInterval, "poll-interval", f.PollInterval, "Initial time between query status checks; lower it for small queries, raise it for huge ones")
+	cmd.Flags().DurationVar(&f.MaxPollInterval, "max-poll-interval", f.MaxPollInterval, "Cap on the back-off between query status checks")
+	cmd.Flags().BoolVar(&f.FixedPoll, "fixed-poll", f.FixedPoll, "Poll query status at a fixed interval instead of backing off (faster for short queries)")
+	cmd.Flags().StringVar(&f.Output, "output", f.Output, "Write the formatted results to this file instead of stdout, creating parent directories ('-' for stdout; no -o shorthand, which is --format)")
+	cmd.Flags().StringArrayVar(&f.AlsoWrite, "also-write", f.AlsoWrite, "Also write the results to a file in another format, as format:path (repeatable, e.g., 'csv:flows.csv')")
+	cmd.Flags().BoolVar(&f.ColumnsFromQuery, "columns-from-query", f.ColumnsFromQuery, "Show raw query columns in the order the fields were requested")
+	cmd.Flags().BoolVar(&f.NoAnnotate, "no-annotate", f.NoAnnotate, "Do not annotate results from the cache")
+	cmd.Flags().StringVar(&f.AnnotateSources, "annotate-sources", f.AnnotateSources, "Only apply these annotation sources, comma-separated from eni, prefix, whois, ptr (default: all)")
+	cmd.Flags().BoolVar(&f.ShowRegion, "show-region", f.ShowRegion, "Include the region of matched cloud provider ranges in annotations, e.g. AWS (52.0.0.0/8), EC2, us-east-1")
+	cmd.Flags().IntVar(&f.WhoisTop, "whois-top", 0, "Run whois lookups for the N most frequent unannotated public IPs (0 disables)")
+	cmd.Flags().IntVar(&f.ResolvePTR, "resolve-ptr", 0, "Add reverse DNS hostnames, resolving the N most frequent uncached public IPs (0 disables)")
+}
+
+// awsTarget names the AWS credentials and region a query runs against, as
+// selected by --aws-profile, --region, and the standard AWS environment variables,
+// so cached results of one account or region are not reused for another.
+func (f *CommandFlags) awsTarget() string {
+	profile := f.AWSProfile
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	region := f.Region
+	if region == "" {
+		region = cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	}
+	return strings.Join([]string{profile, region, os.Getenv("AWS_ACCESS_KEY_ID")}, "\n")
+}
+
+// awsConfigOptions returns the AWS config loader options selected by --region
+// and --aws-profile, which take precedence over the environment and the shared
+// config defaults.
+func (f *CommandFlags) awsConfigOptions() []func(*awsconfig.LoadOptions) error {
+	var opts []func(*awsconfig.LoadOptions) error
+	if f.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(f.Region))
+	}
+	if f.AWSProfile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(f.AWSProfile))
+	}
+	return opts
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	fliconfig "fli/internal/config"
+	"fli/internal/formatter"
+	"fli/internal/runner"
+)
+
+// outputTarget is an additional output requested with --also-write.
+type outputTarget struct {
+	Format string
+	Path   string
+}
+
+// parseOutputTargets parses --also-write values of the form format:path.
+func parseOutputTargets(values []string) ([]outputTarget, error) {
+	targets := make([]outputTarget, 0, len(values))
+	for _, value := range values {
+		format, path, ok := strings.Cut(value, ":")
+		format, path = strings.TrimSpace(format), strings.TrimSpace(path)
+		if !ok || format == "" || path == "" {
+			return nil, fmt.Errorf("invalid output %q: expected format:path", value)
+		}
+		switch format {
+		case "table", "wide", "csv", "json":
+		default:
+			return nil, fmt.Errorf("invalid output %q: unsupported format %q", value, format)
+		}
+		targets = append(targets, outputTarget{Format: format, Path: path})
+	}
+	return targets, nil
+}
+
+// writeResults formats results in the primary format to w, then writes each
+// additional target to its file, creating its parent directories. Files are
+// written without color, only JSON files keep the envelope, and only table
+// files are humanized.
+func writeResults(w io.Writer, results [][]runner.Field, headers []string, options formatter.FormatOptions, stats runner.QueryStatistics, targets []outputTarget) error {
+	output, err := formatter.FormatWithStats(results, headers, options, stats)
+	if err != nil {
+		return fmt.Errorf("failed to format results: %w", err)
+	}
+	if _, err := fmt.Fprint(w, output); err != nil {
+		return fmt.Errorf("failed to write to stdout: %w", err)
+	}
+
+	for _, target := range targets {
+		targetOptions := options
+		targetOptions.Format = target.Format
+		targetOptions.Colorize = false
+		targetOptions.Envelope = options.Envelope && target.Format == "json"
+		targetOptions.Humanize = options.Humanize && formatter.IsTableFormat(target.Format)
+
+		output, err := formatter.FormatWithStats(results, headers, targetOptions, stats)
+		if err != nil {
+			return fmt.Errorf("failed to format results for %s: %w", target.Path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(target.Path), fliconfig.DirPermissions); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", target.Path, err)
+		}
+		if err := os.WriteFile(target.Path, []byte(output), fliconfig.FilePermissions); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target.Path, err)
+		}
+	}
+	return nil
+}
+
+// outputIsFile reports whether an --output value names a file rather than
+// stdout, which is selected by an empty value or "-".
+func outputIsFile(path string) bool {
+	return path != "" && path != "-"
+}
+
+// writeOutput writes the formatted output to stdout, or to the file named by an
+// --output value, creating its parent directories and confirming on stderr.
+func writeOutput(stdout, stderr io.Writer, path string, data []byte) error {
+	if !outputIsFile(path) {
+		if _, err := stdout.Write(data); err != nil {
+			return fmt.Errorf("failed to write to stdout: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), fliconfig.DirPermissions); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, data, fliconfig.FilePermissions); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Fprintf(stderr, "Wrote results to %s\n", path)
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"fli/internal/querybuilder"
@@ -12,13 +13,13 @@ func buildCommandOptions(schema querybuilder.Schema, args []string, cmdFlags *Co
 	var opts []querybuilder.Option
 
 	// Add version
-	opts = append(opts, querybuilder.WithVersion(cmdFlags.Version))
+	opts = append(opts, querybuilder.WithClosestVersion(cmdFlags.Version))
 
 	// Parse verb from first argument
 	if len(args) < 1 {
 		return nil, fmt.Errorf("verb is required")
 	}
-	verb, err := querybuilder.ParseVerb(args[0])
+	verb, verbArg, err := querybuilder.ParseVerbArg(args[0])
 	if err != nil {
 		return nil, fmt.Errorf("invalid verb '%s': %w", args[0], err)
 	}
@@ -32,32 +33,117 @@ func buildCommandOptions(schema querybuilder.Schema, args []string, cmdFlags *Co
 		opts = append(opts, rawOpts...)
 	} else {
 		// Handle aggregation verbs
-		aggOpts, err := buildAggregationVerbOptions(schema, args, verb)
+		aggOpts, err := buildAggregationVerbOptions(schema, args, verb, verbArg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to build aggregation options: %w", err)
 		}
 		opts = append(opts, aggOpts...)
 	}
 
+	// Add sort direction
+	sortOrder, err := querybuilder.ParseSortOrder(cmdFlags.Sort)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --sort: %w", err)
+	}
+	opts = append(opts, querybuilder.WithSortOrder(sortOrder))
+	if cmdFlags.SortBy != "" {
+		opts = append(opts, querybuilder.WithSortField(cmdFlags.SortBy))
+	}
+	if cmdFlags.StableSort {
+		opts = append(opts, querybuilder.WithTieBreak())
+	}
+
+	// Fields in --by and --filter are validated against the effective version so
+	// unknown fields are reported before the query is submitted
+	version := effectiveVersion(schema, cmdFlags.Version)
+
 	// Add group by if --by is set
 	if cmdFlags.By != "" {
-		groupFields := strings.Split(cmdFlags.By, ",")
+		groupFields, err := parseGroupByFields(schema, cmdFlags.By, version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --by: %w", err)
+		}
 		opts = append(opts, querybuilder.WithGroupBy(groupFields...))
 	}
 
 	// Add filter if --filter is set
 	if cmdFlags.Filter != "" {
-		// Parse the filter expression using the querybuilder's parser with schema support
-		filterExpr, err := querybuilder.ParseFilterWithSchema(cmdFlags.Filter, schema)
+		filterExpr, err := querybuilder.ParseFilterForVersion(cmdFlags.Filter, schema, version)
 		if err != nil {
 			return nil, fmt.Errorf("invalid filter expression: %w", err)
 		}
+		if cmdFlags.Lint {
+			for _, warning := range querybuilder.LintFilter(filterExpr) {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+			}
+		}
 		opts = append(opts, querybuilder.WithFilter(filterExpr))
 	}
 
 	return opts, nil
 }
 
+// effectiveVersion returns the version a query will run against: the requested
+// version, or the closest older one when the schema does not support it.
+func effectiveVersion(schema querybuilder.Schema, version int) int {
+	if schema.ValidateVersion(version) != nil {
+		if closest := schema.ClosestSupportedVersion(version); closest != 0 {
+			return closest
+		}
+	}
+	return version
+}
+
+// parseGroupByFields splits a comma-separated --by value and checks each field,
+// including computed fields such as duration, against the schema for the version.
+func parseGroupByFields(schema querybuilder.Schema, by string, version int) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(by, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if err := schema.ValidateField(field, version); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields given")
+	}
+	return fields, nil
+}
+
+// firstGroupByField returns the first field of a --by value other than exclude,
+// the column rows tied on a client-side sort are ordered by, or "" if none.
+func firstGroupByField(by, exclude string) string {
+	for _, field := range strings.Split(by, ",") {
+		if field = strings.TrimSpace(field); field != "" && field != exclude {
+			return field
+		}
+	}
+	return ""
+}
+
+// queryColumns returns the output columns pinned by the query's field list, or nil when
+// the query does not pin them (aggregations and raw queries over all fields).
+func queryColumns(schema querybuilder.Schema, opts []querybuilder.Option) []string {
+	b, err := querybuilder.New(schema, opts...)
+	if err != nil {
+		return nil
+	}
+	return b.Fields()
+}
+
+// queryString returns the query the options build, or an empty string if they are invalid.
+func queryString(schema querybuilder.Schema, opts []querybuilder.Option) string {
+	b, err := querybuilder.New(schema, opts...)
+	if err != nil {
+		return ""
+	}
+	return b.String()
+}
+
 // buildRawVerbOptions builds options for the raw verb.
 func buildRawVerbOptions(args []string) []querybuilder.Option {
 	var opts []querybuilder.Option
@@ -75,17 +161,22 @@ func buildRawVerbOptions(args []string) []querybuilder.Option {
 }
 
 // buildAggregationVerbOptions builds options for aggregation verbs.
-func buildAggregationVerbOptions(_ querybuilder.Schema, args []string, verb querybuilder.Verb) ([]querybuilder.Option, error) {
+// verbArg is the verb's argument, such as the percentile for pct95.
+func buildAggregationVerbOptions(schema querybuilder.Schema, args []string, verb querybuilder.Verb, verbArg float64) ([]querybuilder.Option, error) {
 	opts := []querybuilder.Option{querybuilder.WithVerb(verb)}
-
-	// If no additional arguments, just return the verb option
-	if len(args) <= 1 {
-		return opts, nil
+	if verb == querybuilder.VerbPct {
+		opts = append(opts, querybuilder.WithPercentile(verbArg))
 	}
 
-	// Parse fields from arguments
-	fields := parseFields(args[1:])
+	// Parse fields from arguments; without any, just return the verb option
+	var fields []string
+	if len(args) > 1 {
+		fields = parseFields(args[1:])
+	}
 	if len(fields) == 0 {
+		if verb == querybuilder.VerbCountDistinct {
+			return nil, fmt.Errorf("verb \"distinct\" requires at least one field")
+		}
 		return opts, nil
 	}
 
@@ -95,13 +186,13 @@ func buildAggregationVerbOptions(_ querybuilder.Schema, args []string, verb quer
 	}
 
 	// Create and add aggregations
-	return addAggregationsToOptions(opts, fields, verb)
+	return addAggregationsToOptions(schema, opts, fields, verb, verbArg)
 }
 
 // addAggregationsToOptions creates aggregations for fields and adds them to options.
-func addAggregationsToOptions(opts []querybuilder.Option, fields []string, verb querybuilder.Verb) ([]querybuilder.Option, error) {
+func addAggregationsToOptions(schema querybuilder.Schema, opts []querybuilder.Option, fields []string, verb querybuilder.Verb, verbArg float64) ([]querybuilder.Option, error) {
 	// Create aggregations for each field
-	aggregations, err := createAggregationsForFields(fields, verb)
+	aggregations, err := createAggregationsForFields(schema, fields, verb, verbArg)
 	if err != nil {
 		return nil, err
 	}
@@ -111,18 +202,19 @@ func addAggregationsToOptions(opts []querybuilder.Option, fields []string, verb
 }
 
 // createAggregationsForFields creates aggregation fields for the given fields and verb.
-func createAggregationsForFields(fields []string, verb querybuilder.Verb) ([]querybuilder.AggregationField, error) {
+func createAggregationsForFields(schema querybuilder.Schema, fields []string, verb querybuilder.Verb, verbArg float64) ([]querybuilder.AggregationField, error) {
 	aggregations := make([]querybuilder.AggregationField, 0, len(fields))
 
 	for _, field := range fields {
 		// For non-count verbs, validate that fields are numeric
-		if err := validateFieldForVerb(field, verb); err != nil {
+		if err := validateFieldForVerb(schema, field, verb); err != nil {
 			return nil, fmt.Errorf("field validation failed: %w", err)
 		}
 
 		aggregations = append(aggregations, querybuilder.AggregationField{
 			Verb:  verb,
 			Field: field,
+			Arg:   verbArg,
 		})
 	}
 
@@ -130,14 +222,19 @@ func createAggregationsForFields(fields []string, verb querybuilder.Verb) ([]que
 }
 
 // validateFieldForVerb validates that a field is appropriate for the given verb.
-func validateFieldForVerb(field string, verb querybuilder.Verb) error {
+func validateFieldForVerb(schema querybuilder.Schema, field string, verb querybuilder.Verb) error {
 	// Count verb can use any field
 	if verb == querybuilder.VerbCount || field == "*" {
 		return nil
 	}
 
+	// Distinct values can be counted for any field
+	if verb == querybuilder.VerbCountDistinct {
+		return nil
+	}
+
 	// For other verbs, validate that fields are numeric
-	if !isNumericField(field) {
+	if !schema.IsNumeric(field) {
 		verbStr := strings.ToLower(strings.TrimPrefix(verb.String(), "Verb"))
 		return fmt.Errorf("field %q must be numeric for verb %q", field, verbStr)
 	}
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/spf13/cobra"
 
+	"fli/internal/cache"
 	"fli/internal/formatter"
 	"fli/internal/querybuilder"
 	"fli/internal/runner"
@@ -35,15 +38,23 @@ var NewQueryExecutor = func() QueryExecutorInterface {
 // ExecuteQuery handles the common query execution flow.
 func (e *QueryExecutor) ExecuteQuery(ctx context.Context, _ *cobra.Command, opts []querybuilder.Option, cmdFlags *CommandFlags) ([][]interface{}, runner.QueryStatistics, error) {
 	// Calculate time range
-	end := time.Now()
-	start := end.Add(-cmdFlags.Since)
+	start, end, err := queryTimeRange(cmdFlags, time.Now())
+	if err != nil {
+		return nil, runner.QueryStatistics{}, err
+	}
 
 	// Build query
-	schema := &querybuilder.VPCFlowLogsSchema{}
+	schema, err := querySchema(cmdFlags)
+	if err != nil {
+		return nil, runner.QueryStatistics{}, err
+	}
 	b, err := querybuilder.New(schema, opts...)
 	if err != nil {
 		return nil, runner.QueryStatistics{}, fmt.Errorf("failed to build query: %w", err)
 	}
+	for _, warning := range b.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
 	query := b.String()
 
 	// Enhanced dry-run mode - output YAML configuration
@@ -54,41 +65,130 @@ func (e *QueryExecutor) ExecuteQuery(ctx context.Context, _ *cobra.Command, opts
 		return nil, runner.QueryStatistics{}, nil
 	}
 
+	// Explain mode - print the query and time range without AWS
+	if cmdFlags.explain {
+		return nil, runner.QueryStatistics{}, writeExplanation(os.Stdout, os.Stderr, query, start, end)
+	}
+
 	// Validate log group
-	if cmdFlags.LogGroup == "" {
+	if len(cmdFlags.LogGroups) == 0 {
 		return nil, runner.QueryStatistics{}, fmt.Errorf("log group is required")
 	}
+	if err := runner.ValidateLogGroupNames(cmdFlags.LogGroups); err != nil {
+		return nil, runner.QueryStatistics{}, fmt.Errorf("invalid log group: %w", err)
+	}
+	logGroupField, err := runner.ParseLogGroupField(cmdFlags.LogGroupField)
+	if err != nil {
+		return nil, runner.QueryStatistics{}, err
+	}
+	if cmdFlags.PollInterval <= 0 {
+		return nil, runner.QueryStatistics{}, fmt.Errorf("invalid --poll-interval %v: must be positive", cmdFlags.PollInterval)
+	}
+	if cmdFlags.MaxPollInterval <= 0 {
+		return nil, runner.QueryStatistics{}, fmt.Errorf("invalid --max-poll-interval %v: must be positive", cmdFlags.MaxPollInterval)
+	}
 
-	// Initialize AWS client if not already initialized
-	if e.client == nil {
-		cfg, err := config.LoadDefaultConfig(ctx)
-		if err != nil {
-			return nil, runner.QueryStatistics{}, fmt.Errorf("failed to load AWS config: %w", err)
+	// Resume from the last run's bookmark; the first run uses --since
+	var bookmarkKey, cachePath string
+	if cmdFlags.SinceLast {
+		if cmdFlags.Start != "" {
+			return nil, runner.QueryStatistics{}, fmt.Errorf("--since-last cannot be combined with --start")
+		}
+		if cachePath, err = expandPath(DefaultCachePath); err != nil {
+			return nil, runner.QueryStatistics{}, fmt.Errorf("failed to resolve cache path: %w", err)
+		}
+		bookmarkKey = cache.BookmarkKey(cmdFlags.LogGroups, query)
+		if start, err = sinceLastStart(cachePath, bookmarkKey, start); err != nil {
+			return nil, runner.QueryStatistics{}, err
+		}
+		// Leave the most recent events, which may still be arriving, to the next run
+		if cmdFlags.End == "" {
+			end = end.Add(-sinceLastIngestionLag)
+		}
+		if !start.Before(end) {
+			return nil, runner.QueryStatistics{}, fmt.Errorf("nothing to query: the last run ended at %s", start.Format(time.RFC3339))
 		}
-		e.client = cloudwatchlogs.NewFromConfig(cfg)
 	}
 
-	// Initialize runner if not already initialized
-	if e.runner == nil {
-		e.runner = runner.New(e.client)
+	// Reuse a recent result of the same query over the same window. --since-last
+	// and --watch always want new data, so they skip the cache.
+	var resultKey string
+	if cmdFlags.CacheTTL > 0 && !cmdFlags.NoCache && !cmdFlags.SinceLast && cmdFlags.Watch == 0 {
+		if cachePath == "" {
+			if cachePath, err = expandPath(DefaultCachePath); err != nil {
+				return nil, runner.QueryStatistics{}, fmt.Errorf("failed to resolve cache path: %w", err)
+			}
+		}
+		windowStart, windowEnd := resultWindow(cmdFlags, start, end)
+		resultKey = cache.ResultKey(cmdFlags.awsTarget(), cmdFlags.LogGroups, query, windowStart, windowEnd)
+		if !cmdFlags.Refresh {
+			cached, stored, found, err := cachedQueryResult(cachePath, resultKey, cmdFlags.CacheTTL)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to read cached query result: %v\n", err)
+			} else if found {
+				fmt.Fprintf(os.Stderr, "Using the result cached at %s; run with --refresh to query again\n", stored.Format(time.TimeOnly))
+				return interfaceRows(cached.Results), cached.Statistics, nil
+			}
+		}
 	}
 
-	// Execute query
-	queryResult, err := e.runner.Run(ctx, cmdFlags.LogGroup, query, start.Unix()*MillisecondsPerSecond, end.Unix()*MillisecondsPerSecond)
+	// Initialize runner, and the AWS client it needs, if not already initialized
+	if e.runner == nil {
+		if e.client == nil {
+			cfg, err := loadAWSConfig(ctx, cmdFlags.awsConfigOptions()...)
+			if err != nil {
+				return nil, runner.QueryStatistics{}, err
+			}
+			e.client = cloudwatchlogs.NewFromConfig(cfg)
+		}
+		e.runner = runner.New(e.client,
+			runner.WithPollInterval(cmdFlags.PollInterval),
+			runner.WithMaxPollInterval(cmdFlags.MaxPollInterval))
+	}
+	e.runner.FixedInterval = cmdFlags.FixedPoll
+	e.runner.LogGroupField = logGroupField
+
+	// Execute query, stopping it in CloudWatch if it outlives the query timeout
+	timeout := queryTimeout(cmdFlags)
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	queryResult, err := e.runner.RunMulti(runCtx, cmdFlags.LogGroups, query, start.Unix()*MillisecondsPerSecond, end.Unix()*MillisecondsPerSecond)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, runner.QueryStatistics{}, fmt.Errorf("query exceeded timeout of %s: %w", timeout, err)
+		}
 		return nil, runner.QueryStatistics{}, fmt.Errorf("failed to execute query: %w", err)
 	}
+	// The bookmark only advances once the results are written
+	cmdFlags.sinceLastKey = bookmarkKey
+	if resultKey != "" {
+		if err := storeQueryResult(cachePath, resultKey, queryResult, cmdFlags.CacheTTL); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to cache query result: %v\n", err)
+		}
+	}
+
+	return interfaceRows(queryResult.Results), queryResult.Statistics, nil
+}
 
-	// Convert runner.Field to interface{} for the interface
-	interfaceResults := make([][]interface{}, len(queryResult.Results))
-	for i, row := range queryResult.Results {
-		interfaceResults[i] = make([]interface{}, len(row))
+// interfaceRows converts runner.Field rows to the interface{} rows ExecuteQuery returns.
+func interfaceRows(results [][]runner.Field) [][]interface{} {
+	rows := make([][]interface{}, len(results))
+	for i, row := range results {
+		rows[i] = make([]interface{}, len(row))
 		for j, field := range row {
-			interfaceResults[i][j] = field
+			rows[i][j] = field
 		}
 	}
+	return rows
+}
 
-	return interfaceResults, queryResult.Statistics, nil
+// queryTimeout returns how long a query may run: --timeout, or the default query
+// timeout when it is unset, as for each run of a watch.
+func queryTimeout(cmdFlags *CommandFlags) time.Duration {
+	if cmdFlags.Timeout > 0 {
+		return cmdFlags.Timeout
+	}
+	return defaultTimeouts.Query
 }
 
 // handleDryRunFromQuery extracts verb and fields from a query string and handles dry run output.
@@ -112,10 +212,16 @@ query_timeout: %s
 no_ptr: %t
 proto_names: %t
 use_color: %t`,
-		extractVerbFromQuery(query), cmdFlags.LogGroup, cmdFlags.Since, cmdFlags.Limit,
-		cmdFlags.Version, cmdFlags.Format, cmdFlags.QueryTimeout,
+		extractVerbFromQuery(query), strings.Join(cmdFlags.LogGroups, ", "), cmdFlags.Since, cmdFlags.Limit,
+		cmdFlags.Version, cmdFlags.Format, cmdFlags.Timeout,
 		cmdFlags.NoPtr, cmdFlags.ProtoNames, cmdFlags.UseColor)
 
+	if cmdFlags.Start != "" {
+		output += fmt.Sprintf("\nstart: %s", cmdFlags.Start)
+	}
+	if cmdFlags.End != "" {
+		output += fmt.Sprintf("\nend: %s", cmdFlags.End)
+	}
 	if cmdFlags.Filter != "" {
 		output += fmt.Sprintf("\nfilter: %s", cmdFlags.Filter)
 	}
@@ -142,7 +248,10 @@ func extractVerbFromQuery(query string) string {
 
 // extractAggregationVerb determines the aggregation verb from a query string.
 func extractAggregationVerb(query string) string {
-	if strings.Contains(query, "count(") {
+	if strings.Contains(query, "count_distinct(") && strings.Contains(query, "count(") {
+		// Only the fanout command pairs a distinct count with a flow count
+		return fanoutCommand
+	} else if strings.Contains(query, "count(") {
 		return "count"
 	} else if strings.Contains(query, "sum(") {
 		return "sum"
@@ -152,6 +261,10 @@ func extractAggregationVerb(query string) string {
 		return "min"
 	} else if strings.Contains(query, "max(") {
 		return "max"
+	} else if strings.Contains(query, "pct(") {
+		return "pct"
+	} else if strings.Contains(query, "count_distinct(") {
+		return "distinct"
 	}
 	return ""
 }
@@ -163,92 +276,353 @@ func runVerb(verb querybuilder.Verb) func(cmd *cobra.Command, args []string) err
 		cmdFlags := flags // Use the global flags for now, but pass it as a parameter
 
 		verbStr := strings.ToLower(strings.TrimPrefix(verb.String(), "Verb"))
+		if verb == querybuilder.VerbPct && cmd.CalledAs() != "" {
+			// The alias carries the percentile, e.g. "fli p99 bytes"
+			verbStr = cmd.CalledAs()
+		}
+		if verb == querybuilder.VerbCountDistinct {
+			verbStr = "distinct"
+		}
 		allArgs := append([]string{verbStr}, args...)
-		schema := &querybuilder.VPCFlowLogsSchema{}
+		schema, err := querySchema(cmdFlags)
+		if err != nil {
+			return err
+		}
 		opts, err := buildCommandOptions(schema, allArgs, cmdFlags)
 		if err != nil {
 			return err
 		}
+		var fanout []querybuilder.AggregationField
+		if cmd.Name() == fanoutCommand {
+			if fanout, err = fanoutAggregations(args); err != nil {
+				return err
+			}
+			opts = append(opts, querybuilder.WithAggregations(fanout...))
+		}
 
-		// Regular single query execution
-		results, stats, err := executeQuery(cmd.Context(), cmd, opts, cmdFlags)
+		sortSpec, err := formatter.ParseSortSpec(cmdFlags.SortOut)
+		if err != nil {
+			return fmt.Errorf("invalid --sort-output: %w", err)
+		}
+		if cmdFlags.StableSort {
+			sortSpec.TieBreak = firstGroupByField(cmdFlags.By, sortSpec.Column)
+		}
+		rename, err := formatter.ParseRenameMap(cmdFlags.Rename)
+		if err != nil {
+			return fmt.Errorf("invalid --rename: %w", err)
+		}
+		transformers, err := formatter.ParseTransformers(cmdFlags.Transform)
+		if err != nil {
+			return fmt.Errorf("invalid --transform: %w", err)
+		}
+		columnWidths, err := formatter.ParseColumnWidths(cmdFlags.ColumnWidths)
 		if err != nil {
-			return fmt.Errorf("failed to execute query: %w", err)
+			return fmt.Errorf("invalid --column-widths: %w", err)
+		}
+		failIf, err := formatter.ParseCondition(cmdFlags.FailIf)
+		if err != nil {
+			return fmt.Errorf("invalid --fail-if: %w", err)
+		}
+		if cmdFlags.Envelope && cmdFlags.Format != "json" {
+			return fmt.Errorf("--envelope requires --format json")
+		}
+		outputTargets, err := parseOutputTargets(cmdFlags.AlsoWrite)
+		if err != nil {
+			return fmt.Errorf("invalid --also-write: %w", err)
+		}
+		annotationSources, err := formatter.ParseAnnotationSources(cmdFlags.AnnotateSources)
+		if err != nil {
+			return fmt.Errorf("invalid --annotate-sources: %w", err)
+		}
+		if annotationSources == nil && !formatter.IsTableFormat(cmdFlags.Format) {
+			// ENI labels are a display aid; keep them out of machine-readable
+			// output unless --annotate-sources asks for them.
+			annotationSources = []string{formatter.SourcePrefix, formatter.SourceWhois, formatter.SourcePTR}
+		}
+		rollup, err := formatter.ParseRollupSpec(cmdFlags.Rollup)
+		if err != nil {
+			return fmt.Errorf("invalid --rollup: %w", err)
+		}
+		var rollupKeys []string
+		if rollup.Field != "" {
+			// Only counts and sums can be added up across addresses
+			if verb != querybuilder.VerbCount && verb != querybuilder.VerbSum {
+				return fmt.Errorf("--rollup requires the count or sum verb")
+			}
+			if cmdFlags.By == "" {
+				return fmt.Errorf("--rollup requires --by")
+			}
+			rollupKeys, err = parseGroupByFields(schema, cmdFlags.By, effectiveVersion(schema, cmdFlags.Version))
+			if err != nil {
+				return fmt.Errorf("invalid --by: %w", err)
+			}
+		}
+
+		if cmdFlags.Sessionize && verb != querybuilder.VerbRaw {
+			return fmt.Errorf("--sessionize requires the raw verb")
 		}
 
-		// If this is a dry run, we're done
-		if cmdFlags.DryRun {
-			return nil
+		if cmdFlags.Watch < 0 {
+			return fmt.Errorf("invalid --watch %v: must be positive", cmdFlags.Watch)
 		}
+		if cmdFlags.Watch > 0 && cmdFlags.DryRun {
+			return fmt.Errorf("--watch cannot be combined with --dry-run")
+		}
+		if cmdFlags.Watch > 0 && outputIsFile(cmdFlags.Output) {
+			return fmt.Errorf("--watch cannot be combined with --output")
+		}
+
+		// run executes the query once and writes its formatted results
+		run := func(ctx context.Context) error {
+			results, stats, err := executeQuery(ctx, cmd, opts, cmdFlags)
+			if err != nil {
+				return fmt.Errorf("failed to execute query: %w", err)
+			}
 
-		// Convert interface{} results back to runner.Field
-		fieldResults := make([][]runner.Field, len(results))
-		for i, row := range results {
-			fieldResults[i] = make([]runner.Field, len(row))
-			for j, field := range row {
-				if f, ok := field.(runner.Field); ok {
-					fieldResults[i][j] = f
+			// If this is a dry run or an explain, we're done
+			if cmdFlags.DryRun || cmdFlags.explain {
+				return nil
+			}
+
+			if cmdFlags.Debug {
+				if window := formatter.FormatQueryWindow(stats); window != "" {
+					fmt.Fprintf(os.Stderr, "Debug: queried window %s\n", window)
 				}
 			}
-		}
 
-		// Enrich results with message data
-		enrichedResults := formatter.EnrichResultsWithMessageData(fieldResults)
+			// Convert interface{} results back to runner.Field
+			fieldResults := make([][]runner.Field, len(results))
+			for i, row := range results {
+				fieldResults[i] = make([]runner.Field, len(row))
+				for j, field := range row {
+					if f, ok := field.(runner.Field); ok {
+						fieldResults[i][j] = f
+					}
+				}
+			}
 
-		// Automatically enrich with annotations if the cache exists.
-		cachePath, err := expandPath(DefaultCachePath)
-		if err != nil {
-			// This is unlikely, but handle it. Don't annotate.
-			fmt.Fprintf(os.Stderr, "Warning: could not expand cache path: %v\n", err)
-		} else {
-			// Attempt to annotate. If it fails, print a warning and continue.
-			annotatedResults, err := formatter.EnrichResultsWithAnnotations(enrichedResults, cachePath)
+			// Enrich results with message data
+			enrichedResults := formatter.EnrichResultsWithMessageData(fieldResults)
+
+			if len(fanout) > 0 {
+				enrichedResults, err = formatter.AddRatioColumn(enrichedResults, formatter.FanoutColumn, fanout[0].Alias(), fanout[1].Alias())
+				if err != nil {
+					return fmt.Errorf("failed to compute fanout: %w", err)
+				}
+			}
+
+			// Roll up per-address results by prefix before annotating, since the
+			// annotations describe single addresses
+			enrichedResults, err = formatter.RollupResults(enrichedResults, rollupKeys, rollup)
 			if err != nil {
-				// Non-fatal error, just print to stderr and continue
-				fmt.Fprintf(os.Stderr, "Warning: Failed to enrich results with annotations: %v\n", err)
-			} else {
-				// If successful, use the annotated results.
-				enrichedResults = annotatedResults
+				return fmt.Errorf("failed to roll up results: %w", err)
 			}
-		}
 
-		// Handle cases where there are no results to display
-		if len(enrichedResults) == 0 {
-			if !cmdFlags.DryRun {
-				if _, err := fmt.Fprintln(os.Stdout, "No results found."); err != nil {
-					return fmt.Errorf("failed to write to stdout: %w", err)
+			// Pair raw flows with their replies, before annotating the session endpoints
+			if cmdFlags.Sessionize {
+				if enrichedResults, err = formatter.SessionizeResults(enrichedResults); err != nil {
+					return fmt.Errorf("failed to sessionize results: %w", err)
 				}
 			}
-			return nil
-		}
 
-		// Build headers from enriched results
-		headers := make([]string, 0, len(enrichedResults[0]))
-		for _, field := range enrichedResults[0] {
-			if field.Name != "@ptr" {
-				headers = append(headers, field.Name)
+			// Automatically enrich with annotations if the cache exists.
+			cachePath, err := expandPath(DefaultCachePath)
+			switch {
+			case cmdFlags.NoAnnotate:
+				// Annotations were turned off; leave the results as they are.
+			case err != nil:
+				// This is unlikely, but handle it. Don't annotate.
+				fmt.Fprintf(os.Stderr, "Warning: could not expand cache path: %v\n", err)
+			default:
+				// Attempt to annotate. If it fails, print a warning and continue.
+				annotationOptions := formatter.AnnotationOptions{
+					WhoisTopN:  cmdFlags.WhoisTop,
+					PTRTopN:    cmdFlags.ResolvePTR,
+					Sources:    annotationSources,
+					ShowRegion: cmdFlags.ShowRegion,
+				}
+				if cmdFlags.WhoisTop > 0 {
+					if annotationOptions.WhoisServers, err = configuredWhoisServers(); err != nil {
+						fmt.Fprintf(os.Stderr, "Warning: ignoring whois_servers: %v\n", err)
+					}
+				}
+				annotatedResults, err := formatter.EnrichResultsWithAnnotationOptions(enrichedResults, cachePath, annotationOptions)
+				if err != nil {
+					// Non-fatal error, just print to stderr and continue
+					fmt.Fprintf(os.Stderr, "Warning: Failed to enrich results with annotations: %v\n", err)
+				} else {
+					// If successful, use the annotated results.
+					enrichedResults = annotatedResults
+				}
+			}
+
+			// Keep raw query columns in the order the fields were requested; sessions
+			// have their own columns
+			if cmdFlags.ColumnsFromQuery && !cmdFlags.Sessionize {
+				if columns := queryColumns(schema, opts); len(columns) > 0 {
+					enrichedResults = formatter.SelectColumns(enrichedResults, columns)
+				}
+			}
+
+			// Move annotations out of the rows into a single legend
+			var legend []formatter.LegendEntry
+			if cmdFlags.Legend {
+				enrichedResults, legend = formatter.ExtractLegend(enrichedResults)
+			}
+
+			// Handle cases where there are no results to display; an envelope still
+			// records the query and its statistics
+			if len(enrichedResults) == 0 && !cmdFlags.Envelope {
+				if cmdFlags.DryRun {
+					return nil
+				}
+				if err := writeOutput(os.Stdout, os.Stderr, cmdFlags.Output, []byte("No results found.\n")); err != nil {
+					return err
+				}
+				advanceSinceLast(cmdFlags, stats)
+				return checkFailConditions(cmd, enrichedResults, cmdFlags.FailIfEmpty, failIf)
+			}
+
+			// Build headers from every row, as annotations are only added to the
+			// rows whose ENI or IP is cached
+			var headers []string
+			for _, name := range formatter.ResultHeaders(enrichedResults) {
+				if name != "@ptr" {
+					headers = append(headers, name)
+				}
+			}
+
+			// Format options
+			formatOptions := formatter.FormatOptions{
+				Format:           cmdFlags.Format,
+				Colorize:         cmdFlags.UseColor,
+				UseProtoNames:    cmdFlags.ProtoNames,
+				DecodeTCPFlags:   cmdFlags.DecodeFlags,
+				Debug:            cmdFlags.Debug,
+				Sort:             sortSpec,
+				Rename:           rename,
+				Transformers:     transformers,
+				Envelope:         cmdFlags.Envelope,
+				MaxWidth:         cmdFlags.MaxWidth,
+				ColumnWidths:     columnWidths,
+				TypedJSON:        cmdFlags.TypedJSON,
+				StableKeys:       cmdFlags.StableKeys,
+				Humanize:         cmdFlags.Humanize,
+				ShowTotals:       cmdFlags.Totals,
+				HighlightPrivate: cmdFlags.HighlightPrivate,
+				NumericField:     numericResultField(schema, effectiveVersion(schema, cmdFlags.Version)),
+			}
+			if cmdFlags.Envelope {
+				formatOptions.Query = queryString(schema, opts)
 			}
-		}
 
-		// Format options
-		formatOptions := formatter.FormatOptions{
-			Format:        cmdFlags.Format,
-			Colorize:      cmdFlags.UseColor,
-			UseProtoNames: cmdFlags.ProtoNames,
-			Debug:         cmdFlags.Debug,
+			if outputIsFile(cmdFlags.Output) {
+				formatOptions.Colorize = false
+			}
+
+			// Format the results with statistics, to stdout (or --output) and any
+			// --also-write files
+			var out bytes.Buffer
+			if err := writeResults(&out, enrichedResults, headers, formatOptions, stats, outputTargets); err != nil {
+				return err
+			}
+
+			if legendOutput := formatter.FormatLegend(legend); legendOutput != "" {
+				// Keep machine-readable output parseable by sending the legend to stderr
+				var legendWriter io.Writer = &out
+				if !formatter.IsTableFormat(cmdFlags.Format) {
+					legendWriter = os.Stderr
+				}
+				if _, err := fmt.Fprint(legendWriter, legendOutput); err != nil {
+					return fmt.Errorf("failed to write legend: %w", err)
+				}
+			}
+			if err := writeOutput(os.Stdout, os.Stderr, cmdFlags.Output, out.Bytes()); err != nil {
+				return err
+			}
+			advanceSinceLast(cmdFlags, stats)
+			return checkFailConditions(cmd, enrichedResults, cmdFlags.FailIfEmpty, failIf)
 		}
 
-		// Format the results with statistics
-		output, err := formatter.FormatWithStats(enrichedResults, headers, formatOptions, stats)
-		if err != nil {
-			return fmt.Errorf("failed to format results: %w", err)
+		if cmdFlags.Watch > 0 {
+			return watchQuery(cmd.Context(), os.Stdout, cmdFlags.Watch, run)
 		}
+		return run(cmd.Context())
+	}
+}
 
-		if _, err := fmt.Fprint(os.Stdout, output); err != nil {
-			return fmt.Errorf("failed to write to stdout: %w", err)
+// checkFailConditions returns an error, and so a non-zero exit status, when
+// failIfEmpty is set and there are no results, or when a row of results meets
+// the --fail-if condition. Usage is not printed for these errors since the
+// command itself succeeded.
+func checkFailConditions(cmd *cobra.Command, results [][]runner.Field, failIfEmpty bool, failIf formatter.Condition) error {
+	if len(results) == 0 {
+		if failIfEmpty {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("query returned no results (--fail-if-empty)")
 		}
 		return nil
 	}
+
+	row, err := failIf.FirstMatch(results)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate --fail-if: %w", err)
+	}
+	if row != nil {
+		cmd.SilenceUsage = true
+		var value string
+		for _, field := range row {
+			if field.Name == failIf.Column {
+				value = field.Value
+				break
+			}
+		}
+		return fmt.Errorf("condition met: %s is %s (--fail-if '%s')", failIf.Column, value, failIf)
+	}
+	return nil
+}
+
+// fanoutCommand is the name of the command that divides count_distinct(field) by
+// count(*) per group.
+const fanoutCommand = "fanout"
+
+// fanoutAggregations returns the two aggregations whose ratio the fanout command
+// reports: the distinct count of its single field, then the flow count.
+func fanoutAggregations(args []string) ([]querybuilder.AggregationField, error) {
+	fields := parseFields(args)
+	if len(fields) != 1 || fields[0] == "" {
+		return nil, fmt.Errorf("%s requires exactly one field, got %q", fanoutCommand, strings.Join(args, " "))
+	}
+	return []querybuilder.AggregationField{
+		{Field: fields[0], Verb: querybuilder.VerbCountDistinct},
+		{Field: "*", Verb: querybuilder.VerbCount},
+	}, nil
+}
+
+// numericResultField reports whether a result column may hold a number: a field
+// the schema marks numeric, or a column the schema does not define, such as an
+// aggregation like count or sum_bytes.
+func numericResultField(schema querybuilder.Schema, version int) func(string) bool {
+	return func(field string) bool {
+		return schema.IsNumeric(field) || schema.ValidateField(field, version) != nil
+	}
+}
+
+// querySchema returns the schema loaded from --schema-file, or the built-in
+// VPC Flow Logs schema when no file is given.
+func querySchema(cmdFlags *CommandFlags) (querybuilder.Schema, error) {
+	if cmdFlags.SchemaFile == "" {
+		return &querybuilder.VPCFlowLogsSchema{}, nil
+	}
+	path, err := expandPath(cmdFlags.SchemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --schema-file: %w", err)
+	}
+	schema, err := querybuilder.LoadCustomSchema(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --schema-file: %w", err)
+	}
+	return schema, nil
 }
 
 // For testing.
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"fli/internal/cache"
+	"fli/internal/runner"
+)
+
+// resultWindow describes a query's time window for its result cache key. Times
+// set with --start or --end are fixed, but a window ending now is described
+// relative to the run, e.g. "now-1h0m0s" to "now", so that repeating a --since
+// query can reuse its result until the cache TTL expires.
+func resultWindow(cmdFlags *CommandFlags, start, end time.Time) (string, string) {
+	windowEnd := "now"
+	if cmdFlags.End != "" {
+		windowEnd = end.UTC().Format(time.RFC3339)
+	}
+	windowStart := "now-" + cmdFlags.Since.String()
+	if cmdFlags.Start != "" || cmdFlags.End != "" {
+		windowStart = start.UTC().Format(time.RFC3339)
+	}
+	return windowStart, windowEnd
+}
+
+// cachedQueryResult returns the result stored under key in the cache at
+// cachePath, and when it was stored, if it is no older than ttl.
+func cachedQueryResult(cachePath, key string, ttl time.Duration) (runner.QueryResult, time.Time, bool, error) {
+	cacheObj, err := cache.Open(cachePath)
+	if err != nil {
+		return runner.QueryResult{}, time.Time{}, false, fmt.Errorf("failed to open cache: %w", err)
+	}
+	defer func() {
+		if closeErr := cacheObj.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close cache: %v\n", closeErr)
+		}
+	}()
+	return cacheObj.QueryResult(key, ttl)
+}
+
+// storeQueryResult stores result under key in the cache at cachePath, dropping
+// stored results older than ttl.
+func storeQueryResult(cachePath, key string, result runner.QueryResult, ttl time.Duration) error {
+	cacheObj, err := cache.Open(cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open cache: %w", err)
+	}
+	defer func() {
+		if closeErr := cacheObj.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close cache: %v\n", closeErr)
+		}
+	}()
+	return cacheObj.SetQueryResult(key, result, ttl)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"fli/internal/cache"
+	"fli/internal/runner"
+)
+
+// timeFlagLayouts are the layouts --start and --end accept, tried in order.
+var timeFlagLayouts = []string{time.RFC3339, "2006-01-02 15:04"}
+
+// sinceLastIngestionLag is how long before now a --since-last window ends. Flow
+// logs reach CloudWatch minutes after the traffic they record, so events newer
+// than this may still be arriving and are left to the next run.
+const sinceLastIngestionLag = 10 * time.Minute
+
+// maxLogRetention is the longest retention CloudWatch Logs offers (10 years), so
+// no log group holds events older than this.
+const maxLogRetention = 3653 * 24 * time.Hour
+
+// parseTimeFlag parses a --start or --end value as RFC3339 (2024-05-01T14:30:00Z)
+// or as "2006-01-02 15:04" in loc.
+func parseTimeFlag(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range timeFlagLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339 (2006-01-02T15:04:05Z07:00) or 2006-01-02 15:04", value)
+}
+
+// queryTimeRange returns the window to query. Without --start and --end it is
+// the --since window ending now. --start alone runs until now, and --end alone
+// starts --since before it. The window must not reach into the future or back
+// past CloudWatch's maximum retention.
+func queryTimeRange(cmdFlags *CommandFlags, now time.Time) (time.Time, time.Time, error) {
+	end := now
+	if cmdFlags.End != "" {
+		t, err := parseTimeFlag(cmdFlags.End, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --end: %w", err)
+		}
+		end = t
+	}
+	start := end.Add(-cmdFlags.Since)
+	if cmdFlags.Start != "" {
+		t, err := parseTimeFlag(cmdFlags.Start, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --start: %w", err)
+		}
+		start = t
+	}
+
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid time range: start %s is not before end %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	if end.After(now) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid time range: end %s is in the future", end.Format(time.RFC3339))
+	}
+	if start.Before(now.Add(-maxLogRetention)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid time range: start %s is older than CloudWatch Logs retains events", start.Format(time.RFC3339))
+	}
+	return start, end, nil
+}
+
+// sinceLastStart returns the start of a --since-last window: the end of the
+// last successful run recorded under key in the cache, or fallback when the
+// query has not run before.
+func sinceLastStart(cachePath, key string, fallback time.Time) (time.Time, error) {
+	cacheObj, err := cache.Open(cachePath)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to open cache for --since-last: %w", err)
+	}
+	defer func() {
+		if closeErr := cacheObj.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close cache: %v\n", closeErr)
+		}
+	}()
+
+	last, found, err := cacheObj.Bookmark(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !found {
+		return fallback, nil
+	}
+	return last, nil
+}
+
+// advanceSinceLast moves the bookmark of a --since-last run to the end of the
+// window it queried, once its results are written. A failure only means the next
+// run overlaps this one, so it is a warning.
+func advanceSinceLast(cmdFlags *CommandFlags, stats runner.QueryStatistics) {
+	if cmdFlags.sinceLastKey == "" {
+		return
+	}
+	key := cmdFlags.sinceLastKey
+	cmdFlags.sinceLastKey = ""
+
+	cachePath, err := expandPath(DefaultCachePath)
+	if err == nil {
+		err = saveBookmark(cachePath, key, time.UnixMilli(stats.EndTime))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save --since-last bookmark: %v\n", err)
+	}
+}
+
+// saveBookmark advances the --since-last bookmark under key to end.
+func saveBookmark(cachePath, key string, end time.Time) error {
+	cacheObj, err := cache.Open(cachePath)
+	if err != nil {
+		return fmt.Errorf("failed to open cache for --since-last: %w", err)
+	}
+	defer func() {
+		if closeErr := cacheObj.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close cache: %v\n", closeErr)
+		}
+	}()
+	return cacheObj.SetBookmark(key, end)
+}
@@ -1,11 +1,35 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+
+	fliaws "fli/internal/aws"
 )
 
+// checkCredentials verifies that an AWS configuration holds credentials. It is a
+// variable so tests can exercise the missing-credentials path.
+var checkCredentials = fliaws.CheckCredentials
+
+// loadAWSConfig loads the default AWS configuration and checks its credentials,
+// so that a missing profile fails with a clear message before the first API call
+// rather than deep inside it.
+func loadAWSConfig(ctx context.Context, optFns ...func(*awsconfig.LoadOptions) error) (awssdk.Config, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return awssdk.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if err := checkCredentials(ctx, cfg.Credentials); err != nil {
+		return awssdk.Config{}, err
+	}
+	return cfg, nil
+}
+
 // expandPath expands a path with ~ to the user's home directory.
 func expandPath(path string) (string, error) {
 	if !strings.HasPrefix(path, "~") {
@@ -37,12 +61,3 @@ func parseFields(args []string) []string {
 
 	return fields
 }
-
-// isNumericField returns true if the field is a known numeric field.
-func isNumericField(field string) bool {
-	numericFields := map[string]bool{
-		"bytes":   true,
-		"packets": true,
-	}
-	return numericFields[field]
-}
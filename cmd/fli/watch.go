@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+// watchQuery calls run every interval until ctx is done or the user interrupts
+// with Ctrl-C, clearing the screen and printing a timestamp header before each
+// run. Each run is bounded by the default query timeout. An interrupt ends the
+// watch without an error; an error from run ends it with that error.
+func watchQuery(ctx context.Context, w io.Writer, interval time.Duration, run func(context.Context) error) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		fmt.Fprintf(w, "%sEvery %s: %s\n\n", clearScreen, interval, time.Now().Format(time.RFC3339))
+
+		runCtx, cancel := context.WithTimeout(ctx, defaultTimeouts.Query)
+		err := run(runCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -86,6 +86,31 @@ func LogGroupExists(ctx context.Context, client CloudWatchLogsManagementAPI, nam
 	return false, nil
 }
 
+// maxDescribeLogGroups is the most log groups DescribeLogGroups returns in one page.
+const maxDescribeLogGroups = 50
+
+// ListLogGroupNames returns the names of up to limit log groups whose names start
+// with prefix, or of any log groups when prefix is empty. It reads a single page,
+// so limit is capped at 50.
+func ListLogGroupNames(ctx context.Context, client CloudWatchLogsManagementAPI, prefix string, limit int) ([]string, error) {
+	input := &cloudwatchlogs.DescribeLogGroupsInput{
+		Limit: awssdk.Int32(int32(min(max(limit, 1), maxDescribeLogGroups))), //nolint:gosec // bounded to [1, 50]
+	}
+	if prefix != "" {
+		input.LogGroupNamePrefix = awssdk.String(prefix)
+	}
+	resp, err := client.DescribeLogGroups(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe log groups: %w", err)
+	}
+
+	names := make([]string, 0, len(resp.LogGroups))
+	for _, lg := range resp.LogGroups {
+		names = append(names, awssdk.ToString(lg.LogGroupName))
+	}
+	return names, nil
+}
+
 // FlowLogLogGroupName generates the default log group name for a resource.
 func FlowLogLogGroupName(resourceID string) string {
 	return fmt.Sprintf("/fli/flow-logs/%s", resourceID)
@@ -0,0 +1,59 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// mockLogsAPI implements CloudWatchLogsManagementAPI, answering DescribeLogGroups
+// from a fixed list of names.
+type mockLogsAPI struct {
+	CloudWatchLogsManagementAPI
+	names []string
+	err   error
+	input *cloudwatchlogs.DescribeLogGroupsInput
+}
+
+func (m *mockLogsAPI) DescribeLogGroups(_ context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	m.input = params
+	if m.err != nil {
+		return nil, m.err
+	}
+	out := &cloudwatchlogs.DescribeLogGroupsOutput{}
+	for _, name := range m.names {
+		out.LogGroups = append(out.LogGroups, types.LogGroup{LogGroupName: aws.String(name)})
+	}
+	return out, nil
+}
+
+func TestListLogGroupNames(t *testing.T) {
+	client := &mockLogsAPI{names: []string{"/aws/vpc/prod", "/aws/vpc/staging"}}
+	names, err := ListLogGroupNames(context.Background(), client, "/aws/vpc", 100)
+	if err != nil {
+		t.Fatalf("ListLogGroupNames() error = %v", err)
+	}
+	if !reflect.DeepEqual(names, client.names) {
+		t.Errorf("ListLogGroupNames() = %v, want %v", names, client.names)
+	}
+	if got := aws.ToString(client.input.LogGroupNamePrefix); got != "/aws/vpc" {
+		t.Errorf("prefix sent = %q, want /aws/vpc", got)
+	}
+	if got := aws.ToInt32(client.input.Limit); got != 50 {
+		t.Errorf("limit sent = %d, want it capped at 50", got)
+	}
+
+	if _, err := ListLogGroupNames(context.Background(), client, "", 10); err != nil || client.input.LogGroupNamePrefix != nil {
+		t.Errorf("ListLogGroupNames() with no prefix = %v, sent prefix %v, want no prefix", err, client.input.LogGroupNamePrefix)
+	}
+
+	client.err = errors.New("access denied")
+	if _, err := ListLogGroupNames(context.Background(), client, "/aws", 10); err == nil {
+		t.Error("ListLogGroupNames() with a failing client: error = nil, want an error")
+	}
+}
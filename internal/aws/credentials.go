@@ -0,0 +1,41 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ErrNoCredentials is returned by CheckCredentials when no AWS credentials can
+// be found.
+var ErrNoCredentials = errors.New("no AWS credentials found; configure a profile or env vars")
+
+// imdsNoRole is how the SDK's default credential chain reports that it fell
+// through to the EC2 instance role and found none, i.e. that nothing is
+// configured. The SDK has no typed error for this case.
+const imdsNoRole = "no EC2 IMDS role found"
+
+// CheckCredentials retrieves credentials from provider so that a missing profile
+// or unset environment variables fail before the first API call, rather than
+// deep inside it. When no credentials are configured the error wraps
+// ErrNoCredentials and the provider's reason; other failures, such as an expired
+// SSO token or a denied role assumption, are returned as they are.
+func CheckCredentials(ctx context.Context, provider awssdk.CredentialsProvider) error {
+	if provider == nil {
+		return ErrNoCredentials
+	}
+	creds, err := provider.Retrieve(ctx)
+	if err != nil {
+		if !strings.Contains(err.Error(), imdsNoRole) {
+			return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+		}
+		return fmt.Errorf("%w\n\n  Set one of:\n    export AWS_PROFILE=<profile>\n    export AWS_ACCESS_KEY_ID=... AWS_SECRET_ACCESS_KEY=...\n    aws configure\n\n  Reason: %w", ErrNoCredentials, err)
+	}
+	if !creds.HasKeys() {
+		return ErrNoCredentials
+	}
+	return nil
+}
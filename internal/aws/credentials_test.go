@@ -0,0 +1,70 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestCheckCredentials(t *testing.T) {
+	noProvider := errors.New("failed to refresh cached credentials, no EC2 IMDS role found")
+	tests := []struct {
+		name     string
+		provider aws.CredentialsProvider
+		wantErr  bool
+	}{
+		{name: "no provider", provider: nil, wantErr: true},
+		{
+			name: "retrieve fails",
+			provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, noProvider
+			}),
+			wantErr: true,
+		},
+		{
+			name: "empty credentials",
+			provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{}, nil
+			}),
+			wantErr: true,
+		},
+		{
+			name: "static credentials",
+			provider: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+				return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCredentials(context.Background(), tt.provider)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("CheckCredentials() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrNoCredentials) {
+				t.Errorf("CheckCredentials() error = %v, want ErrNoCredentials", err)
+			}
+		})
+	}
+
+	// The provider's reason is kept for debugging
+	err := CheckCredentials(context.Background(), tests[1].provider)
+	if !errors.Is(err, noProvider) {
+		t.Errorf("CheckCredentials() error = %v, want it to wrap the provider error", err)
+	}
+
+	// Configured credentials that fail are not reported as missing
+	expired := errors.New("failed to refresh cached credentials, the SSO session has expired or is invalid")
+	err = CheckCredentials(context.Background(), aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, expired
+	}))
+	if errors.Is(err, ErrNoCredentials) || !errors.Is(err, expired) {
+		t.Errorf("CheckCredentials() error = %v, want the expired token error without ErrNoCredentials", err)
+	}
+}
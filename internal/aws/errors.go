@@ -75,6 +75,7 @@ var permissionHints = map[string]string{
 	"DescribeLogGroups":       "logs:DescribeLogGroups",
 	"StartQuery":              "logs:StartQuery",
 	"GetQueryResults":         "logs:GetQueryResults",
+	"StopQuery":               "logs:StopQuery",
 	"SimulatePrincipalPolicy": "iam:SimulatePrincipalPolicy",
 }
 
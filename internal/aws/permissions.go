@@ -61,6 +61,7 @@ var CleanupActions = []string{
 var QueryActions = []string{
 	"logs:StartQuery",
 	"logs:GetQueryResults",
+	"logs:StopQuery",
 	"logs:DescribeLogGroups",
 }
 
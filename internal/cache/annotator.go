@@ -11,25 +11,42 @@ import (
 )
 
 // IPAnnotator provides efficient IP address annotation using CIDR prefix matching.
+// Prefixes are stored in a binary trie keyed on address bits, so masks that do not
+// fall on a byte boundary (e.g. /12 or /20) match exactly the addresses they contain.
 type IPAnnotator struct {
 	mu      sync.RWMutex
-	root    *annotatorNode
+	root    *annotatorNode        // IPv4 prefixes
+	root6   *annotatorNode        // IPv6 prefixes
 	entries map[string]*PrefixTag // CIDR -> PrefixTag for quick lookups
 }
 
 type annotatorNode struct {
-	children map[byte]*annotatorNode
+	children [2]*annotatorNode
 	prefix   *PrefixTag
 }
 
 // NewIPAnnotator creates a new IP annotator for efficient CIDR lookups.
 func NewIPAnnotator() *IPAnnotator {
 	return &IPAnnotator{
-		root:    &annotatorNode{children: make(map[byte]*annotatorNode)},
+		root:    &annotatorNode{},
+		root6:   &annotatorNode{},
 		entries: make(map[string]*PrefixTag),
 	}
 }
 
+// rootFor returns the trie root for the address family of addr.
+func (ia *IPAnnotator) rootFor(addr netip.Addr) *annotatorNode {
+	if addr.Is4() {
+		return ia.root
+	}
+	return ia.root6
+}
+
+// addrBit returns bit i of addr, counting from the most significant bit.
+func addrBit(bytes []byte, i int) int {
+	return int(bytes[i/8]>>(7-i%8)) & 1
+}
+
 // Insert adds a CIDR prefix to the annotator.
 func (ia *IPAnnotator) Insert(prefix *PrefixTag) error {
 	ia.mu.Lock()
@@ -40,56 +57,48 @@ func (ia *IPAnnotator) Insert(prefix *PrefixTag) error {
 	if err != nil {
 		return NewValidationError("insert_prefix", prefix.CIDR, "invalid CIDR format")
 	}
+	parsed = parsed.Masked()
 
 	// Store in quick lookup map
 	ia.entries[prefix.CIDR] = prefix
 
-	// Build annotator path from IP bytes
-	addr := parsed.Addr()
-	bytes := addr.AsSlice()
-	current := ia.root
-	for i, b := range bytes {
-		if current.children == nil {
-			current.children = make(map[byte]*annotatorNode)
-		}
-
-		if current.children[b] == nil {
-			current.children[b] = &annotatorNode{}
-		}
-		current = current.children[b]
-
-		// Store prefix at this level if it's the most specific match so far
-		if i >= parsed.Bits()-1 {
-			current.prefix = prefix
+	// Walk one node per mask bit and store the prefix at the last one
+	bytes := parsed.Addr().AsSlice()
+	current := ia.rootFor(parsed.Addr())
+	for i := 0; i < parsed.Bits(); i++ {
+		bit := addrBit(bytes, i)
+		if current.children[bit] == nil {
+			current.children[bit] = &annotatorNode{}
 		}
+		current = current.children[bit]
 	}
+	current.prefix = prefix
 
 	return nil
 }
 
-// Lookup finds the longest matching prefix for an IP address.
+// Lookup finds the longest matching prefix for an IP address. As with
+// Cache.LookupIP, a /0 prefix never matches.
 func (ia *IPAnnotator) Lookup(addr netip.Addr) *PrefixTag {
 	ia.mu.RLock()
 	defer ia.mu.RUnlock()
 
+	if !addr.IsValid() || addr.Zone() != "" {
+		return nil
+	}
+
 	bytes := addr.AsSlice()
-	current := ia.root
+	current := ia.rootFor(addr)
 	var bestMatch *PrefixTag
 
-	for _, b := range bytes {
-		if current.children == nil {
-			break
-		}
-
-		child, exists := current.children[b]
-		if !exists {
+	for i := 0; i < addr.BitLen(); i++ {
+		current = current.children[addrBit(bytes, i)]
+		if current == nil {
 			break
 		}
-
-		if child.prefix != nil {
-			bestMatch = child.prefix
+		if current.prefix != nil {
+			bestMatch = current.prefix
 		}
-		current = child
 	}
 
 	return bestMatch
@@ -100,9 +109,27 @@ func (ia *IPAnnotator) Remove(cidr string) {
 	ia.mu.Lock()
 	defer ia.mu.Unlock()
 
+	tag, ok := ia.entries[cidr]
+	if !ok {
+		return
+	}
 	delete(ia.entries, cidr)
-	// Note: Full annotator cleanup would be more complex
-	// For now, we just remove from the quick lookup map
+
+	parsed, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return
+	}
+	parsed = parsed.Masked()
+
+	bytes := parsed.Addr().AsSlice()
+	current := ia.rootFor(parsed.Addr())
+	for i := 0; i < parsed.Bits() && current != nil; i++ {
+		current = current.children[addrBit(bytes, i)]
+	}
+	// Another CIDR string may have masked to the same prefix since
+	if current != nil && current.prefix == tag {
+		current.prefix = nil
+	}
 }
 
 // GetAll returns all prefixes in the annotator.
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"net/netip"
+	"path/filepath"
+	"testing"
+)
+
+func TestIPAnnotatorLongestMatch(t *testing.T) {
+	prefixes := []PrefixTag{
+		{CIDR: "10.0.0.0/8", Cloud: "A"},
+		{CIDR: "10.0.0.0/12", Cloud: "B"},
+		{CIDR: "10.1.0.0/16", Cloud: "C"},
+		{CIDR: "10.1.2.0/24", Cloud: "D"},
+		{CIDR: "10.16.16.0/20", Cloud: "E"},
+		{CIDR: "2001:db8::/33", Cloud: "F"},
+	}
+
+	ia := NewIPAnnotator()
+	c, err := Open(filepath.Join(t.TempDir(), "test_cache.db"))
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	for i := range prefixes {
+		if err := ia.Insert(&prefixes[i]); err != nil {
+			t.Fatalf("Insert(%s) error = %v", prefixes[i].CIDR, err)
+		}
+		if err := c.UpsertPrefix(prefixes[i]); err != nil {
+			t.Fatalf("UpsertPrefix(%s) error = %v", prefixes[i].CIDR, err)
+		}
+	}
+
+	tests := []struct {
+		addr string
+		want string // CIDR of the expected match, empty for none
+	}{
+		{"10.1.2.3", "10.1.2.0/24"},
+		{"10.1.3.4", "10.1.0.0/16"},
+		{"10.2.0.1", "10.0.0.0/12"}, // inside the /12 but not on its byte path
+		{"10.15.255.255", "10.0.0.0/12"},
+		{"10.16.0.1", "10.0.0.0/8"}, // just past the /12
+		{"10.16.16.1", "10.16.16.0/20"},
+		{"10.16.31.255", "10.16.16.0/20"}, // inside the /20 but not on its byte path
+		{"10.16.32.0", "10.0.0.0/8"},
+		{"11.0.0.1", ""},
+		{"2001:db8:7fff::1", "2001:db8::/33"},
+		{"2001:db8:8000::1", ""},
+		{"::ffff:10.1.2.3", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			addr := netip.MustParseAddr(tt.addr)
+			got := ia.Lookup(addr)
+			gotCIDR := ""
+			if got != nil {
+				gotCIDR = got.CIDR
+			}
+			if gotCIDR != tt.want {
+				t.Errorf("Lookup(%s) = %q, want %q", tt.addr, gotCIDR, tt.want)
+			}
+
+			// Cache.LookupIP must agree on the match
+			want := ""
+			if got != nil {
+				want = got.Cloud + " (" + got.CIDR + ")"
+			}
+			annotation, err := c.LookupIP(addr)
+			if err != nil {
+				t.Fatalf("LookupIP(%s) error = %v", tt.addr, err)
+			}
+			if annotation != want {
+				t.Errorf("LookupIP(%s) = %q, annotator matched %q", tt.addr, annotation, want)
+			}
+		})
+	}
+}
+
+func TestIPAnnotatorRemove(t *testing.T) {
+	ia := NewIPAnnotator()
+	for _, tag := range []*PrefixTag{{CIDR: "10.0.0.0/8"}, {CIDR: "10.0.0.0/12"}} {
+		if err := ia.Insert(tag); err != nil {
+			t.Fatalf("Insert(%s) error = %v", tag.CIDR, err)
+		}
+	}
+
+	addr := netip.MustParseAddr("10.2.0.1")
+	if got := ia.Lookup(addr); got == nil || got.CIDR != "10.0.0.0/12" {
+		t.Fatalf("Lookup() = %v, want 10.0.0.0/12", got)
+	}
+
+	ia.Remove("10.0.0.0/12")
+	if got := ia.Lookup(addr); got == nil || got.CIDR != "10.0.0.0/8" {
+		t.Errorf("Lookup() after Remove = %v, want 10.0.0.0/8", got)
+	}
+	if n := len(ia.GetAll()); n != 1 {
+		t.Errorf("GetAll() returned %d prefixes, want 1", n)
+	}
+
+	if err := ia.Insert(&PrefixTag{CIDR: "not-a-cidr"}); err == nil {
+		t.Error("expected an error inserting an invalid CIDR")
+	}
+}
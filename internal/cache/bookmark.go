@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// BookmarkKey identifies a query over a set of log groups, so that each
+// distinct query keeps its own --since-last bookmark. The order of the log
+// groups does not matter.
+func BookmarkKey(logGroups []string, query string) string {
+	groups := slices.Clone(logGroups)
+	slices.Sort(groups)
+	sum := sha256.Sum256([]byte(strings.Join(groups, "\n") + "\n\n" + query))
+	return hex.EncodeToString(sum[:])
+}
+
+// Bookmark returns the end time of the last successful run of the query with
+// the given key, and false if the query has not run before.
+func (c *Cache) Bookmark(key string) (time.Time, bool, error) {
+	var end time.Time
+	var found bool
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucketBookmarks))
+		if b == nil {
+			return fmt.Errorf("bookmark bucket missing")
+		}
+		v := b.Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		ms, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid bookmark %q: %w", v, err)
+		}
+		end, found = time.UnixMilli(ms), true
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read bookmark: %w", err)
+	}
+	return end, found, nil
+}
+
+// SetBookmark records end as the end time of the last successful run of the
+// query with the given key.
+func (c *Cache) SetBookmark(key string, end time.Time) error {
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucketBookmarks))
+		if b == nil {
+			return fmt.Errorf("bookmark bucket missing")
+		}
+		return b.Put([]byte(key), []byte(strconv.FormatInt(end.UnixMilli(), 10)))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update bookmark: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBookmark(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer c.Close()
+
+	key := BookmarkKey([]string{"/aws/vpc/prod", "/aws/vpc/shared"}, "stats count(*)")
+	if _, found, err := c.Bookmark(key); err != nil || found {
+		t.Fatalf("Bookmark() before any run = %v, %v, want not found", found, err)
+	}
+
+	end := time.UnixMilli(1714572000000)
+	if err := c.SetBookmark(key, end); err != nil {
+		t.Fatalf("SetBookmark() error = %v", err)
+	}
+	got, found, err := c.Bookmark(key)
+	if err != nil || !found || !got.Equal(end) {
+		t.Errorf("Bookmark() = %v, %v, %v, want %v", got, found, err, end)
+	}
+
+	if other := BookmarkKey([]string{"/aws/vpc/prod", "/aws/vpc/shared"}, "stats sum(bytes)"); other == key {
+		t.Error("BookmarkKey() is the same for different queries")
+	}
+	if reordered := BookmarkKey([]string{"/aws/vpc/shared", "/aws/vpc/prod"}, "stats count(*)"); reordered != key {
+		t.Error("BookmarkKey() depends on the order of the log groups")
+	}
+}
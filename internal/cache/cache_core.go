@@ -3,8 +3,10 @@ package cache
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"go.etcd.io/bbolt"
 )
@@ -24,13 +26,23 @@ type PrefixTag struct {
 	CIDR    string // "13.32.0.0/15"
 	Cloud   string // "AWS" | "AZURE" | "GCP"
 	Service string // Optional ("CLOUDFRONT", "EC2", …)
-	Fetched int64
+	Fetched int64  // Unix time the prefix was written
+	// Region the provider lists for the prefix ("us-east-1"), when it lists one
+	Region string `json:",omitempty"`
 }
 
 // IPTag stores IP annotation info.
 type IPTag struct {
 	Addr string
-	Name string
+	Name string // Short label shown in annotations
+	// Whois details, set when the tag comes from a whois lookup
+	ASN     string `json:",omitempty"`
+	Org     string `json:",omitempty"`
+	Country string `json:",omitempty"`
+	// Reverse DNS hostname, set by EnrichPTR
+	PTR string `json:",omitempty"`
+	// Unix time the tag was written; zero for tags written before it was tracked
+	Fetched int64 `json:",omitempty"`
 }
 
 // Cache wraps BoltDB and provides annotation lookups.
@@ -41,12 +53,20 @@ type Cache struct {
 	whoisClient WhoisClient
 	logger      Logger
 	fileSystem  FileSystem
+	resolver    PTRResolver
+
+	// prefixes is the parsed CIDR tag index used by LookupIP, loaded lazily
+	// and dropped whenever prefixes are written.
+	prefixMu sync.Mutex
+	prefixes *prefixIndex
 }
 
 const (
-	bucketENITags  = "eni_tags"
-	bucketCIDRTags = "cidr_tags"
-	bucketIPTags   = "ip_tags"
+	bucketENITags   = "eni_tags"
+	bucketCIDRTags  = "cidr_tags"
+	bucketIPTags    = "ip_tags"
+	bucketBookmarks = "bookmarks"
+	bucketResults   = "query_results"
 )
 
 // Open opens or creates the cache at the given path. It ensures the parent
@@ -63,7 +83,19 @@ func OpenWithConfig(config *Config) (*Cache, error) {
 
 	// Create default dependencies if not provided
 	httpClient := NewDefaultHTTPClient(config.HTTPTimeout)
-	whoisClient := NewDefaultWhoisClient(config.WhoisTimeout)
+	var whoisClient WhoisClient
+	switch config.WhoisBackend {
+	case WhoisBackendWhois, "":
+		client, err := NewWhoisClientWithServers(config.WhoisTimeout, config.WhoisServers)
+		if err != nil {
+			return nil, NewConfigurationError("invalid whois servers", err)
+		}
+		whoisClient = client
+	case WhoisBackendCymru:
+		whoisClient = NewCymruWhoisClient(config.WhoisTimeout)
+	default:
+		return nil, NewConfigurationError(fmt.Sprintf("unknown whois backend %q", config.WhoisBackend), nil)
+	}
 	logger := NewDefaultLogger(config.EnableLogging)
 	fileSystem := NewDefaultFileSystem()
 
@@ -111,6 +143,12 @@ func OpenWithDependencies(
 		if _, err := tx.CreateBucketIfNotExists([]byte(bucketIPTags)); err != nil {
 			return NewDatabaseError("create_bucket", bucketIPTags, err)
 		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(bucketBookmarks)); err != nil {
+			return NewDatabaseError("create_bucket", bucketBookmarks, err)
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(bucketResults)); err != nil {
+			return NewDatabaseError("create_bucket", bucketResults, err)
+		}
 		return nil
 	})
 	if err != nil {
@@ -128,6 +166,7 @@ func OpenWithDependencies(
 		whoisClient: whoisClient,
 		logger:      logger,
 		fileSystem:  fileSystem,
+		resolver:    net.DefaultResolver,
 	}, nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/netip"
+	"time"
 
 	"go.etcd.io/bbolt"
 )
@@ -15,60 +16,91 @@ import (
 func (c *Cache) LookupIP(addr netip.Addr) (string, error) {
 	var annotation string
 	err := c.db.View(func(tx *bbolt.Tx) error {
-		ipStr := addr.String()
-
-		// 1. Exact match in IPTags
-		ipBucket := tx.Bucket([]byte(bucketIPTags))
-		if ipBucket != nil {
-			if v := ipBucket.Get([]byte(ipStr)); v != nil {
-				var tag IPTag
-				if err := json.Unmarshal(v, &tag); err == nil {
-					annotation = tag.Name // Exact match found
-					return nil            // We're done
-				}
-			}
+		idx, err := c.cachedPrefixIndex(tx)
+		if err != nil {
+			return err
 		}
+		annotation = lookupIPTx(tx, idx, addr, AllIPSources)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to lookup IP: %w", err)
+	}
+	return annotation, nil
+}
 
-		// 2. Longest-prefix match in CIDRTags
-		cidrBucket := tx.Bucket([]byte(bucketCIDRTags))
-		if cidrBucket == nil {
-			return nil // No CIDR tags to check
-		}
+// IPSources selects which cached annotations LookupIPsFrom consults.
+type IPSources struct {
+	Tags     bool // exact IP tags, from whois lookups or manual annotation
+	Prefixes bool // cloud provider CIDR tags
+	Region   bool // append the region to prefix annotations that have one
+}
 
-		var bestTag *PrefixTag
-		var bestPrefixLen int
-		err := cidrBucket.ForEach(func(k, v []byte) error {
-			prefix, err := netip.ParsePrefix(string(k))
-			if err != nil {
-				return fmt.Errorf("invalid CIDR key %q: %w", string(k), err)
-			}
-			if prefix.Contains(addr) {
-				if plen := prefix.Bits(); plen > bestPrefixLen {
-					var tag PrefixTag
-					if err := json.Unmarshal(v, &tag); err == nil {
-						bestTag = &tag
-						bestPrefixLen = plen
-					}
-				}
-			}
-			return nil
-		})
+// AllIPSources consults every source, as LookupIP does.
+var AllIPSources = IPSources{Tags: true, Prefixes: true}
+
+// LookupIPs looks up many addresses in a single read transaction, as LookupIP
+// does for one. The returned map holds only the addresses that have an annotation.
+func (c *Cache) LookupIPs(addrs []netip.Addr) (map[netip.Addr]string, error) {
+	return c.LookupIPsFrom(addrs, AllIPSources)
+}
+
+// LookupIPsFrom is LookupIPs restricted to the given sources.
+func (c *Cache) LookupIPsFrom(addrs []netip.Addr, sources IPSources) (map[netip.Addr]string, error) {
+	annotations := make(map[netip.Addr]string)
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		idx, err := c.cachedPrefixIndex(tx)
 		if err != nil {
-			return fmt.Errorf("failed to iterate CIDR bucket: %w", err)
+			return err
 		}
-
-		if bestTag != nil {
-			annotation = fmt.Sprintf("%s (%s)", bestTag.Cloud, bestTag.CIDR)
-			if bestTag.Service != "" {
-				annotation = fmt.Sprintf("%s, %s", annotation, bestTag.Service)
+		for _, addr := range addrs {
+			if _, done := annotations[addr]; done {
+				continue
+			}
+			if annotation := lookupIPTx(tx, idx, addr, sources); annotation != "" {
+				annotations[addr] = annotation
 			}
 		}
 		return nil
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to lookup IP: %w", err)
+		return nil, fmt.Errorf("failed to lookup IPs: %w", err)
 	}
-	return annotation, nil
+	return annotations, nil
+}
+
+// lookupIPTx returns the annotation for addr within tx: an exact IP tag if one
+// exists, otherwise the longest matching CIDR tag, or "" if neither matches.
+// Sources that are not selected are skipped.
+func lookupIPTx(tx *bbolt.Tx, idx *prefixIndex, addr netip.Addr, sources IPSources) string {
+	// 1. Exact match in IPTags
+	ipBucket := tx.Bucket([]byte(bucketIPTags))
+	if ipBucket != nil && sources.Tags {
+		if v := ipBucket.Get([]byte(addr.String())); v != nil {
+			var tag IPTag
+			// Tags holding only a PTR hostname fall through to the prefix match
+			if err := json.Unmarshal(v, &tag); err == nil && tag.Name != "" {
+				return tag.Name // Exact match found
+			}
+		}
+	}
+
+	// 2. Longest-prefix match in CIDRTags
+	if !sources.Prefixes {
+		return ""
+	}
+	bestTag, found := idx.longestMatch(addr)
+	if !found {
+		return ""
+	}
+	annotation := fmt.Sprintf("%s (%s)", bestTag.Cloud, bestTag.CIDR)
+	if bestTag.Service != "" {
+		annotation = fmt.Sprintf("%s, %s", annotation, bestTag.Service)
+	}
+	if sources.Region && bestTag.Region != "" {
+		annotation = fmt.Sprintf("%s, %s", annotation, bestTag.Region)
+	}
+	return annotation
 }
 
 // LookupEni returns the ENITag for the given ENI, if any.
@@ -120,8 +152,12 @@ func (c *Cache) UpsertEni(tag ENITag) error {
 	return nil
 }
 
-// UpsertPrefix inserts or updates a PrefixTag in the cache.
+// UpsertPrefix inserts or updates a PrefixTag in the cache, stamping its
+// Fetched time if unset.
 func (c *Cache) UpsertPrefix(tag PrefixTag) error {
+	if tag.Fetched == 0 {
+		tag.Fetched = time.Now().Unix()
+	}
 	data, err := json.Marshal(tag)
 	if err != nil {
 		return fmt.Errorf("failed to marshal prefix tag: %w", err)
@@ -133,23 +169,36 @@ func (c *Cache) UpsertPrefix(tag PrefixTag) error {
 		}
 		return b.Put([]byte(tag.CIDR), data)
 	})
+	c.invalidatePrefixIndex()
 	if err != nil {
 		return fmt.Errorf("failed to update prefix tag: %w", err)
 	}
 	return nil
 }
 
-// UpsertIP inserts or updates an IPTag in the cache.
+// UpsertIP inserts or updates an IPTag in the cache, stamping its Fetched
+// time if unset.
 func (c *Cache) UpsertIP(tag IPTag) error {
-	data, err := json.Marshal(tag)
-	if err != nil {
-		return fmt.Errorf("failed to marshal IP tag: %w", err)
+	if tag.Fetched == 0 {
+		tag.Fetched = time.Now().Unix()
 	}
-	err = c.db.Update(func(tx *bbolt.Tx) error {
+	err := c.db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(bucketIPTags))
 		if b == nil {
 			return fmt.Errorf("IP tag bucket missing")
 		}
+		// Keep a previously resolved PTR hostname when the new tag has none,
+		// so a whois refresh does not discard it
+		if tag.PTR == "" {
+			var existing IPTag
+			if v := b.Get([]byte(tag.Addr)); v != nil && json.Unmarshal(v, &existing) == nil {
+				tag.PTR = existing.PTR
+			}
+		}
+		data, err := json.Marshal(tag)
+		if err != nil {
+			return fmt.Errorf("failed to marshal IP tag: %w", err)
+		}
 		return b.Put([]byte(tag.Addr), data)
 	})
 	if err != nil {
@@ -165,7 +214,11 @@ func (c *Cache) UpsertPrefixes(tags []PrefixTag) error {
 		if b == nil {
 			return fmt.Errorf("CIDR tag bucket missing")
 		}
+		now := time.Now().Unix()
 		for _, tag := range tags {
+			if tag.Fetched == 0 {
+				tag.Fetched = now
+			}
 			data, err := json.Marshal(tag)
 			if err != nil {
 				return fmt.Errorf("failed to marshal prefix tag: %w", err)
@@ -176,6 +229,7 @@ func (c *Cache) UpsertPrefixes(tags []PrefixTag) error {
 		}
 		return nil
 	})
+	c.invalidatePrefixIndex()
 	if err != nil {
 		return fmt.Errorf("failed to update prefix tags: %w", err)
 	}
@@ -253,3 +307,19 @@ func (c *Cache) DeleteENI(eni string) error {
 	}
 	return nil
 }
+
+// DeletePrefix removes a CIDR tag from the cache.
+func (c *Cache) DeletePrefix(cidr string) error {
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucketCIDRTags))
+		if b == nil {
+			return fmt.Errorf("CIDR tag bucket missing")
+		}
+		return b.Delete([]byte(cidr))
+	})
+	c.invalidatePrefixIndex()
+	if err != nil {
+		return fmt.Errorf("failed to delete prefix: %w", err)
+	}
+	return nil
+}
@@ -321,6 +321,54 @@ func TestLookupIPExactMatchTakesPrecedence(t *testing.T) {
 	}
 }
 
+func TestLookupIPsMatchesLookupIP(t *testing.T) {
+	cache, err := Open(filepath.Join(t.TempDir(), "test_cache.db"))
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	defer func() { _ = cache.Close() }()
+
+	if err := cache.UpsertPrefixes([]PrefixTag{
+		{CIDR: "10.0.0.0/8", Cloud: "AWS", Service: "VPC"},
+		{CIDR: "10.1.0.0/16", Cloud: "GCP"},
+	}); err != nil {
+		t.Fatalf("Failed to upsert prefixes: %v", err)
+	}
+	if err := cache.UpsertIP(IPTag{Addr: "10.1.0.1", Name: "Exact Match"}); err != nil {
+		t.Fatalf("Failed to upsert IP: %v", err)
+	}
+
+	addrs := []netip.Addr{
+		netip.MustParseAddr("10.1.0.1"), // exact match beats the /16
+		netip.MustParseAddr("10.1.0.2"),
+		netip.MustParseAddr("10.2.0.1"),
+		netip.MustParseAddr("192.0.2.1"), // no annotation
+		netip.MustParseAddr("10.1.0.1"),  // duplicates are fine
+	}
+	got, err := cache.LookupIPs(addrs)
+	if err != nil {
+		t.Fatalf("LookupIPs() error = %v", err)
+	}
+
+	want := map[netip.Addr]string{
+		netip.MustParseAddr("10.1.0.1"): "Exact Match",
+		netip.MustParseAddr("10.1.0.2"): "GCP (10.1.0.0/16)",
+		netip.MustParseAddr("10.2.0.1"): "AWS (10.0.0.0/8), VPC",
+	}
+	if len(got) != len(want) {
+		t.Errorf("LookupIPs() = %v, want %v", got, want)
+	}
+	for _, addr := range addrs {
+		single, err := cache.LookupIP(addr)
+		if err != nil {
+			t.Fatalf("LookupIP(%s) error = %v", addr, err)
+		}
+		if got[addr] != single || got[addr] != want[addr] {
+			t.Errorf("LookupIPs()[%s] = %q, LookupIP() = %q, want %q", addr, got[addr], single, want[addr])
+		}
+	}
+}
+
 func TestClose(t *testing.T) {
 	tmpDir := t.TempDir()
 	cachePath := filepath.Join(tmpDir, "test_cache.db")
@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+
+	"go.etcd.io/bbolt"
+)
+
+// BucketCheck holds the integrity check results for a single bucket.
+type BucketCheck struct {
+	Name        string
+	Records     int
+	Corrupt     int
+	CorruptKeys []string
+	// Removed is the number of corrupt records deleted by Repair.
+	Removed int
+}
+
+// CheckReport summarizes an integrity check of a cache database.
+type CheckReport struct {
+	Path           string
+	Buckets        []BucketCheck
+	MissingBuckets []string
+}
+
+// CorruptRecords returns the total number of corrupt records across all buckets.
+func (r *CheckReport) CorruptRecords() int {
+	total := 0
+	for _, b := range r.Buckets {
+		total += b.Corrupt
+	}
+	return total
+}
+
+// RemovedRecords returns the total number of corrupt records deleted by Repair.
+func (r *CheckReport) RemovedRecords() int {
+	total := 0
+	for _, b := range r.Buckets {
+		total += b.Removed
+	}
+	return total
+}
+
+// OK reports whether the check found no missing buckets and no corrupt records.
+func (r *CheckReport) OK() bool {
+	return len(r.MissingBuckets) == 0 && r.CorruptRecords() == 0
+}
+
+// String renders the report for display.
+func (r *CheckReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Cache integrity check: %s\n", r.Path)
+	for _, b := range r.Buckets {
+		fmt.Fprintf(&sb, "  %-10s %d records, %d corrupt", b.Name+":", b.Records, b.Corrupt)
+		if b.Removed > 0 {
+			fmt.Fprintf(&sb, ", %d removed", b.Removed)
+		}
+		sb.WriteString("\n")
+		for _, key := range b.CorruptKeys {
+			fmt.Fprintf(&sb, "    corrupt: %q\n", key)
+		}
+	}
+	for _, name := range r.MissingBuckets {
+		fmt.Fprintf(&sb, "  %-10s missing\n", name+":")
+	}
+	if r.OK() {
+		sb.WriteString("OK\n")
+	} else {
+		fmt.Fprintf(&sb, "Found %d corrupt records and %d missing buckets\n", r.CorruptRecords(), len(r.MissingBuckets))
+	}
+	return sb.String()
+}
+
+// recordValidators checks a single key/value pair for each expected bucket.
+var recordValidators = map[string]func(k, v []byte) error{
+	bucketENITags: func(_, v []byte) error {
+		var tag ENITag
+		return json.Unmarshal(v, &tag)
+	},
+	bucketCIDRTags: func(k, v []byte) error {
+		if _, err := netip.ParsePrefix(string(k)); err != nil {
+			return err
+		}
+		var tag PrefixTag
+		return json.Unmarshal(v, &tag)
+	},
+	bucketIPTags: func(k, v []byte) error {
+		if _, err := netip.ParseAddr(string(k)); err != nil {
+			return err
+		}
+		var tag IPTag
+		return json.Unmarshal(v, &tag)
+	},
+}
+
+// Check opens the cache database at path read-only and verifies that every expected
+// bucket exists and every record can be decoded. It never modifies the database.
+func Check(path string) (*CheckReport, error) {
+	return checkDatabase(path, false, nil)
+}
+
+// Repair runs the same checks as Check but deletes every corrupt record it finds,
+// logging each deletion. Missing buckets are reported but not recreated; opening
+// the cache normally recreates them.
+func Repair(path string, logger Logger) (*CheckReport, error) {
+	if logger == nil {
+		logger = NewDefaultLogger(true)
+	}
+	return checkDatabase(path, true, logger)
+}
+
+// checkDatabase implements Check and Repair.
+func checkDatabase(path string, repair bool, logger Logger) (*CheckReport, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, NewConfigurationError(fmt.Sprintf("cache file %s is not accessible", path), err)
+	}
+
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{
+		ReadOnly: !repair,
+		Timeout:  DefaultConfig().DBTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func() {
+		if closeErr := db.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close database: %v\n", closeErr)
+		}
+	}()
+
+	names := make([]string, 0, len(recordValidators))
+	for name := range recordValidators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := &CheckReport{Path: path}
+	checkBuckets := func(tx *bbolt.Tx) error {
+		for _, name := range names {
+			bucket := tx.Bucket([]byte(name))
+			if bucket == nil {
+				report.MissingBuckets = append(report.MissingBuckets, name)
+				continue
+			}
+
+			result := BucketCheck{Name: name}
+			validate := recordValidators[name]
+			if err := bucket.ForEach(func(k, v []byte) error {
+				result.Records++
+				if err := validate(k, v); err != nil {
+					result.Corrupt++
+					result.CorruptKeys = append(result.CorruptKeys, string(k))
+				}
+				return nil
+			}); err != nil {
+				return NewDatabaseError("check_bucket", name, err)
+			}
+
+			// Keys are deleted after iterating, since bbolt forbids mutation during ForEach
+			if repair {
+				for _, key := range result.CorruptKeys {
+					if err := bucket.Delete([]byte(key)); err != nil {
+						return NewDatabaseError("repair_bucket", key, err)
+					}
+					logger.Info("Removed corrupt record %q from %s", key, name)
+					result.Removed++
+				}
+			}
+			report.Buckets = append(report.Buckets, result)
+		}
+		return nil
+	}
+
+	if repair {
+		err = db.Update(checkBuckets)
+	} else {
+		err = db.View(checkBuckets)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check cache: %w", err)
+	}
+
+	return report, nil
+}
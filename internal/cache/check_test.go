@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+// newCheckTestCache creates a cache with one valid record per bucket and closes it.
+func newCheckTestCache(t *testing.T) string {
+	t.Helper()
+	cachePath := filepath.Join(t.TempDir(), "test_cache.db")
+	c, err := Open(cachePath)
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	if err := c.UpsertEni(ENITag{ENI: "eni-123", Label: "web"}); err != nil {
+		t.Fatalf("Failed to upsert ENI: %v", err)
+	}
+	if err := c.UpsertPrefix(PrefixTag{CIDR: "10.0.0.0/16", Cloud: "AWS"}); err != nil {
+		t.Fatalf("Failed to upsert prefix: %v", err)
+	}
+	if err := c.UpsertIP(IPTag{Addr: "1.1.1.1", Name: "CLOUDFLARE"}); err != nil {
+		t.Fatalf("Failed to upsert IP: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+	return cachePath
+}
+
+// putRaw writes a raw record directly into a bucket, bypassing the cache API.
+func putRaw(t *testing.T, cachePath, bucket, key, value string) {
+	t.Helper()
+	db, err := bbolt.Open(cachePath, 0o600, nil)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Put([]byte(key), []byte(value))
+	}); err != nil {
+		t.Fatalf("Failed to write raw record: %v", err)
+	}
+}
+
+func TestCheckHealthyCache(t *testing.T) {
+	cachePath := newCheckTestCache(t)
+
+	report, err := Check(cachePath)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("expected a healthy cache, got:\n%s", report)
+	}
+	if len(report.Buckets) != 3 {
+		t.Errorf("expected 3 buckets, got %d", len(report.Buckets))
+	}
+}
+
+func TestCheckReportsMalformedRecord(t *testing.T) {
+	cachePath := newCheckTestCache(t)
+	putRaw(t, cachePath, bucketENITags, "eni-bad", "{not json")
+	putRaw(t, cachePath, bucketCIDRTags, "not-a-cidr", `{"CIDR":"not-a-cidr"}`)
+
+	before, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("Failed to read cache file: %v", err)
+	}
+
+	report, err := Check(cachePath)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if report.OK() {
+		t.Fatal("expected the check to report corrupt records")
+	}
+	if got := report.CorruptRecords(); got != 2 {
+		t.Errorf("CorruptRecords() = %d, want 2", got)
+	}
+
+	for _, b := range report.Buckets {
+		switch b.Name {
+		case bucketENITags:
+			if b.Records != 2 || b.Corrupt != 1 || b.CorruptKeys[0] != "eni-bad" {
+				t.Errorf("unexpected eni_tags result: %+v", b)
+			}
+		case bucketCIDRTags:
+			if b.Corrupt != 1 || b.CorruptKeys[0] != "not-a-cidr" {
+				t.Errorf("unexpected cidr_tags result: %+v", b)
+			}
+		case bucketIPTags:
+			if b.Corrupt != 0 {
+				t.Errorf("unexpected ip_tags result: %+v", b)
+			}
+		}
+	}
+	if !strings.Contains(report.String(), `corrupt: "eni-bad"`) {
+		t.Errorf("expected report to list the corrupt key, got:\n%s", report)
+	}
+
+	after, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("Failed to read cache file: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Error("Check() modified the cache file")
+	}
+}
+
+func TestCheckReportsMissingBucket(t *testing.T) {
+	cachePath := newCheckTestCache(t)
+	db, err := bbolt.Open(cachePath, 0o600, nil)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		return tx.DeleteBucket([]byte(bucketIPTags))
+	}); err != nil {
+		t.Fatalf("Failed to delete bucket: %v", err)
+	}
+	_ = db.Close()
+
+	report, err := Check(cachePath)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(report.MissingBuckets) != 1 || report.MissingBuckets[0] != bucketIPTags {
+		t.Errorf("MissingBuckets = %v, want [%s]", report.MissingBuckets, bucketIPTags)
+	}
+}
+
+func TestCheckMissingFile(t *testing.T) {
+	if _, err := Check(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("expected an error for a missing cache file")
+	}
+}
+
+func TestRepairRemovesMalformedRecords(t *testing.T) {
+	cachePath := newCheckTestCache(t)
+	putRaw(t, cachePath, bucketENITags, "eni-bad", "{not json")
+	putRaw(t, cachePath, bucketIPTags, "not-an-ip", `{"Addr":"not-an-ip"}`)
+
+	report, err := Repair(cachePath, nil)
+	if err != nil {
+		t.Fatalf("Repair() error = %v", err)
+	}
+	for _, b := range report.Buckets {
+		if b.Removed != b.Corrupt {
+			t.Errorf("%s: removed %d of %d corrupt records", b.Name, b.Removed, b.Corrupt)
+		}
+	}
+
+	after, err := Check(cachePath)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !after.OK() {
+		t.Errorf("expected a healthy cache after repair, got:\n%s", after)
+	}
+
+	c, err := Open(cachePath)
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	if tag, err := c.LookupEni(context.Background(), "eni-123"); err != nil || tag.Label != "web" {
+		t.Errorf("expected the valid ENI record to survive, got %+v, %v", tag, err)
+	}
+	if name, err := c.LookupIP(netip.MustParseAddr("1.1.1.1")); err != nil || name != "CLOUDFLARE" {
+		t.Errorf("expected the valid IP record to survive, got %q, %v", name, err)
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"go.etcd.io/bbolt"
@@ -39,37 +42,17 @@ func (c *Cache) FetchProvider(ctx context.Context, provider string) (*FetchResul
 
 	start := time.Now()
 
-	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, NewNetworkError("create_request", url, err)
-	}
-
-	// Set user agent
-	req.Header.Set("User-Agent", c.config.UserAgent)
-
-	// Make request with context
-	req = req.WithContext(ctx)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, NewNetworkError("http_request", url, err)
-	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			c.logger.Error("Failed to close response body: %v", closeErr)
+	if provider == "azure" && !strings.HasSuffix(url, ".json") {
+		resolved, err := c.resolveAzureURL(ctx, url)
+		if err != nil {
+			return nil, err
 		}
-	}()
-
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		return nil, NewNetworkError("http_status", url,
-			fmt.Errorf("unexpected status code: %d", resp.StatusCode))
+		url = resolved
 	}
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.get(ctx, url)
 	if err != nil {
-		return nil, NewNetworkError("read_response", url, err)
+		return nil, err
 	}
 
 	// Parse response based on provider
@@ -96,6 +79,12 @@ func (c *Cache) FetchProvider(ctx context.Context, provider string) (*FetchResul
 			return nil, NewInvalidDataError("parse_do_data", provider, "failed to parse DigitalOcean data", err)
 		}
 		data = doData
+	case "azure":
+		var azureData AzureIPRanges
+		if err := json.Unmarshal(body, &azureData); err != nil {
+			return nil, NewInvalidDataError("parse_azure_data", provider, "failed to parse Azure data", err)
+		}
+		data = azureData
 	default:
 		return nil, NewConfigurationError(fmt.Sprintf("unsupported provider: %s", provider), nil)
 	}
@@ -110,20 +99,98 @@ func (c *Cache) FetchProvider(ctx context.Context, provider string) (*FetchResul
 	}, nil
 }
 
-// FetchAllProviders fetches data from all configured providers.
+// get fetches url and returns the response body.
+func (c *Cache) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, NewNetworkError("create_request", url, err)
+	}
+	req.Header.Set("User-Agent", c.config.UserAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, NewNetworkError("http_request", url, err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			c.logger.Error("Failed to close response body: %v", closeErr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewNetworkError("http_status", url,
+			fmt.Errorf("unexpected status code: %d", resp.StatusCode))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, NewNetworkError("read_response", url, err)
+	}
+	return body, nil
+}
+
+// azureServiceTagsURL matches the link to the current ServiceTags_Public file on
+// Microsoft's download page, which changes name every week.
+var azureServiceTagsURL = regexp.MustCompile(`https://download\.microsoft\.com/download/[^"'\s]+/ServiceTags_Public_\d+\.json`)
+
+// resolveAzureURL finds the current Azure service tags file linked from the
+// download page at pageURL.
+func (c *Cache) resolveAzureURL(ctx context.Context, pageURL string) (string, error) {
+	page, err := c.get(ctx, pageURL)
+	if err != nil {
+		return "", err
+	}
+	url := azureServiceTagsURL.Find(page)
+	if url == nil {
+		return "", NewInvalidDataError("resolve_azure_url", pageURL, "no ServiceTags_Public link on the download page", nil)
+	}
+	c.logger.Debug("Resolved Azure service tags to %s", url)
+	return string(url), nil
+}
+
+// maxConcurrentFetches limits how many providers FetchAllProviders fetches at once.
+const maxConcurrentFetches = 4
+
+// FetchAllProviders fetches data from all configured providers concurrently.
+// A provider that fails gets a result carrying its error instead of aborting the
+// others. Results are ordered by provider name.
 func (c *Cache) FetchAllProviders(ctx context.Context) ([]*FetchResult, error) {
-	results := make([]*FetchResult, 0, len(c.config.ProviderURLs))
+	providers := make([]string, 0, len(c.config.ProviderURLs))
 	for provider := range c.config.ProviderURLs {
-		result, err := c.FetchProvider(ctx, provider)
-		if err != nil {
-			c.logger.Error("Failed to fetch %s: %v", provider, err)
-			result = &FetchResult{
-				Provider: provider,
-				Error:    err,
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	results := make([]*FetchResult, len(providers))
+	semaphore := make(chan struct{}, maxConcurrentFetches)
+	var wg sync.WaitGroup
+
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider string) {
+			defer wg.Done()
+
+			var result *FetchResult
+			var err error
+			select {
+			case semaphore <- struct{}{}: // Acquire semaphore
+				result, err = c.FetchProvider(ctx, provider)
+				<-semaphore // Release semaphore
+			case <-ctx.Done():
+				err = ctx.Err()
 			}
-		}
-		results = append(results, result)
+			if err != nil {
+				c.logger.Error("Failed to fetch %s: %v", provider, err)
+				result = &FetchResult{
+					Provider: provider,
+					Error:    err,
+				}
+			}
+			results[i] = result
+		}(i, provider)
 	}
+
+	wg.Wait()
 	return results, nil
 }
 
@@ -138,6 +205,8 @@ func (c *Cache) ProcessFetchResult(result *FetchResult) ([]PrefixTag, error) {
 		return c.processCloudflareData(result.Data)
 	case "digitalocean":
 		return c.processDigitalOceanData(result.Data)
+	case "azure":
+		return c.processAzureData(result.Data)
 	default:
 		return nil, NewConfigurationError(fmt.Sprintf("unsupported provider: %s", result.Provider), nil)
 	}
@@ -161,6 +230,7 @@ func (c *Cache) processAWSData(data interface{}) ([]PrefixTag, error) {
 			CIDR:    prefix.IPPrefix,
 			Cloud:   "AWS",
 			Service: prefix.Service,
+			Region:  prefix.Region,
 		})
 	}
 
@@ -173,13 +243,63 @@ func (c *Cache) processAWSData(data interface{}) ([]PrefixTag, error) {
 			CIDR:    prefix.IPv6Prefix,
 			Cloud:   "AWS",
 			Service: prefix.Service,
+			Region:  prefix.Region,
 		})
 	}
 
+	tags = collapseDuplicates(tags, awsServiceRank)
 	c.logger.Info("Processed %d AWS prefixes", len(tags))
 	return tags, nil
 }
 
+// awsServiceRank orders AWS service names by how specific they are: the catch-all
+// AMAZON, then EC2, which AWS lists alongside many narrower services, then the rest.
+func awsServiceRank(service string) int {
+	switch service {
+	case "AMAZON":
+		return 0
+	case "EC2":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// azureServiceTagRank orders Azure service tag names by how specific they are:
+// the AzureCloud catch-alls, then bare service tags such as Storage, then
+// regional ones such as Storage.WestUS.
+func azureServiceTagRank(service string) int {
+	switch {
+	case service == "AzureCloud" || strings.HasPrefix(service, "AzureCloud."):
+		return 0
+	case !strings.Contains(service, "."):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// collapseDuplicates keeps one tag per CIDR, preferring the service that rank
+// orders highest, so the CIDR-keyed bucket does not keep whichever entry was written
+// last. Among equally specific services the first listed wins; tags keep the
+// order in which their CIDR first appears.
+func collapseDuplicates(tags []PrefixTag, rank func(service string) int) []PrefixTag {
+	index := make(map[string]int, len(tags))
+	collapsed := make([]PrefixTag, 0, len(tags))
+	for _, tag := range tags {
+		i, seen := index[tag.CIDR]
+		if !seen {
+			index[tag.CIDR] = len(collapsed)
+			collapsed = append(collapsed, tag)
+			continue
+		}
+		if rank(tag.Service) > rank(collapsed[i].Service) {
+			collapsed[i] = tag
+		}
+	}
+	return collapsed
+}
+
 // processGCPData converts GCP data to PrefixTags.
 func (c *Cache) processGCPData(data interface{}) ([]PrefixTag, error) {
 	gcpData, ok := data.(GCPIPRanges)
@@ -195,6 +315,7 @@ func (c *Cache) processGCPData(data interface{}) ([]PrefixTag, error) {
 				CIDR:    cidr,
 				Cloud:   "GCP",
 				Service: prefix.Service,
+				Region:  prefix.Scope,
 			})
 		}
 	}
@@ -239,8 +360,9 @@ func (c *Cache) processDigitalOceanData(data interface{}) ([]PrefixTag, error) {
 
 	for _, item := range doData.Data {
 		tags = append(tags, PrefixTag{
-			CIDR:  item.IPPrefix,
-			Cloud: "DigitalOcean",
+			CIDR:   item.IPPrefix,
+			Cloud:  "DigitalOcean",
+			Region: item.Region,
 		})
 	}
 
@@ -248,18 +370,51 @@ func (c *Cache) processDigitalOceanData(data interface{}) ([]PrefixTag, error) {
 	return tags, nil
 }
 
-// UpdatePrefixes fetches and updates all provider prefixes.
-func (c *Cache) UpdatePrefixes() error {
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.HTTPTimeout*2)
+// processAzureData converts Azure service tag data to PrefixTags, using the
+// service tag name (e.g. Storage.WestUS) as the service.
+func (c *Cache) processAzureData(data interface{}) ([]PrefixTag, error) {
+	azureData, ok := data.(AzureIPRanges)
+	if !ok {
+		return nil, NewInvalidDataError("process_azure_data", "", "invalid Azure data type", nil)
+	}
+
+	var tags []PrefixTag
+	for _, value := range azureData.Values {
+		for _, cidr := range value.Properties.AddressPrefixes {
+			tags = append(tags, PrefixTag{
+				CIDR:    cidr,
+				Cloud:   "Azure",
+				Service: value.Name,
+				Region:  value.Properties.Region,
+			})
+		}
+	}
+
+	tags = collapseDuplicates(tags, azureServiceTagRank)
+	c.logger.Info("Processed %d Azure prefixes", len(tags))
+	return tags, nil
+}
+
+// UpdatePrefixes fetches and updates all provider prefixes. Fetching stops when
+// ctx is done or after twice the configured HTTP timeout, whichever is first; if
+// ctx is done, nothing is written and its error is returned.
+func (c *Cache) UpdatePrefixes(ctx context.Context) error {
+	fetchCtx, cancel := context.WithTimeout(ctx, c.config.HTTPTimeout*2)
 	defer cancel()
 
 	c.logger.Info("Starting prefix update from all providers")
 
-	results, err := c.FetchAllProviders(ctx)
+	results, err := c.FetchAllProviders(fetchCtx)
 	if err != nil {
 		return fmt.Errorf("failed to fetch providers: %w", err)
 	}
 
+	// A cancelled caller (e.g. Ctrl-C) gets nothing written, rather than the
+	// providers that happened to finish first
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("prefix update cancelled: %w", err)
+	}
+
 	var allTags []PrefixTag
 	for _, result := range results {
 		if result.Error != nil {
@@ -276,8 +431,31 @@ func (c *Cache) UpdatePrefixes() error {
 		allTags = append(allTags, tags...)
 	}
 
+	// Stamp the fetch time so PruneStale can expire ranges a provider drops
+	now := time.Now().Unix()
+	for i := range allTags {
+		allTags[i].Fetched = now
+	}
+
+	// Optionally aggregate adjacent prefixes, removing the now-redundant members
+	var stale []string
+	if c.config.MergeAdjacentPrefixes {
+		merged := MergeAdjacentPrefixes(allTags)
+		kept := make(map[string]bool, len(merged))
+		for _, tag := range merged {
+			kept[tag.CIDR] = true
+		}
+		for _, tag := range allTags {
+			if !kept[tag.CIDR] {
+				stale = append(stale, tag.CIDR)
+			}
+		}
+		c.logger.Info("Merged %d prefixes into %d", len(allTags), len(merged))
+		allTags = merged
+	}
+
 	// Insert all tags in a single transaction for efficiency
-	if err := c.insertPrefixes(allTags); err != nil {
+	if err := c.insertPrefixes(allTags, stale); err != nil {
 		return fmt.Errorf("failed to update prefixes: %w", err)
 	}
 
@@ -285,14 +463,21 @@ func (c *Cache) UpdatePrefixes() error {
 	return nil
 }
 
-// insertPrefixes efficiently inserts multiple prefixes in a single transaction.
-func (c *Cache) insertPrefixes(tags []PrefixTag) error {
+// insertPrefixes efficiently inserts multiple prefixes in a single transaction,
+// first deleting the stale CIDRs (e.g. ones replaced by a merged prefix).
+func (c *Cache) insertPrefixes(tags []PrefixTag, stale []string) error {
 	err := c.db.Update(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketCIDRTags))
 		if bucket == nil {
 			return NewDatabaseError("get_bucket", bucketCIDRTags, nil)
 		}
 
+		for _, cidr := range stale {
+			if err := bucket.Delete([]byte(cidr)); err != nil {
+				return NewDatabaseError("delete_prefix", cidr, err)
+			}
+		}
+
 		for _, tag := range tags {
 			data, err := json.Marshal(tag)
 			if err != nil {
@@ -306,6 +491,7 @@ func (c *Cache) insertPrefixes(tags []PrefixTag) error {
 
 		return nil
 	})
+	c.invalidatePrefixIndex()
 	if err != nil {
 		return fmt.Errorf("failed to update database: %w", err)
 	}
@@ -340,6 +526,22 @@ type GCPIPRanges struct {
 	} `json:"prefixes"`
 }
 
+// AzureIPRanges represents Azure service tags data (ServiceTags_Public.json).
+type AzureIPRanges struct {
+	ChangeNumber int    `json:"changeNumber"`
+	Cloud        string `json:"cloud"`
+	Values       []struct {
+		Name       string `json:"name"`
+		ID         string `json:"id"`
+		Properties struct {
+			Region          string   `json:"region"`
+			Platform        string   `json:"platform"`
+			SystemService   string   `json:"systemService"`
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
 // DigitalOceanIPRanges represents DigitalOcean IP ranges data.
 type DigitalOceanIPRanges struct {
 	Links struct {
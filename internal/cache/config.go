@@ -17,7 +17,19 @@ type Config struct {
 	UserAgent   string
 
 	// Whois settings
+	// WhoisBackend selects how IPs are enriched: WhoisBackendWhois or WhoisBackendCymru
+	WhoisBackend string
 	WhoisTimeout time.Duration
+	// WhoisMaxRetries is how many times a transiently failing lookup is retried
+	WhoisMaxRetries int
+	// WhoisRetryBaseDelay is the delay before the first retry; it doubles on each attempt
+	WhoisRetryBaseDelay time.Duration
+	// WhoisServers maps CIDR ranges to the whois server, or registry name, queried
+	// for their addresses by the whois backend; the most specific range wins
+	WhoisServers map[string]string
+
+	// PTRTimeout bounds each reverse DNS lookup made by EnrichPTR
+	PTRTimeout time.Duration
 
 	// Provider URLs
 	ProviderURLs map[string]string
@@ -25,6 +37,12 @@ type Config struct {
 	// Feature flags
 	EnableWhoisEnrichment bool
 	EnableLogging         bool
+	// MergeAdjacentPrefixes aggregates adjacent same-annotation prefixes on update
+	MergeAdjacentPrefixes bool
+
+	// IPMaxAge is how old an IP tag may get before PruneStale deletes it; zero
+	// uses the max age passed to PruneStale
+	IPMaxAge time.Duration
 }
 
 // DefaultConfig returns a configuration with sensible defaults.
@@ -36,7 +54,11 @@ func DefaultConfig() *Config {
 		DBTimeout:             timeouts.DB,
 		HTTPTimeout:           timeouts.HTTP,
 		UserAgent:             "fli-cache/1.0",
+		WhoisBackend:          WhoisBackendWhois,
 		WhoisTimeout:          timeouts.Whois,
+		WhoisMaxRetries:       2,
+		WhoisRetryBaseDelay:   500 * time.Millisecond,
+		PTRTimeout:            timeouts.PTR,
 		EnableWhoisEnrichment: true,
 		EnableLogging:         true,
 		ProviderURLs: map[string]string{
@@ -45,6 +67,10 @@ func DefaultConfig() *Config {
 			"gcp_legacy":   "https://www.gstatic.com/ipranges/goog.json",
 			"cloudflare":   "https://www.cloudflare.com/ips-v4",
 			"digitalocean": "https://digitalocean.com/geo/google.json",
+			// Azure publishes a dated file weekly, so this is the download page
+			// the current file is resolved from. A URL ending in .json set with
+			// WithProviderURL is fetched directly instead.
+			"azure": "https://www.microsoft.com/en-us/download/details.aspx?id=56519",
 		},
 	}
 }
@@ -73,6 +99,37 @@ func (c *Config) WithWhoisTimeout(timeout time.Duration) *Config {
 	return c
 }
 
+// WithWhoisBackend selects the whois enrichment backend, WhoisBackendWhois or
+// WhoisBackendCymru.
+func (c *Config) WithWhoisBackend(backend string) *Config {
+	c.WhoisBackend = backend
+	return c
+}
+
+// WithWhoisRetries sets how many times transient whois failures are retried and the
+// base delay of the exponential backoff between attempts.
+func (c *Config) WithWhoisRetries(maxRetries int, baseDelay time.Duration) *Config {
+	c.WhoisMaxRetries = maxRetries
+	c.WhoisRetryBaseDelay = baseDelay
+	return c
+}
+
+// WithWhoisServer sends whois lookups for addresses in cidr to server, which may be
+// a host such as whois.ripe.net or a registry name such as "ripe".
+func (c *Config) WithWhoisServer(cidr, server string) *Config {
+	if c.WhoisServers == nil {
+		c.WhoisServers = make(map[string]string)
+	}
+	c.WhoisServers[cidr] = server
+	return c
+}
+
+// WithPTRTimeout sets the reverse DNS lookup timeout.
+func (c *Config) WithPTRTimeout(timeout time.Duration) *Config {
+	c.PTRTimeout = timeout
+	return c
+}
+
 // WithProviderURL sets a custom URL for a specific provider.
 func (c *Config) WithProviderURL(provider, url string) *Config {
 	if c.ProviderURLs == nil {
@@ -88,6 +145,19 @@ func (c *Config) WithWhoisEnrichment(enabled bool) *Config {
 	return c
 }
 
+// WithMergeAdjacentPrefixes enables or disables merging adjacent prefixes with the
+// same Cloud and Service when updating provider prefixes.
+func (c *Config) WithMergeAdjacentPrefixes(enabled bool) *Config {
+	c.MergeAdjacentPrefixes = enabled
+	return c
+}
+
+// WithIPMaxAge sets how old an IP tag may get before PruneStale deletes it.
+func (c *Config) WithIPMaxAge(maxAge time.Duration) *Config {
+	c.IPMaxAge = maxAge
+	return c
+}
+
 // WithLogging enables or disables logging.
 func (c *Config) WithLogging(enabled bool) *Config {
 	c.EnableLogging = enabled
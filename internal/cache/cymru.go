@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Whois backends selectable with Config.WithWhoisBackend.
+const (
+	// WhoisBackendWhois queries the registries' whois servers and parses the text.
+	WhoisBackendWhois = "whois"
+	// WhoisBackendCymru resolves the ASN, org, and country with Team Cymru's DNS
+	// service, which answers in a fixed format with two TXT lookups.
+	WhoisBackendCymru = "cymru"
+)
+
+// cymruWhoisClient implements WhoisClient using Team Cymru's IP to ASN DNS service.
+// It answers with whois-style origin, org, and country lines so parseWhoisData and
+// the stored WhoisResult fields are the same for either backend.
+type cymruWhoisClient struct {
+	resolver TXTResolver
+	timeout  time.Duration
+}
+
+// NewCymruWhoisClient creates a whois client that queries Team Cymru over DNS,
+// bounding both lookups of each address by timeout.
+func NewCymruWhoisClient(timeout time.Duration) WhoisClient {
+	return &cymruWhoisClient{
+		resolver: net.DefaultResolver,
+		timeout:  timeout,
+	}
+}
+
+func (c *cymruWhoisClient) Lookup(ip string) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", NewValidationError("cymru_lookup", ip, "invalid IP address")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	// "13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11"
+	origin, err := c.lookupRecord(ctx, cymruOriginName(addr))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ip, err)
+	}
+	if len(origin) < 3 {
+		return "", fmt.Errorf("%s: malformed Team Cymru origin record", ip)
+	}
+	// An address announced by several ASNs lists them all; keep the first
+	asn := strings.Fields(origin[0])
+	if len(asn) == 0 {
+		return "", fmt.Errorf("%s: %w", ip, ErrWhoisNoMatch)
+	}
+	country := origin[2]
+
+	// "13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US"; the org name is a
+	// nice-to-have, so a failed lookup still returns the ASN and country
+	var org string
+	if record, err := c.lookupRecord(ctx, "AS"+asn[0]+".asn.cymru.com"); err == nil && len(record) >= 5 {
+		org = strings.TrimSuffix(record[4], ", "+record[1])
+	}
+
+	return fmt.Sprintf("origin: AS%s\norg: %s\ncountry: %s\n", asn[0], org, country), nil
+}
+
+// lookupRecord resolves name and splits its first TXT record into its
+// pipe-separated fields. A name that does not exist means the address is not
+// announced and is reported as ErrWhoisNoMatch.
+func (c *cymruWhoisClient) lookupRecord(ctx context.Context, name string) ([]string, error) {
+	records, err := c.resolver.LookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, ErrWhoisNoMatch
+		}
+		return nil, fmt.Errorf("team cymru lookup failed: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, ErrWhoisNoMatch
+	}
+
+	fields := strings.Split(records[0], "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields, nil
+}
+
+// cymruOriginName returns the DNS name queried for addr: the reversed octets
+// under origin.asn.cymru.com for IPv4, or the reversed nibbles under
+// origin6.asn.cymru.com for IPv6.
+func cymruOriginName(addr netip.Addr) string {
+	addr = addr.Unmap()
+	var labels []string
+	if addr.Is4() {
+		b := addr.As4()
+		for i := len(b) - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprint(b[i]))
+		}
+		return strings.Join(labels, ".") + ".origin.asn.cymru.com"
+	}
+
+	b := addr.As16()
+	for i := len(b) - 1; i >= 0; i-- {
+		labels = append(labels, fmt.Sprintf("%x", b[i]&0x0f), fmt.Sprintf("%x", b[i]>>4))
+	}
+	return strings.Join(labels, ".") + ".origin6.asn.cymru.com"
+}
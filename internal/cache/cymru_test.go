@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeTXTResolver answers TXT lookups from a map; unknown names do not exist.
+type fakeTXTResolver map[string]string
+
+func (r fakeTXTResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if record, ok := r[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func TestCymruOriginName(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"1.1.1.1", "1.1.1.1.origin.asn.cymru.com"},
+		{"203.0.113.10", "10.113.0.203.origin.asn.cymru.com"},
+		{"::ffff:203.0.113.10", "10.113.0.203.origin.asn.cymru.com"},
+		{"2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.origin6.asn.cymru.com"},
+	}
+	for _, tt := range tests {
+		if got := cymruOriginName(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("cymruOriginName(%s) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCymruWhoisClient(t *testing.T) {
+	client := &cymruWhoisClient{
+		resolver: fakeTXTResolver{
+			"1.1.1.1.origin.asn.cymru.com": "13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11",
+			"AS13335.asn.cymru.com":        "13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US",
+			"9.9.9.9.origin.asn.cymru.com": "19281 42 | 9.9.9.0/24 | US | arin | 2017-09-13",
+		},
+		timeout: time.Second,
+	}
+	cfg := DefaultConfig().WithCachePath(filepath.Join(t.TempDir(), "test_cache.db")).WithWhoisRetries(0, 0)
+	c, err := OpenWithDependencies(cfg, NewDefaultHTTPClient(time.Second), client, NewDefaultLogger(false), NewDefaultFileSystem())
+	if err != nil {
+		t.Fatalf("OpenWithDependencies() error = %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	tests := []struct {
+		ip      string
+		want    WhoisResult
+		noMatch bool
+	}{
+		{ip: "1.1.1.1", want: WhoisResult{IP: "1.1.1.1", ASN: "AS13335", Org: "CLOUDFLARENET", Country: "AU"}},
+		// Multiple origin ASNs keep the first; a missing AS record leaves Org empty
+		{ip: "9.9.9.9", want: WhoisResult{IP: "9.9.9.9", ASN: "AS19281", Country: "US"}},
+		{ip: "192.0.2.1", noMatch: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, err := c.EnrichIP(tt.ip)
+			if tt.noMatch {
+				if !errors.Is(err, ErrWhoisNoMatch) {
+					t.Fatalf("EnrichIP() error = %v, want ErrWhoisNoMatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EnrichIP() error = %v", err)
+			}
+			if got.ASN != tt.want.ASN || got.Org != tt.want.Org || got.Country != tt.want.Country {
+				t.Errorf("EnrichIP() = %+v, want %+v", got, tt.want)
+			}
+
+			stored, err := c.GetWhoisInfo(tt.ip)
+			if err != nil || stored.ASN != tt.want.ASN || stored.Country != tt.want.Country {
+				t.Errorf("GetWhoisInfo() = %+v, %v, want %+v", stored, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenWithUnknownWhoisBackend(t *testing.T) {
+	cfg := DefaultConfig().WithCachePath(filepath.Join(t.TempDir(), "test_cache.db")).WithWhoisBackend("finger")
+	if c, err := OpenWithConfig(cfg); err == nil {
+		_ = c.Close()
+		t.Fatal("OpenWithConfig() error = nil, want an unknown backend error")
+	}
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"go.etcd.io/bbolt"
+)
+
+// SnapshotVersion is the version of the document written by Export.
+const SnapshotVersion = 1
+
+// Snapshot is a portable copy of the cached ENI, IP, and prefix annotations.
+type Snapshot struct {
+	Version  int         `json:"version"`
+	ENIs     []ENITag    `json:"enis"`
+	IPs      []IPTag     `json:"ips"`
+	Prefixes []PrefixTag `json:"prefixes"`
+}
+
+// Export writes every cached ENI, IP, and prefix tag to w as a versioned JSON
+// document. Records that cannot be decoded are skipped; run Check to find them.
+func (c *Cache) Export(w io.Writer) error {
+	snapshot := Snapshot{
+		Version:  SnapshotVersion,
+		ENIs:     []ENITag{},
+		IPs:      []IPTag{},
+		Prefixes: []PrefixTag{},
+	}
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		if err := readBucket(c, tx, bucketENITags, &snapshot.ENIs); err != nil {
+			return err
+		}
+		if err := readBucket(c, tx, bucketIPTags, &snapshot.IPs); err != nil {
+			return err
+		}
+		return readBucket(c, tx, bucketCIDRTags, &snapshot.Prefixes)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export cache: %w", err)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(snapshot); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// readBucket decodes every record of the named bucket and appends it to out.
+func readBucket[T any](c *Cache, tx *bbolt.Tx, name string, out *[]T) error {
+	bucket := tx.Bucket([]byte(name))
+	if bucket == nil {
+		return NewDatabaseError("get_bucket", name, nil)
+	}
+	return bucket.ForEach(func(k, v []byte) error {
+		var tag T
+		if err := json.Unmarshal(v, &tag); err != nil {
+			c.logger.Warn("Skipping unreadable %s record %q: %v", name, string(k), err)
+			return nil
+		}
+		*out = append(*out, tag)
+		return nil
+	})
+}
+
+// Import reads a document written by Export and upserts its tags, keeping any
+// existing entries it does not mention. Every entry is validated first, so an
+// invalid document leaves the cache unchanged.
+func (c *Cache) Import(r io.Reader) error {
+	var snapshot Snapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return NewInvalidDataError("import", "", "failed to parse snapshot", err)
+	}
+	if err := snapshot.validate(); err != nil {
+		return err
+	}
+
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		for _, tag := range snapshot.ENIs {
+			if err := putJSON(tx, bucketENITags, tag.ENI, tag); err != nil {
+				return err
+			}
+		}
+		for _, tag := range snapshot.IPs {
+			if err := putJSON(tx, bucketIPTags, tag.Addr, tag); err != nil {
+				return err
+			}
+		}
+		for _, tag := range snapshot.Prefixes {
+			if err := putJSON(tx, bucketCIDRTags, tag.CIDR, tag); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	c.invalidatePrefixIndex()
+	if err != nil {
+		return fmt.Errorf("failed to import cache: %w", err)
+	}
+
+	c.logger.Info("Imported %d ENIs, %d IPs, and %d prefixes",
+		len(snapshot.ENIs), len(snapshot.IPs), len(snapshot.Prefixes))
+	return nil
+}
+
+// validate checks the snapshot version and the key of every entry, rewriting
+// IP and CIDR keys into the canonical form the cache looks them up by.
+func (s *Snapshot) validate() error {
+	if s.Version != SnapshotVersion {
+		return NewValidationError("import", fmt.Sprint(s.Version),
+			fmt.Sprintf("unsupported snapshot version, want %d", SnapshotVersion))
+	}
+	for _, tag := range s.ENIs {
+		if tag.ENI == "" {
+			return NewValidationError("import", "", "ENI tag without an ENI ID")
+		}
+	}
+	for i, tag := range s.IPs {
+		addr, err := netip.ParseAddr(tag.Addr)
+		if err != nil {
+			return NewValidationError("import", tag.Addr, "invalid IP address")
+		}
+		s.IPs[i].Addr = addr.String()
+	}
+	for i, tag := range s.Prefixes {
+		prefix, err := netip.ParsePrefix(tag.CIDR)
+		if err != nil {
+			return NewValidationError("import", tag.CIDR, "invalid CIDR format")
+		}
+		s.Prefixes[i].CIDR = prefix.Masked().String()
+	}
+	return nil
+}
+
+// putJSON stores value under key in the named bucket.
+func putJSON(tx *bbolt.Tx, name, key string, value any) error {
+	bucket := tx.Bucket([]byte(name))
+	if bucket == nil {
+		return NewDatabaseError("get_bucket", name, nil)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return NewInvalidDataError("marshal", key, "failed to marshal tag", err)
+	}
+	if err := bucket.Put([]byte(key), data); err != nil {
+		return NewDatabaseError("put", key, err)
+	}
+	return nil
+}
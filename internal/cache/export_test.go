@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/netip"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func openExportTestCache(t *testing.T) *Cache {
+	t.Helper()
+	cfg := DefaultConfig().WithCachePath(filepath.Join(t.TempDir(), "test_cache.db")).WithLogging(false)
+	c, err := OpenWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := openExportTestCache(t)
+	eni := ENITag{ENI: "eni-123", Label: "api", SGNames: []string{"api-sg"}, FirstSeen: 1700000000, PrivateIPs: []string{"10.0.0.5"}}
+	ip := IPTag{Addr: "1.1.1.1", Name: "CLOUDFLARE", ASN: "AS13335", Fetched: 1700000000}
+	prefix := PrefixTag{CIDR: "13.32.0.0/15", Cloud: "AWS", Service: "CLOUDFRONT", Fetched: 1700000000}
+	if err := src.UpsertEni(eni); err != nil {
+		t.Fatalf("UpsertEni() error = %v", err)
+	}
+	if err := src.UpsertIP(ip); err != nil {
+		t.Fatalf("UpsertIP() error = %v", err)
+	}
+	if err := src.UpsertPrefix(prefix); err != nil {
+		t.Fatalf("UpsertPrefix() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(buf.Bytes(), &snapshot); err != nil {
+		t.Fatalf("Export() wrote invalid JSON: %v", err)
+	}
+	want := Snapshot{Version: SnapshotVersion, ENIs: []ENITag{eni}, IPs: []IPTag{ip}, Prefixes: []PrefixTag{prefix}}
+	if !reflect.DeepEqual(snapshot, want) {
+		t.Errorf("Export() = %+v, want %+v", snapshot, want)
+	}
+
+	// Import merges into a cache that already holds other entries
+	dst := openExportTestCache(t)
+	if err := dst.UpsertIP(IPTag{Addr: "192.0.2.1", Name: "existing"}); err != nil {
+		t.Fatalf("UpsertIP() error = %v", err)
+	}
+	if err := dst.Import(&buf); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	gotENI, err := dst.LookupEni(context.Background(), "eni-123")
+	if err != nil || gotENI == nil || !reflect.DeepEqual(*gotENI, eni) {
+		t.Errorf("LookupEni() = %+v, %v, want %+v", gotENI, err, eni)
+	}
+	for addr, want := range map[string]string{
+		"1.1.1.1":   "CLOUDFLARE",
+		"13.32.1.1": "AWS (13.32.0.0/15), CLOUDFRONT",
+		"192.0.2.1": "existing",
+	} {
+		got, err := dst.LookupIP(netip.MustParseAddr(addr))
+		if err != nil || got != want {
+			t.Errorf("LookupIP(%s) = %q, %v, want %q", addr, got, err, want)
+		}
+	}
+}
+
+func TestImportValidatesBeforeWriting(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot string
+	}{
+		{name: "bad JSON", snapshot: `{"version": 1,`},
+		{name: "unknown version", snapshot: `{"version": 2}`},
+		{name: "missing ENI ID", snapshot: `{"version": 1, "enis": [{"Label": "api"}]}`},
+		{name: "bad IP", snapshot: `{"version": 1, "ips": [{"Addr": "1.1.1.1", "Name": "ok"}, {"Addr": "not-an-ip"}]}`},
+		{name: "bad CIDR", snapshot: `{"version": 1, "prefixes": [{"CIDR": "10.0.0.0/33"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := openExportTestCache(t)
+			if err := c.Import(strings.NewReader(tt.snapshot)); err == nil {
+				t.Fatal("Import() error = nil, want an error")
+			}
+			if ips, _ := c.ListIPs(); len(ips) != 0 {
+				t.Errorf("Import() wrote %v despite the invalid snapshot", ips)
+			}
+		})
+	}
+}
+
+func TestImportCanonicalizesKeys(t *testing.T) {
+	c := openExportTestCache(t)
+	snapshot := `{"version": 1,
+		"ips": [{"Addr": "2001:DB8:0:0::1", "Name": "v6"}],
+		"prefixes": [{"CIDR": "13.32.7.1/15", "Cloud": "AWS", "Service": "CLOUDFRONT"}]}`
+	if err := c.Import(strings.NewReader(snapshot)); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	for addr, want := range map[string]string{
+		"2001:db8::1": "v6",
+		"13.32.1.1":   "AWS (13.32.0.0/15), CLOUDFRONT",
+	} {
+		got, err := c.LookupIP(netip.MustParseAddr(addr))
+		if err != nil || got != want {
+			t.Errorf("LookupIP(%s) = %q, %v, want %q", addr, got, err, want)
+		}
+	}
+}
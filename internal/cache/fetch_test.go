@@ -1,7 +1,14 @@
 package cache
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -195,6 +202,47 @@ func TestProcessAWSData(t *testing.T) {
 	}
 }
 
+func TestProcessAWSDataPrefersSpecificService(t *testing.T) {
+	cache := &Cache{
+		logger: NewDefaultLogger(false),
+	}
+
+	var awsData AWSIPRanges
+	err := json.Unmarshal([]byte(`{"prefixes": [
+		{"ip_prefix": "3.5.0.0/16", "region": "us-east-1", "service": "EC2"},
+		{"ip_prefix": "3.5.0.0/16", "region": "us-east-1", "service": "S3"},
+		{"ip_prefix": "3.5.0.0/16", "region": "us-east-1", "service": "AMAZON"},
+		{"ip_prefix": "3.6.0.0/16", "region": "us-east-1", "service": "ROUTE53_HEALTHCHECKS"},
+		{"ip_prefix": "3.6.0.0/16", "region": "us-east-1", "service": "EC2"},
+		{"ip_prefix": "3.7.0.0/16", "region": "us-east-1", "service": "CLOUDFRONT"},
+		{"ip_prefix": "3.7.0.0/16", "region": "us-east-1", "service": "S3"},
+		{"ip_prefix": "3.8.0.0/16", "region": "us-east-1", "service": "EC2"}
+	]}`), &awsData)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal AWS data: %v", err)
+	}
+
+	tags, err := cache.processAWSData(awsData)
+	if err != nil {
+		t.Fatalf("Failed to process AWS data: %v", err)
+	}
+
+	want := []PrefixTag{
+		{CIDR: "3.5.0.0/16", Cloud: "AWS", Service: "S3", Region: "us-east-1"},
+		{CIDR: "3.6.0.0/16", Cloud: "AWS", Service: "ROUTE53_HEALTHCHECKS", Region: "us-east-1"},
+		{CIDR: "3.7.0.0/16", Cloud: "AWS", Service: "CLOUDFRONT", Region: "us-east-1"}, // first of equally specific services
+		{CIDR: "3.8.0.0/16", Cloud: "AWS", Service: "EC2", Region: "us-east-1"},
+	}
+	if len(tags) != len(want) {
+		t.Fatalf("Expected %d tags, got %d: %+v", len(want), len(tags), tags)
+	}
+	for i := range want {
+		if tags[i] != want[i] {
+			t.Errorf("tag %d = %+v, want %+v", i, tags[i], want[i])
+		}
+	}
+}
+
 func TestProcessGCPData(t *testing.T) {
 	cache := &Cache{
 		logger: NewDefaultLogger(true),
@@ -290,6 +338,101 @@ func TestProcessDigitalOceanData(t *testing.T) {
 	}
 }
 
+func TestProcessAzureData(t *testing.T) {
+	cache := &Cache{
+		logger: NewDefaultLogger(true),
+	}
+
+	jsonData := `{
+		"changeNumber": 327,
+		"cloud": "Public",
+		"values": [
+			{
+				"name": "Storage.WestUS",
+				"id": "Storage.WestUS",
+				"properties": {
+					"region": "westus",
+					"platform": "Azure",
+					"systemService": "AzureStorage",
+					"addressPrefixes": ["13.88.144.0/20", "2603:1030:a01::/48"]
+				}
+			},
+			{
+				"name": "AzureFrontDoor.Frontend",
+				"id": "AzureFrontDoor.Frontend",
+				"properties": {
+					"addressPrefixes": ["13.107.246.0/24"]
+				}
+			}
+		]
+	}`
+
+	var azureData AzureIPRanges
+	if err := json.Unmarshal([]byte(jsonData), &azureData); err != nil {
+		t.Fatalf("Failed to unmarshal Azure service tags: %v", err)
+	}
+
+	tags, err := cache.processAzureData(azureData)
+	if err != nil {
+		t.Fatalf("Failed to process Azure data: %v", err)
+	}
+
+	want := []PrefixTag{
+		{CIDR: "13.88.144.0/20", Cloud: "Azure", Service: "Storage.WestUS", Region: "westus"},
+		{CIDR: "2603:1030:a01::/48", Cloud: "Azure", Service: "Storage.WestUS", Region: "westus"},
+		{CIDR: "13.107.246.0/24", Cloud: "Azure", Service: "AzureFrontDoor.Frontend"},
+	}
+	if len(tags) != len(want) {
+		t.Fatalf("Expected %d tags, got %d", len(want), len(tags))
+	}
+	for i := range want {
+		if tags[i] != want[i] {
+			t.Errorf("tag %d = %+v, want %+v", i, tags[i], want[i])
+		}
+	}
+
+	if _, err := cache.processAzureData("not azure data"); err == nil {
+		t.Error("Expected an error for an invalid Azure data type")
+	}
+}
+
+func TestProcessAzureDataPrefersSpecificServiceTag(t *testing.T) {
+	cache := &Cache{
+		logger: NewDefaultLogger(false),
+	}
+
+	var azureData AzureIPRanges
+	err := json.Unmarshal([]byte(`{"values": [
+		{"name": "AzureCloud", "properties": {"addressPrefixes": ["13.88.144.0/20", "20.38.0.0/16", "40.64.0.0/16"]}},
+		{"name": "AzureCloud.westus", "properties": {"region": "westus", "addressPrefixes": ["13.88.144.0/20", "20.38.0.0/16"]}},
+		{"name": "Storage", "properties": {"addressPrefixes": ["13.88.144.0/20", "20.38.0.0/16"]}},
+		{"name": "Storage.WestUS", "properties": {"region": "westus", "addressPrefixes": ["13.88.144.0/20"]}},
+		{"name": "AzureMonitor", "properties": {"addressPrefixes": ["20.38.0.0/16"]}}
+	]}`), &azureData)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal Azure service tags: %v", err)
+	}
+
+	tags, err := cache.processAzureData(azureData)
+	if err != nil {
+		t.Fatalf("Failed to process Azure data: %v", err)
+	}
+
+	want := []PrefixTag{
+		{CIDR: "13.88.144.0/20", Cloud: "Azure", Service: "Storage.WestUS", Region: "westus"},
+		{CIDR: "20.38.0.0/16", Cloud: "Azure", Service: "Storage"}, // first of equally specific tags
+		{CIDR: "40.64.0.0/16", Cloud: "Azure", Service: "AzureCloud"},
+	}
+	if len(tags) != len(want) {
+		t.Fatalf("Expected %d tags, got %d: %+v", len(want), len(tags), tags)
+	}
+	for i := range want {
+		if tags[i] != want[i] {
+			t.Errorf("tag %d = %+v, want %+v", i, tags[i], want[i])
+		}
+	}
+}
+
 func TestFetchResult(t *testing.T) {
 	result := &FetchResult{
 		Provider: testProviderAWS,
@@ -308,11 +451,172 @@ func TestFetchResult(t *testing.T) {
 	}
 }
 
-// Note: Tests for FetchProvider, FetchAllProviders, and UpdatePrefixes
-// are not included here because they would require real HTTP calls which
-// can fail due to network issues or rate limiting. In a real testing
-// environment, you would:
-// 1. Mock the HTTP client
-// 2. Use httptest.Server to create a test server
-// 3. Use integration tests with controlled test data
-// 4. Test the parsing logic separately from the network calls
+// slowHTTPClient serves every request with an empty Cloudflare-style body after
+// delay, and records the highest number of requests in flight at once.
+type slowHTTPClient struct {
+	delay time.Duration
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (h *slowHTTPClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return h.Do(req)
+}
+
+func (h *slowHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	h.mu.Lock()
+	h.inFlight++
+	h.maxInFlight = max(h.maxInFlight, h.inFlight)
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		h.inFlight--
+		h.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(h.delay):
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func openFetchTestCache(t *testing.T, client HTTPClient, providers ...string) *Cache {
+	t.Helper()
+	cfg := DefaultConfig().WithCachePath(filepath.Join(t.TempDir(), "cache.db"))
+	cfg.ProviderURLs = nil
+	for _, provider := range providers {
+		cfg.WithProviderURL(provider, "https://example.com/"+provider)
+	}
+	c, err := OpenWithDependencies(cfg, client, NewDefaultWhoisClient(time.Second), NewDefaultLogger(false), NewDefaultFileSystem())
+	if err != nil {
+		t.Fatalf("OpenWithDependencies() error = %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestFetchAllProvidersConcurrently(t *testing.T) {
+	client := &slowHTTPClient{delay: 20 * time.Millisecond}
+	// "unknown" fails after its fetch; the others still succeed
+	c := openFetchTestCache(t, client, "cloudflare", "unknown", "p1", "p2", "p3", "p4")
+
+	results, err := c.FetchAllProviders(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAllProviders() error = %v", err)
+	}
+
+	if client.maxInFlight < 2 || client.maxInFlight > maxConcurrentFetches {
+		t.Errorf("max concurrent fetches = %d, want between 2 and %d", client.maxInFlight, maxConcurrentFetches)
+	}
+
+	var providers []string
+	for _, result := range results {
+		providers = append(providers, result.Provider)
+		if wantErr := result.Provider != "cloudflare"; (result.Error != nil) != wantErr {
+			t.Errorf("result for %s: error = %v, wantErr %v", result.Provider, result.Error, wantErr)
+		}
+	}
+	if got := strings.Join(providers, ","); got != "cloudflare,p1,p2,p3,p4,unknown" {
+		t.Errorf("providers = %s, want them sorted by name", got)
+	}
+}
+
+func TestFetchAllProvidersCancelled(t *testing.T) {
+	client := &slowHTTPClient{delay: time.Minute}
+	c := openFetchTestCache(t, client, "aws", "gcp", "azure", "cloudflare", "digitalocean", "gcp_legacy")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	results, err := c.FetchAllProviders(ctx)
+	if err != nil {
+		t.Fatalf("FetchAllProviders() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("FetchAllProviders() took %v after cancellation", elapsed)
+	}
+	if len(results) != 6 {
+		t.Fatalf("got %d results, want 6", len(results))
+	}
+	for _, result := range results {
+		if !errors.Is(result.Error, context.DeadlineExceeded) {
+			t.Errorf("result for %s: error = %v, want the context deadline", result.Provider, result.Error)
+		}
+	}
+}
+
+// staticHTTPClient serves fixed bodies by URL and 404s everything else.
+type staticHTTPClient map[string]string
+
+func (h staticHTTPClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return h.Do(req)
+}
+
+func (h staticHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	body, ok := h[req.URL.String()]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func TestFetchAzureResolvesDownloadPage(t *testing.T) {
+	const fileURL = "https://download.microsoft.com/download/7/1/D/71D86715/ServiceTags_Public_20261012.json"
+	client := staticHTTPClient{
+		"https://example.com/azure": `<a href="` + fileURL + `" class="download">Download</a>`,
+		fileURL:                     `{"changeNumber": 1, "values": []}`,
+	}
+	c := openFetchTestCache(t, client, "azure")
+
+	result, err := c.FetchProvider(context.Background(), "azure")
+	if err != nil {
+		t.Fatalf("FetchProvider() error = %v", err)
+	}
+	if _, ok := result.Data.(AzureIPRanges); !ok {
+		t.Errorf("FetchProvider() data = %T, want AzureIPRanges", result.Data)
+	}
+
+	// A page without a link is an error rather than an empty update
+	c = openFetchTestCache(t, staticHTTPClient{"https://example.com/azure": "<html></html>"}, "azure")
+	if _, err := c.FetchProvider(context.Background(), "azure"); err == nil {
+		t.Error("FetchProvider() error = nil for a page without a ServiceTags link")
+	}
+}
+
+func TestUpdatePrefixesCancelled(t *testing.T) {
+	client := &slowHTTPClient{delay: time.Minute}
+	c := openFetchTestCache(t, client, "aws", "cloudflare")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := c.UpdatePrefixes(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("UpdatePrefixes() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("UpdatePrefixes() took %v to return after cancellation", elapsed)
+	}
+	if prefixes, _ := c.ListPrefixes(); len(prefixes) != 0 {
+		t.Errorf("UpdatePrefixes() wrote %d prefixes after cancellation", len(prefixes))
+	}
+}
+
+// Note: Tests against the real provider URLs are not included here because
+// they would require real HTTP calls which can fail due to network issues or
+// rate limiting. The tests above use slowHTTPClient instead, and the parsing
+// logic is tested separately from the network calls.
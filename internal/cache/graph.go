@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"go.etcd.io/bbolt"
+)
+
+// WriteENIGraph writes the cached ENIs as an undirected Graphviz DOT graph with
+// an edge from each ENI to every security group it belongs to, so ENIs sharing
+// a group are connected through it. ENIs are labelled with their cached label.
+// Records that cannot be decoded are skipped; run Check to find them.
+func (c *Cache) WriteENIGraph(w io.Writer) error {
+	var enis []ENITag
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return readBucket(c, tx, bucketENITags, &enis)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read ENIs: %w", err)
+	}
+	sort.Slice(enis, func(i, j int) bool { return enis[i].ENI < enis[j].ENI })
+
+	var sb strings.Builder
+	sb.WriteString("graph enis {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box];\n")
+
+	groups := make(map[string]bool)
+	for _, eni := range enis {
+		label := dotEscape(eni.ENI)
+		if eni.Label != "" {
+			label += `\n` + dotEscape(eni.Label)
+		}
+		fmt.Fprintf(&sb, "  \"%s\" [label=\"%s\"];\n", dotEscape(eni.ENI), label)
+		for _, sg := range eni.SGNames {
+			groups[sg] = true
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	for sg := range groups {
+		names = append(names, sg)
+	}
+	sort.Strings(names)
+	for _, sg := range names {
+		fmt.Fprintf(&sb, "  \"%s\" [shape=ellipse, label=\"%s\"];\n", dotEscape(sgNodeID(sg)), dotEscape(sg))
+	}
+
+	for _, eni := range enis {
+		for _, sg := range eni.SGNames {
+			fmt.Fprintf(&sb, "  \"%s\" -- \"%s\";\n", dotEscape(eni.ENI), dotEscape(sgNodeID(sg)))
+		}
+	}
+	sb.WriteString("}\n")
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+	return nil
+}
+
+// sgNodeID returns the DOT node ID of a security group, prefixed so that a group
+// cannot share an ID with an ENI.
+func sgNodeID(name string) string {
+	return "sg:" + name
+}
+
+// dotEscape escapes s for use inside a double-quoted DOT string.
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteENIGraph(t *testing.T) {
+	c := openExportTestCache(t)
+	for _, eni := range []ENITag{
+		{ENI: "eni-b", Label: "worker", SGNames: []string{"app-sg"}},
+		{ENI: "eni-a", Label: "api", SGNames: []string{"app-sg", "bastion-sg"}},
+		{ENI: "eni-c", Label: `say "hi"`},
+	} {
+		if err := c.UpsertEni(eni); err != nil {
+			t.Fatalf("UpsertEni(%s) error = %v", eni.ENI, err)
+		}
+	}
+
+	var sb strings.Builder
+	if err := c.WriteENIGraph(&sb); err != nil {
+		t.Fatalf("WriteENIGraph() error = %v", err)
+	}
+	got := sb.String()
+
+	for _, want := range []string{
+		"graph enis {\n",
+		`  "eni-a" [label="eni-a\napi"];`,
+		`  "eni-b" [label="eni-b\nworker"];`,
+		`  "eni-c" [label="eni-c\nsay \"hi\""];`,
+		`  "sg:app-sg" [shape=ellipse, label="app-sg"];`,
+		`  "sg:bastion-sg" [shape=ellipse, label="bastion-sg"];`,
+		`  "eni-a" -- "sg:app-sg";`,
+		`  "eni-a" -- "sg:bastion-sg";`,
+		`  "eni-b" -- "sg:app-sg";`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteENIGraph() output is missing %q:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, " -- "); n != 3 {
+		t.Errorf("WriteENIGraph() wrote %d edges, want 3:\n%s", n, got)
+	}
+	if !strings.HasSuffix(got, "}\n") {
+		t.Errorf("WriteENIGraph() output is not closed:\n%s", got)
+	}
+}
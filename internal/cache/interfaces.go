@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/likexian/whois"
@@ -24,6 +25,16 @@ type WhoisClient interface {
 	Lookup(ip string) (string, error)
 }
 
+// PTRResolver interface for reverse DNS lookups; *net.Resolver implements it.
+type PTRResolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// TXTResolver interface for DNS TXT lookups; *net.Resolver implements it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
 // Logger interface for logging.
 type Logger interface {
 	Debug(msg string, args ...interface{})
@@ -82,15 +93,34 @@ func (c *defaultHTTPClient) Do(req *http.Request) (*http.Response, error) {
 // defaultWhoisClient implements WhoisClient using the likexian/whois package.
 type defaultWhoisClient struct {
 	timeout time.Duration
+	servers []whoisServer
+	whois   func(domain string, servers ...string) (string, error)
 }
 
 // NewDefaultWhoisClient creates a new default whois client with the specified timeout.
 func NewDefaultWhoisClient(timeout time.Duration) WhoisClient {
 	return &defaultWhoisClient{
 		timeout: timeout,
+		whois:   whois.Whois,
 	}
 }
 
+// NewWhoisClientWithServers creates a default whois client that sends lookups for
+// the ranges in servers to the given whois server, or to a registry's server when
+// the value is a registry name such as "ripe" (afrinic, apnic, arin, lacnic, or ripe). Other
+// addresses use the library's server resolution.
+func NewWhoisClientWithServers(timeout time.Duration, servers map[string]string) (WhoisClient, error) {
+	routes, err := parseWhoisServers(servers)
+	if err != nil {
+		return nil, err
+	}
+	return &defaultWhoisClient{
+		timeout: timeout,
+		servers: routes,
+		whois:   whois.Whois,
+	}, nil
+}
+
 func (c *defaultWhoisClient) Lookup(ip string) (string, error) {
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
@@ -100,8 +130,13 @@ func (c *defaultWhoisClient) Lookup(ip string) (string, error) {
 	resultCh := make(chan string, 1)
 	errCh := make(chan error, 1)
 
+	var servers []string
+	if server := whoisServerFor(c.servers, ip); server != "" {
+		servers = append(servers, server)
+	}
+
 	go func() {
-		result, err := whois.Whois(ip)
+		result, err := c.whois(ip, servers...)
 		if err != nil {
 			errCh <- fmt.Errorf("whois lookup failed: %w", err)
 			return
@@ -112,6 +147,9 @@ func (c *defaultWhoisClient) Lookup(ip string) (string, error) {
 	// Wait for result or timeout
 	select {
 	case result := <-resultCh:
+		if isWhoisNoMatch(result) {
+			return "", fmt.Errorf("%s: %w", ip, ErrWhoisNoMatch)
+		}
 		return result, nil
 	case err := <-errCh:
 		return "", err
@@ -120,6 +158,12 @@ func (c *defaultWhoisClient) Lookup(ip string) (string, error) {
 	}
 }
 
+// isWhoisNoMatch reports whether a whois response says the registry has no record.
+func isWhoisNoMatch(result string) bool {
+	low := strings.ToLower(result)
+	return strings.Contains(low, "no match found") || strings.Contains(low, "no match for")
+}
+
 // defaultLogger implements Logger using the standard log package.
 type defaultLogger struct {
 	enabled bool
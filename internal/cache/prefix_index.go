@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"sort"
+
+	"go.etcd.io/bbolt"
+)
+
+// prefixIndex holds the cached CIDR tags parsed once and grouped by prefix length,
+// so a longest-prefix match costs one map lookup per distinct length instead of
+// a scan that re-parses every key.
+type prefixIndex struct {
+	// lengths lists the distinct prefix lengths present, longest first.
+	lengths []int
+	// byLength maps a prefix length to the masked prefixes of that length.
+	byLength map[int]map[netip.Prefix]PrefixTag
+}
+
+// loadPrefixIndex builds a prefix index from the CIDR tags bucket.
+func loadPrefixIndex(tx *bbolt.Tx) (*prefixIndex, error) {
+	idx := &prefixIndex{byLength: make(map[int]map[netip.Prefix]PrefixTag)}
+
+	bucket := tx.Bucket([]byte(bucketCIDRTags))
+	if bucket == nil {
+		return idx, nil
+	}
+
+	err := bucket.ForEach(func(k, v []byte) error {
+		prefix, err := netip.ParsePrefix(string(k))
+		if err != nil {
+			return fmt.Errorf("invalid CIDR key %q: %w", string(k), err)
+		}
+		var tag PrefixTag
+		if err := json.Unmarshal(v, &tag); err != nil {
+			return nil // Unreadable tags never match, as before
+		}
+
+		bits := prefix.Bits()
+		prefixes, ok := idx.byLength[bits]
+		if !ok {
+			prefixes = make(map[netip.Prefix]PrefixTag)
+			idx.byLength[bits] = prefixes
+			idx.lengths = append(idx.lengths, bits)
+		}
+		// Keys are visited in order, so the first of several keys that mask to
+		// the same prefix wins, matching the original scan
+		masked := prefix.Masked()
+		if _, exists := prefixes[masked]; !exists {
+			prefixes[masked] = tag
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate CIDR bucket: %w", err)
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(idx.lengths)))
+	return idx, nil
+}
+
+// longestMatch returns the tag of the longest cached prefix containing addr.
+func (idx *prefixIndex) longestMatch(addr netip.Addr) (PrefixTag, bool) {
+	if addr.Zone() != "" {
+		return PrefixTag{}, false
+	}
+	for _, bits := range idx.lengths {
+		// A /0 prefix is skipped, as the original scan only accepted lengths above zero
+		if bits == 0 || bits > addr.BitLen() {
+			continue
+		}
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if tag, ok := idx.byLength[bits][prefix]; ok {
+			return tag, true
+		}
+	}
+	return PrefixTag{}, false
+}
+
+// cachedPrefixIndex returns the in-memory prefix index, loading it on first use.
+func (c *Cache) cachedPrefixIndex(tx *bbolt.Tx) (*prefixIndex, error) {
+	c.prefixMu.Lock()
+	defer c.prefixMu.Unlock()
+	if c.prefixes == nil {
+		idx, err := loadPrefixIndex(tx)
+		if err != nil {
+			return nil, err
+		}
+		c.prefixes = idx
+	}
+	return c.prefixes, nil
+}
+
+// invalidatePrefixIndex drops the in-memory prefix index after a prefix write,
+// so the next lookup rebuilds it from the database.
+func (c *Cache) invalidatePrefixIndex() {
+	c.prefixMu.Lock()
+	defer c.prefixMu.Unlock()
+	c.prefixes = nil
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+// scanLongestPrefix is the original bucket-scan lookup, kept as a baseline for the index.
+func scanLongestPrefix(t *testing.T, c *Cache, addr netip.Addr) (PrefixTag, bool) {
+	t.Helper()
+	var best PrefixTag
+	bestLen := 0
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(bucketCIDRTags)).ForEach(func(k, v []byte) error {
+			prefix, err := netip.ParsePrefix(string(k))
+			if err != nil {
+				return err
+			}
+			if prefix.Contains(addr) && prefix.Bits() > bestLen {
+				var tag PrefixTag
+				if err := json.Unmarshal(v, &tag); err == nil {
+					best, bestLen = tag, prefix.Bits()
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to scan prefixes: %v", err)
+	}
+	return best, bestLen > 0
+}
+
+func TestLookupIPReflectsPrefixWrites(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "test_cache.db"))
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	addr := netip.MustParseAddr("10.1.2.3")
+	lookup := func(want string) {
+		t.Helper()
+		got, err := c.LookupIP(addr)
+		if err != nil {
+			t.Fatalf("LookupIP() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("LookupIP() = %q, want %q", got, want)
+		}
+	}
+
+	if err := c.UpsertPrefix(PrefixTag{CIDR: "10.0.0.0/8", Cloud: "AWS"}); err != nil {
+		t.Fatalf("UpsertPrefix() error = %v", err)
+	}
+	lookup("AWS (10.0.0.0/8)")
+
+	if err := c.UpsertPrefix(PrefixTag{CIDR: "10.1.0.0/16", Cloud: "GCP"}); err != nil {
+		t.Fatalf("UpsertPrefix() error = %v", err)
+	}
+	lookup("GCP (10.1.0.0/16)")
+
+	if err := c.UpsertPrefixes([]PrefixTag{{CIDR: "10.1.2.0/24", Cloud: "AWS", Service: "EC2"}}); err != nil {
+		t.Fatalf("UpsertPrefixes() error = %v", err)
+	}
+	lookup("AWS (10.1.2.0/24), EC2")
+
+	if err := c.DeletePrefix("10.1.2.0/24"); err != nil {
+		t.Fatalf("DeletePrefix() error = %v", err)
+	}
+	lookup("GCP (10.1.0.0/16)")
+
+	if err := c.DeletePrefix("10.1.0.0/16"); err != nil {
+		t.Fatalf("DeletePrefix() error = %v", err)
+	}
+	if err := c.DeletePrefix("10.0.0.0/8"); err != nil {
+		t.Fatalf("DeletePrefix() error = %v", err)
+	}
+	lookup("")
+}
+
+func TestPrefixIndexMatchesScan(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "test_cache.db"))
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	tags := []PrefixTag{
+		{CIDR: "0.0.0.0/0", Cloud: "ANY"},
+		{CIDR: "3.0.0.0/8", Cloud: "AWS"},
+		{CIDR: "3.5.0.0/16", Cloud: "AWS", Service: "S3"},
+		{CIDR: "3.5.140.0/22", Cloud: "AWS", Service: "CLOUDFRONT"},
+		{CIDR: "34.64.0.0/10", Cloud: "GCP"},
+		{CIDR: "104.16.0.0/13", Cloud: "CLOUDFLARE"},
+		{CIDR: "104.16.5.7/13", Cloud: "UNMASKED"},
+		{CIDR: "2600:1f00::/24", Cloud: "AWS"},
+		{CIDR: "2600:1f14::/35", Cloud: "AWS", Service: "EC2"},
+	}
+	if err := c.UpsertPrefixes(tags); err != nil {
+		t.Fatalf("UpsertPrefixes() error = %v", err)
+	}
+
+	addrs := []string{
+		"3.1.1.1", "3.5.1.1", "3.5.141.9", "34.100.0.1", "104.17.0.1",
+		"8.8.8.8", "2600:1f14::1", "2600:1f00:ffff::1", "2a00::1", "::ffff:3.5.1.1",
+	}
+	var idx *prefixIndex
+	if err := c.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		idx, err = loadPrefixIndex(tx)
+		return err
+	}); err != nil {
+		t.Fatalf("loadPrefixIndex() error = %v", err)
+	}
+	for _, s := range addrs {
+		addr := netip.MustParseAddr(s)
+		wantTag, wantOK := scanLongestPrefix(t, c, addr)
+		gotTag, gotOK := idx.longestMatch(addr)
+		if gotOK != wantOK || gotTag != wantTag {
+			t.Errorf("%s: index = %+v, %v; scan = %+v, %v", s, gotTag, gotOK, wantTag, wantOK)
+		}
+	}
+}
+
+// BenchmarkLookupIPLargePrefixSet measures CIDR lookups against 50k cached prefixes.
+func BenchmarkLookupIPLargePrefixSet(b *testing.B) {
+	c, err := Open(filepath.Join(b.TempDir(), "bench_cache.db"))
+	if err != nil {
+		b.Fatalf("Failed to open cache: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	const prefixCount = 50000
+	tags := make([]PrefixTag, 0, prefixCount)
+	for i := range prefixCount {
+		tags = append(tags, PrefixTag{
+			CIDR:  fmt.Sprintf("10.%d.%d.0/24", i/256, i%256),
+			Cloud: "AWS",
+		})
+	}
+	if err := c.UpsertPrefixes(tags); err != nil {
+		b.Fatalf("Failed to upsert prefixes: %v", err)
+	}
+
+	addrs := []netip.Addr{
+		netip.MustParseAddr("10.0.0.1"),
+		netip.MustParseAddr("10.100.7.9"),
+		netip.MustParseAddr("10.195.79.200"),
+		netip.MustParseAddr("192.168.1.1"), // no match
+	}
+
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := c.LookupIP(addrs[i%len(addrs)]); err != nil {
+			b.Fatalf("LookupIP() error = %v", err)
+		}
+	}
+}
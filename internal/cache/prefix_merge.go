@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"net/netip"
+	"sort"
+)
+
+// prefixGroup identifies prefixes that share an annotation and may be merged.
+type prefixGroup struct {
+	Cloud   string
+	Service string
+	Region  string
+}
+
+// MergeAdjacentPrefixes aggregates prefixes with identical Cloud, Service, and Region into
+// the fewest covering CIDRs: prefixes contained in another are dropped, and sibling
+// pairs (e.g. 10.0.0.0/25 and 10.0.0.128/25) are repeatedly replaced by their parent.
+// Prefixes with different annotations are never merged. A merged tag keeps the
+// latest Fetched time of its members. Unparseable CIDRs are returned unchanged.
+func MergeAdjacentPrefixes(tags []PrefixTag) []PrefixTag {
+	groups := make(map[prefixGroup]map[netip.Prefix]int64)
+	var order []prefixGroup
+	var merged []PrefixTag
+
+	for _, tag := range tags {
+		prefix, err := netip.ParsePrefix(tag.CIDR)
+		if err != nil {
+			merged = append(merged, tag)
+			continue
+		}
+		key := prefixGroup{Cloud: tag.Cloud, Service: tag.Service, Region: tag.Region}
+		set, ok := groups[key]
+		if !ok {
+			set = make(map[netip.Prefix]int64)
+			groups[key] = set
+			order = append(order, key)
+		}
+		prefix = prefix.Masked()
+		set[prefix] = max(set[prefix], tag.Fetched)
+	}
+
+	for _, key := range order {
+		for _, prefix := range mergePrefixSet(groups[key]) {
+			merged = append(merged, PrefixTag{
+				CIDR:    prefix.String(),
+				Cloud:   key.Cloud,
+				Service: key.Service,
+				Region:  key.Region,
+				Fetched: groups[key][prefix],
+			})
+		}
+	}
+	return merged
+}
+
+// mergePrefixSet merges the prefixes of a single group in place and returns the
+// remaining prefixes sorted by address. The set maps each prefix to its Fetched time.
+func mergePrefixSet(set map[netip.Prefix]int64) []netip.Prefix {
+	// Drop prefixes already covered by a shorter prefix in the set
+	for prefix := range set {
+		for bits := prefix.Bits() - 1; bits >= 0; bits-- {
+			parent, _ := prefix.Addr().Prefix(bits)
+			if _, ok := set[parent]; ok {
+				delete(set, prefix)
+				break
+			}
+		}
+	}
+
+	// Merge sibling pairs from the longest prefixes up, so merged parents can
+	// themselves merge at the next length
+	maxBits := 0
+	for prefix := range set {
+		maxBits = max(maxBits, prefix.Bits())
+	}
+	for bits := maxBits; bits > 0; bits-- {
+		var candidates []netip.Prefix
+		for prefix := range set {
+			if prefix.Bits() == bits {
+				candidates = append(candidates, prefix)
+			}
+		}
+		for _, prefix := range candidates {
+			sibling := siblingPrefix(prefix)
+			fetched, ok := set[prefix]
+			siblingFetched, siblingOK := set[sibling]
+			if !ok || !siblingOK {
+				continue
+			}
+			delete(set, prefix)
+			delete(set, sibling)
+			parent, _ := prefix.Addr().Prefix(bits - 1)
+			set[parent] = max(fetched, siblingFetched)
+		}
+	}
+
+	result := make([]netip.Prefix, 0, len(set))
+	for prefix := range set {
+		result = append(result, prefix)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if c := result[i].Addr().Compare(result[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return result[i].Bits() < result[j].Bits()
+	})
+	return result
+}
+
+// siblingPrefix returns the other half of the parent of a masked prefix with
+// at least one bit, e.g. 10.0.1.0/24 for 10.0.0.0/24.
+func siblingPrefix(prefix netip.Prefix) netip.Prefix {
+	bits := prefix.Bits()
+	addr := prefix.Addr().AsSlice()
+	addr[(bits-1)/8] ^= 0x80 >> ((bits - 1) % 8)
+	sibling, _ := netip.AddrFromSlice(addr)
+	return netip.PrefixFrom(sibling, bits)
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeAdjacentPrefixes(t *testing.T) {
+	tags := []PrefixTag{
+		// Four adjacent /24s collapse into a /22
+		{CIDR: "10.0.0.0/24", Cloud: "AWS", Service: "EC2", Fetched: 1},
+		{CIDR: "10.0.1.0/24", Cloud: "AWS", Service: "EC2", Fetched: 3},
+		{CIDR: "10.0.2.0/24", Cloud: "AWS", Service: "EC2", Fetched: 2},
+		{CIDR: "10.0.3.0/24", Cloud: "AWS", Service: "EC2", Fetched: 1},
+		// Contained in the /22 above
+		{CIDR: "10.0.2.128/25", Cloud: "AWS", Service: "EC2", Fetched: 1},
+		// Adjacent to the /22 but a different service, so kept apart
+		{CIDR: "10.0.4.0/22", Cloud: "AWS", Service: "S3", Fetched: 1},
+		// Adjacent but not siblings (different parents), so kept apart
+		{CIDR: "10.1.1.0/24", Cloud: "GCP", Fetched: 1},
+		{CIDR: "10.1.2.0/24", Cloud: "GCP", Fetched: 1},
+		// IPv6 siblings merge too
+		{CIDR: "2600:1f00::/25", Cloud: "AWS", Fetched: 1},
+		{CIDR: "2600:1f80::/25", Cloud: "AWS", Fetched: 1},
+	}
+
+	got := MergeAdjacentPrefixes(tags)
+	want := []PrefixTag{
+		{CIDR: "10.0.0.0/22", Cloud: "AWS", Service: "EC2", Fetched: 3},
+		{CIDR: "10.0.4.0/22", Cloud: "AWS", Service: "S3", Fetched: 1},
+		{CIDR: "10.1.1.0/24", Cloud: "GCP", Fetched: 1},
+		{CIDR: "10.1.2.0/24", Cloud: "GCP", Fetched: 1},
+		{CIDR: "2600:1f00::/24", Cloud: "AWS", Fetched: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeAdjacentPrefixes() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestMergeAdjacentPrefixesKeepsInvalidCIDRs(t *testing.T) {
+	tags := []PrefixTag{{CIDR: "not-a-cidr", Cloud: "AWS"}}
+	if got := MergeAdjacentPrefixes(tags); !reflect.DeepEqual(got, tags) {
+		t.Errorf("MergeAdjacentPrefixes() = %+v, want %+v", got, tags)
+	}
+}
+
+func TestInsertPrefixesRemovesMergedMembers(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "test_cache.db"))
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if err := c.UpsertPrefixes([]PrefixTag{{CIDR: "10.0.0.0/24", Cloud: "AWS"}}); err != nil {
+		t.Fatalf("UpsertPrefixes() error = %v", err)
+	}
+	merged := MergeAdjacentPrefixes([]PrefixTag{
+		{CIDR: "10.0.0.0/24", Cloud: "AWS"},
+		{CIDR: "10.0.1.0/24", Cloud: "AWS"},
+	})
+	if err := c.insertPrefixes(merged, []string{"10.0.0.0/24", "10.0.1.0/24"}); err != nil {
+		t.Fatalf("insertPrefixes() error = %v", err)
+	}
+
+	prefixes, err := c.ListPrefixes()
+	if err != nil {
+		t.Fatalf("ListPrefixes() error = %v", err)
+	}
+	if !reflect.DeepEqual(prefixes, []string{"10.0.0.0/23"}) {
+		t.Errorf("ListPrefixes() = %v, want [10.0.0.0/23]", prefixes)
+	}
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// PruneResult counts the entries deleted by PruneStale.
+type PruneResult struct {
+	Prefixes int
+	IPs      int
+}
+
+// PruneStale deletes prefix tags fetched more than maxAge ago, and IP tags older
+// than the configured IPMaxAge (or maxAge when that is unset), so ranges a provider
+// has dropped stop labelling addresses. Entries without a Fetched time, such as
+// imported or legacy tags, have no known age and are kept. Unreadable records
+// are left for Check to report.
+func (c *Cache) PruneStale(maxAge time.Duration) (PruneResult, error) {
+	ipMaxAge := c.config.IPMaxAge
+	if ipMaxAge == 0 {
+		ipMaxAge = maxAge
+	}
+	now := time.Now()
+	prefixCutoff := now.Add(-maxAge).Unix()
+	ipCutoff := now.Add(-ipMaxAge).Unix()
+
+	var result PruneResult
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		result.Prefixes, err = pruneBucket(tx, bucketCIDRTags, func(v []byte) bool {
+			var tag PrefixTag
+			return json.Unmarshal(v, &tag) == nil && tag.Fetched != 0 && tag.Fetched < prefixCutoff
+		})
+		if err != nil {
+			return err
+		}
+		result.IPs, err = pruneBucket(tx, bucketIPTags, func(v []byte) bool {
+			var tag IPTag
+			return json.Unmarshal(v, &tag) == nil && tag.Fetched != 0 && tag.Fetched < ipCutoff
+		})
+		return err
+	})
+	if result.Prefixes > 0 {
+		c.invalidatePrefixIndex()
+	}
+	if err != nil {
+		return PruneResult{}, err
+	}
+
+	c.logger.Info("Pruned %d stale prefixes and %d stale IP tags", result.Prefixes, result.IPs)
+	return result, nil
+}
+
+// pruneBucket deletes the records in the named bucket for which stale returns
+// true and returns how many were deleted.
+func pruneBucket(tx *bbolt.Tx, name string, stale func(v []byte) bool) (int, error) {
+	bucket := tx.Bucket([]byte(name))
+	if bucket == nil {
+		return 0, nil
+	}
+
+	// Collect the keys first; deleting while iterating with ForEach is unsafe
+	var keys [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		if stale(v) {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, NewDatabaseError("scan_stale", name, err)
+	}
+
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return 0, NewDatabaseError("delete_stale", string(k), err)
+		}
+	}
+	return len(keys), nil
+}
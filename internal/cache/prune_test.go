@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPruneStale(t *testing.T) {
+	cfg := DefaultConfig().WithCachePath(filepath.Join(t.TempDir(), "test_cache.db")).WithLogging(false)
+	c, err := OpenWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	old := time.Now().Add(-48 * time.Hour).Unix()
+	if err := c.UpsertPrefixes([]PrefixTag{
+		{CIDR: "10.0.0.0/8", Cloud: "AWS", Fetched: old},
+		{CIDR: "10.1.0.0/16", Cloud: "GCP"}, // stamped now
+	}); err != nil {
+		t.Fatalf("UpsertPrefixes() error = %v", err)
+	}
+	if err := c.UpsertIP(IPTag{Addr: "192.0.2.1", Name: "OLD", Fetched: old}); err != nil {
+		t.Fatalf("UpsertIP() error = %v", err)
+	}
+	if err := c.UpsertIP(IPTag{Addr: "192.0.2.2", Name: "NEW"}); err != nil {
+		t.Fatalf("UpsertIP() error = %v", err)
+	}
+
+	// Imported tags without a fetch time have no known age and are kept
+	snapshot := `{"version": 1, "ips": [{"Addr": "192.0.2.3", "Name": "IMPORTED"}], "prefixes": [{"CIDR": "172.16.0.0/12", "Cloud": "AWS"}]}`
+	if err := c.Import(strings.NewReader(snapshot)); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	// Warm the prefix index so the prune must invalidate it
+	if got, _ := c.LookupIP(netip.MustParseAddr("10.2.0.1")); got != "AWS (10.0.0.0/8)" {
+		t.Fatalf("LookupIP() = %q before pruning", got)
+	}
+
+	result, err := c.PruneStale(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("PruneStale() error = %v", err)
+	}
+	if result != (PruneResult{Prefixes: 1, IPs: 1}) {
+		t.Errorf("PruneStale() = %+v, want one prefix and one IP", result)
+	}
+
+	prefixes, _ := c.ListPrefixes()
+	ips, _ := c.ListIPs()
+	if want := []string{"10.1.0.0/16", "172.16.0.0/12"}; !slices.Equal(prefixes, want) {
+		t.Errorf("prefixes after pruning = %v, want %v", prefixes, want)
+	}
+	if want := []string{"192.0.2.2", "192.0.2.3"}; !slices.Equal(ips, want) {
+		t.Errorf("IPs after pruning = %v, want %v", ips, want)
+	}
+	if got, _ := c.LookupIP(netip.MustParseAddr("10.2.0.1")); got != "" {
+		t.Errorf("LookupIP() = %q after its prefix was pruned, want no annotation", got)
+	}
+}
+
+func TestPruneStaleIPMaxAge(t *testing.T) {
+	cfg := DefaultConfig().
+		WithCachePath(filepath.Join(t.TempDir(), "test_cache.db")).
+		WithLogging(false).
+		WithIPMaxAge(time.Hour)
+	c, err := OpenWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	twoHoursAgo := time.Now().Add(-2 * time.Hour).Unix()
+	if err := c.UpsertPrefix(PrefixTag{CIDR: "10.0.0.0/8", Cloud: "AWS", Fetched: twoHoursAgo}); err != nil {
+		t.Fatalf("UpsertPrefix() error = %v", err)
+	}
+	if err := c.UpsertIP(IPTag{Addr: "192.0.2.1", Name: "WHOIS", Fetched: twoHoursAgo}); err != nil {
+		t.Fatalf("UpsertIP() error = %v", err)
+	}
+
+	result, err := c.PruneStale(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("PruneStale() error = %v", err)
+	}
+	if result != (PruneResult{Prefixes: 0, IPs: 1}) {
+		t.Errorf("PruneStale() = %+v, want only the IP tag past its own max age", result)
+	}
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// PTRResult represents the result of a reverse DNS lookup.
+type PTRResult struct {
+	IP       string
+	Hostname string
+	Error    error
+}
+
+// EnrichPTR resolves the reverse DNS hostname of each public IP and stores it on
+// the IP's tag, creating a tag when none exists. Private and unparseable addresses
+// are skipped. Each lookup is bounded by the configured PTRTimeout.
+func (c *Cache) EnrichPTR(ips []string) ([]*PTRResult, error) {
+	c.logger.Info("Resolving PTR records for %d IPs", len(ips))
+
+	results := make([]*PTRResult, 0, len(ips))
+	semaphore := make(chan struct{}, 5) // Limit concurrent lookups
+	resultsChan := make(chan *PTRResult, len(ips))
+	var wg sync.WaitGroup
+
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil || addr.IsPrivate() || !addr.IsGlobalUnicast() {
+			continue
+		}
+
+		wg.Add(1)
+		go func(addr netip.Addr) {
+			defer wg.Done()
+			semaphore <- struct{}{}        // Acquire semaphore
+			defer func() { <-semaphore }() // Release semaphore
+
+			resultsChan <- c.resolvePTR(addr)
+		}(addr)
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultsChan)
+	}()
+
+	var failed int
+	for result := range resultsChan {
+		results = append(results, result)
+		if result.Error != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		c.logger.Warn("%d of %d PTR lookups failed", failed, len(results))
+	}
+	return results, nil
+}
+
+// resolvePTR looks up and stores the hostname for a single address.
+func (c *Cache) resolvePTR(addr netip.Addr) *PTRResult {
+	result := &PTRResult{IP: addr.String()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.PTRTimeout)
+	defer cancel()
+	names, err := c.resolver.LookupAddr(ctx, result.IP)
+	if err != nil {
+		result.Error = fmt.Errorf("PTR lookup failed for %s: %w", result.IP, err)
+		return result
+	}
+	if len(names) == 0 {
+		return result
+	}
+
+	result.Hostname = strings.TrimSuffix(names[0], ".")
+	if err := c.storePTR(result.IP, result.Hostname); err != nil {
+		c.logger.Error("Failed to store PTR record for %s: %v", result.IP, err)
+	}
+	return result
+}
+
+// storePTR sets the PTR hostname on the IP's tag, keeping its other fields.
+func (c *Cache) storePTR(ip, hostname string) error {
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketIPTags))
+		if bucket == nil {
+			return NewDatabaseError("get_bucket", bucketIPTags, nil)
+		}
+		tag := IPTag{Addr: ip}
+		if v := bucket.Get([]byte(ip)); v != nil {
+			if err := json.Unmarshal(v, &tag); err != nil {
+				return NewInvalidDataError("unmarshal", ip, "failed to unmarshal IP tag", err)
+			}
+		}
+		tag.PTR = hostname
+		tag.Fetched = time.Now().Unix()
+		return putJSON(tx, bucketIPTags, ip, tag)
+	})
+}
+
+// LookupPTRs returns the cached PTR hostname of each address that has one.
+func (c *Cache) LookupPTRs(addrs []netip.Addr) (map[netip.Addr]string, error) {
+	hostnames := make(map[netip.Addr]string)
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketIPTags))
+		if bucket == nil {
+			return NewDatabaseError("get_bucket", bucketIPTags, nil)
+		}
+		for _, addr := range addrs {
+			var tag IPTag
+			if v := bucket.Get([]byte(addr.String())); v != nil && json.Unmarshal(v, &tag) == nil && tag.PTR != "" {
+				hostnames[addr] = tag.PTR
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup PTR records: %w", err)
+	}
+	return hostnames, nil
+}
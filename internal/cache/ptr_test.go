@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers PTR lookups from a map and records the addresses asked for.
+type fakeResolver struct {
+	mu    sync.Mutex
+	names map[string]string
+	asked []string
+}
+
+func (r *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.asked = append(r.asked, addr)
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, errors.New("lookup without a deadline")
+	}
+	if name, ok := r.names[addr]; ok {
+		return []string{name}, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func TestEnrichPTR(t *testing.T) {
+	c := openExportTestCache(t)
+	resolver := &fakeResolver{names: map[string]string{
+		"8.8.8.8": "dns.google.",
+		"1.1.1.1": "one.one.one.one.",
+	}}
+	c.resolver = resolver
+	c.config.PTRTimeout = time.Second
+
+	if err := c.UpsertIP(IPTag{Addr: "1.1.1.1", Name: "CLOUDFLARE", ASN: "AS13335"}); err != nil {
+		t.Fatalf("UpsertIP() error = %v", err)
+	}
+
+	results, err := c.EnrichPTR([]string{"8.8.8.8", "1.1.1.1", "9.9.9.9", "10.0.0.1", "127.0.0.1", "not-an-ip"})
+	if err != nil {
+		t.Fatalf("EnrichPTR() error = %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("EnrichPTR() returned %d results, want 3", len(results))
+	}
+	sort.Strings(resolver.asked)
+	if want := []string{"1.1.1.1", "8.8.8.8", "9.9.9.9"}; !slices.Equal(resolver.asked, want) {
+		t.Errorf("resolver asked for %v, want %v", resolver.asked, want)
+	}
+
+	addrs := []netip.Addr{
+		netip.MustParseAddr("8.8.8.8"),
+		netip.MustParseAddr("1.1.1.1"),
+		netip.MustParseAddr("9.9.9.9"),
+	}
+	hostnames, err := c.LookupPTRs(addrs)
+	if err != nil {
+		t.Fatalf("LookupPTRs() error = %v", err)
+	}
+	if len(hostnames) != 2 || hostnames[addrs[0]] != "dns.google" || hostnames[addrs[1]] != "one.one.one.one" {
+		t.Errorf("LookupPTRs() = %v, want dns.google and one.one.one.one", hostnames)
+	}
+
+	// The existing whois details survive, and a PTR-only tag is not an annotation
+	info, err := c.GetWhoisInfo("1.1.1.1")
+	if err != nil || info.ASN != "AS13335" {
+		t.Errorf("GetWhoisInfo() = %+v, %v, want ASN AS13335", info, err)
+	}
+	if got, _ := c.LookupIP(addrs[0]); got != "" {
+		t.Errorf("LookupIP(8.8.8.8) = %q, want no annotation", got)
+	}
+
+	// A later whois upsert keeps the hostname
+	if err := c.UpsertIP(IPTag{Addr: "8.8.8.8", Name: "GOOGLE"}); err != nil {
+		t.Fatalf("UpsertIP() error = %v", err)
+	}
+	if hostnames, _ := c.LookupPTRs(addrs[:1]); hostnames[addrs[0]] != "dns.google" {
+		t.Errorf("LookupPTRs() after UpsertIP = %v, want dns.google", hostnames)
+	}
+}
@@ -14,19 +14,35 @@ type ENITagProvider interface {
 	GetENITag(ctx context.Context, eniID string) (aws.ENITag, error)
 }
 
+// RefreshStats counts the outcome of refreshing ENIs.
+type RefreshStats struct {
+	Updated int // ENIs whose tags were written to the cache
+	Removed int // ENIs deleted from the cache because they no longer exist
+	Skipped int // ENIs the provider returned no tag for
+	Failed  int // ENIs whose lookup or write failed
+}
+
 // RefreshENIs fetches tags for a list of ENIs from a provider and updates the cache.
-func (c *Cache) RefreshENIs(ctx context.Context, eniProvider ENITagProvider, enis []string) error {
+// Per-ENI failures are logged and counted in the returned stats rather than
+// aborting the refresh.
+func (c *Cache) RefreshENIs(ctx context.Context, eniProvider ENITagProvider, enis []string) (RefreshStats, error) {
+	var stats RefreshStats
 	for i, eni := range enis {
 		log.Printf("Refreshing ENI %d/%d: %s", i+1, len(enis), eni)
 		awsTag, err := eniProvider.GetENITag(ctx, eni)
 		if err != nil {
-			c.handleENIError(eni, err)
+			if c.handleENIError(eni, err) {
+				stats.Removed++
+			} else {
+				stats.Failed++
+			}
 			continue
 		}
 
 		// Skip if the ENI tag is empty (ENI not found)
 		if awsTag.ENI == "" {
 			log.Printf("ENI %s not found, skipping", eni)
+			stats.Skipped++
 			continue
 		}
 
@@ -41,37 +57,42 @@ func (c *Cache) RefreshENIs(ctx context.Context, eniProvider ENITagProvider, eni
 
 		if err := c.UpsertEni(cacheTag); err != nil {
 			log.Printf("Warning: failed to upsert ENI %s: %v", eni, err)
+			stats.Failed++
 			continue
 		}
 		log.Printf("Tagged ENI %s: %s", eni, cacheTag.Label)
+		stats.Updated++
 	}
-	return nil
+	return stats, nil
 }
 
-// handleENIError handles errors that occur when fetching ENI tags.
-func (c *Cache) handleENIError(eni string, err error) {
+// handleENIError handles errors that occur when fetching ENI tags. It reports
+// whether the ENI no longer exists and was removed from the cache.
+func (c *Cache) handleENIError(eni string, err error) bool {
 	// Check if the ENI no longer exists
-	if aws.IsENINotFoundError(err) {
-		log.Printf("ENI %s no longer exists, removing from cache", eni)
-		if deleteErr := c.DeleteENI(eni); deleteErr != nil {
-			log.Printf("Warning: failed to remove ENI %s from cache: %v", eni, deleteErr)
-		} else {
-			log.Printf("Removed ENI %s from cache", eni)
-		}
-	} else {
+	if !aws.IsENINotFoundError(err) {
 		log.Printf("Warning: failed to tag ENI %s: %v", eni, err)
+		return false
+	}
+
+	log.Printf("ENI %s no longer exists, removing from cache", eni)
+	if deleteErr := c.DeleteENI(eni); deleteErr != nil {
+		log.Printf("Warning: failed to remove ENI %s from cache: %v", eni, deleteErr)
+		return false
 	}
+	log.Printf("Removed ENI %s from cache", eni)
+	return true
 }
 
 // RefreshAllENIs fetches tags for all ENIs currently in the cache.
-func (c *Cache) RefreshAllENIs(ctx context.Context, eniProvider ENITagProvider) error {
+func (c *Cache) RefreshAllENIs(ctx context.Context, eniProvider ENITagProvider) (RefreshStats, error) {
 	enis, err := c.ListENIs()
 	if err != nil {
-		return fmt.Errorf("failed to list ENIs in cache: %w", err)
+		return RefreshStats{}, fmt.Errorf("failed to list ENIs in cache: %w", err)
 	}
 	if len(enis) == 0 {
 		log.Println("No ENIs found in cache to refresh.")
-		return nil
+		return RefreshStats{}, nil
 	}
 	return c.RefreshENIs(ctx, eniProvider, enis)
 }
@@ -12,9 +12,13 @@ import (
 type mockENITagProvider struct {
 	tags map[string]aws.ENITag
 	err  error
+	errs map[string]error // per-ENI errors, checked before err
 }
 
 func (m *mockENITagProvider) GetENITag(ctx context.Context, eniID string) (aws.ENITag, error) {
+	if err, exists := m.errs[eniID]; exists {
+		return aws.ENITag{}, err
+	}
 	if m.err != nil {
 		return aws.ENITag{}, m.err
 	}
@@ -57,7 +61,7 @@ func TestRefreshENIs(t *testing.T) {
 
 	// Test refreshing ENIs
 	enis := []string{"eni-123", "eni-456"}
-	err = cache.RefreshENIs(context.Background(), mockProvider, enis)
+	_, err = cache.RefreshENIs(context.Background(), mockProvider, enis)
 	if err != nil {
 		t.Fatalf("Failed to refresh ENIs: %v", err)
 	}
@@ -112,7 +116,7 @@ func TestRefreshENIsWithError(t *testing.T) {
 
 	// Test refreshing ENIs with error
 	enis := []string{"eni-123"}
-	err = cache.RefreshENIs(context.Background(), mockProvider, enis)
+	_, err = cache.RefreshENIs(context.Background(), mockProvider, enis)
 	if err != nil {
 		t.Fatalf("RefreshENIs should not return error when provider fails: %v", err)
 	}
@@ -152,7 +156,7 @@ func TestRefreshENIsWithPartialError(t *testing.T) {
 
 	// Test refreshing ENIs where one succeeds and one fails
 	enis := []string{"eni-123", "eni-456"} // eni-456 not in mock
-	err = cache.RefreshENIs(context.Background(), mockProvider, enis)
+	_, err = cache.RefreshENIs(context.Background(), mockProvider, enis)
 	if err != nil {
 		t.Fatalf("RefreshENIs should not return error for partial failure: %v", err)
 	}
@@ -210,7 +214,7 @@ func TestRefreshAllENIs(t *testing.T) {
 	}
 
 	// Test refreshing all ENIs
-	err = cache.RefreshAllENIs(context.Background(), mockProvider)
+	_, err = cache.RefreshAllENIs(context.Background(), mockProvider)
 	if err != nil {
 		t.Fatalf("Failed to refresh all ENIs: %v", err)
 	}
@@ -247,7 +251,7 @@ func TestRefreshAllENIsEmpty(t *testing.T) {
 	}
 
 	// Test refreshing all ENIs when cache is empty
-	err = cache.RefreshAllENIs(context.Background(), mockProvider)
+	_, err = cache.RefreshAllENIs(context.Background(), mockProvider)
 	if err != nil {
 		t.Fatalf("Failed to refresh all ENIs when empty: %v", err)
 	}
@@ -292,7 +296,7 @@ func TestRefreshENIsWithENINotFound(t *testing.T) {
 
 	// Test refreshing ENIs where one no longer exists
 	enis := []string{"eni-nonexistent"}
-	err = cache.RefreshENIs(context.Background(), mockProvider, enis)
+	_, err = cache.RefreshENIs(context.Background(), mockProvider, enis)
 	if err != nil {
 		t.Fatalf("RefreshENIs should not return error for ENI not found: %v", err)
 	}
@@ -306,3 +310,35 @@ func TestRefreshENIsWithENINotFound(t *testing.T) {
 		t.Error("Expected ENI to be removed from cache due to not found error")
 	}
 }
+
+func TestRefreshENIsStats(t *testing.T) {
+	cache, err := Open(t.TempDir() + "/test_cache.db")
+	if err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	defer func() { _ = cache.Close() }()
+	if err := cache.UpsertEni(ENITag{ENI: "eni-gone", Label: "old-label"}); err != nil {
+		t.Fatalf("Failed to add existing ENI: %v", err)
+	}
+
+	mockProvider := &mockENITagProvider{
+		tags: map[string]aws.ENITag{
+			"eni-123": {ENI: "eni-123", Label: "api"},
+			"eni-456": {ENI: "eni-456", Label: "worker"},
+		},
+		errs: map[string]error{
+			"eni-gone":   fmt.Errorf("api error InvalidNetworkInterfaceID.NotFound: The networkInterface ID 'eni-gone' does not exist"),
+			"eni-denied": fmt.Errorf("api error UnauthorizedOperation"),
+		},
+	}
+
+	enis := []string{"eni-123", "eni-gone", "eni-456", "eni-denied", "eni-unknown"}
+	stats, err := cache.RefreshENIs(context.Background(), mockProvider, enis)
+	if err != nil {
+		t.Fatalf("RefreshENIs() error = %v", err)
+	}
+	want := RefreshStats{Updated: 2, Removed: 1, Skipped: 1, Failed: 1}
+	if stats != want {
+		t.Errorf("RefreshENIs() stats = %+v, want %+v", stats, want)
+	}
+}
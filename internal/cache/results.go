@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"fli/internal/runner"
+)
+
+// cachedResult is a query result as stored in the results bucket.
+type cachedResult struct {
+	Stored int64 // Unix time the result was stored
+	Result runner.QueryResult
+}
+
+// ResultKey identifies the result of a query over a set of log groups and a
+// time window. target names the AWS account and region queried, such as a
+// profile and region, since the same log group name can exist in several. start
+// and end describe the window as the caller chooses, e.g. RFC 3339 times, or
+// "now-1h" and "now" for a window relative to the run. The order of the log
+// groups does not matter.
+func ResultKey(target string, logGroups []string, query, start, end string) string {
+	groups := slices.Clone(logGroups)
+	slices.Sort(groups)
+	sum := sha256.Sum256([]byte(target + "\n\n" + strings.Join(groups, "\n") + "\n\n" + query + "\n\n" + start + "\n" + end))
+	return hex.EncodeToString(sum[:])
+}
+
+// QueryResult returns the result stored under key, with the time it was stored,
+// if it is no older than maxAge. It returns false when there is no such result.
+func (c *Cache) QueryResult(key string, maxAge time.Duration) (runner.QueryResult, time.Time, bool, error) {
+	var cached cachedResult
+	var found bool
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucketResults))
+		if b == nil {
+			return fmt.Errorf("query result bucket missing")
+		}
+		v := b.Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		if err := json.Unmarshal(v, &cached); err != nil {
+			return fmt.Errorf("invalid query result: %w", err)
+		}
+		found = true
+		return nil
+	})
+	if err != nil {
+		return runner.QueryResult{}, time.Time{}, false, fmt.Errorf("failed to read query result: %w", err)
+	}
+
+	stored := time.Unix(cached.Stored, 0)
+	if !found || time.Since(stored) > maxAge {
+		return runner.QueryResult{}, time.Time{}, false, nil
+	}
+	return cached.Result, stored, true, nil
+}
+
+// SetQueryResult stores result under key. Results older than maxAge are removed
+// at the same time, so the bucket only holds results that can still be used.
+func (c *Cache) SetQueryResult(key string, result runner.QueryResult, maxAge time.Duration) error {
+	now := time.Now()
+	data, err := json.Marshal(cachedResult{Stored: now.Unix(), Result: result})
+	if err != nil {
+		return fmt.Errorf("failed to encode query result: %w", err)
+	}
+
+	err = c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucketResults))
+		if b == nil {
+			return fmt.Errorf("query result bucket missing")
+		}
+
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var cached cachedResult
+			if json.Unmarshal(v, &cached) != nil || now.Sub(time.Unix(cached.Stored, 0)) > maxAge {
+				expired = append(expired, slices.Clone(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return b.Put([]byte(key), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store query result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"fli/internal/runner"
+)
+
+func TestQueryResult(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer c.Close()
+
+	key := ResultKey("prod\nus-east-1", []string{"/aws/vpc/prod"}, "stats count(*)", "now-1h0m0s", "now")
+	if _, _, found, err := c.QueryResult(key, time.Minute); err != nil || found {
+		t.Fatalf("QueryResult() before any run = %v, %v, want not found", found, err)
+	}
+
+	result := runner.QueryResult{
+		Results:    [][]runner.Field{{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "flows", Value: "42"}}},
+		Statistics: runner.QueryStatistics{BytesScanned: 1024, RecordsMatched: 42, StartTime: 1714568400000, EndTime: 1714572000000},
+	}
+	if err := c.SetQueryResult(key, result, time.Minute); err != nil {
+		t.Fatalf("SetQueryResult() error = %v", err)
+	}
+	got, stored, found, err := c.QueryResult(key, time.Minute)
+	if err != nil || !found || !reflect.DeepEqual(got, result) {
+		t.Errorf("QueryResult() = %+v, %v, %v, want %+v", got, found, err, result)
+	}
+	if time.Since(stored) > time.Minute {
+		t.Errorf("QueryResult() stored at %v, want about now", stored)
+	}
+
+	// A result older than the max age is not used, and is removed by the next store
+	if _, _, found, _ := c.QueryResult(key, -time.Second); found {
+		t.Error("QueryResult() with an expired result: found = true, want false")
+	}
+	other := ResultKey("prod\nus-east-1", []string{"/aws/vpc/prod"}, "stats count(*)", "2026-03-01T10:00:00Z", "2026-03-01T11:00:00Z")
+	if err := c.SetQueryResult(other, result, -time.Second); err != nil {
+		t.Fatalf("SetQueryResult() error = %v", err)
+	}
+	if _, _, found, _ := c.QueryResult(key, time.Hour); found {
+		t.Error("QueryResult() after an expired result was pruned: found = true, want false")
+	}
+
+	if reordered := ResultKey("prod\nus-east-1", []string{"/b", "/a"}, "q", "s", "e"); reordered != ResultKey("prod\nus-east-1", []string{"/a", "/b"}, "q", "s", "e") {
+		t.Error("ResultKey() depends on the order of the log groups")
+	}
+	if ResultKey("prod\nus-east-1", []string{"/a"}, "q", "s", "e") == ResultKey("prod\nus-east-1", []string{"/a"}, "q", "s", "e2") {
+		t.Error("ResultKey() is the same for different windows")
+	}
+	if ResultKey("prod\nus-east-1", []string{"/a"}, "q", "s", "e") == ResultKey("prod\neu-west-1", []string{"/a"}, "q", "s", "e") {
+		t.Error("ResultKey() is the same for different regions")
+	}
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/netip"
@@ -14,6 +15,10 @@ import (
 	"go.etcd.io/bbolt"
 )
 
+// ErrWhoisNoMatch is returned by a WhoisClient when the registry has no record for
+// the address. It is a permanent failure and is never retried.
+var ErrWhoisNoMatch = errors.New("no whois match")
+
 // whoisProvider represents a known provider in whois data.
 type whoisProvider struct {
 	searchTerms []string
@@ -98,15 +103,30 @@ func extractOrganization(line string) string {
 	return words[0]
 }
 
+// EnrichStats counts the outcome of whois enrichment.
+type EnrichStats struct {
+	Enriched int // IPs whose whois details were written to the cache
+	Skipped  int // private IPs and IPs that already have an annotation
+	Failed   int // IPs whose lookup or write failed
+}
+
 // EnrichIPs performs whois enrichment for public IPs in the cache.
-func (c *Cache) EnrichIPs() error {
+func (c *Cache) EnrichIPs() (EnrichStats, error) {
 	ips, err := c.ListIPs()
 	if err != nil {
-		return fmt.Errorf("failed to list IPs: %w", err)
+		return EnrichStats{}, fmt.Errorf("failed to list IPs: %w", err)
 	}
+	return c.EnrichAddrs(ips), nil
+}
+
+// EnrichAddrs performs whois enrichment for the given IPs, whether or not they
+// are cached yet. Private IPs and IPs that already have an annotation are skipped.
+func (c *Cache) EnrichAddrs(ips []string) EnrichStats {
+	var stats EnrichStats
 	for i, ip := range ips {
 		addr, err := netip.ParseAddr(ip)
 		if err != nil || addr.IsPrivate() {
+			stats.Skipped++
 			continue
 		}
 
@@ -115,24 +135,33 @@ func (c *Cache) EnrichIPs() error {
 		annotation, err := c.LookupIP(addr)
 		if err != nil {
 			log.Printf("Warning: failed to lookup IP %s: %v", ip, err)
+			stats.Failed++
+			continue
+		}
+		if annotation != "" {
+			stats.Skipped++
 			continue
 		}
 
-		if annotation == "" {
-			// No existing annotation, let's try to enrich it.
-			log.Printf("Enriching public IP %s (%d/%d)...", ip, i+1, len(ips))
-			whoisInfo, err := whois.Whois(ip)
-			if err == nil {
-				label := extractWhoisSummary(whoisInfo)
-				if err := c.UpsertIP(IPTag{Addr: ip, Name: label}); err != nil {
-					log.Printf("Warning: failed to upsert IP %s: %v", ip, err)
-				}
-			} else {
-				log.Printf("Warning: whois lookup failed for %s: %v", ip, err)
-			}
+		// No existing annotation, let's try to enrich it.
+		log.Printf("Enriching public IP %s (%d/%d)...", ip, i+1, len(ips))
+		whoisInfo, err := c.lookupWhoisWithRetry(ip)
+		if err != nil {
+			log.Printf("Warning: whois lookup failed for %s: %v", ip, err)
+			stats.Failed++
+			continue
 		}
+		label := extractWhoisSummary(whoisInfo)
+		details := c.parseWhoisData(ip, whoisInfo)
+		tag := IPTag{Addr: ip, Name: label, ASN: details.ASN, Org: details.Org, Country: details.Country}
+		if err := c.UpsertIP(tag); err != nil {
+			log.Printf("Warning: failed to upsert IP %s: %v", ip, err)
+			stats.Failed++
+			continue
+		}
+		stats.Enriched++
 	}
-	return nil
+	return stats
 }
 
 // WhoisResult represents the result of a whois lookup.
@@ -156,7 +185,7 @@ func (c *Cache) EnrichIP(ip string) (*WhoisResult, error) {
 	start := time.Now()
 
 	// Perform whois lookup
-	whoisData, err := c.whoisClient.Lookup(ip)
+	whoisData, err := c.lookupWhoisWithRetry(ip)
 	if err != nil {
 		return nil, NewWhoisError(ip, err)
 	}
@@ -177,6 +206,33 @@ func (c *Cache) EnrichIP(ip string) (*WhoisResult, error) {
 	return result, nil
 }
 
+// lookupWhoisWithRetry performs a whois lookup, retrying transient failures with
+// exponential backoff. Permanent failures such as "no match" are returned immediately.
+func (c *Cache) lookupWhoisWithRetry(ip string) (string, error) {
+	delay := c.config.WhoisRetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		whoisData, err := c.whoisClient.Lookup(ip)
+		if err == nil {
+			return whoisData, nil
+		}
+		if isPermanentWhoisError(err) || attempt >= c.config.WhoisMaxRetries {
+			return "", err
+		}
+
+		c.logger.Debug("Whois lookup for %s failed (attempt %d/%d), retrying in %v: %v",
+			ip, attempt+1, c.config.WhoisMaxRetries+1, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isPermanentWhoisError reports whether a whois failure will not go away on retry.
+func isPermanentWhoisError(err error) bool {
+	return errors.Is(err, ErrWhoisNoMatch) ||
+		errors.Is(err, whois.ErrDomainEmpty) ||
+		errors.Is(err, whois.ErrWhoisServerNotFound)
+}
+
 // EnrichIPsBatch performs whois lookups for multiple IP addresses.
 func (c *Cache) EnrichIPsBatch(ips []string) ([]*WhoisResult, error) {
 	if !c.config.EnableWhoisEnrichment {
@@ -334,8 +390,11 @@ func (c *Cache) storeWhoisResult(result *WhoisResult) error {
 
 	// Create IP tag with whois data
 	ipTag := IPTag{
-		Addr: result.IP,
-		Name: label,
+		Addr:    result.IP,
+		Name:    label,
+		ASN:     result.ASN,
+		Org:     result.Org,
+		Country: result.Country,
 	}
 
 	return c.UpsertIP(ipTag)
@@ -343,6 +402,7 @@ func (c *Cache) storeWhoisResult(result *WhoisResult) error {
 
 // GetWhoisInfo retrieves stored whois information for an IP.
 func (c *Cache) GetWhoisInfo(ip string) (*WhoisResult, error) {
+	var ipTag IPTag
 	err := c.db.View(func(tx *bbolt.Tx) error {
 		bucket := tx.Bucket([]byte(bucketIPTags))
 		if bucket == nil {
@@ -354,7 +414,6 @@ func (c *Cache) GetWhoisInfo(ip string) (*WhoisResult, error) {
 			return NewNotFoundError("get_whois_info", ip)
 		}
 
-		var ipTag IPTag
 		if err := json.Unmarshal(data, &ipTag); err != nil {
 			return NewInvalidDataError("unmarshal_whois", ip, "failed to unmarshal IP tag", err)
 		}
@@ -366,6 +425,9 @@ func (c *Cache) GetWhoisInfo(ip string) (*WhoisResult, error) {
 	}
 
 	return &WhoisResult{
-		IP: ip,
+		IP:      ip,
+		ASN:     ipTag.ASN,
+		Org:     ipTag.Org,
+		Country: ipTag.Country,
 	}, nil
 }
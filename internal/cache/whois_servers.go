@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// rirWhoisServers maps each regional internet registry to its whois server. The
+// values of Config.WhoisServers may name a registry instead of a server.
+var rirWhoisServers = map[string]string{
+	"afrinic": "whois.afrinic.net",
+	"apnic":   "whois.apnic.net",
+	"arin":    "whois.arin.net",
+	"lacnic":  "whois.lacnic.net",
+	"ripe":    "whois.ripe.net",
+}
+
+// whoisServer routes lookups for the addresses of a prefix to a whois server.
+type whoisServer struct {
+	prefix netip.Prefix
+	server string
+}
+
+// parseWhoisServers converts a CIDR to server mapping into routes ordered from the
+// most to the least specific prefix, resolving registry names to their servers.
+func parseWhoisServers(servers map[string]string) ([]whoisServer, error) {
+	routes := make([]whoisServer, 0, len(servers))
+	for cidr, server := range servers {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid whois server range %q: %w", cidr, err)
+		}
+		server = strings.TrimSpace(server)
+		if rir, ok := rirWhoisServers[strings.ToLower(server)]; ok {
+			server = rir
+		}
+		if server == "" {
+			return nil, fmt.Errorf("invalid whois server range %q: no server", cidr)
+		}
+		routes = append(routes, whoisServer{prefix: prefix.Masked(), server: server})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].prefix.Bits() != routes[j].prefix.Bits() {
+			return routes[i].prefix.Bits() > routes[j].prefix.Bits()
+		}
+		return routes[i].prefix.String() < routes[j].prefix.String()
+	})
+	return routes, nil
+}
+
+// whoisServerFor returns the server of the most specific route containing ip, or
+// "" to let the whois library pick the server.
+func whoisServerFor(routes []whoisServer, ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	for _, route := range routes {
+		if route.prefix.Contains(addr) {
+			return route.server
+		}
+	}
+	return ""
+}
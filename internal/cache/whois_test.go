@@ -1,7 +1,12 @@
 package cache
 
 import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestExtractWhoisSummary(t *testing.T) {
@@ -115,7 +120,231 @@ country:      US`,
 	}
 }
 
+// flakyWhoisClient fails the first failures lookups with err, then succeeds.
+type flakyWhoisClient struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyWhoisClient) Lookup(_ string) (string, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return "", f.err
+	}
+	return "OrgName: Cloudflare, Inc.\nCountry: US\n", nil
+}
+
+func openWhoisTestCache(t *testing.T, client WhoisClient, maxRetries int) *Cache {
+	t.Helper()
+	cfg := DefaultConfig().
+		WithCachePath(filepath.Join(t.TempDir(), "cache.db")).
+		WithWhoisRetries(maxRetries, time.Millisecond)
+	c, err := OpenWithDependencies(cfg, NewDefaultHTTPClient(time.Second), client, NewDefaultLogger(false), NewDefaultFileSystem())
+	if err != nil {
+		t.Fatalf("OpenWithDependencies() error = %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestEnrichIPRetriesTransientFailures(t *testing.T) {
+	client := &flakyWhoisClient{failures: 1, err: errors.New("connection reset by peer")}
+	c := openWhoisTestCache(t, client, 2)
+
+	result, err := c.EnrichIP("1.1.1.1")
+	if err != nil {
+		t.Fatalf("EnrichIP() error = %v", err)
+	}
+	if client.calls != 2 {
+		t.Errorf("expected 2 lookups (1 failure + 1 retry), got %d", client.calls)
+	}
+	if result.Country != "US" {
+		t.Errorf("expected the successful lookup to be parsed, got %+v", result)
+	}
+}
+
+func TestEnrichIPGivesUpAfterMaxRetries(t *testing.T) {
+	client := &flakyWhoisClient{failures: 10, err: errors.New("i/o timeout")}
+	c := openWhoisTestCache(t, client, 2)
+
+	if _, err := c.EnrichIP("1.1.1.1"); err == nil {
+		t.Fatal("expected an error after exhausting retries")
+	}
+	if client.calls != 3 {
+		t.Errorf("expected 3 lookups (1 attempt + 2 retries), got %d", client.calls)
+	}
+}
+
+func TestEnrichIPDoesNotRetryNoMatch(t *testing.T) {
+	client := &flakyWhoisClient{failures: 10, err: fmt.Errorf("192.0.2.1: %w", ErrWhoisNoMatch)}
+	c := openWhoisTestCache(t, client, 2)
+
+	_, err := c.EnrichIP("192.0.2.1")
+	if !errors.Is(err, ErrWhoisNoMatch) {
+		t.Fatalf("expected ErrWhoisNoMatch, got %v", err)
+	}
+	if client.calls != 1 {
+		t.Errorf("expected a single lookup for a permanent failure, got %d", client.calls)
+	}
+}
+
 // Note: Tests for EnrichIPs are not included here because they would require
 // real whois lookups which can hang or take a very long time. In a real
 // testing environment, you would mock the whois.Whois function or use
 // integration tests with a controlled whois server.
+
+func TestWhoisResultRoundTrip(t *testing.T) {
+	c := openWhoisTestCache(t, &flakyWhoisClient{}, 0)
+
+	result := c.parseWhoisData("1.1.1.1", "origin: AS13335\norg: CLOUDFLARENET\ncountry: AU\n")
+	if err := c.storeWhoisResult(result); err != nil {
+		t.Fatalf("storeWhoisResult() error = %v", err)
+	}
+
+	got, err := c.GetWhoisInfo("1.1.1.1")
+	if err != nil {
+		t.Fatalf("GetWhoisInfo() error = %v", err)
+	}
+	want := &WhoisResult{IP: "1.1.1.1", ASN: "AS13335", Org: "CLOUDFLARENET", Country: "AU"}
+	if *got != *want {
+		t.Errorf("GetWhoisInfo() = %+v, want %+v", got, want)
+	}
+
+	// The short label is still used for display
+	annotation, err := c.LookupIP(netip.MustParseAddr("1.1.1.1"))
+	if err != nil {
+		t.Fatalf("LookupIP() error = %v", err)
+	}
+	if annotation != "CLOUDFLARE" {
+		t.Errorf("LookupIP() = %q, want the short label CLOUDFLARE", annotation)
+	}
+}
+
+func TestGetWhoisInfoForManualTag(t *testing.T) {
+	c := openWhoisTestCache(t, &flakyWhoisClient{}, 0)
+
+	if err := c.UpsertIP(IPTag{Addr: "203.0.113.10", Name: "partner-api"}); err != nil {
+		t.Fatalf("UpsertIP() error = %v", err)
+	}
+	got, err := c.GetWhoisInfo("203.0.113.10")
+	if err != nil {
+		t.Fatalf("GetWhoisInfo() error = %v", err)
+	}
+	if got.ASN != "" || got.Org != "" || got.Country != "" {
+		t.Errorf("GetWhoisInfo() = %+v, want no whois details for a manual tag", got)
+	}
+
+	if _, err := c.GetWhoisInfo("198.51.100.1"); err == nil {
+		t.Error("expected an error for an IP that is not cached")
+	}
+}
+
+// mapWhoisClient answers lookups from a map; unknown IPs have no whois record.
+type mapWhoisClient map[string]string
+
+func (m mapWhoisClient) Lookup(ip string) (string, error) {
+	if data, ok := m[ip]; ok {
+		return data, nil
+	}
+	return "", ErrWhoisNoMatch
+}
+
+func TestEnrichIPsStats(t *testing.T) {
+	client := mapWhoisClient{"8.8.8.8": "origin: AS15169\norg: GOOGLE\ncountry: US\n"}
+	c := openWhoisTestCache(t, client, 0)
+
+	for _, tag := range []IPTag{
+		{Addr: "10.0.0.1"},                    // private
+		{Addr: "1.1.1.1", Name: "CLOUDFLARE"}, // already annotated
+		{Addr: "13.32.1.1"},                   // covered by a cloud prefix
+		{Addr: "8.8.8.8"},
+		{Addr: "9.9.9.9"}, // no whois record
+	} {
+		if err := c.UpsertIP(tag); err != nil {
+			t.Fatalf("UpsertIP(%s) error = %v", tag.Addr, err)
+		}
+	}
+	if err := c.UpsertPrefix(PrefixTag{CIDR: "13.32.0.0/15", Cloud: "AWS"}); err != nil {
+		t.Fatalf("UpsertPrefix() error = %v", err)
+	}
+
+	stats, err := c.EnrichIPs()
+	if err != nil {
+		t.Fatalf("EnrichIPs() error = %v", err)
+	}
+	want := EnrichStats{Enriched: 1, Skipped: 3, Failed: 1}
+	if stats != want {
+		t.Errorf("EnrichIPs() stats = %+v, want %+v", stats, want)
+	}
+
+	info, err := c.GetWhoisInfo("8.8.8.8")
+	if err != nil || info.ASN != "AS15169" {
+		t.Errorf("GetWhoisInfo(8.8.8.8) = %+v, %v, want ASN AS15169", info, err)
+	}
+}
+
+func TestEnrichAddrsUncachedIPs(t *testing.T) {
+	client := mapWhoisClient{"8.8.8.8": "origin: AS15169\norg: GOOGLE\ncountry: US\n"}
+	c := openWhoisTestCache(t, client, 0)
+
+	stats := c.EnrichAddrs([]string{"8.8.8.8", "10.0.0.1", "not-an-ip"})
+	if want := (EnrichStats{Enriched: 1, Skipped: 2}); stats != want {
+		t.Errorf("EnrichAddrs() stats = %+v, want %+v", stats, want)
+	}
+	if got, err := c.LookupIP(netip.MustParseAddr("8.8.8.8")); err != nil || got == "" {
+		t.Errorf("LookupIP(8.8.8.8) = %q, %v, want the whois annotation", got, err)
+	}
+}
+
+func TestWhoisClientServerOverride(t *testing.T) {
+	routes, err := parseWhoisServers(map[string]string{
+		"41.0.0.0/8":     "afrinic",
+		"41.10.0.0/16":   "whois.example.net",
+		"2001:67c::/32":  "RIPE",
+		"200.0.0.0/8":    " whois.lacnic.net ",
+		"192.168.0.0/16": "whois.internal",
+	})
+	if err != nil {
+		t.Fatalf("parseWhoisServers() error = %v", err)
+	}
+
+	var gotServers []string
+	client := &defaultWhoisClient{
+		timeout: time.Second,
+		servers: routes,
+		whois: func(_ string, servers ...string) (string, error) {
+			gotServers = servers
+			return "netname: TEST", nil
+		},
+	}
+
+	tests := map[string]string{
+		"41.1.2.3":        "whois.afrinic.net",
+		"41.10.2.3":       "whois.example.net",
+		"2001:67c::1":     "whois.ripe.net",
+		"200.1.2.3":       "whois.lacnic.net",
+		"8.8.8.8":         "",
+		"not-an-ip":       "",
+		"::ffff:41.1.1.1": "whois.afrinic.net",
+	}
+	for ip, want := range tests {
+		gotServers = nil
+		if _, err := client.Lookup(ip); err != nil {
+			t.Fatalf("Lookup(%s) error = %v", ip, err)
+		}
+		var got string
+		if len(gotServers) > 0 {
+			got = gotServers[0]
+		}
+		if got != want || len(gotServers) > 1 {
+			t.Errorf("Lookup(%s) queried servers %v, want %q", ip, gotServers, want)
+		}
+	}
+
+	for _, bad := range []map[string]string{{"41.0.0.0": "afrinic"}, {"41.0.0.0/8": " "}} {
+		if _, err := NewWhoisClientWithServers(time.Second, bad); err == nil {
+			t.Errorf("NewWhoisClientWithServers(%v) error = nil, want an error", bad)
+		}
+	}
+}
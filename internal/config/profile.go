@@ -24,6 +24,10 @@ type Config struct {
 	SchemaVersion int                      `yaml:"schema_version"`
 	ActiveProfile string                   `yaml:"active_profile"`
 	Profiles      map[string]ProfileConfig `yaml:"profiles"`
+
+	// WhoisServers maps IP ranges (CIDRs) to the whois server, or registry name
+	// such as ripe, that lookups for their addresses are sent to.
+	WhoisServers map[string]string `yaml:"whois_servers,omitempty"`
 }
 
 // NewConfig creates a new Config with defaults.
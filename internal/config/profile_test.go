@@ -118,3 +118,19 @@ func TestSchemaVersionTooNew(t *testing.T) {
 		t.Fatal("expected error for newer schema version")
 	}
 }
+
+func TestLoadConfigWhoisServers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "schema_version: 1\nwhois_servers:\n  193.0.0.0/8: ripe\n  203.0.113.0/24: whois.example.net\n"
+	if err := os.WriteFile(path, []byte(data), FilePermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.WhoisServers["193.0.0.0/8"] != "ripe" || cfg.WhoisServers["203.0.113.0/24"] != "whois.example.net" {
+		t.Errorf("WhoisServers = %v, want both ranges", cfg.WhoisServers)
+	}
+}
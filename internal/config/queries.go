@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// QueriesDirName is the directory under ~/.fli holding saved queries.
+const QueriesDirName = "queries"
+
+// savedQueryExt is the file extension of a saved query.
+const savedQueryExt = ".yaml"
+
+// queryNamePattern matches the names a saved query may have, which double as
+// its file name.
+var queryNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ErrQueryNotFound is returned by LoadQuery when no query has the given name.
+var ErrQueryNotFound = errors.New("saved query not found")
+
+// SavedQuery is a named query command line kept in the query library.
+type SavedQuery struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description,omitempty"`
+	Tags        []string `yaml:"tags,omitempty"`
+	// Args is the query command line without the program name, e.g.
+	// [count --by srcaddr --since 1h].
+	Args []string `yaml:"args"`
+}
+
+// HasTag reports whether the query is tagged with tag, ignoring case.
+func (q SavedQuery) HasTag(tag string) bool {
+	return slices.ContainsFunc(q.Tags, func(t string) bool {
+		return strings.EqualFold(t, tag)
+	})
+}
+
+// QueriesDir returns the path to the saved query directory (~/.fli/queries).
+func QueriesDir() (string, error) {
+	dir, err := FliDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, QueriesDirName), nil
+}
+
+// ValidateQueryName checks that name can be used for a saved query: letters,
+// digits, '_', '.' and '-', not starting with a punctuation character.
+func ValidateQueryName(name string) error {
+	if !queryNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid query name %q: use letters, digits, '_', '.' and '-'", name)
+	}
+	return nil
+}
+
+// SaveQuery writes q to dir as <name>.yaml, creating dir if needed. An existing
+// query with the same name is only replaced when overwrite is set.
+func SaveQuery(dir string, q SavedQuery, overwrite bool) error {
+	if err := ValidateQueryName(q.Name); err != nil {
+		return err
+	}
+	if len(q.Args) == 0 {
+		return fmt.Errorf("query %q has no command", q.Name)
+	}
+	if err := os.MkdirAll(dir, DirPermissions); err != nil {
+		return fmt.Errorf("failed to create query directory %s: %w", dir, err)
+	}
+
+	path := filepath.Join(dir, q.Name+savedQueryExt)
+	if !overwrite {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("query %q already exists", q.Name)
+		}
+	}
+
+	data, err := yaml.Marshal(q)
+	if err != nil {
+		return fmt.Errorf("failed to marshal query: %w", err)
+	}
+	if err := os.WriteFile(path, data, FilePermissions); err != nil {
+		return fmt.Errorf("failed to write query file: %w", err)
+	}
+	return nil
+}
+
+// LoadQuery reads the query with the given name from dir.
+func LoadQuery(dir, name string) (SavedQuery, error) {
+	if err := ValidateQueryName(name); err != nil {
+		return SavedQuery{}, err
+	}
+	q, err := readQuery(filepath.Join(dir, name+savedQueryExt))
+	if errors.Is(err, fs.ErrNotExist) {
+		return SavedQuery{}, fmt.Errorf("%w: %s", ErrQueryNotFound, name)
+	}
+	return q, err
+}
+
+// ListQueries returns the saved queries in dir sorted by name. A missing
+// directory holds no queries.
+func ListQueries(dir string) ([]SavedQuery, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read query directory: %w", err)
+	}
+
+	var queries []SavedQuery
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != savedQueryExt {
+			continue
+		}
+		q, err := readQuery(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i].Name < queries[j].Name })
+	return queries, nil
+}
+
+// readQuery reads and parses a saved query file. The name defaults to the file
+// name, so hand-written files need not repeat it.
+func readQuery(path string) (SavedQuery, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SavedQuery{}, fmt.Errorf("failed to read query file: %w", err)
+	}
+	var q SavedQuery
+	if err := yaml.Unmarshal(data, &q); err != nil {
+		return SavedQuery{}, fmt.Errorf("failed to parse query file %s: %w", path, err)
+	}
+	if q.Name == "" {
+		q.Name = strings.TrimSuffix(filepath.Base(path), savedQueryExt)
+	}
+	return q, nil
+}
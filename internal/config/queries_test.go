@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSaveAndLoadQuery(t *testing.T) {
+	dir := t.TempDir() + "/queries"
+	q := SavedQuery{
+		Name:        "rejected-ssh",
+		Description: "SSH attempts that were rejected",
+		Tags:        []string{"security"},
+		Args:        []string{"count", "--by", "srcaddr", "--filter", "action=REJECT and dstport=22"},
+	}
+
+	if err := SaveQuery(dir, q, false); err != nil {
+		t.Fatalf("SaveQuery() error = %v", err)
+	}
+	got, err := LoadQuery(dir, "rejected-ssh")
+	if err != nil {
+		t.Fatalf("LoadQuery() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, q) {
+		t.Errorf("LoadQuery() = %+v, want %+v", got, q)
+	}
+
+	if err := SaveQuery(dir, q, false); err == nil {
+		t.Error("SaveQuery() over an existing query without overwrite: error = nil")
+	}
+	q.Args = []string{"count"}
+	if err := SaveQuery(dir, q, true); err != nil {
+		t.Errorf("SaveQuery() with overwrite error = %v", err)
+	}
+
+	if _, err := LoadQuery(dir, "missing"); !errors.Is(err, ErrQueryNotFound) {
+		t.Errorf("LoadQuery(missing) error = %v, want ErrQueryNotFound", err)
+	}
+}
+
+func TestSaveQueryRejectsBadQueries(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"", "../escape", ".hidden", "with space"} {
+		if err := SaveQuery(dir, SavedQuery{Name: name, Args: []string{"count"}}, false); err == nil {
+			t.Errorf("SaveQuery(%q) error = nil, want an invalid name error", name)
+		}
+	}
+	if err := SaveQuery(dir, SavedQuery{Name: "empty"}, false); err == nil {
+		t.Error("SaveQuery() without args: error = nil")
+	}
+}
+
+func TestListQueries(t *testing.T) {
+	dir := t.TempDir()
+	if queries, err := ListQueries(dir + "/missing"); err != nil || queries != nil {
+		t.Errorf("ListQueries(missing dir) = %v, %v, want no queries", queries, err)
+	}
+
+	for _, q := range []SavedQuery{
+		{Name: "web-bytes", Tags: []string{"Web"}, Args: []string{"sum", "bytes"}},
+		{Name: "all-flows", Args: []string{"count"}},
+	} {
+		if err := SaveQuery(dir, q, false); err != nil {
+			t.Fatalf("SaveQuery(%s) error = %v", q.Name, err)
+		}
+	}
+
+	queries, err := ListQueries(dir)
+	if err != nil {
+		t.Fatalf("ListQueries() error = %v", err)
+	}
+	if len(queries) != 2 || queries[0].Name != "all-flows" || queries[1].Name != "web-bytes" {
+		t.Fatalf("ListQueries() = %+v, want all-flows then web-bytes", queries)
+	}
+	if !queries[1].HasTag("web") || queries[0].HasTag("web") {
+		t.Errorf("HasTag(web) = %v, %v, want false, true", queries[0].HasTag("web"), queries[1].HasTag("web"))
+	}
+}
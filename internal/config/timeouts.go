@@ -20,8 +20,14 @@ type Timeouts struct {
 	// Whois is the timeout for WHOIS lookups
 	Whois time.Duration
 
+	// PTR is the timeout for reverse DNS lookups
+	PTR time.Duration
+
 	// MaxPoll is the maximum interval between query status checks
 	MaxPoll time.Duration
+
+	// StopQuery is the timeout for stopping a query whose context was cancelled
+	StopQuery time.Duration
 }
 
 // DefaultTimeouts returns the default timeout configuration.
@@ -32,6 +38,8 @@ func DefaultTimeouts() Timeouts {
 		DB:           30 * time.Second,
 		HTTP:         10 * time.Second,
 		Whois:        5 * time.Second,
+		PTR:          2 * time.Second,
 		MaxPoll:      10 * time.Second,
+		StopQuery:    5 * time.Second,
 	}
 }
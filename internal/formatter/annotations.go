@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net/netip"
+	"slices"
+	"sort"
+	"strings"
 
 	"fli/internal/cache"
 	"fli/internal/runner"
@@ -15,23 +18,119 @@ const (
 	fieldDstAddr     = "dstaddr"
 )
 
+// Annotation sources that AnnotationOptions.Sources can select.
+const (
+	SourceENI    = "eni"    // ENI labels for interface_id
+	SourcePrefix = "prefix" // cloud provider ranges for addresses
+	SourceWhois  = "whois"  // exact IP tags from whois lookups or manual annotation
+	SourcePTR    = "ptr"    // reverse DNS hostnames
+)
+
+// annotationSources lists every source in the order ParseAnnotationSources reports them.
+var annotationSources = []string{SourceENI, SourcePrefix, SourceWhois, SourcePTR}
+
+// ParseAnnotationSources parses a comma-separated list of annotation sources such
+// as "prefix,eni". An empty spec selects every source and returns nil.
+func ParseAnnotationSources(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	var sources []string
+	for _, source := range strings.Split(spec, ",") {
+		source = strings.ToLower(strings.TrimSpace(source))
+		if !slices.Contains(annotationSources, source) {
+			return nil, fmt.Errorf("unknown annotation source %q (want %s)", source, strings.Join(annotationSources, ", "))
+		}
+		if !slices.Contains(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+	return sources, nil
+}
+
+// AnnotationOptions controls how query results are annotated from the cache.
+type AnnotationOptions struct {
+	// WhoisTopN enables whois lookups for the N most frequent public IPs in the
+	// results that have no cached annotation yet. Zero disables whois lookups,
+	// leaving only cached ENI, IP, and prefix annotations.
+	WhoisTopN int
+
+	// WhoisServers routes the WhoisTopN lookups for addresses in an IP range to a
+	// whois server, as cache.Config.WhoisServers does.
+	WhoisServers map[string]string
+
+	// PTRTopN adds reverse DNS hostnames in <field>_ptr columns, resolving the N
+	// most frequent public addresses in the results that have none cached. Cached
+	// hostnames are shown for every address. Zero disables PTR annotation.
+	PTRTopN int
+
+	// Sources limits annotation to the listed sources (SourceENI, SourcePrefix,
+	// SourceWhois, SourcePTR). Nil uses every source.
+	Sources []string
+
+	// ShowRegion appends the region of a matched cloud provider range to its
+	// annotation, e.g. AWS (52.0.0.0/8), EC2, us-east-1, to spot cross-region
+	// traffic. Ranges cached without a region are annotated as before.
+	ShowRegion bool
+}
+
+// uses reports whether the options select the annotation source.
+func (o AnnotationOptions) uses(source string) bool {
+	return o.Sources == nil || slices.Contains(o.Sources, source)
+}
+
 // EnrichResultsWithAnnotations adds ENI and IP annotations to the results.
 func EnrichResultsWithAnnotations(results [][]runner.Field, cachePath string) ([][]runner.Field, error) {
+	return EnrichResultsWithAnnotationOptions(results, cachePath, AnnotationOptions{})
+}
+
+// EnrichResultsWithAnnotationOptions opens the cache at cachePath and annotates the results
+// according to the given options.
+func EnrichResultsWithAnnotationOptions(results [][]runner.Field, cachePath string, opts AnnotationOptions) ([][]runner.Field, error) {
 	if len(results) == 0 {
 		return results, nil
 	}
 
-	cache, err := cache.Open(cachePath)
+	cfg := cache.DefaultConfig().WithCachePath(cachePath)
+	for cidr, server := range opts.WhoisServers {
+		cfg.WithWhoisServer(cidr, server)
+	}
+	c, err := cache.OpenWithConfig(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open cache for annotations: %w", err)
 	}
 	defer func() {
-		if closeErr := cache.Close(); closeErr != nil {
+		if closeErr := c.Close(); closeErr != nil {
 			// Log the close error but continue; this is a non-critical error in annotation enrichment
 			fmt.Printf("Warning: failed to close cache: %v\n", closeErr)
 		}
 	}()
 
+	return AnnotateResults(results, c, opts), nil
+}
+
+// AnnotateResults adds ENI and IP annotations to the results using an already open cache.
+func AnnotateResults(results [][]runner.Field, c *cache.Cache, opts AnnotationOptions) [][]runner.Field {
+	if len(results) == 0 {
+		return results
+	}
+
+	if opts.WhoisTopN > 0 && opts.uses(SourceWhois) {
+		enrichTopPublicIPs(results, c, opts.WhoisTopN)
+	}
+
+	// Look up every address in one cache transaction rather than one per cell
+	addrs := resultAddrs(results)
+	sources := cache.IPSources{Tags: opts.uses(SourceWhois), Prefixes: opts.uses(SourcePrefix), Region: opts.ShowRegion}
+	var ipAnnotations map[netip.Addr]string
+	if sources.Tags || sources.Prefixes {
+		ipAnnotations, _ = c.LookupIPsFrom(addrs, sources)
+	}
+	var hostnames map[netip.Addr]string
+	if opts.PTRTopN > 0 && opts.uses(SourcePTR) {
+		hostnames = resolvePTRs(c, results, addrs, opts.PTRTopN)
+	}
+
 	enriched := make([][]runner.Field, len(results))
 	for i, row := range results {
 		newRow := make([]runner.Field, len(row))
@@ -42,12 +141,15 @@ func EnrichResultsWithAnnotations(results [][]runner.Field, cachePath string) ([
 
 			switch field.Name {
 			case fieldInterfaceID:
-				if tag, _ := cache.LookupEni(context.Background(), field.Value); tag != nil {
+				if !opts.uses(SourceENI) {
+					break
+				}
+				if tag, _ := c.LookupEni(context.Background(), field.Value); tag != nil {
 					anno = &runner.Field{Name: field.Name + "_annotation", Value: tag.Label}
 				}
 			case fieldSrcAddr, fieldDstAddr:
 				if addr, err := netip.ParseAddr(field.Value); err == nil {
-					if annotation, err := cache.LookupIP(addr); err == nil && annotation != "" {
+					if annotation := ipAnnotations[addr]; annotation != "" {
 						anno = &runner.Field{Name: field.Name + "_annotation", Value: annotation}
 					}
 				}
@@ -56,9 +158,136 @@ func EnrichResultsWithAnnotations(results [][]runner.Field, cachePath string) ([
 			if anno != nil {
 				newRow = append(newRow, *anno)
 			}
+			if field.Name == fieldSrcAddr || field.Name == fieldDstAddr {
+				if addr, err := netip.ParseAddr(field.Value); err == nil && hostnames[addr] != "" {
+					newRow = append(newRow, runner.Field{Name: field.Name + "_ptr", Value: hostnames[addr]})
+				}
+			}
 		}
 		enriched[i] = newRow
 	}
 
-	return enriched, nil
+	return enriched
+}
+
+// resultAddrs returns the distinct addresses in the address columns of the results.
+func resultAddrs(results [][]runner.Field) []netip.Addr {
+	seen := make(map[netip.Addr]bool)
+	var addrs []netip.Addr
+	for _, row := range results {
+		for _, field := range row {
+			if field.Name != fieldSrcAddr && field.Name != fieldDstAddr {
+				continue
+			}
+			if addr, err := netip.ParseAddr(field.Value); err == nil && !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// resolvePTRs returns the PTR hostnames of addrs, resolving up to n of the most
+// frequent public ones in the results that have none cached yet. Lookup failures
+// leave the address without a hostname.
+func resolvePTRs(c *cache.Cache, results [][]runner.Field, addrs []netip.Addr, n int) map[netip.Addr]string {
+	hostnames, err := c.LookupPTRs(addrs)
+	if err != nil {
+		return nil
+	}
+
+	counts := publicAddrCounts(results)
+	var candidates []netip.Addr
+	for addr := range counts {
+		if _, ok := hostnames[addr]; !ok {
+			candidates = append(candidates, addr)
+		}
+	}
+	if len(candidates) == 0 {
+		return hostnames
+	}
+
+	missing := make([]string, 0, n)
+	for _, addr := range mostFrequent(candidates, counts, n) {
+		missing = append(missing, addr.String())
+	}
+	resolved, _ := c.EnrichPTR(missing)
+	for _, result := range resolved {
+		if addr, err := netip.ParseAddr(result.IP); err == nil && result.Hostname != "" {
+			hostnames[addr] = result.Hostname
+		}
+	}
+	return hostnames
+}
+
+// enrichTopPublicIPs performs whois lookups for the n most frequent public IPs
+// in the results that the cache cannot annotate yet.
+func enrichTopPublicIPs(results [][]runner.Field, c *cache.Cache, n int) {
+	for _, addr := range topUnannotatedPublicIPs(results, c, n) {
+		// Lookup failures are non-fatal; the IP is simply left without a whois annotation.
+		_, _ = c.EnrichIP(addr.String())
+	}
+}
+
+// topUnannotatedPublicIPs returns up to n public IPs without a cached annotation,
+// ordered by how many times they occur across the address columns of the results.
+func topUnannotatedPublicIPs(results [][]runner.Field, c *cache.Cache, n int) []netip.Addr {
+	counts := publicAddrCounts(results)
+	addrs := make([]netip.Addr, 0, len(counts))
+	for addr := range counts {
+		addrs = append(addrs, addr)
+	}
+	annotations, err := c.LookupIPs(addrs)
+	if err != nil {
+		annotations = nil
+	}
+
+	candidates := make([]netip.Addr, 0, len(counts))
+	for _, addr := range addrs {
+		if annotations[addr] != "" {
+			continue
+		}
+		candidates = append(candidates, addr)
+	}
+	return mostFrequent(candidates, counts, n)
+}
+
+// publicAddrCounts counts how many times each public address occurs across the
+// address columns of the results.
+func publicAddrCounts(results [][]runner.Field) map[netip.Addr]int {
+	counts := make(map[netip.Addr]int)
+	for _, row := range results {
+		for _, field := range row {
+			if field.Name != fieldSrcAddr && field.Name != fieldDstAddr {
+				continue
+			}
+			addr, err := netip.ParseAddr(field.Value)
+			if err != nil || !isPublicAddr(addr) {
+				continue
+			}
+			counts[addr]++
+		}
+	}
+	return counts
+}
+
+// mostFrequent sorts candidates by their counts, most frequent first, and returns
+// up to n of them. Ties are broken by address so the selection is deterministic.
+func mostFrequent(candidates []netip.Addr, counts map[netip.Addr]int, n int) []netip.Addr {
+	sort.Slice(candidates, func(i, j int) bool {
+		if counts[candidates[i]] != counts[candidates[j]] {
+			return counts[candidates[i]] > counts[candidates[j]]
+		}
+		return candidates[i].Less(candidates[j])
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
+
+// isPublicAddr reports whether addr is a globally routable, non-private address.
+func isPublicAddr(addr netip.Addr) bool {
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
 }
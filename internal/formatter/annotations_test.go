@@ -0,0 +1,280 @@
+package formatter
+
+import (
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fli/internal/cache"
+	"fli/internal/runner"
+)
+
+// recordingWhoisClient records every IP it is asked to look up.
+type recordingWhoisClient struct {
+	mu     sync.Mutex
+	lookup []string
+}
+
+func (r *recordingWhoisClient) Lookup(ip string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookup = append(r.lookup, ip)
+	return "OrgName: Example Org\nCountry: US\n", nil
+}
+
+func openTestCache(t *testing.T, whois cache.WhoisClient) *cache.Cache {
+	t.Helper()
+	cfg := cache.DefaultConfig().WithCachePath(filepath.Join(t.TempDir(), "cache.db"))
+	c, err := cache.OpenWithDependencies(
+		cfg,
+		cache.NewDefaultHTTPClient(time.Second),
+		whois,
+		cache.NewDefaultLogger(false),
+		cache.NewDefaultFileSystem(),
+	)
+	if err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func flowRow(src, dst string) []runner.Field {
+	return []runner.Field{
+		{Name: "srcaddr", Value: src},
+		{Name: "dstaddr", Value: dst},
+	}
+}
+
+func TestAnnotateResultsWhoisTopN(t *testing.T) {
+	results := [][]runner.Field{
+		flowRow("10.0.0.1", "8.8.8.8"),
+		flowRow("10.0.0.1", "8.8.8.8"),
+		flowRow("10.0.0.2", "8.8.8.8"),
+		flowRow("1.1.1.1", "10.0.0.1"),
+		flowRow("1.1.1.1", "10.0.0.3"),
+		flowRow("10.0.0.1", "9.9.9.9"),
+	}
+
+	tests := []struct {
+		name string
+		topN int
+		want []string
+	}{
+		{name: "disabled", topN: 0, want: nil},
+		{name: "top one", topN: 1, want: []string{"8.8.8.8"}},
+		{name: "top two", topN: 2, want: []string{"1.1.1.1", "8.8.8.8"}},
+		{name: "more than available", topN: 10, want: []string{"1.1.1.1", "8.8.8.8", "9.9.9.9"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			whois := &recordingWhoisClient{}
+			c := openTestCache(t, whois)
+
+			annotated := AnnotateResults(results, c, AnnotationOptions{WhoisTopN: tt.topN})
+			if len(annotated) != len(results) {
+				t.Fatalf("got %d rows, want %d", len(annotated), len(results))
+			}
+
+			got := append([]string(nil), whois.lookup...)
+			sort.Strings(got)
+			if len(got) != len(tt.want) {
+				t.Fatalf("looked up %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("looked up %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestTopUnannotatedPublicIPsSkipsPrivate(t *testing.T) {
+	c := openTestCache(t, &recordingWhoisClient{})
+	results := [][]runner.Field{
+		flowRow("10.0.0.1", "192.168.1.1"),
+		flowRow("172.16.0.1", "127.0.0.1"),
+		flowRow("not-an-ip", "-"),
+	}
+
+	if got := topUnannotatedPublicIPs(results, c, 5); len(got) != 0 {
+		t.Errorf("expected no public IPs, got %v", got)
+	}
+}
+
+func TestAnnotateResultsPTR(t *testing.T) {
+	c := openTestCache(t, &recordingWhoisClient{})
+	snapshot := `{"version": 1, "ips": [{"Addr": "8.8.8.8", "Name": "GOOGLE", "PTR": "dns.google"}]}`
+	if err := c.Import(strings.NewReader(snapshot)); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	results := [][]runner.Field{flowRow("10.0.0.1", "8.8.8.8")}
+
+	// Hostnames are only added when asked for
+	if got, ok := fieldValue(AnnotateResults(results, c, AnnotationOptions{})[0], "dstaddr_ptr"); ok {
+		t.Errorf("dstaddr_ptr = %q without PTR, want none", got)
+	}
+
+	annotated := AnnotateResults(results, c, AnnotationOptions{PTRTopN: 1})[0]
+	if got, _ := fieldValue(annotated, "dstaddr_ptr"); got != "dns.google" {
+		t.Errorf("dstaddr_ptr = %q, want dns.google", got)
+	}
+	if got, ok := fieldValue(annotated, "srcaddr_ptr"); ok {
+		t.Errorf("srcaddr_ptr = %q for a private address, want none", got)
+	}
+}
+
+func TestAnnotateResultsSources(t *testing.T) {
+	c := openTestCache(t, &recordingWhoisClient{})
+	if err := c.UpsertEni(cache.ENITag{ENI: "eni-123", Label: "api"}); err != nil {
+		t.Fatalf("UpsertEni() error = %v", err)
+	}
+	if err := c.UpsertIP(cache.IPTag{Addr: "1.1.1.1", Name: "CLOUDFLARE"}); err != nil {
+		t.Fatalf("UpsertIP() error = %v", err)
+	}
+	if err := c.UpsertPrefix(cache.PrefixTag{CIDR: "13.32.0.0/15", Cloud: "AWS", Service: "CLOUDFRONT"}); err != nil {
+		t.Fatalf("UpsertPrefix() error = %v", err)
+	}
+	results := [][]runner.Field{{
+		{Name: "interface_id", Value: "eni-123"},
+		{Name: "srcaddr", Value: "1.1.1.1"},
+		{Name: "dstaddr", Value: "13.32.1.1"},
+	}}
+
+	tests := []struct {
+		sources []string
+		want    map[string]string // annotation column to value
+	}{
+		{sources: nil, want: map[string]string{
+			"interface_id_annotation": "api",
+			"srcaddr_annotation":      "CLOUDFLARE",
+			"dstaddr_annotation":      "AWS (13.32.0.0/15), CLOUDFRONT",
+		}},
+		{sources: []string{SourcePrefix}, want: map[string]string{
+			"dstaddr_annotation": "AWS (13.32.0.0/15), CLOUDFRONT",
+		}},
+		{sources: []string{SourceENI, SourceWhois}, want: map[string]string{
+			"interface_id_annotation": "api",
+			"srcaddr_annotation":      "CLOUDFLARE",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.sources, ","), func(t *testing.T) {
+			row := AnnotateResults(results, c, AnnotationOptions{Sources: tt.sources})[0]
+			got := make(map[string]string)
+			for _, field := range row {
+				if strings.HasSuffix(field.Name, "_annotation") {
+					got[field.Name] = field.Value
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("annotations = %v, want %v", got, tt.want)
+			}
+			for name, value := range tt.want {
+				if got[name] != value {
+					t.Errorf("%s = %q, want %q", name, got[name], value)
+				}
+			}
+		})
+	}
+}
+
+func TestAnnotateResultsShowRegion(t *testing.T) {
+	c := openTestCache(t, &recordingWhoisClient{})
+	if err := c.UpsertPrefix(cache.PrefixTag{CIDR: "52.0.0.0/8", Cloud: "AWS", Service: "EC2", Region: "us-east-1"}); err != nil {
+		t.Fatalf("UpsertPrefix() error = %v", err)
+	}
+	if err := c.UpsertPrefix(cache.PrefixTag{CIDR: "13.32.0.0/15", Cloud: "AWS", Service: "CLOUDFRONT"}); err != nil {
+		t.Fatalf("UpsertPrefix() error = %v", err)
+	}
+	results := [][]runner.Field{{
+		{Name: "srcaddr", Value: "52.1.2.3"},
+		{Name: "dstaddr", Value: "13.32.1.1"},
+	}}
+
+	row := AnnotateResults(results, c, AnnotationOptions{ShowRegion: true})[0]
+	if got, _ := fieldValue(row, "srcaddr_annotation"); got != "AWS (52.0.0.0/8), EC2, us-east-1" {
+		t.Errorf("srcaddr_annotation = %q, want the region appended", got)
+	}
+	if got, _ := fieldValue(row, "dstaddr_annotation"); got != "AWS (13.32.0.0/15), CLOUDFRONT" {
+		t.Errorf("dstaddr_annotation = %q, want no region for a range without one", got)
+	}
+
+	row = AnnotateResults(results, c, AnnotationOptions{})[0]
+	if got, _ := fieldValue(row, "srcaddr_annotation"); got != "AWS (52.0.0.0/8), EC2" {
+		t.Errorf("srcaddr_annotation without ShowRegion = %q, want no region", got)
+	}
+}
+
+func TestAnnotateResultsENILabels(t *testing.T) {
+	c := openTestCache(t, &recordingWhoisClient{})
+	if err := c.UpsertEni(cache.ENITag{ENI: "eni-123", Label: "api", SGNames: []string{"api-sg"}}); err != nil {
+		t.Fatalf("UpsertEni() error = %v", err)
+	}
+	results := [][]runner.Field{
+		{{Name: "interface_id", Value: "eni-999"}, {Name: "srcaddr", Value: "10.0.0.1"}},
+		{{Name: "interface_id", Value: "eni-123"}, {Name: "srcaddr", Value: "10.0.0.2"}},
+	}
+
+	annotated := AnnotateResults(results, c, AnnotationOptions{})
+	if got, ok := fieldValue(annotated[0], "interface_id_annotation"); ok {
+		t.Errorf("uncached ENI annotation = %q, want none", got)
+	}
+	if got, _ := fieldValue(annotated[1], "interface_id_annotation"); got != "api" {
+		t.Errorf("cached ENI annotation = %q, want api", got)
+	}
+
+	headers := ResultHeaders(annotated)
+	if want := []string{"interface_id", "srcaddr", "interface_id_annotation"}; !slices.Equal(headers, want) {
+		t.Fatalf("ResultHeaders() = %v, want %v", headers, want)
+	}
+
+	table := TableFormatter{}.Format(annotated, headers)
+	if !strings.Contains(table, "eni-123 [api]") || strings.Contains(table, "eni-999 [") {
+		t.Errorf("table does not label only the cached ENI:\n%s", table)
+	}
+	csv := CSVFormatter{}.Format(annotated, headers)
+	if want := "interface_id,srcaddr,interface_id_annotation\neni-999,10.0.0.1,\neni-123,10.0.0.2,api\n"; csv != want {
+		t.Errorf("csv = %q, want %q", csv, want)
+	}
+	json := JSONFormatter{StableKeys: true}.Format(annotated, headers)
+	want := `[{"interface_id":"eni-999","srcaddr":"10.0.0.1"},{"interface_id":"eni-123","srcaddr":"10.0.0.2","interface_id_annotation":"api"}]`
+	if json != want {
+		t.Errorf("json = %s, want %s", json, want)
+	}
+}
+
+func TestParseAnnotationSources(t *testing.T) {
+	sources, err := ParseAnnotationSources(" prefix, ENI,prefix ")
+	if err != nil {
+		t.Fatalf("ParseAnnotationSources() error = %v", err)
+	}
+	if want := []string{SourcePrefix, SourceENI}; !slices.Equal(sources, want) {
+		t.Errorf("ParseAnnotationSources() = %v, want %v", sources, want)
+	}
+	if sources, err := ParseAnnotationSources(""); err != nil || sources != nil {
+		t.Errorf("ParseAnnotationSources(\"\") = %v, %v, want nil, nil", sources, err)
+	}
+	if _, err := ParseAnnotationSources("prefix,dns"); err == nil {
+		t.Error("ParseAnnotationSources() accepted an unknown source")
+	}
+}
+
+func TestMostFrequent(t *testing.T) {
+	a, b, c := netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("8.8.8.8"), netip.MustParseAddr("9.9.9.9")
+	counts := map[netip.Addr]int{a: 1, b: 3, c: 1}
+
+	got := mostFrequent([]netip.Addr{c, a, b}, counts, 2)
+	if want := []netip.Addr{b, a}; !slices.Equal(got, want) {
+		t.Errorf("mostFrequent() = %v, want %v: the most frequent first, ties by address, capped at n", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package formatter
+
+import "fli/internal/runner"
+
+// SelectColumns rebuilds each row so that it contains exactly the given columns, in order.
+// A column's annotation and reverse DNS fields, if present, are kept directly after
+// the column, as AnnotateResults places them. Columns
+// missing from a row are filled with empty values so every row has the same shape.
+func SelectColumns(results [][]runner.Field, columns []string) [][]runner.Field {
+	if len(columns) == 0 {
+		return results
+	}
+
+	selected := make([][]runner.Field, len(results))
+	for i, row := range results {
+		byName := make(map[string]runner.Field, len(row))
+		for _, field := range row {
+			byName[field.Name] = field
+		}
+
+		newRow := make([]runner.Field, 0, len(columns))
+		for _, column := range columns {
+			field, ok := byName[column]
+			if !ok {
+				field = runner.Field{Name: column}
+			}
+			newRow = append(newRow, field)
+			for _, suffix := range []string{"_annotation", "_ptr"} {
+				if extra, ok := byName[column+suffix]; ok {
+					newRow = append(newRow, extra)
+				}
+			}
+		}
+		selected[i] = newRow
+	}
+	return selected
+}
+
+// ResultHeaders returns the names of the fields of every row, in the order of the
+// first row. A field only some rows have, such as the annotation of an ENI or IP
+// that is cached, is placed after the field it follows in the first row that has
+// it, so the headers do not depend on whether the first row was annotated.
+func ResultHeaders(results [][]runner.Field) []string {
+	var headers []string
+	seen := make(map[string]bool)
+	for _, row := range results {
+		for j, field := range row {
+			if seen[field.Name] {
+				continue
+			}
+			seen[field.Name] = true
+			at := len(headers)
+			if j > 0 {
+				for k, header := range headers {
+					if header == row[j-1].Name {
+						at = k + 1
+						break
+					}
+				}
+			}
+			headers = append(headers[:at], append([]string{field.Name}, headers[at:]...)...)
+		}
+	}
+	return headers
+}
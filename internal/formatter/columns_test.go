@@ -0,0 +1,53 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+
+	"fli/internal/runner"
+)
+
+func TestSelectColumns(t *testing.T) {
+	results := [][]runner.Field{
+		{
+			{Name: "action", Value: "ACCEPT"},
+			{Name: "srcaddr", Value: "10.0.0.1"},
+			{Name: "srcaddr_annotation", Value: "web"},
+			{Name: "srcaddr_ptr", Value: "web.example.com"},
+			{Name: "@ptr", Value: "abc"},
+		},
+	}
+
+	got := SelectColumns(results, []string{"dstaddr", "srcaddr", "action"})
+	want := [][]runner.Field{
+		{
+			{Name: "dstaddr", Value: ""},
+			{Name: "srcaddr", Value: "10.0.0.1"},
+			{Name: "srcaddr_annotation", Value: "web"},
+			{Name: "srcaddr_ptr", Value: "web.example.com"},
+			{Name: "action", Value: "ACCEPT"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SelectColumns() = %v, want %v", got, want)
+	}
+
+	if got := SelectColumns(results, nil); !reflect.DeepEqual(got, results) {
+		t.Errorf("SelectColumns() without columns should return results unchanged, got %v", got)
+	}
+}
+
+func TestResultHeaders(t *testing.T) {
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "flows", Value: "3"}},
+		{{Name: "srcaddr", Value: "52.0.0.1"}, {Name: "srcaddr_annotation", Value: "AWS"}, {Name: "flows", Value: "1"}},
+		{{Name: "srcaddr", Value: "8.8.8.8"}, {Name: "flows", Value: "1"}, {Name: "srcaddr_ptr", Value: "dns.google"}},
+	}
+	want := []string{"srcaddr", "srcaddr_annotation", "flows", "srcaddr_ptr"}
+	if got := ResultHeaders(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("ResultHeaders() = %v, want %v", got, want)
+	}
+	if got := ResultHeaders(nil); got != nil {
+		t.Errorf("ResultHeaders(nil) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,106 @@
+package formatter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fli/internal/runner"
+)
+
+// conditionOperators are the comparisons a Condition accepts, two-character
+// operators first so that ">=" is not read as ">".
+var conditionOperators = []string{">=", "<=", "==", "!=", ">", "<", "="}
+
+// Condition compares a numeric result column with a threshold, e.g. flows > 1000.
+type Condition struct {
+	// Column is the name of the column to compare. An empty column matches nothing.
+	Column string
+
+	// Operator is one of >, >=, <, <=, = (or ==) and !=
+	Operator string
+
+	// Value is the threshold the column is compared with
+	Value float64
+}
+
+// ParseCondition parses a condition of the form "column op number", e.g.
+// "flows > 1000" or "bytes_sum<=5e9". An empty string gives a zero Condition.
+func ParseCondition(s string) (Condition, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Condition{}, nil
+	}
+
+	i := strings.IndexAny(s, "<>=!")
+	if i < 0 {
+		return Condition{}, fmt.Errorf("invalid condition %q: expected column, operator, and number", s)
+	}
+	column, rest := strings.TrimSpace(s[:i]), s[i:]
+	var operator string
+	for _, op := range conditionOperators {
+		if strings.HasPrefix(rest, op) {
+			operator = op
+			break
+		}
+	}
+	if column == "" || operator == "" {
+		return Condition{}, fmt.Errorf("invalid condition %q: expected column, operator, and number", s)
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(rest[len(operator):]), 64)
+	if err != nil {
+		return Condition{}, fmt.Errorf("invalid condition %q: threshold is not a number", s)
+	}
+	if operator == "==" {
+		operator = "="
+	}
+	return Condition{Column: column, Operator: operator, Value: value}, nil
+}
+
+// String renders the condition as it would be written on the command line.
+func (c Condition) String() string {
+	return fmt.Sprintf("%s %s %s", c.Column, c.Operator, strconv.FormatFloat(c.Value, 'f', -1, 64))
+}
+
+// Match reports whether row satisfies the condition. The column must be present
+// and numeric.
+func (c Condition) Match(row []runner.Field) (bool, error) {
+	n, err := numericColumn(row, c.Column)
+	if err != nil {
+		return false, err
+	}
+	switch c.Operator {
+	case ">":
+		return n > c.Value, nil
+	case ">=":
+		return n >= c.Value, nil
+	case "<":
+		return n < c.Value, nil
+	case "<=":
+		return n <= c.Value, nil
+	case "=":
+		return n == c.Value, nil
+	case "!=":
+		return n != c.Value, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", c.Operator)
+}
+
+// FirstMatch returns the first row of results that satisfies the condition, or
+// nil when none does.
+func (c Condition) FirstMatch(results [][]runner.Field) ([]runner.Field, error) {
+	if c.Column == "" {
+		return nil, nil
+	}
+	for i, row := range results {
+		ok, err := c.Match(row)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		if ok {
+			return row, nil
+		}
+	}
+	return nil, nil
+}
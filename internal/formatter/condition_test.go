@@ -0,0 +1,51 @@
+package formatter
+
+import (
+	"testing"
+
+	"fli/internal/runner"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := map[string]Condition{
+		"flows > 1000":     {Column: "flows", Operator: ">", Value: 1000},
+		"bytes_sum<=5e9":   {Column: "bytes_sum", Operator: "<=", Value: 5e9},
+		" flows == 0 ":     {Column: "flows", Operator: "=", Value: 0},
+		"packets_avg!=1.5": {Column: "packets_avg", Operator: "!=", Value: 1.5},
+		"":                 {},
+	}
+	for input, want := range tests {
+		if got, err := ParseCondition(input); err != nil || got != want {
+			t.Errorf("ParseCondition(%q) = %+v, %v, want %+v", input, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{"flows", "> 1000", "flows >", "flows > many", "flows => 3"} {
+		if _, err := ParseCondition(bad); err == nil {
+			t.Errorf("ParseCondition(%q) error = nil, want an error", bad)
+		}
+	}
+}
+
+func TestConditionFirstMatch(t *testing.T) {
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "flows", Value: "900"}},
+		{{Name: "srcaddr", Value: "10.0.0.2"}, {Name: "flows", Value: "1500"}},
+	}
+
+	cond, _ := ParseCondition("flows > 1000")
+	row, err := cond.FirstMatch(results)
+	if err != nil || row == nil || row[0].Value != "10.0.0.2" {
+		t.Errorf("FirstMatch(flows > 1000) = %v, %v, want the 10.0.0.2 row", row, err)
+	}
+
+	cond, _ = ParseCondition("flows < 100")
+	if row, err := cond.FirstMatch(results); err != nil || row != nil {
+		t.Errorf("FirstMatch(flows < 100) = %v, %v, want no match", row, err)
+	}
+
+	cond, _ = ParseCondition("bytes_sum > 0")
+	if _, err := cond.FirstMatch(results); err == nil {
+		t.Error("FirstMatch() on a missing column: error = nil, want an error")
+	}
+}
@@ -11,6 +11,8 @@ import (
 type CSVFormatter struct {
 	// Delimiter is the character used to separate fields
 	Delimiter rune
+	// Rename maps column names to the display names used in the header row
+	Rename map[string]string
 }
 
 // Format converts the query results to CSV format.
@@ -24,21 +26,21 @@ func (f CSVFormatter) Format(results [][]runner.Field, headers []string) string
 	}
 
 	// Write headers
-	if err := writer.Write(headers); err != nil {
+	if err := writer.Write(renameHeaders(headers, f.Rename)); err != nil {
 		// If we can't write headers, return an error message
 		return "Error: failed to write CSV headers"
 	}
 
-	// Write data rows
+	// Write data rows, one value per header looked up by name: annotations are
+	// only added to the rows they apply to, so positions differ between rows.
+	// The csv.Writer quotes values holding the delimiter, quotes, or newlines.
 	for _, row := range results {
-		values := make([]string, len(row))
-		for i, field := range row {
-			values[i] = field.Value
+		values := make([]string, len(headers))
+		for i, header := range headers {
+			values[i], _ = fieldValue(row, header)
 		}
 		if err := writer.Write(values); err != nil {
-			// If we can't write a row, continue with the next one
-			// This is a non-critical error in CSV formatting
-			continue
+			return "Error: failed to write CSV row"
 		}
 	}
 
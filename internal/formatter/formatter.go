@@ -4,7 +4,9 @@ package formatter
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"fli/internal/runner"
 )
@@ -32,20 +34,70 @@ type Formatter interface {
 
 // FormatOptions contains options for formatting output.
 type FormatOptions struct {
-	// Format specifies the output format (table, csv, json)
+	// Format specifies the output format (table, wide, csv, json)
 	Format string
 
 	// Colorize determines whether to colorize the output (only applies to table format)
 	Colorize bool
 
+	// HighlightPrivate colors private IPs and public IPs differently (only
+	// applies to table formats, and only with Colorize)
+	HighlightPrivate bool
+
 	// RemovePtr determines whether to remove @ptr fields from the output
 	RemovePtr bool
 
 	// UseProtoNames determines whether to convert protocol numbers to names
 	UseProtoNames bool
 
+	// DecodeTCPFlags renders tcp_flags bitmasks as flag names such as SYN,ACK
+	DecodeTCPFlags bool
+
 	// Debug enables debug output
 	Debug bool
+
+	// Sort applies a client-side sort to the rows before they are rendered
+	Sort SortSpec
+
+	// Rename maps column names to display names in the rendered headers (all formats)
+	Rename map[string]string
+
+	// Transformers maps field names to functions applied to their values, e.g. to
+	// mask account IDs. They run after the built-in protocol conversion.
+	Transformers map[string]func(string) string
+
+	// Envelope wraps JSON output in a document that also records the query, its
+	// time window, and its statistics (json format only)
+	Envelope bool
+
+	// Query is the query string recorded in the envelope
+	Query string
+
+	// MaxWidth truncates table values longer than this many characters (0 for no
+	// limit). The wide format ignores it and never truncates.
+	MaxWidth int
+
+	// ColumnWidths overrides MaxWidth for the named columns (0 for no limit). Like
+	// MaxWidth it only applies to the table format.
+	ColumnWidths map[string]int
+
+	// TypedJSON emits numeric values as JSON numbers instead of strings (json
+	// format only). NumericField, when set, limits this to the fields it accepts,
+	// e.g. the schema's numeric fields, so IDs that look numeric stay strings.
+	TypedJSON    bool
+	NumericField func(field string) bool
+
+	// StableKeys orders the keys of JSON objects by the headers instead of
+	// alphabetically (json format only, not the envelope)
+	StableKeys bool
+
+	// Humanize renders byte columns (bytes, bytes_sum, ...) as sizes like 1.5 MB
+	// and duration columns as durations like 2m3s, after any client-side sort
+	Humanize bool
+
+	// ShowTotals appends a TOTAL row summing the sum and count columns of
+	// aggregation results (table formats only)
+	ShowTotals bool
 }
 
 // Format formats query results using the appropriate formatter based on the specified format
@@ -58,6 +110,21 @@ type FormatOptions struct {
 // - The formatted output string
 // - Any error that occurred during formatting.
 func Format(results [][]runner.Field, headers []string, options FormatOptions) (string, error) {
+	processedResults, headers, err := prepareResults(results, headers, options)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := newFormatter(options)
+	if err != nil {
+		return "", fmt.Errorf("failed to get formatter: %w", err)
+	}
+	return f.Format(processedResults, headers), nil
+}
+
+// prepareResults applies the formatting options and client-side sort to the results,
+// deriving the headers from the first row when none are given.
+func prepareResults(results [][]runner.Field, headers []string, options FormatOptions) ([][]runner.Field, []string, error) {
 	// Process results based on options
 	processedResults := processResults(results, options)
 
@@ -76,33 +143,66 @@ func Format(results [][]runner.Field, headers []string, options FormatOptions) (
 		}
 	}
 
-	f, err := GetFormatter(options.Format, options.Colorize)
-	if err != nil {
-		return "", fmt.Errorf("failed to get formatter: %w", err)
+	if options.Sort.Column != "" {
+		if !slices.Contains(headers, options.Sort.Column) {
+			return nil, nil, fmt.Errorf("cannot sort on unknown column %q", options.Sort.Column)
+		}
+		processedResults = SortResults(processedResults, options.Sort)
 	}
-	return f.Format(processedResults, headers), nil
+
+	// Tables humanize their own cells, so that totals are summed from raw values
+	if options.Humanize && !IsTableFormat(options.Format) {
+		processedResults = humanizeResults(processedResults)
+	}
+
+	return processedResults, headers, nil
 }
 
 // FormatWithStats formats query results and appends query statistics.
 func FormatWithStats(results [][]runner.Field, headers []string, options FormatOptions, stats runner.QueryStatistics) (string, error) {
+	if options.Envelope {
+		if options.Format != "json" {
+			return "", fmt.Errorf("envelope output requires the json format, got %q", options.Format)
+		}
+		processedResults, headers, err := prepareResults(results, headers, options)
+		if err != nil {
+			return "", err
+		}
+		return FormatEnvelope(processedResults, headers, options, stats)
+	}
+
 	output, err := Format(results, headers, options)
 	if err != nil {
 		return "", err
 	}
 
-	// Only append statistics for table format
-	if options.Format == "table" {
+	// Only append statistics for table formats
+	if IsTableFormat(options.Format) {
 		statsOutput := fmt.Sprintf("\n\nQuery Statistics:\n"+
 			"  Bytes Scanned:   %d\n"+
 			"  Records Scanned: %d\n"+
 			"  Records Matched: %d\n",
 			stats.BytesScanned, stats.RecordsScanned, stats.RecordsMatched)
+		if window := FormatQueryWindow(stats); window != "" {
+			statsOutput += fmt.Sprintf("  Time Range:      %s\n", window)
+		}
 		return output + statsOutput, nil
 	}
 
 	return output, nil
 }
 
+// FormatQueryWindow renders the queried [start, end] window from stats as RFC 3339 UTC
+// timestamps. It returns an empty string when the window is unknown.
+func FormatQueryWindow(stats runner.QueryStatistics) string {
+	if stats.StartTime == 0 && stats.EndTime == 0 {
+		return ""
+	}
+	start := time.UnixMilli(stats.StartTime).UTC().Format(time.RFC3339)
+	end := time.UnixMilli(stats.EndTime).UTC().Format(time.RFC3339)
+	return start + " to " + end
+}
+
 // generateDebugOutput creates a debug representation of the raw and processed results.
 func generateDebugOutput(rawResults, processedResults [][]runner.Field) string {
 	var sb strings.Builder
@@ -126,17 +226,36 @@ func generateDebugOutput(rawResults, processedResults [][]runner.Field) string {
 	return sb.String()
 }
 
+// jsonFormatter returns the JSONFormatter configured by the options.
+func (o FormatOptions) jsonFormatter() JSONFormatter {
+	return JSONFormatter{Rename: o.Rename, Typed: o.TypedJSON, NumericField: o.NumericField, StableKeys: o.StableKeys}
+}
+
+// IsTableFormat reports whether format renders an ASCII table.
+func IsTableFormat(format string) bool {
+	return format == "table" || format == "wide"
+}
+
 // GetFormatter returns a formatter for the specified format.
 func GetFormatter(format string, colorize bool) (Formatter, error) {
-	switch format {
+	return newFormatter(FormatOptions{Format: format, Colorize: colorize})
+}
+
+// newFormatter returns a formatter for options.Format configured from the remaining options.
+func newFormatter(options FormatOptions) (Formatter, error) {
+	switch options.Format {
 	case "table":
-		return &TableFormatter{ColorizeAction: colorize}, nil
+		return &TableFormatter{MaxWidth: options.MaxWidth, ColumnWidths: options.ColumnWidths, ColorizeAction: options.Colorize, HighlightPrivate: options.HighlightPrivate, Rename: options.Rename, ShowTotals: options.ShowTotals, Humanize: options.Humanize}, nil
+	case "wide":
+		// A table sized to the longest value in each column
+		return &TableFormatter{ColorizeAction: options.Colorize, HighlightPrivate: options.HighlightPrivate, Rename: options.Rename, ShowTotals: options.ShowTotals, Humanize: options.Humanize}, nil
 	case "csv":
-		return &CSVFormatter{}, nil
+		return &CSVFormatter{Rename: options.Rename}, nil
 	case "json":
-		return &JSONFormatter{}, nil
+		formatter := options.jsonFormatter()
+		return &formatter, nil
 	default:
-		return nil, fmt.Errorf("unsupported format: %s", format)
+		return nil, fmt.Errorf("unsupported format: %s", options.Format)
 	}
 }
 
@@ -162,6 +281,14 @@ func processResults(results [][]runner.Field, options FormatOptions) [][]runner.
 				}
 			}
 
+			if options.DecodeTCPFlags && field.Name == tcpFlagsColumn {
+				field.Value, _ = DecodeTCPFlags(field.Value)
+			}
+
+			if transform, ok := options.Transformers[field.Name]; ok {
+				field.Value = transform(field.Value)
+			}
+
 			processedRow = append(processedRow, field)
 		}
 		processedResults[i] = processedRow
@@ -1,6 +1,9 @@
 package formatter
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -120,6 +123,145 @@ func TestTableFormatter(t *testing.T) {
 	}
 }
 
+func TestTableFormatterTotals(t *testing.T) {
+	headers := []string{"srcaddr", "dstport", "bytes_sum", "bytes_avg", "flows"}
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "dstport", Value: "443"}, {Name: "bytes_sum", Value: "1500"}, {Name: "bytes_avg", Value: "750"}, {Name: "flows", Value: "2"}},
+		{{Name: "srcaddr", Value: "10.0.0.2"}, {Name: "dstport", Value: "22"}, {Name: "bytes_sum", Value: "250.5"}, {Name: "bytes_avg", Value: "250.5"}, {Name: "flows", Value: "1"}},
+	}
+
+	output := TableFormatter{ShowTotals: true}.Format(results, headers)
+	want := `+----------+---------+-----------+-----------+-------+
+| srcaddr  | dstport | bytes_sum | bytes_avg | flows |
++----------+---------+-----------+-----------+-------+
+| 10.0.0.1 | 443     | 1500      | 750       | 2     |
+| 10.0.0.2 | 22      | 250.5     | 250.5     | 1     |
++----------+---------+-----------+-----------+-------+
+| TOTAL    |         | 1750.5    |           | 3     |
++----------+---------+-----------+-----------+-------+
+`
+	if output != want {
+		t.Errorf("TableFormatter.Format() =\n%s\nwant\n%s", output, want)
+	}
+
+	// Raw rows have no sum or count columns, so no totals are added
+	rawHeaders := []string{"srcaddr", "bytes"}
+	raw := [][]runner.Field{{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "bytes", Value: "10"}}}
+	if output := (TableFormatter{ShowTotals: true}).Format(raw, rawHeaders); strings.Contains(output, "TOTAL") {
+		t.Errorf("TableFormatter.Format() added totals to raw rows:\n%s", output)
+	}
+
+	// Humanized columns are still totalled, from the raw values
+	output, err := Format(results, headers, FormatOptions{Format: "table", ShowTotals: true, Humanize: true})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(output, "| 1.5 KB ") || !strings.Contains(output, "| TOTAL    |         | 1.8 KB    |") {
+		t.Errorf("Format() did not total the humanized column:\n%s", output)
+	}
+
+	// A column holding any non-numeric value is left blank
+	results[1][2].Value = "n/a"
+	output = TableFormatter{ShowTotals: true}.Format(results, headers)
+	if !strings.Contains(output, "| TOTAL    |         |           |           | 3     |") {
+		t.Errorf("TableFormatter.Format() did not blank the non-numeric column:\n%s", output)
+	}
+}
+
+func TestTableFormatterColumnWidths(t *testing.T) {
+	headers := []string{"srcaddr", "log_status"}
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "2001:db8:85a3::8a2e:370:7334"}, {Name: "log_status", Value: "NODATA-EXTENDED"}},
+	}
+
+	formatter := TableFormatter{MaxWidth: 10, ColumnWidths: map[string]int{"srcaddr": 0, "log_status": 6}}
+	output := formatter.Format(results, headers)
+
+	if !strings.Contains(output, "| 2001:db8:85a3::8a2e:370:7334 |") {
+		t.Errorf("srcaddr was truncated despite its width of 0:\n%s", output)
+	}
+	if !strings.Contains(output, "| NOD...     |") || strings.Contains(output, "NODATA-EXTENDED") {
+		t.Errorf("log_status was not truncated to 6 characters:\n%s", output)
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		value string
+		limit int
+		want  string
+	}{
+		{value: "héllo wörld", limit: 0, want: "héllo wörld"},
+		{value: "héllo wörld", limit: 11, want: "héllo wörld"},
+		{value: "héllo wörld", limit: 8, want: "héllo..."},
+		{value: "日本語のホスト", limit: 3, want: "日本語"},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.value, tt.limit); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.value, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestParseColumnWidths(t *testing.T) {
+	got, err := ParseColumnWidths("srcaddr=0, log_status = 6")
+	if err != nil {
+		t.Fatalf("ParseColumnWidths() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string]int{"srcaddr": 0, "log_status": 6}) {
+		t.Errorf("ParseColumnWidths() = %v", got)
+	}
+	if got, err := ParseColumnWidths(""); err != nil || got != nil {
+		t.Errorf("ParseColumnWidths(\"\") = %v, %v; want nil, nil", got, err)
+	}
+	for _, bad := range []string{"srcaddr", "=6", "srcaddr=wide", "srcaddr=-1"} {
+		if _, err := ParseColumnWidths(bad); err == nil {
+			t.Errorf("ParseColumnWidths(%q) error = nil, want an error", bad)
+		}
+	}
+}
+
+func TestFormatWideNeverTruncates(t *testing.T) {
+	headers := []string{"srcaddr", "dstaddr", "dstaddr_annotation"}
+	annotation := "AWS (13.32.0.0/15), CLOUDFRONT edge location serving a very long distribution name"
+	results := [][]runner.Field{
+		{
+			{Name: "srcaddr", Value: "10.0.0.1"},
+			{Name: "dstaddr", Value: "13.32.1.1"},
+			{Name: "dstaddr_annotation", Value: annotation},
+		},
+		{
+			{Name: "srcaddr", Value: "10.0.0.2"},
+			{Name: "dstaddr", Value: "10.0.0.3"},
+		},
+	}
+
+	table, err := Format(results, headers, FormatOptions{Format: "table", MaxWidth: 20})
+	if err != nil {
+		t.Fatalf("Format(table) error = %v", err)
+	}
+	if strings.Contains(table, annotation) || !strings.Contains(table, "...") {
+		t.Errorf("Format(table) did not truncate the annotation:\n%s", table)
+	}
+
+	wide, err := Format(results, headers, FormatOptions{Format: "wide", MaxWidth: 20})
+	if err != nil {
+		t.Fatalf("Format(wide) error = %v", err)
+	}
+	if !strings.Contains(wide, "13.32.1.1 ["+annotation+"]") {
+		t.Errorf("Format(wide) truncated the annotation:\n%s", wide)
+	}
+
+	// Every line of the table, separators included, has the same width
+	lines := strings.Split(strings.TrimRight(wide, "\n"), "\n")
+	for _, line := range lines[1:] {
+		if len(line) != len(lines[0]) {
+			t.Errorf("Format(wide) line %q is %d wide, want %d:\n%s", line, len(line), len(lines[0]), wide)
+		}
+	}
+}
+
 func TestTableFormatterWithColorization(t *testing.T) {
 	headers := []string{"timestamp", "action", "bytes"}
 	results := [][]runner.Field{
@@ -183,6 +325,45 @@ func TestCSVFormatter(t *testing.T) {
 	}
 }
 
+func TestCSVFormatterQuotesAndAligns(t *testing.T) {
+	headers := []string{"srcaddr", "dstaddr", "dstaddr_annotation", "note"}
+	results := [][]runner.Field{
+		{
+			{Name: "srcaddr", Value: "10.0.0.1"},
+			{Name: "dstaddr", Value: "13.32.1.1"},
+			{Name: "dstaddr_annotation", Value: "AWS (13.32.0.0/15), CLOUDFRONT"},
+			{Name: "note", Value: "said \"hi\"\nthen left"},
+		},
+		// Unannotated rows have fewer fields than the headers
+		{
+			{Name: "srcaddr", Value: "10.0.0.2"},
+			{Name: "dstaddr", Value: "10.0.0.3"},
+			{Name: "note", Value: "a;b"},
+		},
+	}
+
+	for _, delimiter := range []rune{0, ';'} {
+		output := CSVFormatter{Delimiter: delimiter}.Format(results, headers)
+
+		reader := csv.NewReader(strings.NewReader(output))
+		if delimiter != 0 {
+			reader.Comma = delimiter
+		}
+		records, err := reader.ReadAll()
+		if err != nil {
+			t.Fatalf("csv.Reader failed on the output (delimiter %q): %v\n%s", delimiter, err, output)
+		}
+		want := [][]string{
+			headers,
+			{"10.0.0.1", "13.32.1.1", "AWS (13.32.0.0/15), CLOUDFRONT", "said \"hi\"\nthen left"},
+			{"10.0.0.2", "10.0.0.3", "", "a;b"},
+		}
+		if !reflect.DeepEqual(records, want) {
+			t.Errorf("parsed records (delimiter %q) = %q, want %q", delimiter, records, want)
+		}
+	}
+}
+
 func TestJSONFormatter(t *testing.T) {
 	headers := []string{"timestamp", "srcaddr", "bytes"}
 	results := [][]runner.Field{
@@ -212,3 +393,198 @@ func TestJSONFormatter(t *testing.T) {
 		t.Errorf("JSONFormatter.Format() non-pretty output contains newlines: %v", output)
 	}
 }
+
+func TestJSONFormatterTyped(t *testing.T) {
+	headers := []string{"timestamp", "srcaddr", "action", "protocol", "bytes", "packets", "account_id", "rate"}
+	results := [][]runner.Field{
+		{
+			{Name: "timestamp", Value: "2023-01-01 12:00:00"},
+			{Name: "srcaddr", Value: "10.0.0.1"},
+			{Name: "action", Value: "ACCEPT"},
+			{Name: "protocol", Value: "6"},
+			{Name: "bytes", Value: "1024"},
+			{Name: "packets", Value: "12"},
+			{Name: "account_id", Value: "123456789012"},
+			{Name: "rate", Value: "0.5"},
+		},
+	}
+	stringFields := map[string]bool{"account_id": true}
+	numeric := func(field string) bool { return !stringFields[field] }
+
+	tests := []struct {
+		name      string
+		formatter JSONFormatter
+		want      string
+	}{
+		{
+			name:      "strings by default",
+			formatter: JSONFormatter{},
+			want:      `[{"account_id":"123456789012","action":"ACCEPT","bytes":"1024","packets":"12","protocol":"6","rate":"0.5","srcaddr":"10.0.0.1","timestamp":"2023-01-01 12:00:00"}]`,
+		},
+		{
+			name:      "typed",
+			formatter: JSONFormatter{Typed: true},
+			want:      `[{"account_id":123456789012,"action":"ACCEPT","bytes":1024,"packets":12,"protocol":6,"rate":0.5,"srcaddr":"10.0.0.1","timestamp":"2023-01-01 12:00:00"}]`,
+		},
+		{
+			name:      "typed numeric fields only",
+			formatter: JSONFormatter{Typed: true, NumericField: numeric},
+			want:      `[{"account_id":"123456789012","action":"ACCEPT","bytes":1024,"packets":12,"protocol":6,"rate":0.5,"srcaddr":"10.0.0.1","timestamp":"2023-01-01 12:00:00"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.formatter.Format(results, headers); got != tt.want {
+				t.Errorf("Format() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Protocol names and zero-padded values are not numbers
+	for _, value := range []string{"TCP", "0123", "1e", " 1", "-", "NaN"} {
+		if isJSONNumber(value) {
+			t.Errorf("isJSONNumber(%q) = true, want false", value)
+		}
+	}
+}
+
+func TestJSONFormatterStableKeys(t *testing.T) {
+	headers := []string{"srcaddr", "dstaddr", "bytes", "action"}
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "dstaddr", Value: "10.0.0.2"}, {Name: "bytes", Value: "1024"}, {Name: "action", Value: "ACCEPT"}},
+		{{Name: "srcaddr", Value: "10.0.0.3"}, {Name: "dstaddr", Value: "10.0.0.4"}, {Name: "bytes", Value: "-"}, {Name: "action", Value: "REJECT"}},
+	}
+
+	formatter := JSONFormatter{StableKeys: true, Typed: true, Rename: map[string]string{"bytes": "size"}}
+	want := `[{"srcaddr":"10.0.0.1","dstaddr":"10.0.0.2","size":1024,"action":"ACCEPT"},{"srcaddr":"10.0.0.3","dstaddr":"10.0.0.4","size":"-","action":"REJECT"}]`
+	if got := formatter.Format(results, headers); got != want {
+		t.Errorf("Format() = %s, want %s", got, want)
+	}
+
+	formatter.Pretty = true
+	want = `[
+  {
+    "srcaddr": "10.0.0.1",
+    "dstaddr": "10.0.0.2",
+    "size": 1024,
+    "action": "ACCEPT"
+  },
+  {
+    "srcaddr": "10.0.0.3",
+    "dstaddr": "10.0.0.4",
+    "size": "-",
+    "action": "REJECT"
+  }
+]`
+	if got := formatter.Format(results, headers); got != want {
+		t.Errorf("pretty Format() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatWithStatsTimeRange(t *testing.T) {
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.1"}},
+	}
+	stats := runner.QueryStatistics{
+		StartTime: 1700000000000,
+		EndTime:   1700003600000,
+	}
+
+	output, err := FormatWithStats(results, []string{"srcaddr"}, FormatOptions{Format: "table"}, stats)
+	if err != nil {
+		t.Fatalf("FormatWithStats() error = %v", err)
+	}
+	want := "Time Range:      2023-11-14T22:13:20Z to 2023-11-14T23:13:20Z"
+	if !strings.Contains(output, want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, output)
+	}
+
+	output, err = FormatWithStats(results, []string{"srcaddr"}, FormatOptions{Format: "table"}, runner.QueryStatistics{})
+	if err != nil {
+		t.Fatalf("FormatWithStats() error = %v", err)
+	}
+	if strings.Contains(output, "Time Range") {
+		t.Errorf("expected no time range without a known window, got:\n%s", output)
+	}
+}
+
+func TestFormatWithStatsEnvelope(t *testing.T) {
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "flows", Value: "3"}},
+		{{Name: "srcaddr", Value: "10.0.0.2"}, {Name: "flows", Value: "1"}},
+	}
+	stats := runner.QueryStatistics{
+		BytesScanned:   2048,
+		RecordsScanned: 40,
+		RecordsMatched: 4,
+		StartTime:      1700000000000,
+		EndTime:        1700003600000,
+	}
+	options := FormatOptions{Format: "json", Envelope: true, Query: "stats count(*) as flows by srcaddr"}
+
+	output, err := FormatWithStats(results, []string{"srcaddr", "flows"}, options, stats)
+	if err != nil {
+		t.Fatalf("FormatWithStats() error = %v", err)
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(output), &doc); err != nil {
+		t.Fatalf("envelope is not valid JSON: %v\n%s", err, output)
+	}
+	for _, key := range []string{"query", "start", "end", "statistics", "results"} {
+		if _, ok := doc[key]; !ok {
+			t.Errorf("envelope is missing top-level key %q", key)
+		}
+	}
+
+	var envelope Envelope
+	if err := json.Unmarshal([]byte(output), &envelope); err != nil {
+		t.Fatalf("failed to decode envelope: %v", err)
+	}
+	if envelope.Query != options.Query {
+		t.Errorf("query = %q, want %q", envelope.Query, options.Query)
+	}
+	if envelope.Start != "2023-11-14T22:13:20Z" || envelope.End != "2023-11-14T23:13:20Z" {
+		t.Errorf("window = %s to %s, want 2023-11-14T22:13:20Z to 2023-11-14T23:13:20Z", envelope.Start, envelope.End)
+	}
+	wantStats := EnvelopeStatistics{BytesScanned: 2048, RecordsScanned: 40, RecordsMatched: 4}
+	if envelope.Statistics != wantStats {
+		t.Errorf("statistics = %+v, want %+v", envelope.Statistics, wantStats)
+	}
+	if len(envelope.Results) != 2 || envelope.Results[0]["srcaddr"] != "10.0.0.1" || envelope.Results[1]["flows"] != "1" {
+		t.Errorf("results = %v, want both rows keyed by column", envelope.Results)
+	}
+
+	if _, err := FormatWithStats(results, nil, FormatOptions{Format: "csv", Envelope: true}, stats); err == nil {
+		t.Error("expected an error for an envelope in csv format")
+	}
+}
+
+func TestTableFormatterHighlightPrivate(t *testing.T) {
+	headers := []string{"srcaddr", "dstaddr", "flows"}
+	results := [][]runner.Field{
+		{
+			{Name: "srcaddr", Value: "10.0.0.5 [api]"},
+			{Name: "dstaddr", Value: "8.8.8.8"},
+			{Name: "flows", Value: "12"},
+		},
+	}
+
+	formatter := TableFormatter{ColorizeAction: true, HighlightPrivate: true}
+	output := formatter.Format(results, headers)
+	if !strings.Contains(output, colorCyan+"10.0.0.5 [api]"+colorReset) {
+		t.Errorf("Format() = %q, want the private IP in cyan", output)
+	}
+	if !strings.Contains(output, colorYellow+"8.8.8.8"+colorReset) {
+		t.Errorf("Format() = %q, want the public IP in yellow", output)
+	}
+	if strings.Contains(output, "\033[33m12") || strings.Contains(output, "\033[36m12") {
+		t.Errorf("Format() = %q, want non-IP values uncolored", output)
+	}
+
+	formatter.ColorizeAction = false
+	if output := formatter.Format(results, headers); strings.Contains(output, "\033[") {
+		t.Errorf("Format() without colorization = %q, want no color codes", output)
+	}
+}
@@ -0,0 +1,75 @@
+package formatter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"fli/internal/runner"
+)
+
+// byteUnits are the decimal units HumanizeBytes scales through.
+var byteUnits = []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
+
+// HumanizeBytes renders a byte count with a decimal unit, e.g. 1500000 as 1.5 MB.
+// Values that are not numbers are returned unchanged.
+func HumanizeBytes(s string) string {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+	unit := 0
+	for (n >= 1000 || n <= -1000) && unit < len(byteUnits)-1 {
+		n /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return strconv.FormatFloat(n, 'f', -1, 64) + " " + byteUnits[0]
+	}
+	return strconv.FormatFloat(n, 'f', 1, 64) + " " + byteUnits[unit]
+}
+
+// HumanizeDuration renders a number of seconds as a duration, e.g. 123 as 2m3s.
+// Fractional seconds are kept to the millisecond. Values that are not numbers
+// are returned unchanged.
+func HumanizeDuration(s string) string {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+	return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond).String()
+}
+
+// humanizer returns the function that humanizes the named column: bytes and its
+// aggregations (bytes_sum, bytes_p95, ...) as byte sizes, and columns mentioning
+// duration as durations. Counts, such as bytes_count and bytes_distinct, are plain
+// numbers and are left alone.
+func humanizer(name string) (func(string) string, bool) {
+	if strings.HasSuffix(name, "_distinct") || strings.HasSuffix(name, "_count") {
+		return nil, false
+	}
+	switch {
+	case name == "bytes" || strings.HasPrefix(name, "bytes_"):
+		return HumanizeBytes, true
+	case strings.Contains(name, "duration"):
+		return HumanizeDuration, true
+	}
+	return nil, false
+}
+
+// humanizeResults returns a copy of results with byte and duration columns made
+// readable. It runs after any client-side sort, which needs the raw numbers.
+func humanizeResults(results [][]runner.Field) [][]runner.Field {
+	humanized := make([][]runner.Field, len(results))
+	for i, row := range results {
+		newRow := make([]runner.Field, len(row))
+		for j, field := range row {
+			if humanize, ok := humanizer(field.Name); ok {
+				field.Value = humanize(field.Value)
+			}
+			newRow[j] = field
+		}
+		humanized[i] = newRow
+	}
+	return humanized
+}
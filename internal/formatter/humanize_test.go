@@ -0,0 +1,94 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"fli/internal/runner"
+)
+
+func TestHumanizeBytes(t *testing.T) {
+	tests := map[string]string{
+		"512":           "512 B",
+		"1500":          "1.5 KB",
+		"1500000":       "1.5 MB",
+		"2340000000":    "2.3 GB",
+		"1234567890123": "1.2 TB",
+		"n/a":           "n/a",
+	}
+	for input, want := range tests {
+		if got := HumanizeBytes(input); got != want {
+			t.Errorf("HumanizeBytes(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestHumanizeDuration(t *testing.T) {
+	tests := map[string]string{
+		"0":     "0s",
+		"45":    "45s",
+		"123":   "2m3s",
+		"3725":  "1h2m5s",
+		"12.25": "12.25s",
+		"n/a":   "n/a",
+	}
+	for input, want := range tests {
+		if got := HumanizeDuration(input); got != want {
+			t.Errorf("HumanizeDuration(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestHumanizer(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "bytes", want: true},
+		{name: "bytes_sum", want: true},
+		{name: "bytes_p95", want: true},
+		{name: "duration_avg", want: true},
+		{name: "bytes_count"},
+		{name: "duration_count"},
+		{name: "bytes_distinct"},
+		{name: "packets"},
+	}
+	for _, tt := range tests {
+		if _, ok := humanizer(tt.name); ok != tt.want {
+			t.Errorf("humanizer(%q) humanizes = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
+
+func TestFormatHumanize(t *testing.T) {
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "bytes_sum", Value: "900000"}, {Name: "duration_avg", Value: "61"}, {Name: "bytes_distinct", Value: "4000"}},
+		{{Name: "srcaddr", Value: "10.0.0.2"}, {Name: "bytes_sum", Value: "1500000"}, {Name: "duration_avg", Value: "5"}, {Name: "bytes_distinct", Value: "12"}},
+	}
+	options := FormatOptions{
+		Format:   "csv",
+		Sort:     SortSpec{Column: "bytes_sum", Descending: true},
+		Humanize: true,
+	}
+
+	output, err := Format(results, nil, options)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	// The sort compares the raw numbers, so 1.5 MB comes before 900.0 KB
+	want := "srcaddr,bytes_sum,duration_avg,bytes_distinct\n" +
+		"10.0.0.2,1.5 MB,5s,12\n" +
+		"10.0.0.1,900.0 KB,1m1s,4000\n"
+	if output != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", output, want)
+	}
+
+	options.Humanize = false
+	output, err = Format(results, nil, options)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(output, "1500000") {
+		t.Errorf("Format() without Humanize = %q, want raw values", output)
+	}
+}
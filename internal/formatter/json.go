@@ -1,7 +1,11 @@
 package formatter
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
 	"fli/internal/runner"
 )
@@ -10,35 +14,168 @@ import (
 type JSONFormatter struct {
 	// Pretty determines if the JSON should be pretty-printed
 	Pretty bool
+	// Rename maps column names to the keys used in each JSON object
+	Rename map[string]string
+	// Typed emits numeric values as JSON numbers instead of strings
+	Typed bool
+	// NumericField limits typed values to the fields it accepts; nil types every
+	// value that is a valid JSON number
+	NumericField func(field string) bool
+	// StableKeys orders the keys of each object by the headers instead of
+	// alphabetically, so columns appear in the order they are displayed
+	StableKeys bool
 }
 
 // Format converts the query results to JSON format.
 func (f JSONFormatter) Format(results [][]runner.Field, headers []string) string {
-	// Convert results to a more JSON-friendly structure
-	jsonData := make([]map[string]string, 0, len(results))
-
-	for _, row := range results {
-		rowMap := make(map[string]string)
-		for i, field := range row {
-			if i < len(headers) {
-				rowMap[headers[i]] = field.Value
-			}
-		}
-		jsonData = append(jsonData, rowMap)
+	var jsonData any = jsonRows(results, headers, f.Rename, f.valueFunc())
+	if f.StableKeys {
+		jsonData = orderedJSONRows(results, headers, f.Rename, f.valueFunc())
 	}
 
-	var bytes []byte
+	var data []byte
 	var err error
 
 	if f.Pretty {
-		bytes, err = json.MarshalIndent(jsonData, "", "  ")
+		data, err = json.MarshalIndent(jsonData, "", "  ")
 	} else {
-		bytes, err = json.Marshal(jsonData)
+		data, err = json.Marshal(jsonData)
 	}
 
 	if err != nil {
 		return "{\"error\": \"Failed to format as JSON\"}"
 	}
 
-	return string(bytes)
+	return string(data)
+}
+
+// valueFunc returns how each field value is rendered into a JSON object.
+func (f JSONFormatter) valueFunc() func(runner.Field) any {
+	return func(field runner.Field) any {
+		if f.Typed && isJSONNumber(field.Value) && (f.NumericField == nil || f.NumericField(field.Name)) {
+			return json.Number(field.Value)
+		}
+		return field.Value
+	}
+}
+
+// isJSONNumber reports whether s is a JSON number literal, so "1024" and "0.5"
+// qualify but IPs, timestamps, and zero-padded IDs such as "0123" do not.
+func isJSONNumber(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+	return json.Valid([]byte(s))
+}
+
+// jsonRows converts results to one object per row keyed by the renamed headers,
+// rendering each field with value. Fields are looked up by name, as annotations
+// are only added to the rows they apply to; a row without a header's field
+// omits that key.
+func jsonRows(results [][]runner.Field, headers []string, rename map[string]string, value func(runner.Field) any) []map[string]any {
+	keys := renameHeaders(headers, rename)
+	rows := make([]map[string]any, 0, len(results))
+	for _, row := range results {
+		rowMap := make(map[string]any)
+		for i, header := range headers {
+			if v, ok := fieldValue(row, header); ok {
+				rowMap[keys[i]] = value(runner.Field{Name: header, Value: v})
+			}
+		}
+		rows = append(rows, rowMap)
+	}
+	return rows
+}
+
+// orderedObject is a JSON object whose keys are written in a fixed order.
+type orderedObject struct {
+	keys   []string
+	values map[string]any
+}
+
+// MarshalJSON writes the object's keys in order.
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// orderedJSONRows is jsonRows with each object's keys in header order. A header
+// repeated by a rename keeps its first position and its last value, as in a map.
+func orderedJSONRows(results [][]runner.Field, headers []string, rename map[string]string, value func(runner.Field) any) []orderedObject {
+	keys := renameHeaders(headers, rename)
+	rows := make([]orderedObject, 0, len(results))
+	for _, row := range results {
+		object := orderedObject{values: make(map[string]any)}
+		for i, header := range headers {
+			v, ok := fieldValue(row, header)
+			if !ok {
+				continue
+			}
+			if _, seen := object.values[keys[i]]; !seen {
+				object.keys = append(object.keys, keys[i])
+			}
+			object.values[keys[i]] = value(runner.Field{Name: header, Value: v})
+		}
+		rows = append(rows, object)
+	}
+	return rows
+}
+
+// Envelope is a self-describing JSON document holding a query together with its
+// time window, statistics, and results, suitable for archiving an investigation.
+type Envelope struct {
+	Query      string             `json:"query"`
+	Start      string             `json:"start"`
+	End        string             `json:"end"`
+	Statistics EnvelopeStatistics `json:"statistics"`
+	Results    []map[string]any   `json:"results"`
+}
+
+// EnvelopeStatistics holds the query statistics recorded in an Envelope.
+type EnvelopeStatistics struct {
+	BytesScanned   int64 `json:"bytes_scanned"`
+	RecordsScanned int64 `json:"records_scanned"`
+	RecordsMatched int64 `json:"records_matched"`
+}
+
+// FormatEnvelope renders the results as an indented Envelope. The start and end of
+// the window are RFC 3339 UTC timestamps, or empty when the window is unknown.
+func FormatEnvelope(results [][]runner.Field, headers []string, options FormatOptions, stats runner.QueryStatistics) (string, error) {
+	envelope := Envelope{
+		Query: options.Query,
+		Statistics: EnvelopeStatistics{
+			BytesScanned:   stats.BytesScanned,
+			RecordsScanned: stats.RecordsScanned,
+			RecordsMatched: stats.RecordsMatched,
+		},
+		Results: jsonRows(results, headers, options.Rename, options.jsonFormatter().valueFunc()),
+	}
+	if stats.StartTime != 0 || stats.EndTime != 0 {
+		envelope.Start = time.UnixMilli(stats.StartTime).UTC().Format(time.RFC3339)
+		envelope.End = time.UnixMilli(stats.EndTime).UTC().Format(time.RFC3339)
+	}
+
+	data, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to format envelope as JSON: %w", err)
+	}
+	return string(data), nil
 }
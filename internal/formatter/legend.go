@@ -0,0 +1,81 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"fli/internal/runner"
+)
+
+// annotationSuffix marks a field holding the annotation of the field it is named after.
+const annotationSuffix = "_annotation"
+
+// LegendEntry maps an annotated value, such as an IP address or ENI ID, to its annotation.
+type LegendEntry struct {
+	Value      string
+	Annotation string
+}
+
+// ExtractLegend moves inline annotations out of the results. It returns the rows
+// without their annotation fields, plus one legend entry per distinct annotated
+// value, sorted by value.
+func ExtractLegend(results [][]runner.Field) ([][]runner.Field, []LegendEntry) {
+	seen := make(map[LegendEntry]bool)
+	var legend []LegendEntry
+
+	stripped := make([][]runner.Field, len(results))
+	for i, row := range results {
+		values := make(map[string]string, len(row))
+		for _, field := range row {
+			values[field.Name] = field.Value
+		}
+
+		newRow := make([]runner.Field, 0, len(row))
+		for _, field := range row {
+			column, isAnnotation := strings.CutSuffix(field.Name, annotationSuffix)
+			if !isAnnotation {
+				newRow = append(newRow, field)
+				continue
+			}
+			value, ok := values[column]
+			if !ok || value == "" || field.Value == "" {
+				continue
+			}
+			entry := LegendEntry{Value: value, Annotation: field.Value}
+			if !seen[entry] {
+				seen[entry] = true
+				legend = append(legend, entry)
+			}
+		}
+		stripped[i] = newRow
+	}
+
+	sort.Slice(legend, func(i, j int) bool {
+		if legend[i].Value != legend[j].Value {
+			return legend[i].Value < legend[j].Value
+		}
+		return legend[i].Annotation < legend[j].Annotation
+	})
+	return stripped, legend
+}
+
+// FormatLegend renders legend entries as an aligned two-column section.
+// It returns an empty string when there are no entries.
+func FormatLegend(legend []LegendEntry) string {
+	if len(legend) == 0 {
+		return ""
+	}
+
+	width := 0
+	for _, entry := range legend {
+		width = max(width, len(entry.Value))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\nLegend:\n")
+	for _, entry := range legend {
+		fmt.Fprintf(&sb, "  %-*s  %s\n", width, entry.Value, entry.Annotation)
+	}
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package formatter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"fli/internal/runner"
+)
+
+func TestExtractLegend(t *testing.T) {
+	results := [][]runner.Field{
+		{
+			{Name: "srcaddr", Value: "10.0.0.1"},
+			{Name: "srcaddr_annotation", Value: "web"},
+			{Name: "dstaddr", Value: "1.1.1.1"},
+			{Name: "dstaddr_annotation", Value: "CLOUDFLARE"},
+		},
+		{
+			{Name: "srcaddr", Value: "1.1.1.1"},
+			{Name: "srcaddr_annotation", Value: "CLOUDFLARE"},
+			{Name: "dstaddr", Value: "10.0.0.1"},
+			{Name: "dstaddr_annotation", Value: "web"},
+		},
+		{
+			{Name: "srcaddr", Value: "10.0.0.9"},
+			{Name: "dstaddr", Value: "10.0.0.1"},
+			{Name: "dstaddr_annotation", Value: "web"},
+		},
+	}
+
+	rows, legend := ExtractLegend(results)
+
+	wantLegend := []LegendEntry{
+		{Value: "1.1.1.1", Annotation: "CLOUDFLARE"},
+		{Value: "10.0.0.1", Annotation: "web"},
+	}
+	if !reflect.DeepEqual(legend, wantLegend) {
+		t.Errorf("ExtractLegend() legend = %v, want %v", legend, wantLegend)
+	}
+
+	for i, row := range rows {
+		for _, field := range row {
+			if strings.HasSuffix(field.Name, "_annotation") {
+				t.Errorf("row %d still has annotation field %s", i, field.Name)
+			}
+		}
+		if len(row) != 2 {
+			t.Errorf("row %d has %d fields, want 2", i, len(row))
+		}
+	}
+
+	output := FormatLegend(legend)
+	for _, entry := range wantLegend {
+		if n := strings.Count(output, entry.Value+" "); n != 1 {
+			t.Errorf("expected %s listed once in legend, found %d times:\n%s", entry.Value, n, output)
+		}
+	}
+	if FormatLegend(nil) != "" {
+		t.Error("expected an empty legend for no entries")
+	}
+}
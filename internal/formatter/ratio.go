@@ -0,0 +1,50 @@
+package formatter
+
+import (
+	"fmt"
+	"strconv"
+
+	"fli/internal/runner"
+)
+
+// FanoutColumn is the column the fanout verb adds: distinct values per flow.
+const FanoutColumn = "fanout"
+
+// AddRatioColumn appends a column named name to every row holding the numerator
+// column divided by the denominator column, to three decimal places. Both columns
+// must be present and numeric in every row; a zero denominator gives 0.
+func AddRatioColumn(results [][]runner.Field, name, numerator, denominator string) ([][]runner.Field, error) {
+	withRatio := make([][]runner.Field, len(results))
+	for i, row := range results {
+		num, err := numericColumn(row, numerator)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		den, err := numericColumn(row, denominator)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+
+		ratio := 0.0
+		if den != 0 {
+			ratio = num / den
+		}
+		newRow := make([]runner.Field, len(row), len(row)+1)
+		copy(newRow, row)
+		withRatio[i] = append(newRow, runner.Field{Name: name, Value: strconv.FormatFloat(ratio, 'f', 3, 64)})
+	}
+	return withRatio, nil
+}
+
+// numericColumn returns the value of the named column in row as a number.
+func numericColumn(row []runner.Field, name string) (float64, error) {
+	value, ok := fieldValue(row, name)
+	if !ok {
+		return 0, fmt.Errorf("missing column %q", name)
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("column %q is not numeric: %q", name, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,45 @@
+package formatter
+
+import (
+	"testing"
+
+	"fli/internal/runner"
+)
+
+func TestAddRatioColumn(t *testing.T) {
+	row := func(src, distinct, flows string) []runner.Field {
+		return []runner.Field{
+			{Name: "srcaddr", Value: src},
+			{Name: "dstport_distinct", Value: distinct},
+			{Name: "flows", Value: flows},
+		}
+	}
+	results := [][]runner.Field{
+		row("10.0.0.1", "1000", "1000"), // a scanner: every flow hits a new port
+		row("10.0.0.2", "2", "800"),
+		row("10.0.0.3", "1", "3"),
+	}
+
+	got, err := AddRatioColumn(results, FanoutColumn, "dstport_distinct", "flows")
+	if err != nil {
+		t.Fatalf("AddRatioColumn() error = %v", err)
+	}
+	for i, want := range []string{"1.000", "0.003", "0.333"} {
+		last := got[i][len(got[i])-1]
+		if last.Name != FanoutColumn || last.Value != want {
+			t.Errorf("row %d fanout = %+v, want %s", i, last, want)
+		}
+	}
+	if len(results[0]) != 3 {
+		t.Errorf("AddRatioColumn() modified its input row: %v", results[0])
+	}
+
+	// Both aggregations are required in every row
+	missing := [][]runner.Field{{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "flows", Value: "3"}}}
+	if _, err := AddRatioColumn(missing, FanoutColumn, "dstport_distinct", "flows"); err == nil {
+		t.Error("AddRatioColumn() error = nil for a row without the numerator")
+	}
+	if _, err := AddRatioColumn([][]runner.Field{row("10.0.0.1", "x", "3")}, FanoutColumn, "dstport_distinct", "flows"); err == nil {
+		t.Error("AddRatioColumn() error = nil for a non-numeric value")
+	}
+}
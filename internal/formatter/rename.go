@@ -0,0 +1,41 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseRenameMap parses a comma-separated list of column=name pairs,
+// e.g. "srcaddr=Source,dstaddr=Destination".
+func ParseRenameMap(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	rename := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		column, name, ok := strings.Cut(pair, "=")
+		column, name = strings.TrimSpace(column), strings.TrimSpace(name)
+		if !ok || column == "" || name == "" {
+			return nil, fmt.Errorf("invalid rename %q: expected column=name", strings.TrimSpace(pair))
+		}
+		rename[column] = name
+	}
+	return rename, nil
+}
+
+// renameHeaders returns headers with any column listed in rename replaced by its display name.
+func renameHeaders(headers []string, rename map[string]string) []string {
+	if len(rename) == 0 {
+		return headers
+	}
+	renamed := make([]string, len(headers))
+	for i, h := range headers {
+		if name, ok := rename[h]; ok {
+			renamed[i] = name
+		} else {
+			renamed[i] = h
+		}
+	}
+	return renamed
+}
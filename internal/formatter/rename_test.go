@@ -0,0 +1,83 @@
+package formatter
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fli/internal/runner"
+)
+
+func TestParseRenameMap(t *testing.T) {
+	got, err := ParseRenameMap("srcaddr=Source, dstaddr = Destination")
+	if err != nil {
+		t.Fatalf("ParseRenameMap() error = %v", err)
+	}
+	want := map[string]string{"srcaddr": "Source", "dstaddr": "Destination"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRenameMap() = %v, want %v", got, want)
+	}
+
+	if got, err := ParseRenameMap(""); err != nil || got != nil {
+		t.Errorf("ParseRenameMap(\"\") = %v, %v; want nil, nil", got, err)
+	}
+
+	for _, bad := range []string{"srcaddr", "=Source", "srcaddr="} {
+		if _, err := ParseRenameMap(bad); err == nil {
+			t.Errorf("ParseRenameMap(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestFormatRename(t *testing.T) {
+	results := [][]runner.Field{
+		{
+			{Name: "srcaddr", Value: "10.0.0.1"},
+			{Name: "action", Value: "ACCEPT"},
+		},
+	}
+	headers := []string{"srcaddr", "action"}
+	rename := map[string]string{"srcaddr": "Source"}
+
+	t.Run("table", func(t *testing.T) {
+		output, err := Format(results, headers, FormatOptions{Format: "table", Rename: rename})
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+		header := strings.Split(output, "\n")[1]
+		if !strings.Contains(header, "Source") || strings.Contains(header, "srcaddr") {
+			t.Errorf("expected header row to show Source, got %q", header)
+		}
+		if !strings.Contains(output, "10.0.0.1") {
+			t.Errorf("expected renamed column to keep its values, got:\n%s", output)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		output, err := Format(results, headers, FormatOptions{Format: "csv", Rename: rename})
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+		if !strings.HasPrefix(output, "Source,action\n") {
+			t.Errorf("expected CSV header Source,action, got:\n%s", output)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		output, err := Format(results, headers, FormatOptions{Format: "json", Rename: rename})
+		if err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+		var rows []map[string]string
+		if err := json.Unmarshal([]byte(output), &rows); err != nil {
+			t.Fatalf("invalid JSON output: %v", err)
+		}
+		if len(rows) != 1 || rows[0]["Source"] != "10.0.0.1" {
+			t.Errorf("expected key Source=10.0.0.1, got %v", rows)
+		}
+		if _, ok := rows[0]["srcaddr"]; ok {
+			t.Errorf("expected srcaddr key to be renamed, got %v", rows)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package formatter
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"fli/internal/runner"
+)
+
+// RollupLevelColumn is the column that labels each rolled-up row with its prefix length.
+const RollupLevelColumn = "rollup"
+
+// RollupSpec describes a client-side rollup of results grouped by an IP field.
+type RollupSpec struct {
+	// Field is the group-by field holding IP addresses. An empty field disables the rollup.
+	Field string
+
+	// Prefixes lists the prefix lengths to roll up to, in output order (e.g. 24, 16).
+	Prefixes []int
+}
+
+// ParseRollupSpec parses a rollup specification of the form "field:24,16".
+func ParseRollupSpec(s string) (RollupSpec, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return RollupSpec{}, nil
+	}
+
+	field, levels, ok := strings.Cut(s, ":")
+	field = strings.TrimSpace(field)
+	if !ok || field == "" || strings.TrimSpace(levels) == "" {
+		return RollupSpec{}, fmt.Errorf("invalid rollup %q: expected field:prefix[,prefix...]", s)
+	}
+
+	spec := RollupSpec{Field: field}
+	for _, level := range strings.Split(levels, ",") {
+		bits, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(level), "/"))
+		if err != nil || bits < 0 || bits > 128 {
+			return RollupSpec{}, fmt.Errorf("invalid rollup %q: bad prefix length %q", s, strings.TrimSpace(level))
+		}
+		spec.Prefixes = append(spec.Prefixes, bits)
+	}
+	return spec, nil
+}
+
+// RollupResults aggregates results grouped by spec.Field into one set of rows per
+// prefix length. CloudWatch Logs Insights cannot group by several prefix lengths in
+// one query, so the rollup runs client-side over the per-address results.
+//
+// keys are the group-by columns; every other column is summed, so the results must
+// come from a count or sum query. Rows are emitted level by level in the order of
+// spec.Prefixes, each labeled with its level in the rollup column, and within a level
+// in the order their prefix first appears. Addresses that are not IPs, or are shorter
+// than the prefix length, are kept as they are.
+//
+// Only the returned rows are rolled up, so the query limit must be high enough to
+// cover every address of interest.
+func RollupResults(results [][]runner.Field, keys []string, spec RollupSpec) ([][]runner.Field, error) {
+	if spec.Field == "" {
+		return results, nil
+	}
+
+	isKey := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	if !isKey[spec.Field] {
+		return nil, fmt.Errorf("cannot roll up %q: not a group-by field", spec.Field)
+	}
+
+	var rolledUp [][]runner.Field
+	for _, bits := range spec.Prefixes {
+		level := fmt.Sprintf("/%d", bits)
+		index := make(map[string]int)
+		var sums [][]float64
+		var rows [][]runner.Field
+
+		for _, row := range results {
+			var groupKey strings.Builder
+			newRow := make([]runner.Field, 0, len(row)+1)
+			newRow = append(newRow, runner.Field{Name: RollupLevelColumn, Value: level})
+			var values []float64
+
+			for _, field := range row {
+				if field.Name == "@ptr" {
+					continue
+				}
+				if !isKey[field.Name] {
+					value, err := strconv.ParseFloat(field.Value, 64)
+					if err != nil {
+						return nil, fmt.Errorf("cannot roll up non-numeric %s value %q", field.Name, field.Value)
+					}
+					values = append(values, value)
+					newRow = append(newRow, field)
+					continue
+				}
+				if field.Name == spec.Field {
+					field.Value = rollupPrefix(field.Value, bits)
+				}
+				groupKey.WriteString(field.Name + "=" + field.Value + "\x00")
+				newRow = append(newRow, field)
+			}
+
+			i, ok := index[groupKey.String()]
+			if !ok {
+				index[groupKey.String()] = len(rows)
+				rows = append(rows, newRow)
+				sums = append(sums, values)
+				continue
+			}
+			if len(values) != len(sums[i]) {
+				return nil, fmt.Errorf("cannot roll up rows with different columns")
+			}
+			for j, value := range values {
+				sums[i][j] += value
+			}
+		}
+
+		for i, row := range rows {
+			j := 0
+			for k, field := range row {
+				if field.Name == RollupLevelColumn || isKey[field.Name] {
+					continue
+				}
+				row[k].Value = strconv.FormatFloat(sums[i][j], 'f', -1, 64)
+				j++
+			}
+		}
+		rolledUp = append(rolledUp, rows...)
+	}
+
+	return rolledUp, nil
+}
+
+// rollupPrefix returns the network of addr at the given prefix length, e.g.
+// 10.0.1.7 at 24 becomes 10.0.1.0/24. Values that cannot be masked are returned unchanged.
+func rollupPrefix(addr string, bits int) string {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return addr
+	}
+	prefix, err := ip.Prefix(bits)
+	if err != nil {
+		return addr
+	}
+	return prefix.String()
+}
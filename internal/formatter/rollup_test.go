@@ -0,0 +1,109 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+
+	"fli/internal/runner"
+)
+
+func TestParseRollupSpec(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    RollupSpec
+		wantErr bool
+	}{
+		{input: "", want: RollupSpec{}},
+		{input: "srcaddr:24,16", want: RollupSpec{Field: "srcaddr", Prefixes: []int{24, 16}}},
+		{input: " dstaddr : /24 , /8 ", want: RollupSpec{Field: "dstaddr", Prefixes: []int{24, 8}}},
+		{input: "srcaddr", wantErr: true},
+		{input: ":24", wantErr: true},
+		{input: "srcaddr:", wantErr: true},
+		{input: "srcaddr:24,x", wantErr: true},
+		{input: "srcaddr:129", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRollupSpec(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRollupSpec(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRollupSpec(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollupResults(t *testing.T) {
+	row := func(fields ...string) []runner.Field {
+		var r []runner.Field
+		for i := 0; i < len(fields); i += 2 {
+			r = append(r, runner.Field{Name: fields[i], Value: fields[i+1]})
+		}
+		return r
+	}
+	results := [][]runner.Field{
+		row("srcaddr", "10.0.1.7", "count", "100"),
+		row("srcaddr", "10.0.2.9", "count", "40"),
+		row("srcaddr", "10.0.1.20", "count", "25"),
+		row("srcaddr", "10.1.0.1", "count", "5"),
+	}
+
+	got, err := RollupResults(results, []string{"srcaddr"}, RollupSpec{Field: "srcaddr", Prefixes: []int{24, 16}})
+	if err != nil {
+		t.Fatalf("RollupResults() error = %v", err)
+	}
+	want := [][]runner.Field{
+		row("rollup", "/24", "srcaddr", "10.0.1.0/24", "count", "125"),
+		row("rollup", "/24", "srcaddr", "10.0.2.0/24", "count", "40"),
+		row("rollup", "/24", "srcaddr", "10.1.0.0/24", "count", "5"),
+		row("rollup", "/16", "srcaddr", "10.0.0.0/16", "count", "165"),
+		row("rollup", "/16", "srcaddr", "10.1.0.0/16", "count", "5"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RollupResults() =\n%v\nwant\n%v", got, want)
+	}
+
+	// The input rows are left untouched
+	if results[0][0].Value != "10.0.1.7" || results[0][1].Value != "100" {
+		t.Errorf("RollupResults() modified its input: %v", results[0])
+	}
+}
+
+func TestRollupResultsKeepsOtherKeys(t *testing.T) {
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.1.7"}, {Name: "action", Value: "ACCEPT"}, {Name: "sum_bytes", Value: "1.5"}},
+		{{Name: "srcaddr", Value: "10.0.1.8"}, {Name: "action", Value: "REJECT"}, {Name: "sum_bytes", Value: "2"}},
+		{{Name: "srcaddr", Value: "10.0.1.9"}, {Name: "action", Value: "ACCEPT"}, {Name: "sum_bytes", Value: "3"}},
+		{{Name: "srcaddr", Value: "-"}, {Name: "action", Value: "ACCEPT"}, {Name: "sum_bytes", Value: "7"}},
+	}
+
+	got, err := RollupResults(results, []string{"srcaddr", "action"}, RollupSpec{Field: "srcaddr", Prefixes: []int{24}})
+	if err != nil {
+		t.Fatalf("RollupResults() error = %v", err)
+	}
+	want := [][]runner.Field{
+		{{Name: "rollup", Value: "/24"}, {Name: "srcaddr", Value: "10.0.1.0/24"}, {Name: "action", Value: "ACCEPT"}, {Name: "sum_bytes", Value: "4.5"}},
+		{{Name: "rollup", Value: "/24"}, {Name: "srcaddr", Value: "10.0.1.0/24"}, {Name: "action", Value: "REJECT"}, {Name: "sum_bytes", Value: "2"}},
+		{{Name: "rollup", Value: "/24"}, {Name: "srcaddr", Value: "-"}, {Name: "action", Value: "ACCEPT"}, {Name: "sum_bytes", Value: "7"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RollupResults() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestRollupResultsErrors(t *testing.T) {
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.1.7"}, {Name: "dstaddr", Value: "10.0.2.1"}},
+	}
+	spec := RollupSpec{Field: "srcaddr", Prefixes: []int{24}}
+
+	if _, err := RollupResults(results, []string{"dstaddr"}, spec); err == nil {
+		t.Error("expected an error rolling up a field that is not grouped by")
+	}
+	if _, err := RollupResults(results, []string{"srcaddr"}, spec); err == nil {
+		t.Error("expected an error summing a non-numeric column")
+	}
+}
@@ -0,0 +1,119 @@
+package formatter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fli/internal/runner"
+)
+
+// sessionKeyColumns are the 5-tuple columns a session row keeps, with the forward
+// direction's source first.
+var sessionKeyColumns = []string{"srcaddr", "srcport", "dstaddr", "dstport", "protocol"}
+
+// sessionColumns are the columns every row must have to be sessionized.
+var sessionColumns = []string{"srcaddr", "srcport", "dstaddr", "dstport", "protocol", "bytes", "packets"}
+
+// Columns SessionizeResults adds. Forward is the direction from the session's
+// srcaddr to its dstaddr, reverse the replies.
+const (
+	SessionFlowsColumn      = "flows"
+	SessionBytesFwdColumn   = "bytes_fwd"
+	SessionPacketsFwdColumn = "packets_fwd"
+	SessionBytesRevColumn   = "bytes_rev"
+	SessionPacketsRevColumn = "packets_rev"
+)
+
+// session accumulates the flows of one bidirectional connection.
+type session struct {
+	key                  []string
+	flows                int
+	bytesFwd, packetsFwd float64
+	bytesRev, packetsRev float64
+}
+
+// SessionizeResults groups raw flow records into bidirectional sessions by their
+// 5-tuple (srcaddr, dstaddr, srcport, dstport, protocol), so a flow and its reply
+// collapse into one row. Each row holds the 5-tuple, the number of flows, and the
+// bytes and packets sent in each direction. The first flow seen for a session
+// sets its forward direction, and sessions are in the order they first appear.
+//
+// The results must include the 5-tuple, bytes, and packets columns. Values of "-",
+// which flow logs write for records without data, count as zero. Only the returned
+// rows are grouped, so the query limit must be high enough to cover the flows of
+// interest.
+func SessionizeResults(results [][]runner.Field) ([][]runner.Field, error) {
+	index := make(map[string]int)
+	var sessions []*session
+
+	for i, row := range results {
+		values := make(map[string]string, len(row))
+		for _, field := range row {
+			values[field.Name] = field.Value
+		}
+		for _, column := range sessionColumns {
+			if _, ok := values[column]; !ok {
+				return nil, fmt.Errorf("cannot sessionize: row %d has no %s column", i+1, column)
+			}
+		}
+		bytes, err := sessionCount(values, "bytes")
+		if err != nil {
+			return nil, fmt.Errorf("cannot sessionize: row %d: %w", i+1, err)
+		}
+		packets, err := sessionCount(values, "packets")
+		if err != nil {
+			return nil, fmt.Errorf("cannot sessionize: row %d: %w", i+1, err)
+		}
+
+		forward := []string{values["srcaddr"], values["srcport"], values["dstaddr"], values["dstport"], values["protocol"]}
+		reverse := []string{values["dstaddr"], values["dstport"], values["srcaddr"], values["srcport"], values["protocol"]}
+
+		if j, ok := index[strings.Join(forward, "\x00")]; ok {
+			s := sessions[j]
+			s.flows++
+			s.bytesFwd += bytes
+			s.packetsFwd += packets
+			continue
+		}
+		if j, ok := index[strings.Join(reverse, "\x00")]; ok {
+			s := sessions[j]
+			s.flows++
+			s.bytesRev += bytes
+			s.packetsRev += packets
+			continue
+		}
+		index[strings.Join(forward, "\x00")] = len(sessions)
+		sessions = append(sessions, &session{key: forward, flows: 1, bytesFwd: bytes, packetsFwd: packets})
+	}
+
+	rows := make([][]runner.Field, len(sessions))
+	for i, s := range sessions {
+		row := make([]runner.Field, 0, len(sessionKeyColumns)+5)
+		for j, column := range sessionKeyColumns {
+			row = append(row, runner.Field{Name: column, Value: s.key[j]})
+		}
+		row = append(row,
+			runner.Field{Name: SessionFlowsColumn, Value: strconv.Itoa(s.flows)},
+			runner.Field{Name: SessionBytesFwdColumn, Value: strconv.FormatFloat(s.bytesFwd, 'f', -1, 64)},
+			runner.Field{Name: SessionPacketsFwdColumn, Value: strconv.FormatFloat(s.packetsFwd, 'f', -1, 64)},
+			runner.Field{Name: SessionBytesRevColumn, Value: strconv.FormatFloat(s.bytesRev, 'f', -1, 64)},
+			runner.Field{Name: SessionPacketsRevColumn, Value: strconv.FormatFloat(s.packetsRev, 'f', -1, 64)},
+		)
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// sessionCount returns the named counter column as a number, with "-" as zero.
+func sessionCount(values map[string]string, column string) (float64, error) {
+	value := values[column]
+	if value == "-" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("non-numeric %s value %q", column, value)
+	}
+	return n, nil
+}
@@ -0,0 +1,57 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+
+	"fli/internal/runner"
+)
+
+func sessionFlow(src, srcport, dst, dstport, bytes, packets string) []runner.Field {
+	return []runner.Field{
+		{Name: "srcaddr", Value: src}, {Name: "dstaddr", Value: dst},
+		{Name: "srcport", Value: srcport}, {Name: "dstport", Value: dstport},
+		{Name: "protocol", Value: "6"}, {Name: "bytes", Value: bytes}, {Name: "packets", Value: packets},
+		{Name: "@ptr", Value: "ptr"},
+	}
+}
+
+func TestSessionizeResults(t *testing.T) {
+	results := [][]runner.Field{
+		sessionFlow("10.0.0.1", "50000", "10.0.0.2", "443", "1000", "10"),
+		sessionFlow("10.0.0.2", "443", "10.0.0.1", "50000", "5000", "8"),
+		sessionFlow("10.0.0.1", "50000", "10.0.0.2", "443", "200", "2"),
+		sessionFlow("10.0.0.3", "50001", "10.0.0.2", "443", "-", "-"),
+	}
+
+	got, err := SessionizeResults(results)
+	if err != nil {
+		t.Fatalf("SessionizeResults() error = %v", err)
+	}
+	want := [][]runner.Field{
+		{
+			{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "srcport", Value: "50000"},
+			{Name: "dstaddr", Value: "10.0.0.2"}, {Name: "dstport", Value: "443"}, {Name: "protocol", Value: "6"},
+			{Name: "flows", Value: "3"}, {Name: "bytes_fwd", Value: "1200"}, {Name: "packets_fwd", Value: "12"},
+			{Name: "bytes_rev", Value: "5000"}, {Name: "packets_rev", Value: "8"},
+		},
+		{
+			{Name: "srcaddr", Value: "10.0.0.3"}, {Name: "srcport", Value: "50001"},
+			{Name: "dstaddr", Value: "10.0.0.2"}, {Name: "dstport", Value: "443"}, {Name: "protocol", Value: "6"},
+			{Name: "flows", Value: "1"}, {Name: "bytes_fwd", Value: "0"}, {Name: "packets_fwd", Value: "0"},
+			{Name: "bytes_rev", Value: "0"}, {Name: "packets_rev", Value: "0"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SessionizeResults() =\n%v\nwant\n%v", got, want)
+	}
+
+	missing := [][]runner.Field{{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "bytes", Value: "1"}}}
+	if _, err := SessionizeResults(missing); err == nil {
+		t.Error("SessionizeResults() without the 5-tuple: error = nil, want an error")
+	}
+	bad := [][]runner.Field{sessionFlow("10.0.0.1", "1", "10.0.0.2", "2", "many", "1")}
+	if _, err := SessionizeResults(bad); err == nil {
+		t.Error("SessionizeResults() with non-numeric bytes: error = nil, want an error")
+	}
+}
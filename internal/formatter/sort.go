@@ -0,0 +1,118 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fli/internal/runner"
+)
+
+// SortSpec describes a client-side sort of formatted results on a single column.
+type SortSpec struct {
+	// Column is the name of the column to sort on. An empty column disables sorting.
+	Column string
+
+	// Descending reverses the sort order.
+	Descending bool
+
+	// TieBreak names a column that orders rows tied on Column, always ascending,
+	// so that the output does not depend on the order rows arrived in.
+	TieBreak string
+}
+
+// ParseSortSpec parses a sort specification of the form "column", "column:asc" or "column:desc".
+func ParseSortSpec(s string) (SortSpec, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return SortSpec{}, nil
+	}
+
+	column, direction, hasDirection := strings.Cut(s, ":")
+	column = strings.TrimSpace(column)
+	if column == "" {
+		return SortSpec{}, fmt.Errorf("invalid sort %q: missing column name", s)
+	}
+
+	spec := SortSpec{Column: column}
+	if hasDirection {
+		switch strings.ToLower(strings.TrimSpace(direction)) {
+		case "asc":
+		case "desc":
+			spec.Descending = true
+		default:
+			return SortSpec{}, fmt.Errorf("invalid sort %q: direction must be asc or desc", s)
+		}
+	}
+	return spec, nil
+}
+
+// SortResults returns the results stably sorted according to spec.
+// Values that parse as numbers are compared numerically, everything else is
+// compared as strings. Rows missing the column sort last in either direction,
+// and rows tied on it are ordered by spec.TieBreak when set.
+func SortResults(results [][]runner.Field, spec SortSpec) [][]runner.Field {
+	if spec.Column == "" || len(results) < 2 {
+		return results
+	}
+
+	sorted := make([][]runner.Field, len(results))
+	copy(sorted, results)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		c := compareColumn(sorted[i], sorted[j], spec.Column, spec.Descending)
+		if c == 0 && spec.TieBreak != "" {
+			c = compareColumn(sorted[i], sorted[j], spec.TieBreak, false)
+		}
+		return c < 0
+	})
+
+	return sorted
+}
+
+// fieldValue returns the value of the named field in row, and whether it was present and non-empty.
+func fieldValue(row []runner.Field, name string) (string, bool) {
+	for _, field := range row {
+		if field.Name == name {
+			return field.Value, field.Value != ""
+		}
+	}
+	return "", false
+}
+
+// compareColumn compares the named column of rows a and b, in descending order
+// if requested. A row missing the column sorts after one that has it either way.
+func compareColumn(a, b []runner.Field, column string, descending bool) int {
+	av, aok := fieldValue(a, column)
+	bv, bok := fieldValue(b, column)
+	switch {
+	case !aok && !bok:
+		return 0
+	case !aok:
+		return 1
+	case !bok:
+		return -1
+	}
+	if descending {
+		return compareValues(bv, av)
+	}
+	return compareValues(av, bv)
+}
+
+// compareValues compares a and b numerically when both are numbers and lexically otherwise.
+func compareValues(a, b string) int {
+	af, aerr := strconv.ParseFloat(a, 64)
+	bf, berr := strconv.ParseFloat(b, 64)
+	if aerr == nil && berr == nil {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
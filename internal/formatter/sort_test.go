@@ -0,0 +1,159 @@
+package formatter
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"fli/internal/runner"
+)
+
+func TestParseSortSpec(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    SortSpec
+		wantErr bool
+	}{
+		{input: "", want: SortSpec{}},
+		{input: "bytes", want: SortSpec{Column: "bytes"}},
+		{input: "bytes:asc", want: SortSpec{Column: "bytes"}},
+		{input: "bytes:DESC", want: SortSpec{Column: "bytes", Descending: true}},
+		{input: ":desc", wantErr: true},
+		{input: "bytes:sideways", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSortSpec(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSortSpec(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSortSpec(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortResults(t *testing.T) {
+	rows := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.2"}, {Name: "bytes", Value: "900"}},
+		{{Name: "srcaddr", Value: "10.0.0.10"}, {Name: "bytes", Value: "10000"}},
+		{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "bytes", Value: "85"}},
+	}
+
+	column := func(results [][]runner.Field, name string) []string {
+		var values []string
+		for _, row := range results {
+			v, _ := fieldValue(row, name)
+			values = append(values, v)
+		}
+		return values
+	}
+
+	tests := []struct {
+		name   string
+		spec   SortSpec
+		column string
+		want   []string
+	}{
+		{
+			name:   "numeric ascending",
+			spec:   SortSpec{Column: "bytes"},
+			column: "bytes",
+			want:   []string{"85", "900", "10000"},
+		},
+		{
+			name:   "numeric descending",
+			spec:   SortSpec{Column: "bytes", Descending: true},
+			column: "bytes",
+			want:   []string{"10000", "900", "85"},
+		},
+		{
+			name:   "string ascending",
+			spec:   SortSpec{Column: "srcaddr"},
+			column: "srcaddr",
+			want:   []string{"10.0.0.1", "10.0.0.10", "10.0.0.2"},
+		},
+		{
+			name:   "string descending",
+			spec:   SortSpec{Column: "srcaddr", Descending: true},
+			column: "srcaddr",
+			want:   []string{"10.0.0.2", "10.0.0.10", "10.0.0.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := column(SortResults(rows, tt.spec), tt.column)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SortResults() %s = %v, want %v", tt.column, got, tt.want)
+			}
+		})
+	}
+
+	if got := column(rows, "bytes"); !reflect.DeepEqual(got, []string{"900", "10000", "85"}) {
+		t.Errorf("SortResults() modified its input: %v", got)
+	}
+}
+
+func TestSortResultsMissingValuesLast(t *testing.T) {
+	rows := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.1"}},
+		{{Name: "srcaddr", Value: "10.0.0.2"}, {Name: "bytes", Value: "5"}},
+		{{Name: "srcaddr", Value: "10.0.0.3"}, {Name: "bytes", Value: "50"}},
+	}
+
+	for _, desc := range []bool{false, true} {
+		got := SortResults(rows, SortSpec{Column: "bytes", Descending: desc})
+		if v, _ := fieldValue(got[len(got)-1], "srcaddr"); v != "10.0.0.1" {
+			t.Errorf("descending=%v: expected row without bytes last, got %v", desc, got)
+		}
+	}
+}
+
+func TestSortResultsTieBreak(t *testing.T) {
+	rows := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.3"}, {Name: "flows", Value: "7"}},
+		{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "flows", Value: "7"}},
+		{{Name: "srcaddr", Value: "10.0.0.9"}, {Name: "flows", Value: "12"}},
+		{{Name: "srcaddr", Value: "10.0.0.2"}, {Name: "flows", Value: "7"}},
+	}
+	want := []string{"10.0.0.9", "10.0.0.1", "10.0.0.2", "10.0.0.3"}
+
+	spec := SortSpec{Column: "flows", Descending: true, TieBreak: "srcaddr"}
+	// Reversing the input must not change the output
+	reversed := slices.Clone(rows)
+	slices.Reverse(reversed)
+	for _, input := range [][][]runner.Field{rows, reversed} {
+		var got []string
+		for _, row := range SortResults(input, spec) {
+			v, _ := fieldValue(row, "srcaddr")
+			got = append(got, v)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("SortResults() order = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFormatWithSort(t *testing.T) {
+	rows := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "flows", Value: "3"}},
+		{{Name: "srcaddr", Value: "10.0.0.2"}, {Name: "flows", Value: "12"}},
+	}
+	headers := []string{"srcaddr", "flows"}
+
+	output, err := Format(rows, headers, FormatOptions{Format: "csv", Sort: SortSpec{Column: "flows", Descending: true}})
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if strings.Index(output, "10.0.0.2") > strings.Index(output, "10.0.0.1") {
+		t.Errorf("expected 10.0.0.2 first, got:\n%s", output)
+	}
+
+	if _, err := Format(rows, headers, FormatOptions{Format: "csv", Sort: SortSpec{Column: "nope"}}); err == nil {
+		t.Error("expected an error sorting on an unknown column")
+	}
+}
@@ -2,24 +2,41 @@ package formatter
 
 import (
 	"fmt"
+	"net/netip"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"fli/internal/runner"
 )
 
 // ANSI color codes.
 const (
-	colorReset = "\033[0m"
-	colorRed   = "\033[31m"
-	colorGreen = "\033[32m"
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
 )
 
 // TableFormatter formats query results as an ASCII table.
 type TableFormatter struct {
 	// MaxWidth limits the width of each column (0 for no limit)
 	MaxWidth int
+	// ColumnWidths overrides MaxWidth for the named columns (0 for no limit)
+	ColumnWidths map[string]int
 	// ColorizeAction determines if ACCEPT/REJECT actions should be colorized
 	ColorizeAction bool
+	// HighlightPrivate colors private IPs cyan and public IPs yellow when
+	// ColorizeAction is set
+	HighlightPrivate bool
+	// Rename maps column names to the display names used in the header row
+	Rename map[string]string
+	// ShowTotals appends a TOTAL row summing the additive aggregation columns
+	ShowTotals bool
+	// Humanize renders byte and duration columns in readable units; totals are
+	// summed from the raw values first
+	Humanize bool
 }
 
 // Format converts the query results into a formatted table string.
@@ -36,10 +53,12 @@ func (f TableFormatter) Format(results [][]runner.Field, headers []string) strin
 		}
 	}
 
-	// Extract values from results
+	// Extract values from results, keeping the raw values for the totals
 	rows := make([][]string, len(results))
+	rawRows := make([][]string, len(results))
 	for i, result := range results {
 		row := make([]string, len(displayHeaders))
+		rawRow := make([]string, len(displayHeaders))
 		// Create a map of fields for easier annotation lookup
 		fieldMap := make(map[string]string)
 		for _, field := range result {
@@ -67,16 +86,6 @@ func (f TableFormatter) Format(results [][]runner.Field, headers []string) strin
 					break
 				}
 
-				// Try partial match (for fields like "interface-id" vs "eni-id")
-				if (header == "eni-id" && strings.Contains(field.Name, "interface")) ||
-					(header == "interface-id" && (strings.Contains(field.Name, "eni") ||
-						field.Name == "interface_id")) {
-					value = field.Value
-					fieldName = field.Name
-					found = true
-					break
-				}
-
 				// Handle value field for stats queries
 				if header == "value" && (field.Name == "value" || field.Name == "bytes" ||
 					field.Name == "packets" || field.Name == "count") {
@@ -85,18 +94,6 @@ func (f TableFormatter) Format(results [][]runner.Field, headers []string) strin
 					found = true
 					break
 				}
-
-				// Special handling for group-by fields in stats queries
-				if field.Name == header ||
-					(field.Name == "srcaddr" && header == "srcAddr") ||
-					(field.Name == "dstaddr" && header == "dstAddr") ||
-					(field.Name == "srcport" && header == "srcPort") ||
-					(field.Name == "dstport" && header == "dstPort") {
-					value = field.Value
-					fieldName = field.Name
-					found = true
-					break
-				}
 			}
 
 			// If no match found, leave empty
@@ -114,24 +111,45 @@ func (f TableFormatter) Format(results [][]runner.Field, headers []string) strin
 				annotation = anno
 			}
 
-			if annotation != "" {
-				row[j] = fmt.Sprintf("%s [%s]", value, annotation)
-			} else {
-				row[j] = value
+			rawRow[j] = withAnnotation(value, annotation)
+			if f.Humanize {
+				if humanize, ok := humanizer(fieldName); ok {
+					value = humanize(value)
+				}
 			}
+			row[j] = withAnnotation(value, annotation)
 		}
 		rows[i] = row
+		rawRows[i] = rawRow
+	}
+
+	var totals []string
+	if f.ShowTotals {
+		totals = totalsRow(rawRows, displayHeaders)
+	}
+	if totals != nil && f.Humanize {
+		for i, header := range displayHeaders {
+			if humanize, ok := humanizer(header); ok && totals[i] != "" {
+				totals[i] = humanize(totals[i])
+			}
+		}
 	}
 
-	// Calculate column widths
-	widths := f.calculateColumnWidths(rows, displayHeaders)
+	// Calculate column widths, making room for the totals
+	widthRows := rows
+	if totals != nil {
+		widthRows = append(rows[:len(rows):len(rows)], totals)
+	}
+	limits := f.columnLimits(displayHeaders)
+	widths := f.calculateColumnWidths(widthRows, renameHeaders(displayHeaders, f.Rename), limits)
 
 	// Build the table
 	var sb strings.Builder
 
 	// Write header
 	f.writeSeparator(&sb, widths)
-	f.writeRow(&sb, displayHeaders, widths, -1) // -1 indicates this is a header row
+	// Headers are never truncated, and -1 indicates this is a header row
+	f.writeRow(&sb, renameHeaders(displayHeaders, f.Rename), widths, make([]int, len(displayHeaders)), -1)
 	f.writeSeparator(&sb, widths)
 
 	// Write data rows
@@ -145,22 +163,106 @@ func (f TableFormatter) Format(results [][]runner.Field, headers []string) strin
 			}
 		}
 
-		f.writeRow(&sb, row, widths, actionIndex)
+		f.writeRow(&sb, row, widths, limits, actionIndex)
 	}
 
 	// Write final separator
 	f.writeSeparator(&sb, widths)
 
+	if totals != nil {
+		f.writeRow(&sb, totals, widths, limits, -1)
+		f.writeSeparator(&sb, widths)
+	}
+
 	return sb.String()
 }
 
-// calculateColumnWidths determines the width needed for each column.
-func (f TableFormatter) calculateColumnWidths(rows [][]string, headers []string) []int {
+// withAnnotation appends annotation to value in brackets, if there is one.
+func withAnnotation(value, annotation string) string {
+	if annotation == "" {
+		return value
+	}
+	return fmt.Sprintf("%s [%s]", value, annotation)
+}
+
+// totalsRow returns the TOTAL row for rows: the sum of every additive
+// aggregation column, the TOTAL label in the first column that is not summed,
+// and blanks elsewhere. It returns nil when no column can be summed.
+func totalsRow(rows [][]string, headers []string) []string {
+	totals := make([]string, len(headers))
+	summed := false
+	for i, header := range headers {
+		if !isAdditiveColumn(header) {
+			continue
+		}
+		if total, ok := sumColumn(rows, i); ok {
+			totals[i] = total
+			summed = true
+		}
+	}
+	if !summed {
+		return nil
+	}
+	for i, header := range headers {
+		if !isAdditiveColumn(header) {
+			totals[i] = "TOTAL"
+			break
+		}
+	}
+	return totals
+}
+
+// isAdditiveColumn reports whether a column holds an aggregation whose values
+// can be meaningfully added up: sums and counts, including the flows count.
+// Averages, extremes, percentiles and distinct counts are not.
+func isAdditiveColumn(header string) bool {
+	return header == "flows" || strings.HasSuffix(header, "_sum") || strings.HasSuffix(header, "_count")
+}
+
+// sumColumn adds up column i of rows. It reports false when any value is not a
+// number, e.g. because it was annotated.
+func sumColumn(rows [][]string, i int) (string, bool) {
+	var total float64
+	integral := true
+	for _, row := range rows {
+		value := row[i]
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", false
+		}
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			integral = false
+		}
+		total += n
+	}
+	if integral {
+		return strconv.FormatFloat(total, 'f', 0, 64), true
+	}
+	return strconv.FormatFloat(total, 'f', -1, 64), true
+}
+
+// columnLimits returns the maximum width of each column: its ColumnWidths entry
+// if it has one, otherwise MaxWidth. A limit of 0 means no limit.
+func (f TableFormatter) columnLimits(headers []string) []int {
+	limits := make([]int, len(headers))
+	for i, header := range headers {
+		if width, ok := f.ColumnWidths[header]; ok {
+			limits[i] = width
+		} else {
+			limits[i] = f.MaxWidth
+		}
+	}
+	return limits
+}
+
+// calculateColumnWidths determines the width needed for each column, capped at
+// its limit.
+func (f TableFormatter) calculateColumnWidths(rows [][]string, headers []string, limits []int) []int {
 	widths := make([]int, len(headers))
 
 	// Start with header widths
 	for i, header := range headers {
-		widths[i] = len(header)
+		widths[i] = utf8.RuneCountInString(header)
 	}
 
 	// Check data widths
@@ -169,9 +271,9 @@ func (f TableFormatter) calculateColumnWidths(rows [][]string, headers []string)
 			if i >= len(widths) {
 				continue
 			}
-			width := len(value)
-			if f.MaxWidth > 0 && width > f.MaxWidth {
-				width = f.MaxWidth
+			width := utf8.RuneCountInString(value)
+			if limits[i] > 0 && width > limits[i] {
+				width = limits[i]
 			}
 			if width > widths[i] {
 				widths[i] = width
@@ -192,43 +294,49 @@ func (f TableFormatter) writeSeparator(sb *strings.Builder, widths []int) {
 	sb.WriteString("\n")
 }
 
+// truncate shortens value to limit characters, marking the cut with "..." when
+// there is room for it. Characters are counted as runes, so a multi-byte
+// character is never split. A limit of 0 means no limit.
+func truncate(value string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(value) <= limit {
+		return value
+	}
+	runes := []rune(value)
+	if limit <= 3 {
+		return string(runes[:limit])
+	}
+	return string(runes[:limit-3]) + "..."
+}
+
 // writeRow writes a single row of data.
-// actionIndex is the index of the action column, or -1 if not applicable.
-func (f TableFormatter) writeRow(sb *strings.Builder, values []string, widths []int, actionIndex int) {
+// limits holds the maximum width of each column, and actionIndex is the index
+// of the action column, or -1 if not applicable.
+func (f TableFormatter) writeRow(sb *strings.Builder, values []string, widths, limits []int, actionIndex int) {
 	sb.WriteString("|")
 	for i, value := range values {
 		if i >= len(widths) {
 			continue
 		}
 
-		// Truncate if needed
-		if f.MaxWidth > 0 && len(value) > f.MaxWidth {
-			value = value[:f.MaxWidth-3] + "..."
+		// Pick the color from the full value, then truncate if needed
+		color := ""
+		if f.ColorizeAction {
+			color = f.cellColor(value, i == actionIndex)
 		}
+		value = truncate(value, limits[i])
 
 		// Start cell
 		sb.WriteString(" ")
-
-		// Apply color if this is the action column and colorization is enabled
-		if f.ColorizeAction && i == actionIndex {
-			switch strings.ToUpper(value) {
-			case "ACCEPT":
-				sb.WriteString(colorGreen)
-				sb.WriteString(value)
-				sb.WriteString(colorReset)
-			case "REJECT":
-				sb.WriteString(colorRed)
-				sb.WriteString(value)
-				sb.WriteString(colorReset)
-			default:
-				sb.WriteString(value)
-			}
+		if color != "" {
+			sb.WriteString(color)
+			sb.WriteString(value)
+			sb.WriteString(colorReset)
 		} else {
 			sb.WriteString(value)
 		}
 
 		// Pad with spaces
-		padding := widths[i] - len(value)
+		padding := widths[i] - utf8.RuneCountInString(value)
 		if padding > 0 {
 			sb.WriteString(strings.Repeat(" ", padding))
 		}
@@ -236,3 +344,54 @@ func (f TableFormatter) writeRow(sb *strings.Builder, values []string, widths []
 	}
 	sb.WriteString("\n")
 }
+
+// cellColor returns the ANSI color for a cell, or "" to leave it uncolored:
+// green or red for ACCEPT or REJECT in the action column, and with
+// HighlightPrivate, cyan for private IPs and yellow for public ones. An IP may
+// be followed by a merged annotation, as in "10.0.0.5 [api]".
+func (f TableFormatter) cellColor(value string, isAction bool) string {
+	if isAction {
+		switch strings.ToUpper(value) {
+		case "ACCEPT":
+			return colorGreen
+		case "REJECT":
+			return colorRed
+		}
+		return ""
+	}
+	if !f.HighlightPrivate {
+		return ""
+	}
+	ip, _, _ := strings.Cut(value, " ")
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	if addr.IsPrivate() {
+		return colorCyan
+	}
+	return colorYellow
+}
+
+// ParseColumnWidths parses a comma-separated list of column=width pairs, e.g.
+// "srcaddr=0,log_status=6". A width of 0 shows the column in full.
+func ParseColumnWidths(s string) (map[string]int, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	widths := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		column, value, ok := strings.Cut(pair, "=")
+		column, value = strings.TrimSpace(column), strings.TrimSpace(value)
+		if !ok || column == "" || value == "" {
+			return nil, fmt.Errorf("invalid column width %q: expected column=width", strings.TrimSpace(pair))
+		}
+		width, err := strconv.Atoi(value)
+		if err != nil || width < 0 {
+			return nil, fmt.Errorf("invalid column width %q: width must be a non-negative integer", strings.TrimSpace(pair))
+		}
+		widths[column] = width
+	}
+	return widths, nil
+}
@@ -0,0 +1,45 @@
+package formatter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// tcpFlagsColumn is the flow log field holding the TCP flags bitmask (v3 and later).
+const tcpFlagsColumn = "tcp_flags"
+
+// tcpFlagNames are the TCP flags flow logs record, by bit, lowest first.
+var tcpFlagNames = []struct {
+	bit  int
+	name string
+}{
+	{1, "FIN"},
+	{2, "SYN"},
+	{4, "RST"},
+	{8, "PSH"},
+	{16, "ACK"},
+	{32, "URG"},
+}
+
+// DecodeTCPFlags renders a tcp_flags bitmask as the flags it sets, lowest bit
+// first, e.g. "18" as "SYN,ACK". Flow logs OR the flags of every packet in the
+// aggregation interval, so a whole connection can show as "FIN,SYN,ACK". Values
+// that are not a bitmask of the known flags, including 0 and "-", are returned
+// unchanged and ok is false.
+func DecodeTCPFlags(value string) (decoded string, ok bool) {
+	mask, err := strconv.Atoi(value)
+	if err != nil || mask <= 0 {
+		return value, false
+	}
+	var names []string
+	for _, flag := range tcpFlagNames {
+		if mask&flag.bit != 0 {
+			names = append(names, flag.name)
+			mask &^= flag.bit
+		}
+	}
+	if mask != 0 {
+		return value, false
+	}
+	return strings.Join(names, ","), true
+}
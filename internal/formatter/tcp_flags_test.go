@@ -0,0 +1,46 @@
+package formatter
+
+import (
+	"testing"
+
+	"fli/internal/runner"
+)
+
+func TestDecodeTCPFlags(t *testing.T) {
+	tests := map[string]string{
+		"2":  "SYN",
+		"18": "SYN,ACK",
+		"16": "ACK",
+		"1":  "FIN",
+		"4":  "RST",
+		"19": "FIN,SYN,ACK",
+		"24": "PSH,ACK",
+		"63": "FIN,SYN,RST,PSH,ACK,URG",
+	}
+	for value, want := range tests {
+		if got, ok := DecodeTCPFlags(value); !ok || got != want {
+			t.Errorf("DecodeTCPFlags(%q) = %q, %v, want %q", value, got, ok, want)
+		}
+	}
+
+	for _, value := range []string{"0", "-", "", "SYN", "64", "-2"} {
+		if got, ok := DecodeTCPFlags(value); ok || got != value {
+			t.Errorf("DecodeTCPFlags(%q) = %q, %v, want it unchanged", value, got, ok)
+		}
+	}
+}
+
+func TestFormatDecodeTCPFlags(t *testing.T) {
+	results := [][]runner.Field{
+		{{Name: "srcaddr", Value: "10.0.0.1"}, {Name: "tcp_flags", Value: "18"}},
+	}
+
+	decoded := processResults(results, FormatOptions{DecodeTCPFlags: true})
+	if got := decoded[0][1].Value; got != "SYN,ACK" {
+		t.Errorf("tcp_flags with DecodeTCPFlags = %q, want SYN,ACK", got)
+	}
+	raw := processResults(results, FormatOptions{})
+	if got := raw[0][1].Value; got != "18" {
+		t.Errorf("tcp_flags without DecodeTCPFlags = %q, want 18", got)
+	}
+}
@@ -0,0 +1,83 @@
+package formatter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maskVisible is the number of trailing characters MaskValue leaves readable.
+const maskVisible = 4
+
+// defaultTruncateLength is the length the truncate transformer keeps when none is given.
+const defaultTruncateLength = 12
+
+// MaskValue replaces all but the last four characters of s with '*', e.g.
+// 123456789012 becomes ********9012. Values of four characters or fewer are
+// masked entirely.
+func MaskValue(s string) string {
+	runes := []rune(s)
+	if len(runes) <= maskVisible {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-maskVisible) + string(runes[len(runes)-maskVisible:])
+}
+
+// TruncateValue returns a transformer that shortens values longer than n characters
+// to their first n characters followed by "...".
+func TruncateValue(n int) func(string) string {
+	return func(s string) string {
+		runes := []rune(s)
+		if len(runes) <= n {
+			return s
+		}
+		return string(runes[:n]) + "..."
+	}
+}
+
+// ParseTransformers parses a comma-separated list of column=transformer pairs,
+// e.g. "account_id=mask,interface_id=truncate:8". The built-in transformers are
+// mask and truncate[:n].
+func ParseTransformers(s string) (map[string]func(string) string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	transformers := make(map[string]func(string) string)
+	for _, pair := range strings.Split(s, ",") {
+		column, spec, ok := strings.Cut(pair, "=")
+		column, spec = strings.TrimSpace(column), strings.TrimSpace(spec)
+		if !ok || column == "" || spec == "" {
+			return nil, fmt.Errorf("invalid transform %q: expected column=transformer", strings.TrimSpace(pair))
+		}
+		fn, err := parseTransformer(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform for %q: %w", column, err)
+		}
+		transformers[column] = fn
+	}
+	return transformers, nil
+}
+
+// parseTransformer returns the built-in transformer named by spec.
+func parseTransformer(spec string) (func(string) string, error) {
+	name, arg, hasArg := strings.Cut(strings.ToLower(spec), ":")
+	switch name {
+	case "mask":
+		if hasArg {
+			return nil, fmt.Errorf("mask takes no argument")
+		}
+		return MaskValue, nil
+	case "truncate":
+		n := defaultTruncateLength
+		if hasArg {
+			var err error
+			if n, err = strconv.Atoi(arg); err != nil || n <= 0 {
+				return nil, fmt.Errorf("truncate length must be a positive integer, got %q", arg)
+			}
+		}
+		return TruncateValue(n), nil
+	default:
+		return nil, fmt.Errorf("unknown transformer %q (want mask or truncate[:n])", spec)
+	}
+}
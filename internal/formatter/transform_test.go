@@ -0,0 +1,78 @@
+package formatter
+
+import (
+	"strings"
+	"testing"
+
+	"fli/internal/runner"
+)
+
+func TestMaskValue(t *testing.T) {
+	tests := map[string]string{
+		"123456789012": "********9012",
+		"1234":         "****",
+		"":             "",
+	}
+	for input, want := range tests {
+		if got := MaskValue(input); got != want {
+			t.Errorf("MaskValue(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestTruncateValue(t *testing.T) {
+	truncate := TruncateValue(8)
+	if got := truncate("eni-0123456789abcdef0"); got != "eni-0123..." {
+		t.Errorf("truncate = %q, want eni-0123...", got)
+	}
+	if got := truncate("eni-0123"); got != "eni-0123" {
+		t.Errorf("truncate = %q, want value unchanged", got)
+	}
+}
+
+func TestParseTransformers(t *testing.T) {
+	transformers, err := ParseTransformers("account_id=mask, interface_id = truncate:8")
+	if err != nil {
+		t.Fatalf("ParseTransformers() error = %v", err)
+	}
+	if got := transformers["account_id"]("123456789012"); got != "********9012" {
+		t.Errorf("account_id transformer = %q, want masked", got)
+	}
+	if got := transformers["interface_id"]("eni-0123456789abcdef0"); got != "eni-0123..." {
+		t.Errorf("interface_id transformer = %q, want truncated", got)
+	}
+
+	if got, err := ParseTransformers(""); err != nil || got != nil {
+		t.Errorf("ParseTransformers(\"\") = %v, %v; want nil, nil", got, err)
+	}
+
+	for _, bad := range []string{"account_id", "=mask", "account_id=hash", "account_id=mask:2", "interface_id=truncate:0"} {
+		if _, err := ParseTransformers(bad); err == nil {
+			t.Errorf("ParseTransformers(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestFormatTransformers(t *testing.T) {
+	results := [][]runner.Field{
+		{
+			{Name: "account_id", Value: "123456789012"},
+			{Name: "srcaddr", Value: "10.0.0.1"},
+		},
+	}
+	options := FormatOptions{
+		Format:       "csv",
+		Transformers: map[string]func(string) string{"account_id": MaskValue},
+	}
+
+	output, err := Format(results, []string{"account_id", "srcaddr"}, options)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(output, "********9012,10.0.0.1") {
+		t.Errorf("expected masked account_id and untouched srcaddr, got:\n%s", output)
+	}
+	if strings.Contains(output, "123456789012") {
+		t.Errorf("expected the account ID to be masked, got:\n%s", output)
+	}
+}
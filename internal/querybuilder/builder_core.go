@@ -3,35 +3,80 @@ package querybuilder
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
+// timestampField is the event time Insights records for every log event.
+const timestampField = "@timestamp"
+
 // AggregationField represents a field with its aggregation verb.
 type AggregationField struct {
 	Field string
 	Verb  Verb
+	// Arg is the verb's argument: the percentile (0-100] for VerbPct, unused otherwise.
+	Arg float64
 }
 
-// getAlias returns the alias for this aggregation field.
-func (af AggregationField) getAlias() string {
+// Alias returns the column name the aggregation is reported under, e.g. flows
+// for count(*) or bytes_sum.
+func (af AggregationField) Alias() string {
 	statFn := verbToStat[af.Verb]
 	// Special case for count(*) - use "flows" alias
 	if af.Field == "*" && af.Verb == VerbCount {
 		return "flows"
 	}
+	// Percentiles are named after the percentile, e.g. bytes_p95 or bytes_p99_9
+	if af.Verb == VerbPct {
+		return fmt.Sprintf("%s_p%s", af.Field, strings.ReplaceAll(af.percentile(), ".", "_"))
+	}
+	if af.Verb == VerbCountDistinct {
+		return af.Field + "_distinct"
+	}
 	return fmt.Sprintf("%s_%s", af.Field, statFn)
 }
 
+// percentileArg returns the percentile of a VerbPct aggregation, applying the default.
+func (af AggregationField) percentileArg() float64 {
+	if af.Arg == 0 {
+		return DefaultPercentile
+	}
+	return af.Arg
+}
+
+// percentile returns the percentile argument of a VerbPct aggregation as a string.
+func (af AggregationField) percentile() string {
+	return strconv.FormatFloat(af.percentileArg(), 'f', -1, 64)
+}
+
+// statCall renders the aggregation function call over expr, e.g. sum(bytes) or pct(bytes, 95).
+func (af AggregationField) statCall(expr string) string {
+	if af.Verb == VerbPct {
+		return fmt.Sprintf("pct(%s, %s)", expr, af.percentile())
+	}
+	return fmt.Sprintf("%s(%s)", verbToStat[af.Verb], expr)
+}
+
 // Builder constructs CloudWatch Logs Insights queries.
 type Builder struct {
 	aggregations  []AggregationField
 	fields        []string // For raw verb
 	pendingFields []string // Fields set by WithFields but not yet used
 	groupBy       []string
+	timeBin       time.Duration // Zero means no bin() grouping
 	limit         int
+	sortOrder     SortOrder
+	sortField     string // Empty means the primary aggregation alias
+	tieBreak      bool   // Break sort ties on a group-by field
 	filters       []Expr
+	dedup         []string // Raw verb only
+	parseFields   []string // Fields to limit the parse to; see WithParseFields
 	version       int
 	schema        Schema
+	warnings      []string
 }
 
 // New creates a new Builder with the given options.
@@ -48,9 +93,89 @@ func New(schema Schema, opts ...Option) (*Builder, error) {
 			return nil, err
 		}
 	}
+	if err := b.validateSortField(); err != nil {
+		return nil, err
+	}
+	if err := b.validateDedup(); err != nil {
+		return nil, err
+	}
+	if err := b.validateParseFields(); err != nil {
+		return nil, err
+	}
 	return b, nil
 }
 
+// validateParseFields checks the fields set by WithParseFields against the final
+// version. Like validateSortField, it runs after all options are applied.
+func (b *Builder) validateParseFields() error {
+	for _, field := range b.parseFields {
+		if field == "*" || b.schema.ValidateField(field, b.version) != nil {
+			return fmt.Errorf("invalid parse field '%s' for version %d", field, b.version)
+		}
+	}
+	return nil
+}
+
+// validateDedup checks the fields set by WithDedup against the final version.
+// Like validateSortField, it runs after all options are applied.
+func (b *Builder) validateDedup() error {
+	for _, field := range b.dedup {
+		if err := b.schema.ValidateField(field, b.version); err != nil {
+			return fmt.Errorf("invalid dedup field '%s': %w", field, err)
+		}
+	}
+	return nil
+}
+
+// validateSortField checks that a sort field set by WithSortField names a column
+// of the stats output, i.e. an aggregation alias or a group-by field, or for a raw
+// query a field of the schema or @timestamp. It runs after all options are applied
+// so that option order does not matter.
+func (b *Builder) validateSortField() error {
+	if b.sortField == "" {
+		return nil
+	}
+	if len(b.aggregations) == 0 {
+		if b.sortField == timestampField {
+			return nil
+		}
+		if b.sortField == "*" || b.schema.ValidateField(b.sortField, b.version) != nil {
+			return fmt.Errorf("invalid sort field '%s': not a field of version %d or %s",
+				b.sortField, b.version, timestampField)
+		}
+		return nil
+	}
+	outputs := make([]string, 0, len(b.aggregations)+len(b.groupBy))
+	for _, agg := range b.aggregations {
+		outputs = append(outputs, agg.Alias())
+	}
+	outputs = append(outputs, b.groupBy...)
+	if !slices.Contains(outputs, b.sortField) {
+		return fmt.Errorf("sort field '%s' is not in the stats output (available: %s)",
+			b.sortField, strings.Join(outputs, ", "))
+	}
+	return nil
+}
+
+// sortColumn returns the stats output column that results are sorted by.
+func (b *Builder) sortColumn() string {
+	if b.sortField != "" {
+		return b.sortField
+	}
+	return b.aggregations[0].Alias()
+}
+
+// tieBreakColumn returns the group-by field that breaks ties in the sort column,
+// the first one other than the sort column itself, or "" when there is none.
+func (b *Builder) tieBreakColumn() string {
+	for _, field := range b.groupBy {
+		if field != b.sortColumn() {
+			return field
+		}
+	}
+	return ""
+}
+
 // handleRawVerb sets up the builder for raw verb operations.
 func (b *Builder) handleRawVerb() {
 	// For raw verb, clear aggregations and set up for fields
@@ -77,11 +202,28 @@ func (b *Builder) handleAggregationVerb(v Verb) {
 }
 
 var verbToStat = map[Verb]string{
-	VerbCount: "count",
-	VerbSum:   "sum",
-	VerbAvg:   "avg",
-	VerbMin:   "min",
-	VerbMax:   "max",
+	VerbCount:         "count",
+	VerbSum:           "sum",
+	VerbAvg:           "avg",
+	VerbMin:           "min",
+	VerbMax:           "max",
+	VerbPct:           "pct",
+	VerbCountDistinct: "count_distinct",
+}
+
+// Warnings returns non-fatal adjustments made while building the query,
+// such as downgrading an unsupported version.
+func (b Builder) Warnings() []string {
+	return append([]string(nil), b.warnings...)
+}
+
+// Fields returns the fields selected by a raw query, in the order they were requested.
+// It returns nil for aggregation queries and for raw queries that select all fields.
+func (b Builder) Fields() []string {
+	if len(b.aggregations) > 0 || len(b.fields) == 0 || b.fields[0] == "*" {
+		return nil
+	}
+	return append([]string(nil), b.fields...)
 }
 
 // String returns the query string.
@@ -90,7 +232,7 @@ func (b Builder) String() string {
 	var parts []string
 
 	// Start with the 'parse' statement.
-	parsePattern, err := b.schema.GetParsePattern(b.version)
+	parsePattern, err := b.parsePattern()
 	if err != nil {
 		// This should not happen if validation is done in New().
 		// Return an empty string or handle error appropriately.
@@ -118,12 +260,114 @@ func (b Builder) String() string {
 		}
 	}
 
+	// Add 'sort' for a raw query sorted by a field; by default Insights returns
+	// the most recent events first.
+	if len(b.aggregations) == 0 && b.sortField != "" {
+		parts = append(parts, "sort "+b.sortField+" "+b.sortOrder.String())
+	}
+
+	// Add 'dedup' to collapse duplicate rows of a raw query.
+	if len(b.aggregations) == 0 && len(b.dedup) > 0 {
+		parts = append(parts, "dedup "+strings.Join(b.dedup, ", "))
+	}
+
 	// Add 'limit'.
 	if b.limit > 0 {
 		parts = append(parts, fmt.Sprintf("limit %d", b.limit))
 	}
 
-	return strings.Join(parts, " | ")
+	// Skip empty stages (e.g. a schema with no parse pattern) so the query
+	// never starts with, or contains, a dangling pipe.
+	stages := parts[:0]
+	for _, part := range parts {
+		if part != "" {
+			stages = append(stages, part)
+		}
+	}
+	return strings.Join(stages, " | ")
+}
+
+// parsePattern returns the 'parse' statement: the schema's full pattern, or one
+// limited to the fields the query reads when the query names its fields (a raw
+// query with WithFields, or WithParseFields) and the schema can narrow it.
+func (b Builder) parsePattern() (string, error) {
+	parser, ok := b.schema.(FieldParser)
+	fields := b.parsedFields()
+	if !ok || len(fields) == 0 || len(fields) == len(b.schema.Fields(b.version)) {
+		return b.schema.GetParsePattern(b.version)
+	}
+	return parser.GetFieldsParsePattern(b.version, fields)
+}
+
+// parsedFields returns the parsed fields the query reads, or nil when it may read
+// any of them. The fields named by WithParseFields, or for a raw query by
+// WithFields, are joined by the fields its aggregations, grouping, filters,
+// dedup, and sort use; computed fields stand for the fields in their expression.
+func (b Builder) parsedFields() []string {
+	var named []string
+	switch {
+	case len(b.parseFields) > 0:
+		named = slices.Clone(b.parseFields)
+	case len(b.aggregations) == 0 && len(b.fields) > 0 && b.fields[0] != "*":
+		named = slices.Clone(b.fields)
+	default:
+		return nil
+	}
+
+	for _, agg := range b.aggregations {
+		named = append(named, agg.Field)
+	}
+	named = append(named, b.groupBy...)
+	for _, filter := range b.filters {
+		named = append(named, filterFields(filter)...)
+	}
+	named = append(named, b.dedup...)
+	named = append(named, b.sortField)
+
+	parsed := b.schema.Fields(b.version)
+	var fields []string
+	for _, field := range named {
+		candidates := []string{field}
+		if expr := b.schema.GetComputedFieldExpression(field, b.version); expr != "" {
+			candidates = strings.FieldsFunc(expr, func(r rune) bool {
+				return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+		}
+		for _, candidate := range candidates {
+			if slices.Contains(parsed, candidate) && !slices.Contains(fields, candidate) {
+				fields = append(fields, candidate)
+			}
+		}
+	}
+	return fields
+}
+
+// filterFields returns the fields a filter expression compares.
+func filterFields(e Expr) []string {
+	switch x := e.(type) {
+	case *And:
+		return filterListFields(*x)
+	case *Or:
+		return filterListFields(*x)
+	case *NotExpr:
+		return filterFields(x.Expr)
+	case *IsPresent:
+		return []string{x.Field}
+	case *FieldCompare:
+		return []string{x.Field, x.Other}
+	case FieldValueExpr:
+		return []string{x.GetField()}
+	}
+	return nil
+}
+
+// filterListFields returns the fields the expressions of an And or Or compare.
+func filterListFields(exprs []Expr) []string {
+	var fields []string
+	for _, e := range exprs {
+		fields = append(fields, filterFields(e)...)
+	}
+	return fields
 }
 
 // buildStatsAndSortClauses constructs the 'stats' and 'sort' parts of the query.
@@ -136,24 +380,23 @@ func (b *Builder) buildStatsAndSortClauses() (string, string) {
 	// Build stats clause for multiple aggregations
 	var stats []string
 	for _, agg := range b.aggregations {
-		statFn := verbToStat[agg.Verb]
-		alias := agg.getAlias()
+		alias := agg.Alias()
 
 		// Handle computed fields
 		computedExpr := b.schema.GetComputedFieldExpression(agg.Field, b.version)
 		if computedExpr != "" {
 			// Use the computed field expression
-			stats = append(stats, fmt.Sprintf("%s(%s) as %s", statFn, computedExpr, alias))
+			stats = append(stats, fmt.Sprintf("%s as %s", agg.statCall(computedExpr), alias))
 		} else {
 			// Use the field name directly
-			stats = append(stats, fmt.Sprintf("%s(%s) as %s", statFn, agg.Field, alias))
+			stats = append(stats, fmt.Sprintf("%s as %s", agg.statCall(agg.Field), alias))
 		}
 	}
 
 	statsClause := "stats " + strings.Join(stats, ", ")
 
 	// Add grouping if specified
-	if len(b.groupBy) > 0 {
+	if len(b.groupBy) > 0 || b.timeBin > 0 {
 		groupByExpressions := b.buildGroupByExpressions()
 		var sb strings.Builder
 		sb.WriteString(statsClause)
@@ -162,20 +405,28 @@ func (b *Builder) buildStatsAndSortClauses() (string, string) {
 		statsClause = sb.String()
 	}
 
-	// Sort by first aggregation field (primary field sorting)
-	primaryAlias := b.aggregations[0].getAlias()
-	sortClause := "sort " + primaryAlias + " desc"
+	// Sort by the requested field, or by the first aggregation (primary field sorting)
+	sortClause := "sort " + b.sortColumn() + " " + b.sortOrder.String()
+	if b.tieBreak {
+		if key := b.tieBreakColumn(); key != "" {
+			sortClause += ", " + key + " asc"
+		}
+	}
 
 	return statsClause, sortClause
 }
 
 // buildGroupByExpressions constructs the group by expressions, handling computed fields.
 func (b *Builder) buildGroupByExpressions() string {
-	if len(b.groupBy) == 0 {
+	if len(b.groupBy) == 0 && b.timeBin == 0 {
 		return ""
 	}
 
 	var groupByExpressions []string
+	// The time bin comes first so each series is ordered by time within its group
+	if b.timeBin > 0 {
+		groupByExpressions = append(groupByExpressions, "bin("+formatBinDuration(b.timeBin)+")")
+	}
 	for _, field := range b.groupBy {
 		// Check if this is a computed field
 		computedExpr := b.schema.GetComputedFieldExpression(field, b.version)
@@ -213,3 +464,27 @@ func (b *Builder) buildDisplayClause() string {
 
 	return "display " + strings.Join(fieldExpressions, ", ")
 }
+
+// binUnits lists the CloudWatch Logs Insights bin() units from largest to smallest.
+var binUnits = []struct {
+	unit string
+	size time.Duration
+}{
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+	{"ms", time.Millisecond},
+}
+
+// formatBinDuration renders d in bin() syntax using the largest unit that divides
+// it evenly, e.g. 5m for 5 minutes and 90s for a minute and a half. d must be a
+// positive whole number of milliseconds.
+func formatBinDuration(d time.Duration) string {
+	for _, u := range binUnits {
+		if d%u.size == 0 {
+			return strconv.FormatInt(int64(d/u.size), 10) + u.unit
+		}
+	}
+	return ""
+}
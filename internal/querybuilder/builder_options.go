@@ -3,6 +3,8 @@ package querybuilder
 
 import (
 	"fmt"
+	"strings"
+	"time"
 )
 
 // Option is a function that configures a Builder.
@@ -46,6 +48,22 @@ func WithFields(fields ...string) Option {
 	}
 }
 
+// WithParseFields limits the 'parse' statement to the given fields and those the
+// query's aggregations, grouping, filters, dedup, and sort use, when the schema
+// implements FieldParser. Raw queries with WithFields are limited to their fields
+// this way without it.
+func WithParseFields(fields ...string) Option {
+	return func(b *Builder) error {
+		for _, field := range fields {
+			if strings.TrimSpace(field) == "" {
+				return fmt.Errorf("parse field cannot be empty")
+			}
+		}
+		b.parseFields = fields
+		return nil
+	}
+}
+
 // WithAggregations sets multiple aggregation fields.
 func WithAggregations(aggregations ...AggregationField) Option {
 	return func(b *Builder) error {
@@ -53,7 +71,10 @@ func WithAggregations(aggregations ...AggregationField) Option {
 			if err := b.schema.ValidateField(agg.Field, b.version); err != nil {
 				return fmt.Errorf("invalid field '%s': %w", agg.Field, err)
 			}
-			if agg.Verb != VerbCount && !b.schema.IsNumeric(agg.Field) {
+			if agg.Verb == VerbCountDistinct && agg.Field == "*" {
+				return fmt.Errorf("verb '%s' requires a field", agg.Verb)
+			}
+			if agg.Verb != VerbCount && agg.Verb != VerbCountDistinct && !b.schema.IsNumeric(agg.Field) {
 				return fmt.Errorf("field '%s' must be numeric for verb '%s'", agg.Field, agg.Verb)
 			}
 		}
@@ -75,6 +96,32 @@ func WithGroupBy(fields ...string) Option {
 	}
 }
 
+// WithDedup collapses raw query results that share the values of the given fields.
+// It is ignored for aggregation verbs.
+func WithDedup(fields ...string) Option {
+	return func(b *Builder) error {
+		for _, field := range fields {
+			if strings.TrimSpace(field) == "" {
+				return fmt.Errorf("dedup field cannot be empty")
+			}
+		}
+		b.dedup = fields
+		return nil
+	}
+}
+
+// WithTimeBin groups aggregation results into time buckets of width d by adding
+// bin(d) to the group-by expressions, e.g. bin(5m). It composes with WithGroupBy.
+func WithTimeBin(d time.Duration) Option {
+	return func(b *Builder) error {
+		if d <= 0 || d%time.Millisecond != 0 {
+			return fmt.Errorf("time bin must be a positive whole number of milliseconds, got %s", d)
+		}
+		b.timeBin = d
+		return nil
+	}
+}
+
 // WithLimit sets the result limit.
 func WithLimit(n int) Option {
 	return func(b *Builder) error {
@@ -86,9 +133,47 @@ func WithLimit(n int) Option {
 	}
 }
 
-// WithFilter adds a filter expression.
+// WithSortOrder sets the direction of the sort applied to aggregation results.
+func WithSortOrder(o SortOrder) Option {
+	return func(b *Builder) error {
+		if o != SortAsc && o != SortDesc {
+			return fmt.Errorf("invalid sort order %d", o)
+		}
+		b.sortOrder = o
+		return nil
+	}
+}
+
+// WithSortField sorts aggregation results by the named stats output column, which
+// must be an aggregation alias (e.g. packets_sum) or a group-by field. When unset,
+// results are sorted by the first aggregation. A raw query can be sorted by any
+// field of the schema or by @timestamp, and is otherwise left in Insights' order.
+func WithSortField(field string) Option {
+	return func(b *Builder) error {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			return fmt.Errorf("sort field cannot be empty")
+		}
+		b.sortField = field
+		return nil
+	}
+}
+
+// WithTieBreak makes the order of aggregation results deterministic by sorting
+// rows that tie on the sort column by the first group-by field, ascending.
+func WithTieBreak() Option {
+	return func(b *Builder) error {
+		b.tieBreak = true
+		return nil
+	}
+}
+
+// WithFilter adds a filter expression. A nil expression adds no filter.
 func WithFilter(e Expr) Option {
 	return func(b *Builder) error {
+		if e == nil {
+			return nil
+		}
 		if err := ValidateFilter(e, b.schema, b.version); err != nil {
 			return err
 		}
@@ -97,6 +182,25 @@ func WithFilter(e Expr) Option {
 	}
 }
 
+// privateIPv4Ranges are the RFC 1918 ranges WithPublicOnly excludes.
+var privateIPv4Ranges = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+
+// WithPublicOnly adds a filter excluding flows whose IP field, such as srcaddr, is
+// in a private IPv4 range. Insights has no isPrivate function, so each range in
+// privateIPv4Ranges becomes a negated isIpv4InSubnet clause.
+func WithPublicOnly(field string) Option {
+	if fieldType, ok := defaultFieldRegistry.GetFieldType(field); !ok || fieldType.Name != "ip" {
+		return func(*Builder) error {
+			return fmt.Errorf("public IP filter requires an IP address field, got '%s'", field)
+		}
+	}
+	exprs := make(And, 0, len(privateIPv4Ranges))
+	for _, cidr := range privateIPv4Ranges {
+		exprs = append(exprs, &NotExpr{Expr: &IsIpv4InSubnet{Field: field, Value: cidr}})
+	}
+	return WithFilter(&exprs)
+}
+
 // WithVersion sets the flow log version.
 func WithVersion(v int) Option {
 	return func(b *Builder) error {
@@ -107,3 +211,37 @@ func WithVersion(v int) Option {
 		return nil
 	}
 }
+
+// WithClosestVersion sets the flow log version like WithVersion, but downgrades an
+// unsupported version to the closest supported one and records a warning instead
+// of failing. It still fails if no supported version is old enough.
+func WithClosestVersion(v int) Option {
+	return func(b *Builder) error {
+		if b.schema.ValidateVersion(v) == nil {
+			b.version = v
+			return nil
+		}
+		closest := b.schema.ClosestSupportedVersion(v)
+		if closest == 0 {
+			return fmt.Errorf("invalid version %d: no supported version at or below it", v)
+		}
+		b.warnings = append(b.warnings, fmt.Sprintf("flow log version %d is not supported, using version %d", v, closest))
+		b.version = closest
+		return nil
+	}
+}
+
+// WithPercentile sets the percentile computed by VerbPct aggregations.
+func WithPercentile(p float64) Option {
+	return func(b *Builder) error {
+		if p <= 0 || p > 100 {
+			return fmt.Errorf("percentile must be between 0 and 100, got %v", p)
+		}
+		for i := range b.aggregations {
+			if b.aggregations[i].Verb == VerbPct {
+				b.aggregations[i].Arg = p
+			}
+		}
+		return nil
+	}
+}
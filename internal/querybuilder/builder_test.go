@@ -1,8 +1,10 @@
 package querybuilder
 
 import (
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func clean(s string) string {
@@ -23,6 +25,14 @@ func TestNewBuilder(t *testing.T) {
 			expected: `parse @message "* * * * * * * * * * * * * *" as version, account_id, interface_id, srcaddr, dstaddr, srcport, dstport, protocol, packets, bytes, start, end, action, log_status
 | stats count(*) as flows
 | sort flows desc
+| limit 100`,
+		},
+		{
+			name:    "with ascending sort",
+			options: []Option{WithSortOrder(SortAsc)},
+			expected: `parse @message "* * * * * * * * * * * * * *" as version, account_id, interface_id, srcaddr, dstaddr, srcport, dstport, protocol, packets, bytes, start, end, action, log_status
+| stats count(*) as flows
+| sort flows asc
 | limit 100`,
 		},
 		{
@@ -108,7 +118,7 @@ func TestNewBuilder(t *testing.T) {
 				WithFields("srcaddr", "dstaddr"),
 				WithVerb(VerbRaw),
 			},
-			expected: `parse @message "* * * * * * * * * * * * * *" as version, account_id, interface_id, srcaddr, dstaddr, srcport, dstport, protocol, packets, bytes, start, end, action, log_status
+			expected: `parse @message /^\S+ \S+ \S+ (?<srcaddr>\S+) (?<dstaddr>\S+)/
 | display srcaddr, dstaddr
 | limit 100`,
 		},
@@ -207,7 +217,7 @@ func TestNewBuilder(t *testing.T) {
 				WithVerb(VerbRaw),
 				WithFields("duration"),
 			},
-			expected: `parse @message "* * * * * * * * * * * * * *" as version, account_id, interface_id, srcaddr, dstaddr, srcport, dstport, protocol, packets, bytes, start, end, action, log_status
+			expected: `parse @message /^\S+ \S+ \S+ \S+ \S+ \S+ \S+ \S+ \S+ \S+ (?<start>\S+) (?<end>\S+)/
 | display end - start as duration
 | limit 100`,
 		},
@@ -398,6 +408,37 @@ func TestWithAggregations(t *testing.T) {
 			},
 			expectErr: false,
 		},
+		{
+			name: "percentile on numeric field",
+			aggregations: []AggregationField{
+				{Field: "bytes", Verb: VerbPct, Arg: 99},
+			},
+			expectErr: false,
+		},
+		{
+			name: "percentile on non-numeric field",
+			aggregations: []AggregationField{
+				{Field: "srcaddr", Verb: VerbPct, Arg: 99},
+			},
+			expectErr:      true,
+			expectedErrStr: "field 'srcaddr' must be numeric for verb 'VerbPct'",
+		},
+		{
+			name: "count distinct on non-numeric field",
+			aggregations: []AggregationField{
+				{Field: "srcaddr", Verb: VerbCountDistinct},
+				{Field: "bytes", Verb: VerbSum},
+			},
+			expectErr: false,
+		},
+		{
+			name: "count distinct without a field",
+			aggregations: []AggregationField{
+				{Field: "*", Verb: VerbCountDistinct},
+			},
+			expectErr:      true,
+			expectedErrStr: "verb 'VerbCountDistinct' requires a field",
+		},
 		{
 			name: "mixed valid and invalid",
 			aggregations: []AggregationField{
@@ -465,13 +506,33 @@ func TestAggregationFieldGetAlias(t *testing.T) {
 			field:    AggregationField{Field: "bytes", Verb: VerbMax},
 			expected: "bytes_max",
 		},
+		{
+			name:     "percentile field",
+			field:    AggregationField{Field: "bytes", Verb: VerbPct, Arg: 95},
+			expected: "bytes_p95",
+		},
+		{
+			name:     "fractional percentile",
+			field:    AggregationField{Field: "bytes", Verb: VerbPct, Arg: 99.9},
+			expected: "bytes_p99_9",
+		},
+		{
+			name:     "percentile default",
+			field:    AggregationField{Field: "packets", Verb: VerbPct},
+			expected: "packets_p95",
+		},
+		{
+			name:     "count distinct field",
+			field:    AggregationField{Field: "srcaddr", Verb: VerbCountDistinct},
+			expected: "srcaddr_distinct",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := tt.field.getAlias()
+			got := tt.field.Alias()
 			if got != tt.expected {
-				t.Errorf("getAlias() = %v, want %v", got, tt.expected)
+				t.Errorf("Alias() = %v, want %v", got, tt.expected)
 			}
 		})
 	}
@@ -504,3 +565,432 @@ func TestIsNumeric(t *testing.T) {
 		})
 	}
 }
+
+func TestComputedFields(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+	fields := schema.ComputedFields()
+	if len(fields) == 0 || fields[0] != "duration" {
+		t.Fatalf("ComputedFields() = %v, want duration listed", fields)
+	}
+	for _, field := range fields {
+		if schema.GetComputedFieldExpression(field, DefaultVersion) == "" {
+			t.Errorf("computed field %q has no expression", field)
+		}
+		if err := schema.ValidateField(field, DefaultVersion); err != nil {
+			t.Errorf("ValidateField(%q) error = %v", field, err)
+		}
+	}
+}
+
+// noParseSchema is a schema whose messages need no parse stage, such as JSON logs.
+type noParseSchema struct {
+	VPCFlowLogsSchema
+}
+
+func (noParseSchema) GetParsePattern(int) (string, error) {
+	return "", nil
+}
+
+func TestStringSkipsEmptyParsePattern(t *testing.T) {
+	b, err := New(&noParseSchema{}, WithFilter(&Eq{Field: "action", Value: "REJECT"}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	got := b.String()
+	want := "filter action = 'REJECT' | stats count(*) as flows | sort flows desc | limit 100"
+	if got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if strings.HasPrefix(got, " |") || strings.HasPrefix(got, "|") {
+		t.Errorf("String() starts with a stray pipe: %q", got)
+	}
+}
+
+func TestPercentileQuery(t *testing.T) {
+	b, err := New(&VPCFlowLogsSchema{},
+		WithAggregations(AggregationField{Field: "bytes", Verb: VerbPct, Arg: 95}),
+		WithGroupBy("srcaddr"),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	want := "stats pct(bytes, 95) as bytes_p95 by srcaddr | sort bytes_p95 desc | limit 100"
+	if got := clean(b.String()); !strings.HasSuffix(got, want) {
+		t.Errorf("String() = %q, want suffix %q", got, want)
+	}
+}
+
+func TestWithPercentile(t *testing.T) {
+	b, err := New(&VPCFlowLogsSchema{}, WithVerb(VerbPct), WithFields("bytes"), WithPercentile(99))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got := b.String(); !strings.Contains(got, "pct(bytes, 99) as bytes_p99") {
+		t.Errorf("String() = %q, want pct(bytes, 99) as bytes_p99", got)
+	}
+
+	if _, err := New(&VPCFlowLogsSchema{}, WithVerb(VerbPct), WithPercentile(150)); err == nil {
+		t.Error("expected an error for a percentile above 100")
+	}
+}
+
+func TestParseVerbArg(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantVerb Verb
+		wantArg  float64
+		wantErr  bool
+	}{
+		{input: "sum", wantVerb: VerbSum},
+		{input: "pct", wantVerb: VerbPct, wantArg: DefaultPercentile},
+		{input: "distinct", wantVerb: VerbCountDistinct},
+		{input: "COUNT_DISTINCT", wantVerb: VerbCountDistinct},
+		{input: "pct95", wantVerb: VerbPct, wantArg: 95},
+		{input: "P99", wantVerb: VerbPct, wantArg: 99},
+		{input: "p99.9", wantVerb: VerbPct, wantArg: 99.9},
+		{input: "p0", wantErr: true},
+		{input: "pnan", wantErr: true},
+		{input: "pct101", wantErr: true},
+		{input: "packets", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			verb, arg, err := ParseVerbArg(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVerbArg(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if verb != tt.wantVerb || arg != tt.wantArg {
+				t.Errorf("ParseVerbArg(%q) = %v, %v; want %v, %v", tt.input, verb, arg, tt.wantVerb, tt.wantArg)
+			}
+		})
+	}
+}
+
+func TestParseSortOrder(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    SortOrder
+		wantErr bool
+	}{
+		{input: "asc", want: SortAsc},
+		{input: "DESC", want: SortDesc},
+		{input: "", want: SortDesc},
+		{input: "up", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSortOrder(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSortOrder(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSortOrder(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSortField(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+	multi := WithAggregations(
+		AggregationField{Field: "bytes", Verb: VerbSum},
+		AggregationField{Field: "packets", Verb: VerbSum},
+	)
+
+	tests := []struct {
+		name           string
+		options        []Option
+		expectedSort   string
+		expectedErrStr string
+	}{
+		{
+			name:         "defaults to the primary aggregation",
+			options:      []Option{multi},
+			expectedSort: "| sort bytes_sum desc",
+		},
+		{
+			name:         "secondary aggregation",
+			options:      []Option{multi, WithSortField("packets_sum")},
+			expectedSort: "| sort packets_sum desc",
+		},
+		{
+			name:         "group-by field set before grouping",
+			options:      []Option{WithSortField("srcaddr"), multi, WithGroupBy("srcaddr"), WithSortOrder(SortAsc)},
+			expectedSort: "| sort srcaddr asc",
+		},
+		{
+			name:         "tie break on the first group-by field",
+			options:      []Option{multi, WithGroupBy("srcaddr", "dstaddr"), WithTieBreak()},
+			expectedSort: "| sort bytes_sum desc, srcaddr asc |",
+		},
+		{
+			name:         "tie break skips the sort column",
+			options:      []Option{multi, WithGroupBy("srcaddr", "dstaddr"), WithSortField("srcaddr"), WithTieBreak()},
+			expectedSort: "| sort srcaddr desc, dstaddr asc |",
+		},
+		{
+			name:         "tie break without grouping",
+			options:      []Option{multi, WithTieBreak()},
+			expectedSort: "| sort bytes_sum desc |",
+		},
+		{
+			name:           "field not in stats output",
+			options:        []Option{multi, WithSortField("dstport")},
+			expectedErrStr: "sort field 'dstport' is not in the stats output (available: bytes_sum, packets_sum)",
+		},
+		{
+			name:         "raw query by a schema field",
+			options:      []Option{WithVerb(VerbRaw), WithSortField("bytes"), WithLimit(10)},
+			expectedSort: "| sort bytes desc | limit 10",
+		},
+		{
+			name:         "raw query by timestamp",
+			options:      []Option{WithVerb(VerbRaw), WithFields("srcaddr", "bytes"), WithSortField("@timestamp"), WithSortOrder(SortAsc)},
+			expectedSort: "| display srcaddr, bytes | sort @timestamp asc | limit",
+		},
+		{
+			name:           "raw query by an unknown field",
+			options:        []Option{WithVerb(VerbRaw), WithSortField("bytes_sum")},
+			expectedErrStr: "invalid sort field 'bytes_sum': not a field of version 2 or @timestamp",
+		},
+		{
+			name:           "empty field",
+			options:        []Option{WithSortField(" ")},
+			expectedErrStr: "sort field cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := New(schema, tt.options...)
+			if tt.expectedErrStr != "" {
+				if err == nil || err.Error() != tt.expectedErrStr {
+					t.Fatalf("expected error %q, got %v", tt.expectedErrStr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(b.String(), tt.expectedSort) {
+				t.Errorf("expected query to contain %q, got:\n%s", tt.expectedSort, b.String())
+			}
+		})
+	}
+}
+
+func TestRawParseNarrowing(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{
+			name:    "three fields keep their log order",
+			options: []Option{WithVerb(VerbRaw), WithFields("action", "srcaddr", "dstport")},
+			want:    `parse @message /^\S+ \S+ \S+ (?<srcaddr>\S+) \S+ \S+ (?<dstport>\S+) \S+ \S+ \S+ \S+ \S+ (?<action>\S+)/ | display action, srcaddr, dstport | limit 100`,
+		},
+		{
+			name: "filter, dedup, and sort fields are parsed too",
+			options: []Option{
+				WithVerb(VerbRaw), WithFields("srcaddr"), WithFilter(&Eq{Field: "dstport", Value: 22}),
+				WithDedup("dstaddr"), WithSortField("bytes"),
+			},
+			want: `parse @message /^\S+ \S+ \S+ (?<srcaddr>\S+) (?<dstaddr>\S+) \S+ (?<dstport>\S+) \S+ \S+ (?<bytes>\S+)/ | filter dstport = 22 | display srcaddr | sort bytes desc | dedup dstaddr | limit 100`,
+		},
+		{
+			name:    "aggregations with parse fields",
+			options: []Option{WithVerb(VerbSum), WithFields("bytes"), WithGroupBy("srcaddr"), WithParseFields("srcaddr")},
+			want:    `parse @message /^\S+ \S+ \S+ (?<srcaddr>\S+) \S+ \S+ \S+ \S+ \S+ (?<bytes>\S+)/ | stats sum(bytes) as bytes_sum by srcaddr | sort bytes_sum desc | limit 100`,
+		},
+		{
+			name:    "raw without fields parses everything",
+			options: []Option{WithVerb(VerbRaw), WithLimit(5)},
+			want:    ParsePatternV2 + " | limit 5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := New(schema, tt.options...)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("String() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := New(schema, WithParseFields("vpc_id")); err == nil {
+		t.Error("New() with a parse field missing from version 2: error = nil, want an error")
+	}
+}
+
+func TestWithDedup(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+
+	t.Run("raw query", func(t *testing.T) {
+		b, err := New(schema, WithVerb(VerbRaw), WithFields("srcaddr", "dstaddr"),
+			WithFilter(&Eq{Field: "action", Value: "REJECT"}), WithDedup("srcaddr", "dstaddr"))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		want := "| filter action = 'REJECT' | display srcaddr, dstaddr | dedup srcaddr, dstaddr | limit 100"
+		if got := clean(b.String()); !strings.HasSuffix(got, want) {
+			t.Errorf("String() = %q, want suffix %q", got, want)
+		}
+	})
+
+	t.Run("omitted for aggregation verbs", func(t *testing.T) {
+		b, err := New(schema, WithVerb(VerbCount), WithDedup("srcaddr"))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if got := b.String(); strings.Contains(got, "dedup") {
+			t.Errorf("String() = %q, want no dedup clause", got)
+		}
+	})
+
+	t.Run("invalid field", func(t *testing.T) {
+		_, err := New(schema, WithVerb(VerbRaw), WithDedup("nonexistent"))
+		if err == nil || !strings.Contains(err.Error(), "invalid dedup field 'nonexistent'") {
+			t.Errorf("New() error = %v, want invalid dedup field", err)
+		}
+	})
+
+	t.Run("field missing from version", func(t *testing.T) {
+		_, err := New(schema, WithDedup("vpc_id"), WithVersion(2), WithVerb(VerbRaw))
+		if err == nil || !strings.Contains(err.Error(), "invalid field 'vpc_id' for version 2") {
+			t.Errorf("New() error = %v, want version error", err)
+		}
+	})
+}
+
+func TestWithTimeBin(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+	sumBytes := WithAggregations(AggregationField{Field: "bytes", Verb: VerbSum})
+
+	tests := []struct {
+		name           string
+		options        []Option
+		expectedSuffix string
+		expectedErrStr string
+	}{
+		{
+			name:           "bin alone",
+			options:        []Option{sumBytes, WithTimeBin(5 * time.Minute)},
+			expectedSuffix: "stats sum(bytes) as bytes_sum by bin(5m) | sort bytes_sum desc | limit 100",
+		},
+		{
+			name:           "bin with group by",
+			options:        []Option{WithGroupBy("srcaddr"), sumBytes, WithTimeBin(time.Hour)},
+			expectedSuffix: "stats sum(bytes) as bytes_sum by bin(1h), srcaddr | sort bytes_sum desc | limit 100",
+		},
+		{
+			name:           "largest whole unit",
+			options:        []Option{sumBytes, WithTimeBin(90 * time.Second)},
+			expectedSuffix: "by bin(90s) | sort bytes_sum desc | limit 100",
+		},
+		{
+			name:           "non-positive duration",
+			options:        []Option{sumBytes, WithTimeBin(0)},
+			expectedErrStr: "time bin must be a positive whole number of milliseconds",
+		},
+		{
+			name:           "sub-millisecond duration",
+			options:        []Option{sumBytes, WithTimeBin(1500 * time.Microsecond)},
+			expectedErrStr: "time bin must be a positive whole number of milliseconds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := New(schema, tt.options...)
+			if tt.expectedErrStr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErrStr) {
+					t.Fatalf("New() error = %v, want %q", err, tt.expectedErrStr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if got := clean(b.String()); !strings.HasSuffix(got, tt.expectedSuffix) {
+				t.Errorf("String() = %q, want suffix %q", got, tt.expectedSuffix)
+			}
+		})
+	}
+}
+
+func TestCountDistinctQuery(t *testing.T) {
+	b, err := New(&VPCFlowLogsSchema{},
+		WithAggregations(
+			AggregationField{Field: "srcaddr", Verb: VerbCountDistinct},
+			AggregationField{Field: "bytes", Verb: VerbSum},
+		),
+		WithGroupBy("dstport"),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	want := "stats count_distinct(srcaddr) as srcaddr_distinct, sum(bytes) as bytes_sum by dstport | sort srcaddr_distinct desc | limit 100"
+	if got := clean(b.String()); !strings.HasSuffix(got, want) {
+		t.Errorf("String() = %q, want suffix %q", got, want)
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+	tests := map[string]FieldKind{
+		"srcaddr":     FieldKindIP,
+		"pkt_dstaddr": FieldKindIP,
+		"dstport":     FieldKindPort,
+		"bytes":       FieldKindNumeric,
+		"duration":    FieldKindNumeric,
+		"action":      FieldKindCategorical,
+		"transport":   FieldKindCategorical,
+	}
+	for field, want := range tests {
+		if got := KindOf(schema, field); got != want {
+			t.Errorf("KindOf(%q) = %v, want %v", field, got, want)
+		}
+	}
+}
+
+func TestSchemaFields(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+	if fields := schema.Fields(5); !slices.Contains(fields, "vpc_id") {
+		t.Errorf("Fields(5) = %v, want vpc_id", fields)
+	}
+	if fields := schema.Fields(2); slices.Contains(fields, "vpc_id") {
+		t.Errorf("Fields(2) = %v, want no vpc_id", fields)
+	}
+	if fields := schema.Fields(4); fields != nil {
+		t.Errorf("Fields(4) = %v, want nil", fields)
+	}
+}
+
+func TestWithPublicOnly(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+	b, err := New(schema, WithVerb(VerbRaw), WithFields("srcaddr", "dstaddr"), WithPublicOnly("srcaddr"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	want := "| filter not isIpv4InSubnet(srcaddr, '10.0.0.0/8') and not isIpv4InSubnet(srcaddr, '172.16.0.0/12') and not isIpv4InSubnet(srcaddr, '192.168.0.0/16')"
+	if got := clean(b.String()); !strings.Contains(got, want) {
+		t.Errorf("String() = %q, want a filter excluding every private range %q", got, want)
+	}
+
+	for _, field := range []string{"nonexistent", "dstport", "action"} {
+		if _, err := New(schema, WithPublicOnly(field)); err == nil {
+			t.Errorf("New() with WithPublicOnly(%q): error = nil, want an IP field error", field)
+		}
+	}
+}
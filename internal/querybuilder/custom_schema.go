@@ -0,0 +1,162 @@
+package querybuilder
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CustomSchema implements the Schema interface from a user-supplied definition,
+// for flow logs with a custom format such as Firehose-delivered logs with extra columns.
+//
+// An example definition:
+//
+//	default_version: 1
+//	versions:
+//	  1:
+//	    parse_pattern: parse @message "* * * *" as srcaddr, dstaddr, bytes, team
+//	    fields: [srcaddr, dstaddr, bytes, team]
+//	numeric_fields: [bytes, kilobytes]
+//	computed_fields:
+//	  kilobytes: bytes / 1024
+type CustomSchema struct {
+	// DefaultVersion is the version used when none is requested. It defaults to
+	// the lowest defined version.
+	DefaultVersion int `yaml:"default_version"`
+	// Versions maps each supported version to its parse pattern and fields.
+	Versions map[int]CustomSchemaVersion `yaml:"versions"`
+	// NumericFields lists the fields, including computed ones, that hold numbers.
+	NumericFields []string `yaml:"numeric_fields"`
+	// Computed maps computed field names to their Logs Insights expressions.
+	Computed map[string]string `yaml:"computed_fields"`
+}
+
+// CustomSchemaVersion describes a single version of a CustomSchema.
+type CustomSchemaVersion struct {
+	ParsePattern string   `yaml:"parse_pattern"`
+	Fields       []string `yaml:"fields"`
+}
+
+// LoadCustomSchema reads and validates a CustomSchema from a YAML file.
+func LoadCustomSchema(path string) (*CustomSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+	return ParseCustomSchema(data)
+}
+
+// ParseCustomSchema parses and validates a CustomSchema from YAML.
+func ParseCustomSchema(data []byte) (*CustomSchema, error) {
+	var s CustomSchema
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse schema file: %w", err)
+	}
+	if len(s.Versions) == 0 {
+		return nil, fmt.Errorf("schema must define at least one version")
+	}
+	for v, def := range s.Versions {
+		if len(def.Fields) == 0 {
+			return nil, fmt.Errorf("schema version %d must list its fields", v)
+		}
+	}
+	if s.DefaultVersion == 0 {
+		s.DefaultVersion = s.versions()[0]
+	} else if err := s.ValidateVersion(s.DefaultVersion); err != nil {
+		return nil, fmt.Errorf("invalid default version: %w", err)
+	}
+	return &s, nil
+}
+
+// versions returns the defined versions in ascending order.
+func (s *CustomSchema) versions() []int {
+	versions := make([]int, 0, len(s.Versions))
+	for v := range s.Versions {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// GetParsePattern returns the 'parse' statement pattern for a given log version.
+// An empty pattern is allowed for logs whose fields are already discovered.
+func (s *CustomSchema) GetParsePattern(version int) (string, error) {
+	def, ok := s.Versions[version]
+	if !ok {
+		return "", fmt.Errorf("unsupported schema version for parse pattern: %d", version)
+	}
+	return def.ParsePattern, nil
+}
+
+// ValidateField checks if a field is valid for the given log version.
+func (s *CustomSchema) ValidateField(field string, version int) error {
+	def, ok := s.Versions[version]
+	if !ok {
+		return fmt.Errorf("invalid flow log version: %d", version)
+	}
+
+	// Allow computed fields.
+	if _, ok := s.Computed[field]; ok || field == "*" {
+		return nil
+	}
+
+	if slices.Contains(def.Fields, field) {
+		return nil
+	}
+	return fmt.Errorf("invalid field '%s' for version %d", field, version)
+}
+
+// ValidateVersion checks if a version number is defined by the schema.
+func (s *CustomSchema) ValidateVersion(version int) error {
+	if _, ok := s.Versions[version]; !ok {
+		return fmt.Errorf("invalid flow log version: %d", version)
+	}
+	return nil
+}
+
+// ClosestSupportedVersion returns the highest defined version that is not
+// greater than version, or 0 if version is older than every defined version.
+func (s *CustomSchema) ClosestSupportedVersion(version int) int {
+	closest := 0
+	for v := range s.Versions {
+		if v <= version && v > closest {
+			closest = v
+		}
+	}
+	return closest
+}
+
+// GetDefaultVersion returns the default version for the schema.
+func (s *CustomSchema) GetDefaultVersion() int {
+	return s.DefaultVersion
+}
+
+// IsNumeric returns true if the field is listed as numeric.
+func (s *CustomSchema) IsNumeric(field string) bool {
+	return slices.Contains(s.NumericFields, field)
+}
+
+// GetComputedFieldExpression returns the CloudWatch Logs Insights expression for a computed field.
+// Returns empty string if the field is not a computed field.
+func (s *CustomSchema) GetComputedFieldExpression(field string, _ int) string {
+	return s.Computed[field]
+}
+
+// ComputedFields returns the names of the computed fields, sorted.
+func (s *CustomSchema) ComputedFields() []string {
+	fields := make([]string, 0, len(s.Computed))
+	for field := range s.Computed {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Fields returns the fields of the given schema version in log order, or nil
+// if the version is not defined.
+func (s *CustomSchema) Fields(version int) []string {
+	return slices.Clone(s.Versions[version].Fields)
+}
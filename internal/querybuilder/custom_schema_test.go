@@ -0,0 +1,104 @@
+package querybuilder
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testCustomSchema = `
+versions:
+  1:
+    parse_pattern: parse @message "* * * *" as srcaddr, dstaddr, bytes, team
+    fields: [srcaddr, dstaddr, bytes, team]
+  2:
+    parse_pattern: parse @message "* * * * *" as srcaddr, dstaddr, bytes, team, cluster
+    fields: [srcaddr, dstaddr, bytes, team, cluster]
+numeric_fields: [bytes, kilobytes]
+computed_fields:
+  kilobytes: bytes / 1024
+`
+
+func TestLoadCustomSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.yaml")
+	if err := os.WriteFile(path, []byte(testCustomSchema), 0o600); err != nil {
+		t.Fatalf("failed to write schema: %v", err)
+	}
+
+	s, err := LoadCustomSchema(path)
+	if err != nil {
+		t.Fatalf("LoadCustomSchema() error = %v", err)
+	}
+	if got := s.GetDefaultVersion(); got != 1 {
+		t.Errorf("GetDefaultVersion() = %d, want the lowest version 1", got)
+	}
+	if err := s.ValidateField("team", 1); err != nil {
+		t.Errorf("ValidateField(team, 1) error = %v", err)
+	}
+	if err := s.ValidateField("cluster", 1); err == nil || err.Error() != "invalid field 'cluster' for version 1" {
+		t.Errorf("ValidateField(cluster, 1) error = %v, want invalid field", err)
+	}
+	if err := s.ValidateField("kilobytes", 1); err != nil {
+		t.Errorf("ValidateField(kilobytes, 1) error = %v, want computed fields allowed", err)
+	}
+	if !s.IsNumeric("bytes") || s.IsNumeric("team") {
+		t.Error("IsNumeric() does not follow numeric_fields")
+	}
+	if got := s.GetComputedFieldExpression("kilobytes", 1); got != "bytes / 1024" {
+		t.Errorf("GetComputedFieldExpression(kilobytes) = %q", got)
+	}
+	if got := s.ComputedFields(); !slices.Equal(got, []string{"kilobytes"}) {
+		t.Errorf("ComputedFields() = %v, want [kilobytes]", got)
+	}
+	if got := s.ClosestSupportedVersion(5); got != 2 {
+		t.Errorf("ClosestSupportedVersion(5) = %d, want 2", got)
+	}
+
+	if _, err := LoadCustomSchema(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing schema file")
+	}
+}
+
+func TestParseCustomSchemaErrors(t *testing.T) {
+	tests := map[string]string{
+		"no versions":       "numeric_fields: [bytes]",
+		"version no fields": "versions:\n  1:\n    parse_pattern: x",
+		"unknown default":   "default_version: 3\nversions:\n  1:\n    fields: [srcaddr]",
+		"malformed":         "versions: [",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseCustomSchema([]byte(data)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestBuilderWithCustomSchema(t *testing.T) {
+	s, err := ParseCustomSchema([]byte(testCustomSchema))
+	if err != nil {
+		t.Fatalf("ParseCustomSchema() error = %v", err)
+	}
+
+	b, err := New(s,
+		WithVersion(2),
+		WithAggregations(AggregationField{Field: "kilobytes", Verb: VerbSum}),
+		WithGroupBy("team", "cluster"),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	want := `parse @message "* * * * *" as srcaddr, dstaddr, bytes, team, cluster` +
+		" | stats sum(bytes / 1024) as kilobytes_sum by team, cluster | sort kilobytes_sum desc | limit 100"
+	if got := b.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	_, err = New(s, WithAggregations(AggregationField{Field: "team", Verb: VerbSum}))
+	if err == nil || !strings.Contains(err.Error(), "must be numeric") {
+		t.Errorf("New() error = %v, want a numeric field error", err)
+	}
+}
@@ -64,6 +64,19 @@ func Example_v5() {
 	// parse @message "* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * *" as version, account_id, interface_id, srcaddr, dstaddr, srcport, dstport, protocol, packets, bytes, start, end, action, log_status, vpc_id, subnet_id, instance_id, tcp_flags, type, pkt_srcaddr, pkt_dstaddr, region, az_id, sublocation_type, sublocation_id, pkt_src_aws_service, pkt_dst_aws_service, flow_direction, traffic_path | filter pkt_src_aws_service = 'EC2' | stats sum(bytes) as bytes_sum by flow_direction | sort bytes_sum desc | limit 5
 }
 
+func Example_isPresent() {
+	schema := &querybuilder.VPCFlowLogsSchema{}
+	filter, _ := querybuilder.ParseFilterWithSchema("pkt_src_aws_service is not null and vpc_id is null", schema)
+	q, _ := querybuilder.New(schema,
+		querybuilder.WithVersion(5),
+		querybuilder.WithFilter(filter),
+		querybuilder.WithGroupBy("pkt_src_aws_service"),
+	)
+	fmt.Println(q.String())
+	// Output:
+	// parse @message "* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * *" as version, account_id, interface_id, srcaddr, dstaddr, srcport, dstport, protocol, packets, bytes, start, end, action, log_status, vpc_id, subnet_id, instance_id, tcp_flags, type, pkt_srcaddr, pkt_dstaddr, region, az_id, sublocation_type, sublocation_id, pkt_src_aws_service, pkt_dst_aws_service, flow_direction, traffic_path | filter ispresent(pkt_src_aws_service) and not ispresent(vpc_id) | stats count(*) as flows by pkt_src_aws_service | sort flows desc | limit 100
+}
+
 func Example_basic() {
 	opts := []querybuilder.Option{
 		querybuilder.WithLimit(5),
@@ -78,7 +91,7 @@ func Example_basic() {
 	}
 	fmt.Println(b.String())
 	// Output:
-	// parse @message "* * * * * * * * * * * * * *" as version, account_id, interface_id, srcaddr, dstaddr, srcport, dstport, protocol, packets, bytes, start, end, action, log_status | display srcaddr, dstaddr, action | limit 5
+	// parse @message /^\S+ \S+ \S+ (?<srcaddr>\S+) (?<dstaddr>\S+) \S+ \S+ \S+ \S+ \S+ \S+ \S+ (?<action>\S+)/ | display srcaddr, dstaddr, action | limit 5
 }
 
 func Example_complex() {
@@ -266,6 +266,7 @@ func TestGetFieldAndGetValue(t *testing.T) {
 		{Gte{Field: "gte", Value: 3}, "gte", 3, "Gte"},
 		{Lte{Field: "lte", Value: 4}, "lte", 4, "Lte"},
 		{IsIpv4InSubnet{Field: "ip", Value: "10.0.0.0/24"}, "ip", "10.0.0.0/24", "IsIpv4InSubnet"},
+		{IsIpv6InSubnet{Field: "ip", Value: "2600::/40"}, "ip", "2600::/40", "IsIpv6InSubnet"},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -301,3 +302,80 @@ func TestIsComputedField(t *testing.T) {
 		})
 	}
 }
+
+func TestExprSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		expr Expr
+		want string
+	}{
+		{name: "eq string", expr: Eq{Field: "action", Value: "REJECT"}, want: "action = 'REJECT'"},
+		{name: "eq integer", expr: Eq{Field: "dstport", Value: 443}, want: "dstport = 443"},
+		{name: "eq quote escaping", expr: Eq{Field: "action", Value: "it's"}, want: "action = 'it''s'"},
+		{name: "eq reserved column", expr: Eq{Field: "end", Value: 1700000000}, want: `"end" = 1700000000`},
+		{name: "neq", expr: Neq{Field: "dstport", Value: 22}, want: "dstport <> 22"},
+		{name: "gt", expr: Gt{Field: "bytes", Value: 1000}, want: "bytes > 1000"},
+		{name: "lt", expr: Lt{Field: "bytes", Value: 1000}, want: "bytes < 1000"},
+		{name: "gte", expr: Gte{Field: "packets", Value: 10}, want: "packets >= 10"},
+		{name: "lte", expr: Lte{Field: "packets", Value: 10}, want: "packets <= 10"},
+		{name: "like", expr: Like{Field: "srcaddr", Value: "10.0"}, want: `srcaddr LIKE '%10.0%' ESCAPE '\'`},
+		{name: "like escapes wildcards", expr: Like{Field: "interface_id", Value: "eni_1%"}, want: `interface_id LIKE '%eni\_1\%%' ESCAPE '\'`},
+		{name: "not like", expr: NotLike{Field: "srcaddr", Value: "10.0"}, want: `srcaddr NOT LIKE '%10.0%' ESCAPE '\'`},
+		{
+			name: "subnet",
+			expr: IsIpv4InSubnet{Field: "dstaddr", Value: "10.0.0.0/16"},
+			want: "contains('10.0.0.0/16', CAST(dstaddr AS IPADDRESS))",
+		},
+		{
+			name: "negated subnet",
+			expr: NotExpr{Expr: &IsIpv4InSubnet{Field: "srcaddr", Value: "192.168.0.0/16"}},
+			want: "NOT (contains('192.168.0.0/16', CAST(srcaddr AS IPADDRESS)))",
+		},
+		{
+			name: "negated conjunction",
+			expr: &NotExpr{Expr: &And{&Eq{Field: "action", Value: "ACCEPT"}, &Eq{Field: "dstport", Value: 443}}},
+			want: "NOT (action = 'ACCEPT' AND dstport = 443)",
+		},
+		{
+			name: "and",
+			expr: And{&Eq{Field: "action", Value: "ACCEPT"}, &Gt{Field: "bytes", Value: 0}},
+			want: "action = 'ACCEPT' AND bytes > 0",
+		},
+		{
+			name: "or",
+			expr: Or{&Eq{Field: "dstport", Value: 80}, &Eq{Field: "dstport", Value: 443}},
+			want: "(dstport = 80 OR dstport = 443)",
+		},
+		{name: "empty and", expr: And{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.expr.SQL(); got != tt.want {
+				t.Errorf("SQL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprSQLLeavesStringUnchanged(t *testing.T) {
+	e := NotExpr{Expr: &IsIpv4InSubnet{Field: "srcaddr", Value: "10.0.0.0/8"}}
+	if got, want := e.String(), "not isIpv4InSubnet(srcaddr, '10.0.0.0/8')"; got != want {
+		t.Errorf("String() = %v, want %v", got, want)
+	}
+}
+
+func TestIsIpv6InSubnetString(t *testing.T) {
+	tests := []struct {
+		expr Expr
+		want string
+	}{
+		{&IsIpv6InSubnet{Field: "srcaddr", Value: "2600:1f00::/40"}, "isIpv6InSubnet(srcaddr, '2600:1f00::/40')"},
+		{&NotExpr{Expr: &IsIpv6InSubnet{Field: "dstaddr", Value: "2600:1f00::/40"}}, "not isIpv6InSubnet(dstaddr, '2600:1f00::/40')"},
+	}
+	for _, tt := range tests {
+		if got := tt.expr.String(); got != tt.want {
+			t.Errorf("String() = %v, want %v", got, tt.want)
+		}
+	}
+}
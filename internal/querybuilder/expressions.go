@@ -8,7 +8,10 @@ import (
 
 // Expr represents a query expression.
 type Expr interface {
+	// String renders the expression for CloudWatch Logs Insights.
 	String() string
+	// SQL renders the expression for Athena SQL.
+	SQL() string
 }
 
 // FieldValueExpr is implemented by expressions with a Field and Value
@@ -29,6 +32,10 @@ func (e Eq) String() string {
 	return fmt.Sprintf("%s = %s", formatField(e.Field), quote(e.Value))
 }
 
+func (e Eq) SQL() string {
+	return sqlCompare(e.Field, "=", e.Value)
+}
+
 // GetField returns the field name for the equality expression.
 func (e Eq) GetField() string { return e.Field }
 
@@ -45,6 +52,10 @@ func (e Neq) String() string {
 	return fmt.Sprintf("%s != %s", formatField(e.Field), quote(e.Value))
 }
 
+func (e Neq) SQL() string {
+	return sqlCompare(e.Field, "<>", e.Value)
+}
+
 // GetField returns the field name for the non-equality expression.
 func (e Neq) GetField() string { return e.Field }
 
@@ -61,6 +72,10 @@ func (g Gt) String() string {
 	return fmt.Sprintf("%s > %s", formatField(g.Field), quote(g.Value))
 }
 
+func (g Gt) SQL() string {
+	return sqlCompare(g.Field, ">", g.Value)
+}
+
 // GetField returns the field name for the greater than expression.
 func (g Gt) GetField() string { return g.Field }
 
@@ -77,6 +92,10 @@ func (l Lt) String() string {
 	return fmt.Sprintf("%s < %s", formatField(l.Field), quote(l.Value))
 }
 
+func (l Lt) SQL() string {
+	return sqlCompare(l.Field, "<", l.Value)
+}
+
 // GetField returns the field name for the less than expression.
 func (l Lt) GetField() string { return l.Field }
 
@@ -93,6 +112,10 @@ func (e Like) String() string {
 	return fmt.Sprintf("%s like %s", e.Field, quote(e.Value))
 }
 
+func (e Like) SQL() string {
+	return fmt.Sprintf(`%s LIKE %s ESCAPE '\'`, sqlIdent(e.Field), sqlLikePattern(e.Value))
+}
+
 // GetField returns the field name for the like expression.
 func (e Like) GetField() string { return e.Field }
 
@@ -109,6 +132,10 @@ func (e NotLike) String() string {
 	return fmt.Sprintf("%s not like %s", e.Field, quote(e.Value))
 }
 
+func (e NotLike) SQL() string {
+	return fmt.Sprintf(`%s NOT LIKE %s ESCAPE '\'`, sqlIdent(e.Field), sqlLikePattern(e.Value))
+}
+
 // GetField returns the field name for the not like expression.
 func (e NotLike) GetField() string { return e.Field }
 
@@ -125,6 +152,10 @@ func (e Gte) String() string {
 	return fmt.Sprintf("%s >= %s", formatField(e.Field), quote(e.Value))
 }
 
+func (e Gte) SQL() string {
+	return sqlCompare(e.Field, ">=", e.Value)
+}
+
 // GetField returns the field name for the greater than or equal expression.
 func (e Gte) GetField() string { return e.Field }
 
@@ -141,6 +172,10 @@ func (e Lte) String() string {
 	return fmt.Sprintf("%s <= %s", formatField(e.Field), quote(e.Value))
 }
 
+func (e Lte) SQL() string {
+	return sqlCompare(e.Field, "<=", e.Value)
+}
+
 // GetField returns the field name for the less than or equal expression.
 func (e Lte) GetField() string { return e.Field }
 
@@ -161,6 +196,17 @@ func (e And) String() string {
 	return strings.Join(parts, " and ")
 }
 
+func (e And) SQL() string {
+	if len(e) == 0 {
+		return ""
+	}
+	parts := make([]string, len(e))
+	for i, expr := range e {
+		parts[i] = expr.SQL()
+	}
+	return strings.Join(parts, " AND ")
+}
+
 // Or represents a disjunction of expressions.
 type Or []Expr
 
@@ -178,6 +224,17 @@ func (e Or) String() string {
 	return "(" + strings.Join(parts, " or ") + ")"
 }
 
+func (e Or) SQL() string {
+	if len(e) == 0 {
+		return ""
+	}
+	parts := make([]string, len(e))
+	for i, expr := range e {
+		parts[i] = expr.SQL()
+	}
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
+
 // NotExpr represents a logical NOT operation on an expression.
 type NotExpr struct {
 	Expr
@@ -187,6 +244,10 @@ func (e NotExpr) String() string {
 	return fmt.Sprintf("not %s", e.Expr.String())
 }
 
+func (e NotExpr) SQL() string {
+	return "NOT (" + e.Expr.SQL() + ")"
+}
+
 // quote returns a properly quoted value for CloudWatch Logs Insights.
 func quote(v any) string {
 	switch x := v.(type) {
@@ -233,8 +294,80 @@ func (e IsIpv4InSubnet) String() string {
 	return fmt.Sprintf("isIpv4InSubnet(%s, '%s')", e.Field, e.Value)
 }
 
+func (e IsIpv4InSubnet) SQL() string {
+	return fmt.Sprintf("contains(%s, CAST(%s AS IPADDRESS))", sqlQuote(e.Value), sqlIdent(e.Field))
+}
+
 // GetField returns the field name for the IPv4 subnet check expression.
 func (e IsIpv4InSubnet) GetField() string { return e.Field }
 
 // GetValue returns the value for the IPv4 subnet check expression.
 func (e IsIpv4InSubnet) GetValue() any { return e.Value }
+
+// IsIpv6InSubnet represents an IPv6 CIDR block membership check.
+// It generates a CloudWatch Logs Insights expression using the isIpv6InSubnet function.
+// Example: IsIpv6InSubnet{Field: "srcaddr", Value: "2600:1f00::/40"} generates:
+// isIpv6InSubnet(srcaddr, '2600:1f00::/40').
+type IsIpv6InSubnet struct {
+	Field string
+	Value string
+}
+
+func (e IsIpv6InSubnet) String() string {
+	return fmt.Sprintf("isIpv6InSubnet(%s, '%s')", e.Field, e.Value)
+}
+
+func (e IsIpv6InSubnet) SQL() string {
+	return fmt.Sprintf("contains(%s, CAST(%s AS IPADDRESS))", sqlQuote(e.Value), sqlIdent(e.Field))
+}
+
+// GetField returns the field name for the IPv6 subnet check expression.
+func (e IsIpv6InSubnet) GetField() string { return e.Field }
+
+// GetValue returns the value for the IPv6 subnet check expression.
+func (e IsIpv6InSubnet) GetValue() any { return e.Value }
+
+// IsPresent represents a check that a field has a value, for optional fields such as
+// the v3/v5 pkt_src_aws_service. With Negate set it checks that the field is absent.
+// Example: IsPresent{Field: "vpc_id", Negate: true} generates:
+// not ispresent(vpc_id).
+type IsPresent struct {
+	Field  string
+	Negate bool
+}
+
+func (e IsPresent) String() string {
+	if e.Negate {
+		return fmt.Sprintf("not ispresent(%s)", e.Field)
+	}
+	return fmt.Sprintf("ispresent(%s)", e.Field)
+}
+
+func (e IsPresent) SQL() string {
+	if e.Negate {
+		return sqlIdent(e.Field) + " IS NULL"
+	}
+	return sqlIdent(e.Field) + " IS NOT NULL"
+}
+
+// FieldCompare compares two fields of the same log event, e.g. to find NAT by
+// comparing the packet-level and interface-level addresses.
+// Example: FieldCompare{Field: "pkt_srcaddr", Op: "!=", Other: "srcaddr"} generates:
+// pkt_srcaddr != srcaddr.
+type FieldCompare struct {
+	Field string
+	Op    string // =, !=, >, <, >=, or <=
+	Other string
+}
+
+func (e FieldCompare) String() string {
+	return fmt.Sprintf("%s %s %s", formatField(e.Field), e.Op, formatField(e.Other))
+}
+
+func (e FieldCompare) SQL() string {
+	op := e.Op
+	if op == "!=" {
+		op = "<>"
+	}
+	return fmt.Sprintf("%s %s %s", sqlIdent(e.Field), op, sqlIdent(e.Other))
+}
@@ -40,13 +40,16 @@ const (
 
 // Error messages for better consistency.
 const (
-	ErrInvalidFilterClause = "invalid filter clause: %q"
-	ErrUnsupportedOperator = "unsupported operator for %s field: %q"
-	ErrInvalidPortValue    = "invalid port value: %s"
-	ErrPortOutOfRange      = "port out of range: %d"
-	ErrInvalidNumericValue = "invalid numeric value for field %s: %s"
-	ErrInvalidIPValue      = "invalid IP, CIDR, or prefix value for field %s: %s"
-	ErrInvalidCIDRBlock    = "invalid CIDR block: %v"
+	ErrInvalidFilterClause  = "invalid filter clause: %q"
+	ErrUnsupportedOperator  = "unsupported operator for %s field: %q"
+	ErrInvalidPortValue     = "invalid port value: %s"
+	ErrPortOutOfRange       = "port out of range: %d"
+	ErrInvalidNumericValue  = "invalid numeric value for field %s: %s"
+	ErrInvalidIPValue       = "invalid IP, CIDR, or prefix value for field %s: %s"
+	ErrInvalidCIDRBlock     = "invalid CIDR block: %v"
+	ErrInvalidProtocolValue = "invalid protocol value for %s: %s is not a protocol number or known name"
+	ErrInvalidInList        = "invalid in list: %q"
+	ErrProtocolWildcard     = "unsupported operator for protocol %s: %q; only = is allowed"
 )
 
 // FieldType represents the type of a field and its supported operators.
@@ -259,14 +262,19 @@ func parseIPFieldExpr(field, op, value string) (Expr, error) {
 	}
 
 	if strings.Contains(value, "/") { // CIDR
-		if _, err := netip.ParsePrefix(value); err != nil {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
 			return nil, fmt.Errorf(ErrInvalidCIDRBlock, err)
 		}
+		var subnet Expr = &IsIpv4InSubnet{Field: field, Value: value}
+		if prefix.Addr().Is6() {
+			subnet = &IsIpv6InSubnet{Field: field, Value: value}
+		}
 		switch op {
 		case "=", operatorLike:
-			return &IsIpv4InSubnet{Field: field, Value: value}, nil
+			return subnet, nil
 		case "!=", operatorNotLike:
-			return &NotExpr{Expr: &IsIpv4InSubnet{Field: field, Value: value}}, nil
+			return &NotExpr{Expr: subnet}, nil
 		}
 	} else if _, err := netip.ParseAddr(value); err == nil { // Full IP
 		switch op {
@@ -339,34 +347,49 @@ func parseNumericFieldExpr(field, op, value string) (Expr, error) {
 	return nil, fmt.Errorf(ErrInvalidNumericValue, field, value)
 }
 
+// protocolNumbers maps the protocol acronyms accepted in filters to their IANA numbers.
+var protocolNumbers = map[string]string{
+	"tcp":    "6",
+	"udp":    "17",
+	"icmp":   "1",
+	"icmpv6": "58",
+	"esp":    "50",
+	"ah":     "51",
+}
+
+// protocolWildcards are the protocol values that match every protocol.
+var protocolWildcards = map[string]struct{}{
+	"all": {},
+	"any": {},
+}
+
 // parseProtocolFieldExpr returns the correct Expr for a protocol field, operator, and value.
 func parseProtocolFieldExpr(field, op, value string) (Expr, error) {
-	// Protocol can be numeric (6, 17) or string (TCP, UDP)
-
-	// Try to parse as integer first
-	if num, err := strconv.Atoi(value); err == nil {
-		// Use numeric value directly
-		parser := NewOperatorParser(field, strconv.Itoa(num))
-		return parser.ParseOperator(op)
+	// "all" and "any" place no restriction on the protocol, so the clause is
+	// dropped; any other operator with them could never match
+	if _, ok := protocolWildcards[strings.ToLower(value)]; ok {
+		if op != "=" {
+			return nil, fmt.Errorf(ErrProtocolWildcard, value, op)
+		}
+		return nil, nil
 	}
 
-	// If not numeric, check if it's a known protocol acronym
-	protocolMap := map[string]string{
-		"tcp":    "6",
-		"udp":    "17",
-		"icmp":   "1",
-		"icmpv6": "58",
-		"esp":    "50",
-		"ah":     "51",
+	// Protocol can be numeric (6, 17) or a known acronym (TCP, UDP) for every
+	// operator; acronyms are converted to their numbers
+	if protocolNum, exists := protocolNumbers[strings.ToLower(value)]; exists {
+		value = protocolNum
 	}
 
-	if protocolNum, exists := protocolMap[strings.ToLower(value)]; exists {
-		// Convert protocol acronym to numeric value and create parser with numeric value
-		parser := NewOperatorParser(field, protocolNum)
+	if num, err := strconv.Atoi(value); err == nil {
+		parser := NewOperatorParser(field, strconv.Itoa(num))
 		return parser.ParseOperator(op)
 	}
 
-	// If not a known acronym, treat as string (for custom protocols)
+	// Unknown names can only be matched for (in)equality, e.g. custom protocols;
+	// ordering them is meaningless
+	if op != "=" && op != "!=" {
+		return nil, fmt.Errorf(ErrInvalidProtocolValue, field, value)
+	}
 	parser := NewOperatorParser(field, value)
 	return parser.ParseOperator(op)
 }
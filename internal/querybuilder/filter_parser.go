@@ -3,6 +3,8 @@ package querybuilder
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -13,57 +15,111 @@ func ParseFilter(s string) (Expr, error) {
 
 // ParseFilterWithSchema parses a filter string into an expression tree with schema support for computed fields.
 func ParseFilterWithSchema(s string, schema Schema) (Expr, error) {
+	return parseFilter(s, schema, DefaultSchemaVersion)
+}
+
+// parseFilter parses a filter string for the given version, which decides the
+// fields a clause may compare against and how computed fields are expanded.
+func parseFilter(s string, schema Schema, version int) (Expr, error) {
 	s = strings.TrimSpace(s)
 	if s == "" {
 		return nil, nil
 	}
-	return parseOrWithSchema(s, schema)
+	return parseOrWithSchema(s, schema, version)
+}
+
+// ParseFilterForVersion parses a filter string like ParseFilterWithSchema and then
+// validates its fields against the given version, so an unknown field fails at
+// parse time rather than when the query is built.
+func ParseFilterForVersion(s string, schema Schema, version int) (Expr, error) {
+	expr, err := parseFilter(s, schema, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateFilter(expr, schema, version); err != nil {
+		return nil, err
+	}
+	return expr, nil
 }
 
-func parseOrWithSchema(s string, schema Schema) (Expr, error) {
+func parseOrWithSchema(s string, schema Schema, version int) (Expr, error) {
 	parts := splitOnLogical(s, "or")
 	if len(parts) == 1 {
-		return parseAndWithSchema(s, schema)
+		return parseAndWithSchema(s, schema, version)
 	}
 	exprs := make([]Expr, len(parts))
 	for i, p := range parts {
-		expr, err := parseAndWithSchema(p, schema)
+		expr, err := parseAndWithSchema(p, schema, version)
 		if err != nil {
 			return nil, err
 		}
 		exprs[i] = expr
 	}
-	orExpr := Or(exprs)
-	return &orExpr, nil
+	return orOf(exprs), nil
 }
 
-func parseAndWithSchema(s string, schema Schema) (Expr, error) {
+func parseAndWithSchema(s string, schema Schema, version int) (Expr, error) {
 	parts := splitOnLogical(s, "and")
 	if len(parts) == 1 {
-		return parsePrimaryWithSchema(s, schema)
+		return parsePrimaryWithSchema(s, schema, version)
 	}
 	exprs := make([]Expr, len(parts))
 	for i, p := range parts {
-		expr, err := parsePrimaryWithSchema(p, schema)
+		expr, err := parsePrimaryWithSchema(p, schema, version)
 		if err != nil {
 			return nil, err
 		}
 		exprs[i] = expr
 	}
-	andExpr := And(exprs)
-	return &andExpr, nil
+	return andOf(exprs), nil
+}
+
+// andOf combines exprs with and. A nil expression, such as protocol = all, places
+// no restriction and is dropped; nil is returned when none remain.
+func andOf(exprs []Expr) Expr {
+	kept := make([]Expr, 0, len(exprs))
+	for _, expr := range exprs {
+		if expr != nil {
+			kept = append(kept, expr)
+		}
+	}
+	switch len(kept) {
+	case 0:
+		return nil
+	case 1:
+		return kept[0]
+	}
+	andExpr := And(kept)
+	return &andExpr
+}
+
+// orOf combines exprs with or. A nil expression matches everything, and so does
+// the whole or when any of exprs is nil.
+func orOf(exprs []Expr) Expr {
+	if slices.Contains(exprs, nil) {
+		return nil
+	}
+	orExpr := Or(exprs)
+	return &orExpr
 }
 
-func parsePrimaryWithSchema(s string, schema Schema) (Expr, error) {
+func parsePrimaryWithSchema(s string, schema Schema, version int) (Expr, error) {
 	s = strings.TrimSpace(s)
 	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
-		return ParseFilterWithSchema(s[1:len(s)-1], schema)
+		return parseFilter(s[1:len(s)-1], schema, version)
 	}
-	return parseClauseWithSchema(s, schema)
+	return parseClauseWithSchema(s, schema, version)
 }
 
 // parseClause parses a single filter clause like "field op value".
-func parseClauseWithSchema(clause string, schema Schema) (Expr, error) {
+func parseClauseWithSchema(clause string, schema Schema, version int) (Expr, error) {
+	if expr, ok := parseNullCheck(clause); ok {
+		return expr, nil
+	}
+	if field, negate, values, ok := splitInClause(clause); ok {
+		return parseInClause(field, negate, values, schema, version)
+	}
+
 	operators := []string{"!=", operatorNotLike, ">=", "<=", ">", "<", "=", operatorLike}
 	var op, field, value string
 
@@ -92,15 +148,22 @@ func parseClauseWithSchema(clause string, schema Schema) (Expr, error) {
 	if op == "" {
 		return nil, fmt.Errorf(ErrInvalidFilterClause, clause)
 	}
+	quoted := strings.Trim(value, "'\"") != value
 	value = strings.Trim(value, "'\"") // Remove quotes
 
 	if schema != nil {
-		if computedExpr := schema.GetComputedFieldExpression(field, DefaultSchemaVersion); computedExpr != "" {
+		if computedExpr := schema.GetComputedFieldExpression(field, version); computedExpr != "" {
 			parser := NewOperatorParser(computedExpr, value)
 			return parser.ParseOperator(op)
 		}
 	}
 
+	// An unquoted value naming a field of the version compares the two fields;
+	// quote it to match the name as a literal
+	if !quoted && schema != nil && isComparisonOperator(op) && slices.Contains(schema.Fields(version), value) {
+		return &FieldCompare{Field: field, Op: op, Other: value}, nil
+	}
+
 	if fieldType, exists := defaultFieldRegistry.GetFieldType(field); exists {
 		// Only call ValueValidator if it's set
 		if fieldType.ValueValidator != nil {
@@ -126,6 +189,79 @@ func parseClauseWithSchema(clause string, schema Schema) (Expr, error) {
 	}
 }
 
+// inClausePattern matches "field in (a, b)" and "field not in (a, b)".
+var inClausePattern = regexp.MustCompile(`(?i)^(\S+)\s+(not\s+)?in\s*\((.*)\)$`)
+
+// splitInClause splits an in or not in clause into its field and list values.
+func splitInClause(clause string) (field string, negate bool, values []string, ok bool) {
+	m := inClausePattern.FindStringSubmatch(strings.TrimSpace(clause))
+	if m == nil {
+		return "", false, nil, false
+	}
+	for _, value := range strings.Split(m[3], ",") {
+		values = append(values, strings.TrimSpace(value))
+	}
+	return m[1], m[2] != "", values, true
+}
+
+// parseInClause expands "field in (a, b)" to field = a or field = b, and
+// "field not in (a, b)" to field != a and field != b. Each value is parsed as its
+// own clause, so field-specific handling such as protocol names applies.
+func parseInClause(field string, negate bool, values []string, schema Schema, version int) (Expr, error) {
+	op := "="
+	if negate {
+		op = "!="
+	}
+	exprs := make([]Expr, 0, len(values))
+	for _, value := range values {
+		if strings.Trim(value, "'\"") == "" {
+			return nil, fmt.Errorf(ErrInvalidInList, strings.Join(values, ", "))
+		}
+		expr, err := parseClauseWithSchema(field+" "+op+" "+value, schema, version)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	if negate {
+		return andOf(exprs), nil
+	}
+	return orOf(exprs), nil
+}
+
+// isComparisonOperator reports whether op compares values rather than matching a pattern.
+func isComparisonOperator(op string) bool {
+	switch op {
+	case "=", "!=", ">", "<", ">=", "<=":
+		return true
+	}
+	return false
+}
+
+// parseNullCheck parses "field is null" and "field is not null" clauses.
+func parseNullCheck(clause string) (Expr, bool) {
+	lower := strings.ToLower(clause)
+	for _, check := range []struct {
+		suffix string
+		negate bool
+	}{
+		{suffix: " is not null", negate: false},
+		{suffix: " is null", negate: true},
+	} {
+		if strings.HasSuffix(lower, check.suffix) {
+			field := strings.TrimSpace(clause[:len(clause)-len(check.suffix)])
+			if field == "" {
+				return nil, false
+			}
+			return &IsPresent{Field: field, Negate: check.negate}, true
+		}
+	}
+	return nil, false
+}
+
 // ValidateFilter recursively checks an Expr for valid fields, operators, and values for the given version.
 func ValidateFilter(expr Expr, schema Schema, version int) error {
 	if expr == nil {
@@ -151,6 +287,13 @@ func ValidateFilter(expr Expr, schema Schema, version int) error {
 			return nil
 		case *NotExpr:
 			return validate(x.Expr)
+		case *IsPresent:
+			return schema.ValidateField(x.Field, version)
+		case *FieldCompare:
+			if err := schema.ValidateField(x.Field, version); err != nil {
+				return err
+			}
+			return schema.ValidateField(x.Other, version)
 		case FieldValueExpr:
 			// The parser already validated the value (e.g., that a CIDR is valid).
 			// We only need to check if the field name itself is valid for the version.
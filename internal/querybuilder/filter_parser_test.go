@@ -57,6 +57,26 @@ func TestParseFilter(t *testing.T) {
 			input: "srcaddr != '10.0.0.0/24'",
 			want:  &NotExpr{Expr: &IsIpv4InSubnet{Field: "srcaddr", Value: "10.0.0.0/24"}},
 		},
+		{
+			name:  "is not null",
+			input: "pkt_src_aws_service is not null",
+			want:  &IsPresent{Field: "pkt_src_aws_service"},
+		},
+		{
+			name:  "is null combined",
+			input: "vpc_id IS NULL and action = 'ACCEPT'",
+			want:  &And{&IsPresent{Field: "vpc_id", Negate: true}, &Eq{Field: "action", Value: "ACCEPT"}},
+		},
+		{
+			name:  "ipv6 subnet",
+			input: "srcaddr = '2600:1f00::/40'",
+			want:  &IsIpv6InSubnet{Field: "srcaddr", Value: "2600:1f00::/40"},
+		},
+		{
+			name:  "ipv6 subnet not equals",
+			input: "srcaddr != '2600:1f00::/40'",
+			want:  &NotExpr{Expr: &IsIpv6InSubnet{Field: "srcaddr", Value: "2600:1f00::/40"}},
+		},
 		{
 			name:    "invalid expression",
 			input:   "srcaddr 10.0.0.1",
@@ -117,6 +137,51 @@ func TestParseFilter(t *testing.T) {
 			input: "protocol > 6",
 			want:  &Gt{Field: "protocol", Value: 6},
 		},
+		{
+			name:  "protocol not equals name",
+			input: "protocol != icmp",
+			want:  &Neq{Field: "protocol", Value: 1},
+		},
+		{
+			name:  "protocol compared with name",
+			input: "protocol >= TCP",
+			want:  &Gte{Field: "protocol", Value: 6},
+		},
+		{
+			name:    "protocol compared with unknown name",
+			input:   "protocol > foo",
+			wantErr: true,
+		},
+		{
+			name:  "protocol in names",
+			input: "protocol in (tcp,udp)",
+			want:  &Or{&Eq{Field: "protocol", Value: 6}, &Eq{Field: "protocol", Value: 17}},
+		},
+		{
+			name:  "protocol not in names",
+			input: "protocol NOT IN (icmp, 'icmpv6')",
+			want:  &And{&Neq{Field: "protocol", Value: 1}, &Neq{Field: "protocol", Value: 58}},
+		},
+		{
+			name:  "in with one value",
+			input: "dstport in (22)",
+			want:  &Eq{Field: "dstport", Value: 22},
+		},
+		{
+			name:  "in combined",
+			input: "action = 'REJECT' and srcaddr in ('10.0.0.1', 10.0.0.0/8)",
+			want:  &And{&Eq{Field: "action", Value: "REJECT"}, &Or{&Eq{Field: "srcaddr", Value: "10.0.0.1"}, &IsIpv4InSubnet{Field: "srcaddr", Value: "10.0.0.0/8"}}},
+		},
+		{
+			name:    "in with an empty value",
+			input:   "dstport in (22,)",
+			wantErr: true,
+		},
+		{
+			name:    "in with an invalid value",
+			input:   "dstport in (22, ssh)",
+			wantErr: true,
+		},
 		{
 			name:  "port greater than",
 			input: "srcport > 1024",
@@ -313,6 +378,38 @@ func TestParseProtocolFieldExpr(t *testing.T) {
 		assertError(t, err, false)
 		assertEq(t, expr, "protocol", "customproto")
 	})
+	t.Run("unknown protocol compared", func(t *testing.T) {
+		_, err := parseProtocolFieldExpr("protocol", ">", "foo")
+		assertError(t, err, true)
+		if want := "invalid protocol value for protocol: foo"; err != nil && !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("error = %q, want it to start with %q", err, want)
+		}
+	})
+	t.Run("in renders as or", func(t *testing.T) {
+		expr, err := ParseFilter("protocol in (tcp,udp)")
+		assertError(t, err, false)
+		if got, want := expr.String(), "(protocol = 6 or protocol = 17)"; got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+	t.Run("all drops the clause", func(t *testing.T) {
+		expr, err := ParseFilter("protocol = all and action = 'ACCEPT'")
+		assertError(t, err, false)
+		if got, want := expr.String(), "action = 'ACCEPT'"; got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	})
+	t.Run("any matches everything in an or", func(t *testing.T) {
+		expr, err := ParseFilter("protocol = any or action = 'ACCEPT'")
+		assertError(t, err, false)
+		if expr != nil {
+			t.Errorf("ParseFilter() = %v, want no filter", expr)
+		}
+	})
+	t.Run("all with another operator", func(t *testing.T) {
+		_, err := parseProtocolFieldExpr("protocol", "!=", "all")
+		assertError(t, err, true)
+	})
 }
 
 func TestIsValidIPPrefix(t *testing.T) {
@@ -350,6 +447,18 @@ func TestValidateFilter(t *testing.T) {
 			t.Error("expected error for invalid field")
 		}
 	})
+	t.Run("presence check on v5 field", func(t *testing.T) {
+		err := ValidateFilter(&IsPresent{Field: "pkt_src_aws_service"}, schema, 5)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("presence check on field missing from version", func(t *testing.T) {
+		err := ValidateFilter(&NotExpr{Expr: &IsPresent{Field: "vpc_id", Negate: true}}, schema, 2)
+		if err == nil {
+			t.Error("expected error for a v5-only field on version 2")
+		}
+	})
 	t.Run("invalid version", func(t *testing.T) {
 		err := ValidateFilter(&Eq{Field: "srcaddr", Value: "10.0.0.1"}, schema, 999)
 		if err == nil {
@@ -357,3 +466,73 @@ func TestValidateFilter(t *testing.T) {
 		}
 	})
 }
+
+func TestParseFilterForVersion(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+	t.Run("valid field", func(t *testing.T) {
+		expr, err := ParseFilterForVersion("vpc_id = 'vpc-123'", schema, 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expr == nil {
+			t.Error("expected a parsed expression")
+		}
+	})
+	t.Run("field missing from version", func(t *testing.T) {
+		_, err := ParseFilterForVersion("srcaddr = '10.0.0.1' and vpc_id = 'vpc-123'", schema, 2)
+		if err == nil || err.Error() != "invalid field 'vpc_id' for version 2" {
+			t.Errorf("got error %v, want invalid field 'vpc_id' for version 2", err)
+		}
+	})
+	t.Run("parse error", func(t *testing.T) {
+		if _, err := ParseFilterForVersion("srcaddr", schema, 2); err == nil {
+			t.Error("expected parse error")
+		}
+	})
+	t.Run("empty filter", func(t *testing.T) {
+		expr, err := ParseFilterForVersion("", schema, 2)
+		if err != nil || expr != nil {
+			t.Errorf("got (%v, %v), want (nil, nil)", expr, err)
+		}
+	})
+}
+
+func TestParseFilterFieldComparison(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+	tests := []struct {
+		name    string
+		input   string
+		version int
+		want    string
+		wantErr bool
+	}{
+		{name: "field rhs", input: "pkt_srcaddr != srcaddr", version: 5, want: "pkt_srcaddr != srcaddr"},
+		{name: "field rhs in a conjunction", input: "action = 'ACCEPT' and dstport < srcport", version: 2, want: "action = 'ACCEPT' and dstport < srcport"},
+		{name: "quoted rhs is a literal", input: "interface_id = 'srcaddr'", version: 2, want: "interface_id = 'srcaddr'"},
+		{name: "unknown rhs is a literal", input: "action = ACCEPT", version: 2, want: "action = 'ACCEPT'"},
+		{name: "rhs field missing from version", input: "account_id = vpc_id", version: 2, want: "account_id = 'vpc_id'"},
+		{name: "lhs field missing from version", input: "pkt_srcaddr != srcaddr", version: 2, wantErr: true},
+		{name: "like keeps a literal", input: "interface_id like srcaddr", version: 2, want: "interface_id like 'srcaddr'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := ParseFilterForVersion(tt.input, schema, tt.version)
+			assertError(t, err, tt.wantErr)
+			if err != nil {
+				return
+			}
+			if got := expr.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	expr, err := ParseFilterForVersion("pkt_srcaddr != srcaddr", schema, 5)
+	assertError(t, err, false)
+	if got, want := expr.SQL(), "pkt_srcaddr <> srcaddr"; got != want {
+		t.Errorf("SQL() = %q, want %q", got, want)
+	}
+	if expr, err := ParseFilter("pkt_srcaddr != srcaddr"); err == nil {
+		t.Errorf("ParseFilter() without a schema = %v, want an invalid IP error", expr)
+	}
+}
@@ -98,6 +98,44 @@ func TestIntegration_InvalidVersion(t *testing.T) {
 	}
 }
 
+func TestClosestSupportedVersion(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+	tests := map[int]int{1: 0, 2: 2, 3: 3, 4: 3, 5: 5, 7: 5}
+	for version, want := range tests {
+		if got := schema.ClosestSupportedVersion(version); got != want {
+			t.Errorf("ClosestSupportedVersion(%d) = %d, want %d", version, got, want)
+		}
+	}
+}
+
+func TestIntegration_ClosestVersion(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+
+	b, err := New(schema, WithClosestVersion(4), WithGroupBy("vpc_id"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(b.String(), ParsePatternV3) {
+		t.Errorf("expected the v3 parse pattern, got:\n%s", b.String())
+	}
+	want := []string{"flow log version 4 is not supported, using version 3"}
+	if got := b.Warnings(); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("Warnings() = %v, want %v", got, want)
+	}
+
+	b, err = New(schema, WithClosestVersion(5))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(b.Warnings()) != 0 {
+		t.Errorf("expected no warnings for a supported version, got %v", b.Warnings())
+	}
+
+	if _, err := New(schema, WithClosestVersion(1)); err == nil {
+		t.Error("expected an error for a version older than every supported version")
+	}
+}
+
 // Multi-field aggregation integration tests
 func TestIntegration_MultiFieldAggregations(t *testing.T) {
 	schema := &VPCFlowLogsSchema{}
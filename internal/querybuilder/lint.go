@@ -0,0 +1,70 @@
+package querybuilder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// minLikePatternLength is the shortest literal like pattern LintFilter accepts
+// without a warning. Shorter patterns match nearly every log event.
+const minLikePatternLength = 3
+
+// likeWildcards are trimmed from a like pattern before its length is checked.
+const likeWildcards = "%*^$/"
+
+// Warning describes a filter clause that is valid but likely to be costly.
+type Warning struct {
+	// Field is the field the clause applies to
+	Field string
+
+	// Message explains the problem and suggests an alternative
+	Message string
+}
+
+// String renders the warning for display.
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.Field, w.Message)
+}
+
+// LintFilter inspects a parsed filter for clauses that match almost every log
+// event, such as srcaddr like '1', and returns a warning for each. It never
+// rejects a filter; a nil expression gives no warnings.
+func LintFilter(expr Expr) []Warning {
+	var warnings []Warning
+	var lint func(e Expr)
+	lint = func(e Expr) {
+		switch x := e.(type) {
+		case *And:
+			for _, sub := range *x {
+				lint(sub)
+			}
+		case *Or:
+			for _, sub := range *x {
+				lint(sub)
+			}
+		case *NotExpr:
+			lint(x.Expr)
+		case *Like:
+			if w, ok := lintLike(x.Field, x.Value); ok {
+				warnings = append(warnings, w)
+			}
+		}
+	}
+	if expr != nil {
+		lint(expr)
+	}
+	return warnings
+}
+
+// lintLike reports a warning when the literal part of a like pattern is too
+// short to narrow the scan.
+func lintLike(field, pattern string) (Warning, bool) {
+	if len(strings.Trim(pattern, likeWildcards)) >= minLikePatternLength {
+		return Warning{}, false
+	}
+	return Warning{
+		Field: field,
+		Message: fmt.Sprintf("like %q matches almost every event; use a longer pattern (at least %d characters) or an exact match",
+			pattern, minLikePatternLength),
+	}, true
+}
@@ -0,0 +1,41 @@
+package querybuilder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLintFilter(t *testing.T) {
+	schema := &VPCFlowLogsSchema{}
+
+	tests := []struct {
+		filter     string
+		wantFields []string
+	}{
+		{filter: "srcaddr like '1'", wantFields: []string{"srcaddr"}},
+		{filter: "action like 'AC'", wantFields: []string{"action"}},
+		{filter: "dstport = 443 and (action = 'ACCEPT' or dstaddr like '10')", wantFields: []string{"dstaddr"}},
+		{filter: "srcaddr like '10.0'"},
+		{filter: "srcaddr = '10.0.0.1'"},
+	}
+	for _, tt := range tests {
+		expr, err := ParseFilterWithSchema(tt.filter, schema)
+		if err != nil {
+			t.Fatalf("ParseFilterWithSchema(%q) error = %v", tt.filter, err)
+		}
+		warnings := LintFilter(expr)
+		if len(warnings) != len(tt.wantFields) {
+			t.Errorf("LintFilter(%q) = %v, want warnings for %v", tt.filter, warnings, tt.wantFields)
+			continue
+		}
+		for i, w := range warnings {
+			if w.Field != tt.wantFields[i] || !strings.Contains(w.String(), "longer pattern") {
+				t.Errorf("LintFilter(%q)[%d] = %q, want a warning for %s", tt.filter, i, w, tt.wantFields[i])
+			}
+		}
+	}
+
+	if warnings := LintFilter(nil); warnings != nil {
+		t.Errorf("LintFilter(nil) = %v, want nil", warnings)
+	}
+}
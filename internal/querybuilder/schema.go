@@ -10,6 +10,9 @@ type Schema interface {
 	ValidateField(field string, version int) error
 	// ValidateVersion checks if a version number is supported by the schema.
 	ValidateVersion(version int) error
+	// ClosestSupportedVersion returns the highest supported version that is not
+	// greater than version, or 0 if version is older than every supported version.
+	ClosestSupportedVersion(version int) int
 	// GetDefaultVersion returns the default version for the schema.
 	GetDefaultVersion() int
 	// IsNumeric returns true if the field is of a numeric type.
@@ -17,4 +20,63 @@ type Schema interface {
 	// GetComputedFieldExpression returns the CloudWatch Logs Insights expression for a computed field.
 	// Returns empty string if the field is not a computed field.
 	GetComputedFieldExpression(field string, version int) string
+	// ComputedFields returns the names of the computed fields, sorted.
+	ComputedFields() []string
+	// Fields returns the fields parsed from the logs of the given version, in
+	// log order, or nil if the version is not supported.
+	Fields(version int) []string
+}
+
+// FieldParser is implemented by schemas that can parse a subset of their fields,
+// so that Insights extracts only the columns a query reads.
+type FieldParser interface {
+	// GetFieldsParsePattern returns a 'parse' statement that extracts only the
+	// given fields of the version, which must not be computed fields.
+	GetFieldsParsePattern(version int, fields []string) (string, error)
+}
+
+// FieldKind classifies a field by the kind of value it holds.
+type FieldKind int
+
+const (
+	// FieldKindCategorical is a field with a fixed or free-form set of labels,
+	// such as action or interface_id.
+	FieldKindCategorical FieldKind = iota
+	// FieldKindIP is an IP address field, such as srcaddr.
+	FieldKindIP
+	// FieldKindPort is a port number field, such as dstport.
+	FieldKindPort
+	// FieldKindNumeric is any other numeric field, such as bytes.
+	FieldKindNumeric
+)
+
+// String returns the lowercase name of the kind.
+func (k FieldKind) String() string {
+	switch k {
+	case FieldKindIP:
+		return "ip"
+	case FieldKindPort:
+		return "port"
+	case FieldKindNumeric:
+		return "numeric"
+	default:
+		return "categorical"
+	}
+}
+
+// KindOf returns the kind of a field of the schema. Address and port fields are
+// the ones the filter parser treats as such, so the two always agree.
+func KindOf(s Schema, field string) FieldKind {
+	if fieldType, ok := defaultFieldRegistry.GetFieldType(field); ok {
+		switch fieldType.Name {
+		case "ip":
+			return FieldKindIP
+		case "port":
+			return FieldKindPort
+		}
+	}
+	if s.IsNumeric(field) {
+		return FieldKindNumeric
+	}
+	return FieldKindCategorical
 }
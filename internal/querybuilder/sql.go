@@ -0,0 +1,147 @@
+package querybuilder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sqlReserved lists flow log column names that are reserved words in Athena SQL
+// and must be double-quoted when used as identifiers.
+var sqlReserved = map[string]bool{
+	"end": true,
+}
+
+// SQL renders the query as Athena SQL over the given table.
+// The stage model maps onto SQL clauses: filter becomes WHERE, stats becomes
+// aggregates with GROUP BY, sort becomes ORDER BY, and limit becomes LIMIT.
+// Time bins and dedup have no equivalent here and are rejected rather than
+// silently dropped.
+func (b Builder) SQL(table string) (string, error) {
+	if strings.TrimSpace(table) == "" {
+		return "", fmt.Errorf("table name is required")
+	}
+	if b.timeBin > 0 {
+		return "", fmt.Errorf("time bins are not supported in SQL output")
+	}
+	if len(b.dedup) > 0 {
+		return "", fmt.Errorf("dedup is not supported in SQL output")
+	}
+
+	var columns []string
+	if len(b.aggregations) > 0 {
+		for _, field := range b.groupBy {
+			columns = append(columns, b.sqlColumn(field))
+		}
+		for _, agg := range b.aggregations {
+			arg := "*"
+			if agg.Field != "*" {
+				arg = b.sqlFieldExpr(agg.Field)
+			}
+			columns = append(columns, fmt.Sprintf("%s AS %s", agg.sqlCall(arg), agg.Alias()))
+		}
+	} else if len(b.fields) > 0 && b.fields[0] != "*" {
+		for _, field := range b.fields {
+			columns = append(columns, b.sqlColumn(field))
+		}
+	} else {
+		columns = []string{"*"}
+	}
+
+	parts := []string{
+		"SELECT " + strings.Join(columns, ", "),
+		"FROM " + table,
+	}
+
+	if len(b.filters) > 0 {
+		parts = append(parts, "WHERE "+And(b.filters).SQL())
+	}
+
+	if len(b.aggregations) > 0 {
+		if len(b.groupBy) > 0 {
+			groups := make([]string, len(b.groupBy))
+			for i, field := range b.groupBy {
+				groups[i] = b.sqlFieldExpr(field)
+			}
+			parts = append(parts, "GROUP BY "+strings.Join(groups, ", "))
+		}
+		orderBy := "ORDER BY " + sqlIdent(b.sortColumn()) + " " + strings.ToUpper(b.sortOrder.String())
+		if b.tieBreak {
+			if key := b.tieBreakColumn(); key != "" {
+				orderBy += ", " + sqlIdent(key) + " ASC"
+			}
+		}
+		parts = append(parts, orderBy)
+	} else if b.sortField != "" {
+		parts = append(parts, "ORDER BY "+sqlIdent(b.sortField)+" "+strings.ToUpper(b.sortOrder.String()))
+	}
+
+	if b.limit > 0 {
+		parts = append(parts, fmt.Sprintf("LIMIT %d", b.limit))
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+// sqlCall renders the aggregation function call over expr in Athena SQL.
+func (af AggregationField) sqlCall(expr string) string {
+	if af.Verb == VerbPct {
+		fraction := strconv.FormatFloat(af.percentileArg()/100, 'g', 10, 64)
+		return fmt.Sprintf("APPROX_PERCENTILE(%s, %s)", expr, fraction)
+	}
+	if af.Verb == VerbCountDistinct {
+		return fmt.Sprintf("COUNT(DISTINCT %s)", expr)
+	}
+	return fmt.Sprintf("%s(%s)", strings.ToUpper(verbToStat[af.Verb]), expr)
+}
+
+// sqlColumn renders a selected column, aliasing computed fields to their name.
+func (b *Builder) sqlColumn(field string) string {
+	if b.schema.GetComputedFieldExpression(field, b.version) != "" {
+		return fmt.Sprintf("%s AS %s", b.sqlFieldExpr(field), field)
+	}
+	return sqlIdent(field)
+}
+
+// sqlFieldExpr renders a field reference, expanding computed fields to their expression.
+func (b *Builder) sqlFieldExpr(field string) string {
+	computed := b.schema.GetComputedFieldExpression(field, b.version)
+	if computed == "" {
+		return sqlIdent(field)
+	}
+	tokens := strings.Fields(computed)
+	for i, tok := range tokens {
+		tokens[i] = sqlIdent(tok)
+	}
+	return "(" + strings.Join(tokens, " ") + ")"
+}
+
+// sqlIdent quotes an identifier if it is a reserved word.
+func sqlIdent(name string) string {
+	if sqlReserved[strings.ToLower(name)] {
+		return `"` + name + `"`
+	}
+	return name
+}
+
+// sqlQuote renders a literal value using SQL quoting rules.
+func sqlQuote(v any) string {
+	switch x := v.(type) {
+	case int, int64, float64:
+		return fmt.Sprint(x)
+	default:
+		// SQL escapes a single quote by doubling it
+		return "'" + strings.ReplaceAll(fmt.Sprint(x), "'", "''") + "'"
+	}
+}
+
+// sqlLikePattern turns a substring match into a LIKE pattern, escaping wildcards.
+func sqlLikePattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return sqlQuote("%" + r.Replace(s) + "%")
+}
+
+// sqlCompare renders a binary comparison between a column and a literal.
+func sqlCompare(field, op string, value any) string {
+	return fmt.Sprintf("%s %s %s", sqlIdent(field), op, sqlQuote(value))
+}
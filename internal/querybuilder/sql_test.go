@@ -0,0 +1,149 @@
+package querybuilder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuilderSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []Option
+		expected string
+	}{
+		{
+			name:     "count",
+			options:  []Option{},
+			expected: `SELECT COUNT(*) AS flows FROM vpc_flow_logs ORDER BY flows DESC LIMIT 100`,
+		},
+		{
+			name: "sum by",
+			options: []Option{
+				WithVerb(VerbSum),
+				WithFields("bytes"),
+				WithGroupBy("srcaddr", "dstport"),
+				WithLimit(10),
+			},
+			expected: `SELECT srcaddr, dstport, SUM(bytes) AS bytes_sum FROM vpc_flow_logs GROUP BY srcaddr, dstport ORDER BY bytes_sum DESC LIMIT 10`,
+		},
+		{
+			name: "filtered",
+			options: []Option{
+				WithFilter(&And{
+					&Eq{Field: "action", Value: "REJECT"},
+					&Or{
+						&Eq{Field: "dstport", Value: 22},
+						&Eq{Field: "dstport", Value: 3389},
+					},
+				}),
+				WithGroupBy("srcaddr"),
+			},
+			expected: `SELECT srcaddr, COUNT(*) AS flows FROM vpc_flow_logs WHERE action = 'REJECT' AND (dstport = 22 OR dstport = 3389) GROUP BY srcaddr ORDER BY flows DESC LIMIT 100`,
+		},
+		{
+			name: "percentile",
+			options: []Option{
+				WithAggregations(AggregationField{Field: "bytes", Verb: VerbPct, Arg: 99.9}),
+			},
+			expected: `SELECT APPROX_PERCENTILE(bytes, 0.999) AS bytes_p99_9 FROM vpc_flow_logs ORDER BY bytes_p99_9 DESC LIMIT 100`,
+		},
+		{
+			name: "count distinct",
+			options: []Option{
+				WithAggregations(AggregationField{Field: "srcaddr", Verb: VerbCountDistinct}),
+				WithGroupBy("dstport"),
+			},
+			expected: `SELECT dstport, COUNT(DISTINCT srcaddr) AS srcaddr_distinct FROM vpc_flow_logs GROUP BY dstport ORDER BY srcaddr_distinct DESC LIMIT 100`,
+		},
+		{
+			name: "sort by group-by field",
+			options: []Option{
+				WithGroupBy("srcaddr"),
+				WithSortField("srcaddr"),
+				WithSortOrder(SortAsc),
+			},
+			expected: `SELECT srcaddr, COUNT(*) AS flows FROM vpc_flow_logs GROUP BY srcaddr ORDER BY srcaddr ASC LIMIT 100`,
+		},
+		{
+			name: "computed field",
+			options: []Option{
+				WithVerb(VerbMax),
+				WithFields("duration"),
+				WithGroupBy("interface_id"),
+			},
+			expected: `SELECT interface_id, MAX(("end" - start)) AS duration_max FROM vpc_flow_logs GROUP BY interface_id ORDER BY duration_max DESC LIMIT 100`,
+		},
+		{
+			name: "raw fields",
+			options: []Option{
+				WithVerb(VerbRaw),
+				WithFields("srcaddr", "dstaddr", "end"),
+				WithFilter(&IsIpv4InSubnet{Field: "srcaddr", Value: "10.0.0.0/8"}),
+				WithLimit(5),
+			},
+			expected: `SELECT srcaddr, dstaddr, "end" FROM vpc_flow_logs WHERE contains('10.0.0.0/8', CAST(srcaddr AS IPADDRESS)) LIMIT 5`,
+		},
+		{
+			name: "raw sorted",
+			options: []Option{
+				WithVerb(VerbRaw),
+				WithFields("srcaddr", "bytes"),
+				WithSortField("bytes"),
+				WithLimit(5),
+			},
+			expected: `SELECT srcaddr, bytes FROM vpc_flow_logs ORDER BY bytes DESC LIMIT 5`,
+		},
+		{
+			name: "tie break",
+			options: []Option{
+				WithGroupBy("srcaddr"),
+				WithTieBreak(),
+			},
+			expected: `SELECT srcaddr, COUNT(*) AS flows FROM vpc_flow_logs GROUP BY srcaddr ORDER BY flows DESC, srcaddr ASC LIMIT 100`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := New(&VPCFlowLogsSchema{}, tt.options...)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			got, err := b.SQL("vpc_flow_logs")
+			if err != nil {
+				t.Fatalf("SQL() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("SQL() =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuilderSQLRequiresTable(t *testing.T) {
+	b, err := New(&VPCFlowLogsSchema{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := b.SQL(" "); err == nil {
+		t.Error("expected an error for an empty table name")
+	}
+}
+
+func TestBuilderSQLRejectsUnsupportedStages(t *testing.T) {
+	tests := map[string][]Option{
+		"time bin": {WithGroupBy("srcaddr"), WithTimeBin(5 * time.Minute)},
+		"dedup":    {WithVerb(VerbRaw), WithFields("srcaddr"), WithDedup("srcaddr")},
+	}
+	for name, options := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := New(&VPCFlowLogsSchema{}, options...)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if got, err := b.SQL("vpc_flow_logs"); err == nil {
+				t.Errorf("SQL() = %s, want an error", got)
+			}
+		})
+	}
+}
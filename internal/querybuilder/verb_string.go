@@ -14,11 +14,13 @@ func _() {
 	_ = x[VerbAvg-3]
 	_ = x[VerbMin-4]
 	_ = x[VerbMax-5]
+	_ = x[VerbPct-6]
+	_ = x[VerbCountDistinct-7]
 }
 
-const _Verb_name = "VerbRawVerbCountVerbSumVerbAvgVerbMinVerbMax"
+const _Verb_name = "VerbRawVerbCountVerbSumVerbAvgVerbMinVerbMaxVerbPctVerbCountDistinct"
 
-var _Verb_index = [...]uint8{0, 7, 16, 23, 30, 37, 44}
+var _Verb_index = [...]uint8{0, 7, 16, 23, 30, 37, 44, 51, 68}
 
 func (i Verb) String() string {
 	if i < 0 || i >= Verb(len(_Verb_index)-1) {
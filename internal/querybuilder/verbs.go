@@ -3,6 +3,8 @@ package querybuilder
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 )
 
@@ -27,26 +29,106 @@ const (
 	VerbMin
 	// VerbMax finds the maximum value of a numeric field.
 	VerbMax
+	// VerbPct computes a percentile of a numeric field.
+	VerbPct
+	// VerbCountDistinct counts the distinct values of a field.
+	VerbCountDistinct
 )
 
+// DefaultPercentile is the percentile used by VerbPct when none is given (e.g. "pct").
+const DefaultPercentile = 95
+
 // ParseVerb converts a string to a Verb.
 // This function complements the auto-generated String() method in verb_string.go
 // by providing the reverse operation: converting a string to a Verb.
+// Percentile tokens such as "pct95" or "p99" parse as VerbPct; use ParseVerbArg
+// to also obtain the percentile.
 func ParseVerb(s string) (Verb, error) {
+	v, _, err := ParseVerbArg(s)
+	return v, err
+}
+
+// ParseVerbArg converts a string to a Verb and its argument. The argument is the
+// percentile for VerbPct ("pct", "pct95", "p99", "p99.9") and zero for all other verbs.
+func ParseVerbArg(s string) (Verb, float64, error) {
 	switch strings.ToLower(s) {
 	case "raw":
-		return VerbRaw, nil
+		return VerbRaw, 0, nil
 	case "count":
-		return VerbCount, nil
+		return VerbCount, 0, nil
 	case "sum":
-		return VerbSum, nil
+		return VerbSum, 0, nil
 	case "avg":
-		return VerbAvg, nil
+		return VerbAvg, 0, nil
 	case "min":
-		return VerbMin, nil
+		return VerbMin, 0, nil
 	case "max":
-		return VerbMax, nil
+		return VerbMax, 0, nil
+	case "pct":
+		return VerbPct, DefaultPercentile, nil
+	case "distinct", "count_distinct":
+		return VerbCountDistinct, 0, nil
+	}
+
+	if p, ok, err := parsePercentileToken(s); ok {
+		if err != nil {
+			return VerbRaw, 0, err
+		}
+		return VerbPct, p, nil
+	}
+	return VerbRaw, 0, fmt.Errorf("unknown verb: %s", s)
+}
+
+// parsePercentileToken parses "pctN" and "pN" tokens. ok reports whether s looked
+// like a percentile token at all; err reports an out-of-range percentile.
+func parsePercentileToken(s string) (p float64, ok bool, err error) {
+	lower := strings.ToLower(s)
+	var digits string
+	switch {
+	case strings.HasPrefix(lower, "pct"):
+		digits = lower[len("pct"):]
+	case strings.HasPrefix(lower, "p"):
+		digits = lower[len("p"):]
+	default:
+		return 0, false, nil
+	}
+
+	p, convErr := strconv.ParseFloat(digits, 64)
+	if convErr != nil {
+		return 0, false, nil
+	}
+	if math.IsNaN(p) || p <= 0 || p > 100 {
+		return 0, true, fmt.Errorf("percentile must be between 0 and 100, got %s", digits)
+	}
+	return p, true, nil
+}
+
+// SortOrder is the direction of the sort applied to aggregation results.
+type SortOrder int
+
+const (
+	// SortDesc sorts from largest to smallest. It is the default.
+	SortDesc SortOrder = iota
+	// SortAsc sorts from smallest to largest.
+	SortAsc
+)
+
+// String returns the query keyword for the sort order.
+func (o SortOrder) String() string {
+	if o == SortAsc {
+		return "asc"
+	}
+	return "desc"
+}
+
+// ParseSortOrder converts "asc" or "desc" to a SortOrder.
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "asc":
+		return SortAsc, nil
+	case "desc", "":
+		return SortDesc, nil
 	default:
-		return VerbRaw, fmt.Errorf("unknown verb: %s", s)
+		return SortDesc, fmt.Errorf("unknown sort order %q (want asc or desc)", s)
 	}
 }
@@ -1,7 +1,11 @@
 // Package querybuilder provides tools for building CloudWatch Logs Insights queries.
 package querybuilder
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
 
 // VPCFlowLogsSchema implements the Schema interface for VPC Flow Logs.
 type VPCFlowLogsSchema struct{}
@@ -59,6 +63,38 @@ func (s *VPCFlowLogsSchema) GetParsePattern(version int) (string, error) {
 	}
 }
 
+// GetFieldsParsePattern returns a regular expression 'parse' statement that
+// captures only the given fields, in log order. Flow log fields are separated by
+// single spaces, so the fields before a wanted one are matched without being
+// captured and the fields after the last wanted one are not matched at all.
+func (s *VPCFlowLogsSchema) GetFieldsParsePattern(version int, fields []string) (string, error) {
+	all, ok := versionFields[version]
+	if !ok {
+		return "", fmt.Errorf("unsupported VPC Flow Log version for parse pattern: %d", version)
+	}
+	last := -1
+	for _, field := range fields {
+		i := slices.Index(all, field)
+		if i < 0 {
+			return "", fmt.Errorf("invalid field '%s' for version %d", field, version)
+		}
+		last = max(last, i)
+	}
+	if last < 0 {
+		return "", fmt.Errorf("no fields to parse")
+	}
+
+	parts := make([]string, last+1)
+	for i, field := range all[:last+1] {
+		if slices.Contains(fields, field) {
+			parts[i] = "(?<" + field + `>\S+)`
+		} else {
+			parts[i] = `\S+`
+		}
+	}
+	return "parse @message /^" + strings.Join(parts, " ") + "/", nil
+}
+
 // ValidateField checks if a field is valid for the given log version.
 func (s *VPCFlowLogsSchema) ValidateField(field string, version int) error {
 	validFields, ok := versionFields[version]
@@ -67,7 +103,7 @@ func (s *VPCFlowLogsSchema) ValidateField(field string, version int) error {
 	}
 
 	// Allow computed fields.
-	if field == "duration" || field == "*" {
+	if field == "*" || s.GetComputedFieldExpression(field, version) != "" {
 		return nil
 	}
 
@@ -87,6 +123,19 @@ func (s *VPCFlowLogsSchema) ValidateVersion(version int) error {
 	return nil
 }
 
+// ClosestSupportedVersion returns the highest supported version that is not
+// greater than version, or 0 if version is older than every supported version.
+// For example, version 4 maps to version 3.
+func (s *VPCFlowLogsSchema) ClosestSupportedVersion(version int) int {
+	closest := 0
+	for v := range versionFields {
+		if v <= version && v > closest {
+			closest = v
+		}
+	}
+	return closest
+}
+
 // GetDefaultVersion returns the default version for the schema.
 func (s *VPCFlowLogsSchema) GetDefaultVersion() int {
 	return DefaultVersion
@@ -118,3 +167,14 @@ func (s *VPCFlowLogsSchema) GetComputedFieldExpression(field string, _ int) stri
 		return ""
 	}
 }
+
+// ComputedFields returns the names of the computed fields, sorted.
+func (s *VPCFlowLogsSchema) ComputedFields() []string {
+	return []string{"duration"}
+}
+
+// Fields returns the fields of the given log version in log order, or nil if
+// the version is not supported.
+func (s *VPCFlowLogsSchema) Fields(version int) []string {
+	return slices.Clone(versionFields[version])
+}
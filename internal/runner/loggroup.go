@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MaxLogGroupNameLength is the maximum length CloudWatch Logs accepts for a log group name.
+const MaxLogGroupNameLength = 512
+
+// maxLogGroupARNLength leaves room for the "arn:aws:logs:<region>:<account>:log-group:" prefix.
+const maxLogGroupARNLength = MaxLogGroupNameLength + 128
+
+// MaxLogGroups is the maximum number of log groups a single Logs Insights query
+// can search.
+const MaxLogGroups = 50
+
+// ValidateLogGroupName rejects log group identifiers that CloudWatch would never accept,
+// so that obviously malformed input fails before any AWS call is made.
+//
+// Names are otherwise passed to StartQuery verbatim: characters such as '$', '/', '#'
+// and spaces are not escaped or rewritten.
+func ValidateLogGroupName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("log group name is empty")
+	}
+	if name != strings.TrimSpace(name) {
+		return fmt.Errorf("log group name %q has leading or trailing whitespace", name)
+	}
+
+	limit := MaxLogGroupNameLength
+	if strings.HasPrefix(name, "arn:") {
+		limit = maxLogGroupARNLength
+	}
+	if len(name) > limit {
+		return fmt.Errorf("log group name is %d characters long, maximum is %d", len(name), limit)
+	}
+
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("log group name %q contains a control character", name)
+		}
+	}
+	return nil
+}
+
+// ValidateLogGroupNames validates each log group of a multi-group query and
+// rejects an empty list, more than MaxLogGroups groups, and duplicates.
+func ValidateLogGroupNames(names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("log group name is empty")
+	}
+	if len(names) > MaxLogGroups {
+		return fmt.Errorf("%d log groups given, maximum is %d", len(names), MaxLogGroups)
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if err := ValidateLogGroupName(name); err != nil {
+			return err
+		}
+		if seen[name] {
+			return fmt.Errorf("log group %q is given more than once", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package runner
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+func TestValidateLogGroupName(t *testing.T) {
+	tests := []struct {
+		name    string
+		lg      string
+		wantErr bool
+	}{
+		{name: "plain name", lg: "/aws/vpc/flowlogs", wantErr: false},
+		{name: "special characters", lg: "/aws/vpc/flow-logs $Latest", wantErr: false},
+		{name: "hash and dots", lg: "/fli/flow-logs/vpc-123#v5.1", wantErr: false},
+		{name: "arn", lg: "arn:aws:logs:us-east-1:123456789012:log-group:/aws/vpc/flowlogs", wantErr: false},
+		{name: "empty", lg: "", wantErr: true},
+		{name: "whitespace only", lg: "   ", wantErr: true},
+		{name: "trailing whitespace", lg: "/aws/vpc/flowlogs ", wantErr: true},
+		{name: "newline", lg: "/aws/vpc\n/flowlogs", wantErr: true},
+		{name: "too long", lg: "/" + strings.Repeat("a", MaxLogGroupNameLength), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLogGroupName(tt.lg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLogGroupName(%q) error = %v, wantErr %v", tt.lg, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLogGroupNames(t *testing.T) {
+	tooMany := make([]string, MaxLogGroups+1)
+	for i := range tooMany {
+		tooMany[i] = "/aws/vpc/flowlogs-" + strconv.Itoa(i)
+	}
+
+	tests := []struct {
+		name    string
+		lgs     []string
+		wantErr bool
+	}{
+		{name: "single", lgs: []string{"/aws/vpc/flowlogs"}, wantErr: false},
+		{name: "several", lgs: []string{"/aws/vpc/prod", "/aws/vpc/staging"}, wantErr: false},
+		{name: "none", lgs: nil, wantErr: true},
+		{name: "one malformed", lgs: []string{"/aws/vpc/prod", "bad\tname"}, wantErr: true},
+		{name: "duplicate", lgs: []string{"/aws/vpc/prod", "/aws/vpc/prod"}, wantErr: true},
+		{name: "too many", lgs: tooMany, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLogGroupNames(tt.lgs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLogGroupNames(%q) error = %v, wantErr %v", tt.lgs, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunMultiSendsAllLogGroups(t *testing.T) {
+	logGroups := []string{"/aws/vpc/prod", "/aws/vpc/staging"}
+
+	var gotIdentifiers []string
+	mockClient := &mockCloudWatchLogsClient{
+		StartQueryFunc: func(_ context.Context, params *cloudwatchlogs.StartQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+			gotIdentifiers = params.LogGroupIdentifiers
+			return &cloudwatchlogs.StartQueryOutput{QueryId: stringPtr("query-123")}, nil
+		},
+		GetQueryResultsFunc: func(_ context.Context, _ *cloudwatchlogs.GetQueryResultsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+			return &cloudwatchlogs.GetQueryResultsOutput{Status: types.QueryStatusComplete}, nil
+		},
+	}
+
+	r := &Runner{Client: mockClient, PollInterval: time.Millisecond}
+	if _, err := r.RunMulti(context.Background(), logGroups, "stats count(*)", 0, 1); err != nil {
+		t.Fatalf("RunMulti() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(gotIdentifiers, logGroups) {
+		t.Errorf("StartQuery received log groups %q, want %q", gotIdentifiers, logGroups)
+	}
+}
+
+func TestRunPassesLogGroupUnmodified(t *testing.T) {
+	const logGroup = "/aws/vpc/flow-logs $Latest"
+
+	var gotIdentifiers []string
+	mockClient := &mockCloudWatchLogsClient{
+		StartQueryFunc: func(_ context.Context, params *cloudwatchlogs.StartQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+			gotIdentifiers = params.LogGroupIdentifiers
+			return &cloudwatchlogs.StartQueryOutput{QueryId: stringPtr("query-123")}, nil
+		},
+		GetQueryResultsFunc: func(_ context.Context, _ *cloudwatchlogs.GetQueryResultsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+			return &cloudwatchlogs.GetQueryResultsOutput{Status: types.QueryStatusComplete}, nil
+		},
+	}
+
+	r := &Runner{Client: mockClient, PollInterval: time.Millisecond}
+	if _, err := r.Run(context.Background(), logGroup, "stats count(*)", 0, 1); err != nil {
+		t.Fatalf("Run() unexpected error: %v", err)
+	}
+
+	if len(gotIdentifiers) != 1 || gotIdentifiers[0] != logGroup {
+		t.Errorf("StartQuery received log groups %q, want [%q]", gotIdentifiers, logGroup)
+	}
+}
+
+func TestRunRejectsMalformedLogGroup(t *testing.T) {
+	called := false
+	mockClient := &mockCloudWatchLogsClient{
+		StartQueryFunc: func(_ context.Context, _ *cloudwatchlogs.StartQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+			called = true
+			return &cloudwatchlogs.StartQueryOutput{QueryId: stringPtr("query-123")}, nil
+		},
+	}
+
+	r := &Runner{Client: mockClient, PollInterval: time.Millisecond}
+	if _, err := r.Run(context.Background(), "bad\tname", "stats count(*)", 0, 1); err == nil {
+		t.Fatal("Run() expected an error for a malformed log group")
+	}
+	if called {
+		t.Error("StartQuery should not be called for a malformed log group")
+	}
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// DefaultMaxStartAttempts is how many times StartQuery is tried while
+	// CloudWatch throttles it.
+	DefaultMaxStartAttempts = 4
+
+	// DefaultRetryBaseDelay is the delay before the first StartQuery retry.
+	DefaultRetryBaseDelay = time.Second
+)
+
+// throttlingErrorCodes are the API error codes CloudWatch Logs returns when too
+// many queries or requests are in flight. They clear up on their own.
+var throttlingErrorCodes = map[string]bool{
+	"ThrottlingException":      true,
+	"LimitExceededException":   true,
+	"TooManyRequestsException": true,
+}
+
+// isThrottlingError reports whether err is a CloudWatch throttling or limit error
+// worth retrying. Other errors, such as a malformed query, are not.
+func isThrottlingError(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && throttlingErrorCodes[apiErr.ErrorCode()]
+}
+
+// startQuery starts input, retrying throttling errors up to MaxStartAttempts
+// times with exponential backoff and jitter. Other errors are returned at once.
+func (r *Runner) startQuery(ctx context.Context, input *cloudwatchlogs.StartQueryInput) (*cloudwatchlogs.StartQueryOutput, error) {
+	attempts := r.MaxStartAttempts
+	if attempts <= 0 {
+		attempts = DefaultMaxStartAttempts
+	}
+	delay := r.RetryBaseDelay
+	if delay <= 0 {
+		delay = DefaultRetryBaseDelay
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := r.Client.StartQuery(ctx, input)
+		if err == nil {
+			return resp, nil
+		}
+		if !isThrottlingError(err) || attempt >= attempts {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (while retrying: %w)", ctx.Err(), err)
+		case <-time.After(jitter(delay)):
+		}
+		delay *= 2
+	}
+}
+
+// jitter returns a random duration between half of d and d, so that clients
+// throttled together do not retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
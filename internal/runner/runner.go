@@ -4,8 +4,10 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
@@ -28,6 +30,11 @@ type QueryStatistics struct {
 	BytesScanned   int64
 	RecordsScanned int64
 	RecordsMatched int64
+
+	// StartTime and EndTime are the window actually sent to StartQuery, in the
+	// same units the caller passed to Run (fli uses Unix milliseconds).
+	StartTime int64
+	EndTime   int64
 }
 
 // QueryResult contains the results and statistics of a query execution.
@@ -41,6 +48,32 @@ type QueryResult struct {
 type CloudWatchLogsClient interface {
 	StartQuery(ctx context.Context, params *cloudwatchlogs.StartQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error)
 	GetQueryResults(ctx context.Context, params *cloudwatchlogs.GetQueryResultsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error)
+	StopQuery(ctx context.Context, params *cloudwatchlogs.StopQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error)
+}
+
+// LogGroupField selects the StartQueryInput field the log group is sent in.
+type LogGroupField string
+
+const (
+	// LogGroupFieldIdentifier sends the log group in LogGroupIdentifiers, which
+	// accepts names and ARNs. It is the default.
+	LogGroupFieldIdentifier LogGroupField = "identifier"
+
+	// LogGroupFieldName sends the log group in LogGroupNames, which accepts names
+	// only but suits some cross-account setups.
+	LogGroupFieldName LogGroupField = "name"
+)
+
+// ParseLogGroupField parses a --log-group-field value; an empty value selects
+// LogGroupFieldIdentifier.
+func ParseLogGroupField(s string) (LogGroupField, error) {
+	switch field := LogGroupField(s); field {
+	case "":
+		return LogGroupFieldIdentifier, nil
+	case LogGroupFieldIdentifier, LogGroupFieldName:
+		return field, nil
+	}
+	return "", fmt.Errorf("invalid log group field %q: must be %q or %q", s, LogGroupFieldName, LogGroupFieldIdentifier)
 }
 
 // Runner handles the execution of CloudWatch Logs queries.
@@ -50,14 +83,68 @@ type Runner struct {
 
 	// PollInterval is the time to wait between query status checks (defaults to 500ms if not set)
 	PollInterval time.Duration
+
+	// MaxPollInterval caps the exponential back-off between status checks
+	// (defaults to config.DefaultTimeouts().MaxPoll if not set). It is never
+	// lower than PollInterval.
+	MaxPollInterval time.Duration
+
+	// FixedInterval keeps polling every PollInterval instead of backing off
+	// exponentially, which suits short queries
+	FixedInterval bool
+
+	// LogGroupField selects the StartQueryInput field the log group is sent in
+	// (defaults to LogGroupFieldIdentifier if not set)
+	LogGroupField LogGroupField
+
+	// MaxStartAttempts is how many times StartQuery is tried while CloudWatch
+	// throttles it (defaults to DefaultMaxStartAttempts if not set)
+	MaxStartAttempts int
+
+	// RetryBaseDelay is the delay before the first StartQuery retry; it doubles on
+	// each attempt (defaults to DefaultRetryBaseDelay if not set)
+	RetryBaseDelay time.Duration
+}
+
+// DefaultPollInterval is the initial time between query status checks.
+const DefaultPollInterval = 500 * time.Millisecond
+
+// Option configures a Runner created by New.
+type Option func(*Runner)
+
+// WithPollInterval sets the initial time between query status checks.
+func WithPollInterval(d time.Duration) Option {
+	return func(r *Runner) {
+		r.PollInterval = d
+	}
+}
+
+// WithMaxPollInterval sets the cap on the back-off between query status checks.
+func WithMaxPollInterval(d time.Duration) Option {
+	return func(r *Runner) {
+		r.MaxPollInterval = d
+	}
+}
+
+// WithStartRetries sets how many times StartQuery is tried while throttled and
+// the delay before the first retry.
+func WithStartRetries(attempts int, baseDelay time.Duration) Option {
+	return func(r *Runner) {
+		r.MaxStartAttempts = attempts
+		r.RetryBaseDelay = baseDelay
+	}
 }
 
 // New creates a new Runner instance with the given CloudWatch Logs client.
-func New(client CloudWatchLogsClient) *Runner {
-	return &Runner{
+func New(client CloudWatchLogsClient, opts ...Option) *Runner {
+	r := &Runner{
 		Client:       client,
-		PollInterval: 500 * time.Millisecond,
+		PollInterval: DefaultPollInterval,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 // Run executes a CloudWatch Logs query and returns the results
@@ -72,13 +159,38 @@ func New(client CloudWatchLogsClient) *Runner {
 // - A QueryResult containing results and statistics
 // - Any error that occurred during query execution.
 func (r *Runner) Run(ctx context.Context, lg string, q string, start, end int64) (QueryResult, error) {
-	// Start the query
-	startResp, err := r.Client.StartQuery(ctx, &cloudwatchlogs.StartQueryInput{
-		LogGroupIdentifiers: []string{lg},
-		QueryString:         &q,
-		StartTime:           &start,
-		EndTime:             &end,
-	})
+	return r.RunMulti(ctx, []string{lg}, q, start, end)
+}
+
+// RunMulti executes one CloudWatch Logs query across several log groups, up to
+// MaxLogGroups, so that flows from several VPCs can be correlated in a single
+// result set. It otherwise behaves like Run.
+func (r *Runner) RunMulti(ctx context.Context, lgs []string, q string, start, end int64) (QueryResult, error) {
+	if err := ValidateLogGroupNames(lgs); err != nil {
+		return QueryResult{}, fmt.Errorf("invalid log group: %w", err)
+	}
+
+	input := &cloudwatchlogs.StartQueryInput{
+		QueryString: &q,
+		StartTime:   &start,
+		EndTime:     &end,
+	}
+	switch r.LogGroupField {
+	case "", LogGroupFieldIdentifier:
+		input.LogGroupIdentifiers = lgs
+	case LogGroupFieldName:
+		for _, lg := range lgs {
+			if strings.HasPrefix(lg, "arn:") {
+				return QueryResult{}, fmt.Errorf("invalid log group: %q is an ARN, which the name field does not accept", lg)
+			}
+		}
+		input.LogGroupNames = lgs
+	default:
+		return QueryResult{}, fmt.Errorf("unknown log group field %q", r.LogGroupField)
+	}
+
+	// Start the query, retrying while CloudWatch throttles it
+	startResp, err := r.startQuery(ctx, input)
 	if err != nil {
 		return QueryResult{}, fmt.Errorf("failed to start query: %w", err)
 	}
@@ -86,15 +198,18 @@ func (r *Runner) Run(ctx context.Context, lg string, q string, start, end int64)
 	// Wait for query completion
 	queryID := startResp.QueryId
 	var results [][]Field
-	var stats QueryStatistics
+	stats := QueryStatistics{StartTime: start, EndTime: end}
 
 	initialPollInterval := r.PollInterval
 	if initialPollInterval == 0 {
-		initialPollInterval = 500 * time.Millisecond
+		initialPollInterval = DefaultPollInterval
 	}
 	pollInterval := initialPollInterval
-	timeouts := config.DefaultTimeouts()
-	maxPollInterval := timeouts.MaxPoll
+	maxPollInterval := r.MaxPollInterval
+	if maxPollInterval == 0 {
+		maxPollInterval = config.DefaultTimeouts().MaxPoll
+	}
+	maxPollInterval = max(maxPollInterval, initialPollInterval)
 
 	// Track query execution time
 	startTime := time.Now()
@@ -105,7 +220,7 @@ func (r *Runner) Run(ctx context.Context, lg string, q string, start, end int64)
 		// Check if context is done
 		select {
 		case <-ctx.Done():
-			return QueryResult{}, fmt.Errorf("query cancelled by context: %w", ctx.Err())
+			return QueryResult{}, r.cancelled(ctx, queryID)
 		default:
 			// Continue with query
 		}
@@ -121,6 +236,9 @@ func (r *Runner) Run(ctx context.Context, lg string, q string, start, end int64)
 			QueryId: queryID,
 		})
 		if err != nil {
+			if ctx.Err() != nil {
+				return QueryResult{}, r.cancelled(ctx, queryID)
+			}
 			return QueryResult{}, fmt.Errorf("failed to get query results: %w", err)
 		}
 
@@ -171,8 +289,11 @@ func (r *Runner) Run(ctx context.Context, lg string, q string, start, end int64)
 			// Wait before checking again, with exponential back-off
 			select {
 			case <-ctx.Done():
-				return QueryResult{}, fmt.Errorf("query cancelled by context: %w", ctx.Err())
+				return QueryResult{}, r.cancelled(ctx, queryID)
 			case <-time.After(pollInterval):
+				if r.FixedInterval {
+					continue
+				}
 				// Exponential back-off, capped at maxPollInterval
 				pollInterval *= 2
 				if pollInterval > maxPollInterval {
@@ -186,3 +307,18 @@ func (r *Runner) Run(ctx context.Context, lg string, q string, start, end int64)
 		}
 	}
 }
+
+// cancelled stops the query server-side, so it does not keep running and scanning
+// after the caller gave up, and returns the cancellation error. The stop request
+// gets its own deadline since ctx is already done; if it fails, its error is
+// joined to the cancellation error.
+func (r *Runner) cancelled(ctx context.Context, queryID *string) error {
+	err := fmt.Errorf("query cancelled by context: %w", ctx.Err())
+
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), config.DefaultTimeouts().StopQuery)
+	defer cancel()
+	if _, stopErr := r.Client.StopQuery(stopCtx, &cloudwatchlogs.StopQueryInput{QueryId: queryID}); stopErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to stop query: %w", stopErr))
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
@@ -10,12 +11,14 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
 )
 
 // mockCloudWatchLogsClient implements the CloudWatch Logs client interface for testing
 type mockCloudWatchLogsClient struct {
 	StartQueryFunc      func(ctx context.Context, params *cloudwatchlogs.StartQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error)
 	GetQueryResultsFunc func(ctx context.Context, params *cloudwatchlogs.GetQueryResultsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error)
+	StopQueryFunc       func(ctx context.Context, params *cloudwatchlogs.StopQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error)
 }
 
 func (m *mockCloudWatchLogsClient) StartQuery(ctx context.Context, params *cloudwatchlogs.StartQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
@@ -26,6 +29,13 @@ func (m *mockCloudWatchLogsClient) GetQueryResults(ctx context.Context, params *
 	return m.GetQueryResultsFunc(ctx, params, optFns...)
 }
 
+func (m *mockCloudWatchLogsClient) StopQuery(ctx context.Context, params *cloudwatchlogs.StopQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error) {
+	if m.StopQueryFunc == nil {
+		return &cloudwatchlogs.StopQueryOutput{}, nil
+	}
+	return m.StopQueryFunc(ctx, params, optFns...)
+}
+
 func TestRunnerRun(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -84,6 +94,8 @@ func TestRunnerRun(t *testing.T) {
 					BytesScanned:   1024,
 					RecordsMatched: 59,
 					RecordsScanned: 100,
+					StartTime:      1609459200,
+					EndTime:        1609545600,
 				},
 			},
 			wantErr: false,
@@ -183,7 +195,263 @@ func TestRunnerRun(t *testing.T) {
 	}
 }
 
+func TestRunReportsQueriedWindow(t *testing.T) {
+	const start, end = int64(1700000000000), int64(1700003600000)
+	var sentStart, sentEnd int64
+
+	mockClient := &mockCloudWatchLogsClient{
+		StartQueryFunc: func(_ context.Context, params *cloudwatchlogs.StartQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+			sentStart, sentEnd = *params.StartTime, *params.EndTime
+			return &cloudwatchlogs.StartQueryOutput{QueryId: stringPtr("query-123")}, nil
+		},
+		GetQueryResultsFunc: func(_ context.Context, _ *cloudwatchlogs.GetQueryResultsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+			return &cloudwatchlogs.GetQueryResultsOutput{Status: types.QueryStatusComplete}, nil
+		},
+	}
+
+	r := &Runner{Client: mockClient, PollInterval: time.Millisecond}
+	got, err := r.Run(context.Background(), "/aws/vpc/flowlogs", "fields @timestamp", start, end)
+	if err != nil {
+		t.Fatalf("Runner.Run() error = %v", err)
+	}
+
+	if got.Statistics.StartTime != sentStart || got.Statistics.EndTime != sentEnd {
+		t.Errorf("reported window [%d, %d], StartQuery received [%d, %d]",
+			got.Statistics.StartTime, got.Statistics.EndTime, sentStart, sentEnd)
+	}
+	if sentStart != start || sentEnd != end {
+		t.Errorf("StartQuery received [%d, %d], want [%d, %d]", sentStart, sentEnd, start, end)
+	}
+}
+
 // Helper functions for creating pointers to primitives
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestRunPollInterval(t *testing.T) {
+	const interval = 20 * time.Millisecond
+
+	tests := []struct {
+		name   string
+		fixed  bool
+		capped bool
+	}{
+		{name: "backoff", fixed: false},
+		{name: "fixed", fixed: true},
+		{name: "capped backoff", capped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var polls []time.Time
+			mockClient := &mockCloudWatchLogsClient{
+				StartQueryFunc: func(_ context.Context, _ *cloudwatchlogs.StartQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+					return &cloudwatchlogs.StartQueryOutput{QueryId: stringPtr("query-123")}, nil
+				},
+				GetQueryResultsFunc: func(_ context.Context, _ *cloudwatchlogs.GetQueryResultsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+					polls = append(polls, time.Now())
+					if len(polls) < 5 {
+						return &cloudwatchlogs.GetQueryResultsOutput{Status: types.QueryStatusRunning}, nil
+					}
+					return &cloudwatchlogs.GetQueryResultsOutput{Status: types.QueryStatusComplete}, nil
+				},
+			}
+
+			r := New(mockClient, WithPollInterval(interval))
+			r.FixedInterval = tt.fixed
+			if tt.capped {
+				r.MaxPollInterval = 2 * interval
+			}
+			if _, err := r.Run(context.Background(), "/aws/vpc/flowlogs", "fields @timestamp", 0, 1); err != nil {
+				t.Fatalf("Runner.Run() error = %v", err)
+			}
+
+			// Backing off, the last wait is 8x the interval; fixed, it stays at the
+			// interval, and capped, at the cap
+			last := polls[len(polls)-1].Sub(polls[len(polls)-2])
+			if tt.fixed && last >= 4*interval {
+				t.Errorf("last poll interval = %v, want about %v in fixed mode", last, interval)
+			}
+			if tt.capped && last >= 4*interval {
+				t.Errorf("last poll interval = %v, want it capped at %v", last, 2*interval)
+			}
+			if !tt.fixed && !tt.capped && last < 4*interval {
+				t.Errorf("last poll interval = %v, want it to grow past %v with backoff", last, 4*interval)
+			}
+		})
+	}
+}
+
+func TestRunLogGroupField(t *testing.T) {
+	const lg = "/aws/vpc/flowlogs"
+	tests := []struct {
+		field           LogGroupField
+		wantIdentifiers []string
+		wantNames       []string
+	}{
+		{field: "", wantIdentifiers: []string{lg}},
+		{field: LogGroupFieldIdentifier, wantIdentifiers: []string{lg}},
+		{field: LogGroupFieldName, wantNames: []string{lg}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.field), func(t *testing.T) {
+			var sent *cloudwatchlogs.StartQueryInput
+			mockClient := &mockCloudWatchLogsClient{
+				StartQueryFunc: func(_ context.Context, params *cloudwatchlogs.StartQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+					sent = params
+					return &cloudwatchlogs.StartQueryOutput{QueryId: stringPtr("query-123")}, nil
+				},
+				GetQueryResultsFunc: func(_ context.Context, _ *cloudwatchlogs.GetQueryResultsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+					return &cloudwatchlogs.GetQueryResultsOutput{Status: types.QueryStatusComplete}, nil
+				},
+			}
+
+			r := &Runner{Client: mockClient, PollInterval: time.Millisecond, LogGroupField: tt.field}
+			if _, err := r.Run(context.Background(), lg, "fields @timestamp", 0, 1); err != nil {
+				t.Fatalf("Runner.Run() error = %v", err)
+			}
+			if !reflect.DeepEqual(sent.LogGroupIdentifiers, tt.wantIdentifiers) || !reflect.DeepEqual(sent.LogGroupNames, tt.wantNames) {
+				t.Errorf("StartQuery received identifiers %v and names %v, want %v and %v",
+					sent.LogGroupIdentifiers, sent.LogGroupNames, tt.wantIdentifiers, tt.wantNames)
+			}
+		})
+	}
+
+	r := &Runner{Client: &mockCloudWatchLogsClient{}, LogGroupField: LogGroupFieldName}
+	if _, err := r.Run(context.Background(), "arn:aws:logs:us-east-1:123456789012:log-group:flows", "fields @timestamp", 0, 1); err == nil {
+		t.Error("Runner.Run() with an ARN in the name field: error = nil, want an error")
+	}
+}
+
+func TestParseLogGroupField(t *testing.T) {
+	for input, want := range map[string]LogGroupField{"": LogGroupFieldIdentifier, "identifier": LogGroupFieldIdentifier, "name": LogGroupFieldName} {
+		if got, err := ParseLogGroupField(input); err != nil || got != want {
+			t.Errorf("ParseLogGroupField(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseLogGroupField("arn"); err == nil {
+		t.Error("ParseLogGroupField(\"arn\") error = nil, want an error")
+	}
+}
+
+func TestRunStopsQueryOnCancellation(t *testing.T) {
+	tests := []struct {
+		name    string
+		stopErr error
+	}{
+		{name: "stop succeeds"},
+		{name: "stop fails", stopErr: fmt.Errorf("throttled")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var stopped []string
+			mockClient := &mockCloudWatchLogsClient{
+				StartQueryFunc: func(_ context.Context, _ *cloudwatchlogs.StartQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+					return &cloudwatchlogs.StartQueryOutput{QueryId: stringPtr("query-123")}, nil
+				},
+				GetQueryResultsFunc: func(_ context.Context, _ *cloudwatchlogs.GetQueryResultsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+					// The caller gives up while the query is still running
+					cancel()
+					return &cloudwatchlogs.GetQueryResultsOutput{Status: types.QueryStatusRunning}, nil
+				},
+				StopQueryFunc: func(stopCtx context.Context, params *cloudwatchlogs.StopQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error) {
+					if stopCtx.Err() != nil {
+						t.Error("StopQuery was called with a cancelled context")
+					}
+					stopped = append(stopped, *params.QueryId)
+					return &cloudwatchlogs.StopQueryOutput{}, tt.stopErr
+				},
+			}
+
+			r := &Runner{Client: mockClient, PollInterval: time.Hour}
+			_, err := r.Run(ctx, "/aws/vpc/flowlogs", "fields @timestamp", 0, 1)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("Runner.Run() error = %v, want context.Canceled", err)
+			}
+			if !reflect.DeepEqual(stopped, []string{"query-123"}) {
+				t.Errorf("StopQuery called for %v, want [query-123]", stopped)
+			}
+			if tt.stopErr != nil && !errors.Is(err, tt.stopErr) {
+				t.Errorf("Runner.Run() error = %v, want it to include the StopQuery error", err)
+			}
+		})
+	}
+}
+
+func TestRunRetriesThrottledStart(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   bool
+		wantCalls int
+	}{
+		{
+			name:      "throttled twice then started",
+			errs:      []error{&smithy.GenericAPIError{Code: "ThrottlingException"}, &types.LimitExceededException{}},
+			wantCalls: 3,
+		},
+		{
+			name:      "malformed query is not retried",
+			errs:      []error{&types.MalformedQueryException{}},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name:      "gives up after the last attempt",
+			errs:      []error{&smithy.GenericAPIError{Code: "ThrottlingException"}, &smithy.GenericAPIError{Code: "ThrottlingException"}, &smithy.GenericAPIError{Code: "ThrottlingException"}},
+			wantErr:   true,
+			wantCalls: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			mockClient := &mockCloudWatchLogsClient{
+				StartQueryFunc: func(_ context.Context, _ *cloudwatchlogs.StartQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error) {
+					calls++
+					if calls <= len(tt.errs) {
+						return nil, tt.errs[calls-1]
+					}
+					return &cloudwatchlogs.StartQueryOutput{QueryId: stringPtr("query-123")}, nil
+				},
+				GetQueryResultsFunc: func(_ context.Context, _ *cloudwatchlogs.GetQueryResultsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+					return &cloudwatchlogs.GetQueryResultsOutput{
+						Status:  types.QueryStatusComplete,
+						Results: [][]types.ResultField{{{Field: stringPtr("srcaddr"), Value: stringPtr("10.0.0.1")}}},
+					}, nil
+				},
+			}
+
+			r := New(mockClient, WithStartRetries(3, time.Millisecond))
+			result, err := r.Run(context.Background(), "/aws/vpc/flowlogs", "fields @timestamp", 0, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Runner.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("StartQuery called %d times, want %d", calls, tt.wantCalls)
+			}
+			if !tt.wantErr && (len(result.Results) != 1 || result.Results[0][0].Value != "10.0.0.1") {
+				t.Errorf("Runner.Run() results = %v, want the query's row", result.Results)
+			}
+		})
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	r := New(&mockCloudWatchLogsClient{})
+	if r.PollInterval != DefaultPollInterval || r.MaxPollInterval != 0 {
+		t.Errorf("New() = poll %v, max %v; want the defaults", r.PollInterval, r.MaxPollInterval)
+	}
+
+	r = New(&mockCloudWatchLogsClient{}, WithPollInterval(2*time.Second), WithMaxPollInterval(time.Minute))
+	if r.PollInterval != 2*time.Second || r.MaxPollInterval != time.Minute {
+		t.Errorf("New() = poll %v, max %v; want 2s, 1m", r.PollInterval, r.MaxPollInterval)
+	}
+}